@@ -0,0 +1,63 @@
+package swap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// Age encrypted values ------------------------------------------------------------------------------------------------
+
+// agePrefix marks the age encrypted values, followed by the base64 of the age file,
+// eg.: `password: "!age:YWdlLWVuY3J5cHRpb24ub3JnL3Yx..."` (quoted in YAML).
+const agePrefix = "!age:"
+
+// errNoAgeIdentity is returned decrypting values without identities.
+var errNoAgeIdentity = errors.New("age: no identity to decrypt the value")
+
+// WithAgeIdentities set the age identities decrypting, in Parse,
+// the `!age:` prefixed values and the `age` flagged fields values,
+// so that secrets can be committed alongside the plain config.
+// Identities can be loaded with `age.ParseIdentities`.
+func (s *Builder) WithAgeIdentities(identities ...age.Identity) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ageIdentities = identities
+	return s
+}
+
+// decryptAge returns the plaintext of the armored age file
+// or of the (optionally `!age:` prefixed) base64 encoded one.
+func (p *parser) decryptAge(value string) (string, error) {
+	if len(p.ageIdentities) == 0 {
+		return "", errNoAgeIdentity
+	}
+
+	var ciphertext io.Reader
+	value = strings.TrimSpace(strings.TrimPrefix(value, agePrefix))
+	if strings.HasPrefix(value, armor.Header) {
+		ciphertext = armor.NewReader(strings.NewReader(value))
+	} else {
+		data, err := decodeBase64(value)
+		if err != nil {
+			return "", fmt.Errorf("age: %s", err.Error())
+		}
+		ciphertext = bytes.NewReader(data)
+	}
+
+	reader, err := age.Decrypt(ciphertext, p.ageIdentities...)
+	if err != nil {
+		return "", err
+	}
+	plain, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,98 @@
+package swap
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Archive FileSystem --------------------------------------------------------------------------------------------------
+
+// NewFileSystemArchive returns the in-memory FileSystem holding
+// the files of the zip, tar.gz or tar archive at path, so that a single
+// artifact can carry all the environments configs.
+// Files are served by their path in the archive (eg.: `config/tool.yaml`).
+func NewFileSystemArchive(path string) (FileSystem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := readArchive(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return NewFileSystemMemory(files), nil
+}
+
+// readArchive returns the regular files of the archive, by path,
+// the format is detected from the data.
+func readArchive(data []byte) (map[string][]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return readZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return readTar(gz)
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		return readTar(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unknown archive format, must be zip, tar.gz or tar")
+	}
+}
+
+func readZip(data []byte) (map[string][]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name, err.Error())
+		}
+		files[file.Name] = content
+	}
+	return files, nil
+}
+
+func readTar(r io.Reader) (map[string][]byte, error) {
+	reader := tar.NewReader(r)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", header.Name, err.Error())
+		}
+		files[header.Name] = content
+	}
+}
@@ -1,14 +1,23 @@
 package swap
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"log/slog"
 	"math"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"filippo.io/age"
 	"github.com/oblq/swap/internal/logger"
 )
 
@@ -19,11 +28,41 @@ const (
 
 	// to skip a struct field
 	sffBuilderSkip = "-"
+
+	// fields that must be built before this one
+	// eg.: `swap:"deps=DB|Cache"`
+	sffBuilderDeps = "deps"
+
+	// labels passed to the MetricsHook
+	// eg.: `swap:"metrics=component:db|team:core"`
+	sffBuilderMetrics = "metrics"
+
+	// optional fields, Build does not fail if they can't be built
+	// eg.: `swap:"degrade"`
+	sffBuilderDegrade = "degrade"
+
+	// fields whose config files may be missing,
+	// they are left to their zero value then
+	// eg.: `swap:"Tool,optional"`
+	sffBuilderOptional = "optional"
+
+	// endpoints which must be reachable before building the field
+	// eg.: `swap:"waitfor=tcp://db:5432|http://api/health,timeout=30s"`
+	sffBuilderWaitFor        = "waitfor"
+	sffBuilderWaitForTimeout = "timeout"
+
+	// swap.Lazy fields configured on the first Get call
+	// eg.: `swap:"lazy"`
+	sffBuilderLazy = "lazy"
 )
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-// FileSearchCaseSensitive determine config files search mode, false by default.
+// FileSearchCaseSensitive determine the config files search mode
+// of Parse, ParseByEnv and the new Builders, false by default.
+//
+// Deprecated: use the WithCaseSensitiveFileSearch option,
+// the Builder does not read it after its creation.
 var FileSearchCaseSensitive bool
 
 // SetColoredLogs enable / disable colors in the stdOut.
@@ -39,6 +78,13 @@ type Configurable interface {
 	Configure(configFiles ...string) error
 }
 
+// ConfigurableCtx interface is Configurable with the Build context,
+// long-running configurations (eg.: DB ping, remote fetch) can be cancelled
+// and carry deadlines and trace context.
+type ConfigurableCtx interface {
+	Configure(ctx context.Context, configFiles ...string) error
+}
+
 // Factory interface (factory) -----------------------------------------------------------------------------------------
 
 // FactoryFunc is the factory method type.
@@ -51,12 +97,39 @@ type Factory interface {
 
 // Implementation ------------------------------------------------------------------------------------------------------
 
+// DebugFormatJSON emits the debug tree as JSON lines, one per field, see ReportField.
+const DebugFormatJSON = "json"
+
+// Verbosity is the amount of debug output printed by the Builder.
+type Verbosity int
+
+const (
+	// VerbositySilent prints nothing, override tokens notices included.
+	VerbositySilent Verbosity = iota
+
+	// VerbosityErrors prints the failed and degraded fields only.
+	VerbosityErrors
+
+	// VerbositySummary prints the environment info, the failed and degraded fields
+	// and a summary line with the number of fields by state.
+	VerbositySummary
+
+	// VerbosityFull prints the environment info and the debug tree, the default.
+	VerbosityFull
+)
+
 type debugOptions struct {
 	// Enabled true will print the loaded objects.
 	Enabled bool
+
+	// Verbosity is VerbosityFull by default.
+	Verbosity Verbosity
 	//Levels         int
 	HideUnhandled bool
 	HideSkipped   bool
+
+	// Format is the debug output format, the ANSI tree if empty or DebugFormatJSON.
+	Format string
 }
 
 // Builder recursively build/configure struct fields
@@ -65,28 +138,156 @@ type debugOptions struct {
 type Builder struct {
 	typeFactories map[reflect.Type]FactoryFunc
 
+	typeValidators map[reflect.Type]ValidatorFunc
+
 	configPath string
 
+	fs FileSystem
+
+	logger Logger
+
+	tagKey string
+
+	// configTagKey is the config parser tag key.
+	configTagKey string
+
+	// caseSensitive determine the config files search mode.
+	caseSensitive bool
+
+	// recursive enable the config files search in sub-directories.
+	recursive bool
+
+	// strict make unknown config keys an error in Parse.
+	strict bool
+
+	// lazy defers the configuration of all the swap.Lazy fields to their first Get call.
+	lazy bool
+
+	// legacyTOML decodes the TOML files with the decoder of the former versions.
+	legacyTOML bool
+
+	// forceTemplates run the template pass on the config files without placeholders too.
+	forceTemplates bool
+
+	// dotEnvFiles are the dotenv files, dotEnv their variables loaded by the last Build.
+	dotEnvFiles []string
+	dotEnv      map[string]string
+
+	// exportedDotEnvFiles are the dotenv files loaded into the process environment.
+	exportedDotEnvFiles []string
+
+	// envPrefix maps the config fields to the <envPrefix><FIELD_PATH> env vars in Parse.
+	envPrefix string
+
+	// pathOverridePrefix is the prefix of the env vars overriding the toolbox fields by path.
+	pathOverridePrefix string
+
+	// fieldOverrides are the path overrides of the fields being configured,
+	// by their config files, see bindPathOverrides.
+	fieldOverrides      map[string]boundOverrides
+	fieldOverridesMutex sync.Mutex
+
+	// ageIdentities decrypt the age encrypted config values in Parse.
+	ageIdentities []age.Identity
+
+	// encryptionKeyEnv is the env var holding the AES-GCM key decrypting the `enc:` values in Parse.
+	encryptionKeyEnv string
+
+	// boundFlags are the command-line flags of the config fields, by field path.
+	boundFlags      map[string]*boundFlag
+	boundFlagsMutex sync.Mutex
+
+	metricsHook MetricsHook
+
+	// overrideKey verify the override token,
+	// override holds the claims of the last applied one,
+	// overrideRestore restores the env vars and the environment it changed.
+	overrideKey     ed25519.PublicKey
+	override        *OverrideClaims
+	overrideRestore func()
+
 	mutex sync.Mutex
 
+	// slogger, if not nil, replaces the logger, see WithSlog.
+	slogger *slog.Logger
+
+	// toolBox is the toolbox of the last Build.
+	toolBox interface{}
+
+	// ctx is the context of the running BuildContext.
+	ctx context.Context
+
 	EnvHandler *EnvironmentHandler
 
+	// ProfileHandler holds the config files dimensions after the environment one (eg.: region),
+	// there are none by default.
+	ProfileHandler *ProfileHandler
+
 	DebugOptions debugOptions
+
+	// built tools, in build order, to be shut down.
+	built      []builtTool
+	builtMutex sync.Mutex
+
+	// concurrency is the max number of fields configured at the same time.
+	concurrency int
+	semaphore   chan struct{}
+
+	// loadedFiles are the hashes of the config files found during the last Build.
+	loadedFiles      map[string]string
+	loadedFilesMutex sync.Mutex
+
+	// report are the outcomes of the fields built during the last Build.
+	report      []ReportField
+	reportMutex sync.Mutex
+
+	// fieldFiles are the config files of the fields built during the last Build, by field path.
+	fieldFiles      map[string][]string
+	fieldFilesMutex sync.Mutex
+
+	// unavailable degraded fields of the last Build, by path.
+	unavailable      map[string]error
+	unavailableMutex sync.Mutex
+
+	// timeline of the last Build.
+	timeline      []TimelineSpan
+	timelineMutex sync.Mutex
 }
 
-// NewBuilder return a builder,
-// a custom EnvHandler can be provided later.
-func NewBuilder(configsPath string) *Builder {
-	return &Builder{
-		typeFactories: make(map[reflect.Type]FactoryFunc),
-		configPath:    configsPath,
-		EnvHandler:    NewEnvironmentHandler(DefaultEnvs.Slice()),
+// NewBuilder return a builder for the given config path
+// configured with the optional functional options,
+// a custom EnvHandler can also be provided later.
+func NewBuilder(configsPath string, opts ...Option) *Builder {
+	s := &Builder{
+		typeFactories:      make(map[reflect.Type]FactoryFunc),
+		configPath:         configsPath,
+		fs:                 NewFileSystemLocal(),
+		logger:             defaultLogger,
+		tagKey:             sftBuilderKey,
+		configTagKey:       sftConfigKey,
+		caseSensitive:      FileSearchCaseSensitive,
+		pathOverridePrefix: DefaultPathOverridePrefix,
 		DebugOptions: debugOptions{
-			true,
-			true,
-			true,
+			Enabled:       true,
+			Verbosity:     VerbosityFull,
+			HideUnhandled: true,
+			HideSkipped:   true,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.EnvHandler == nil {
+		s.EnvHandler = NewEnvironmentHandler(DefaultEnvs.Slice())
+	}
+
+	if s.ProfileHandler == nil {
+		s.ProfileHandler = NewProfileHandler()
+	}
+
+	return s
 }
 
 // WithCustomEnvHandler return the same instance of the Builder
@@ -99,6 +300,69 @@ func (s *Builder) WithCustomEnvHandler(eh *EnvironmentHandler) *Builder {
 	return s
 }
 
+// WithTagKey return the same instance of the Builder
+// but with a custom struct field tag key, `swap` by default.
+// The config parser tag key used by Builder.Parse will be `<key>cp`
+// (eg.: `myapp` and `myappcp`), so that libraries embedding swap
+// can use their own tag namespace.
+func (s *Builder) WithTagKey(key string) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tagKey = key
+	s.configTagKey = key + "cp"
+	return s
+}
+
+// WithEnvPrefix return the same instance of the Builder but mapping every
+// config field parsed by Builder.Parse to the env var named after its path,
+// without `env` flags (eg.: `APP_PG_PASSWORD` for `PG.Password` with the `APP` prefix),
+// the env vars override the config files values.
+func (s *Builder) WithEnvPrefix(prefix string) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.envPrefix = envPrefixName(prefix)
+	return s
+}
+
+// FileSystem returns the FileSystem where the config files are searched and read,
+// eg.: for the tools reading their own files.
+func (s *Builder) FileSystem() FileSystem {
+	return s.fs
+}
+
+// Parse strictly parse the specified config files into the config interface,
+// like the package level Parse func but using the builder FileSystem,
+// config parser tag key and registered validators.
+func (s *Builder) Parse(config interface{}, files ...string) (err error) {
+	if err = exportDotEnv(s.fs, s.exportedDotEnvFiles, s.EnvHandler.Current); err != nil {
+		return err
+	}
+	p := s.parser()
+	if p.dotEnv, err = s.loadDotEnv(); err != nil {
+		return err
+	}
+	p.cliFlags = s.setFlags()
+	if t := reflect.TypeOf(config); t != nil && t.Kind() == reflect.Ptr {
+		p.pathOverrides = s.boundPathOverrides(t.Elem(), files)
+	}
+	start := time.Now()
+	err = p.parseByEnv(config, nil, files...)
+	s.observeParse(files, start, err)
+	if err != nil {
+		return err
+	}
+	return s.validate("", reflect.ValueOf(config))
+}
+
+// parser returns a config parser with the builder options.
+func (s *Builder) parser() *parser {
+	return &parser{fs: s.fs, tagKey: s.configTagKey, caseSensitive: s.caseSensitive, recursive: s.recursive, strict: s.strict, dotEnv: s.dotEnv,
+		envPrefix: s.envPrefix, ageIdentities: s.ageIdentities, encryptionKeyEnv: s.encryptionKeyEnv, filesPath: s.configPath,
+		legacyTOML: s.legacyTOML, forceTemplates: s.forceTemplates, profiles: s.ProfileHandler.Current()}
+}
+
 // RegisterType register a configurator func for a specific type and
 // return the builder itself.
 func (s *Builder) RegisterType(t reflect.Type, factory FactoryFunc) *Builder {
@@ -109,97 +373,251 @@ func (s *Builder) RegisterType(t reflect.Type, factory FactoryFunc) *Builder {
 	return s
 }
 
+// Concurrency set the maximum number of fields configured at the same time
+// and return the builder itself, 1 (sequential build) by default.
+// Independent fields (see the `deps` tag) are built in parallel,
+// their `Configure` and `New` methods must be safe for concurrent use then.
+func (s *Builder) Concurrency(n int) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.concurrency = n
+	return s
+}
+
+// Strict make unknown keys in the config files an error in Parse
+// and return the builder itself, so typos fail fast.
+func (s *Builder) Strict(enabled bool) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.strict = enabled
+	return s
+}
+
+// SetOutput set the writer of the environment info and the debug tree (eg.: a buffer or a file)
+// and return the builder itself, the stdOut is used by default, see also WithLogger.
+func (s *Builder) SetOutput(w io.Writer) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.logger = log.New(w, "", 0)
+	return s
+}
+
 // Build initialize and (eventually) configure the provided struct pointer
 // looking for the config files in the provided configPath.
 func (s *Builder) Build(toolBox interface{}) (err error) {
+	return s.BuildContext(context.Background(), toolBox)
+}
+
+// BuildContext is Build with a context, passed to the `ConfigurableCtx` tools,
+// the fields are not built anymore once it is done and its error is returned.
+func (s *Builder) BuildContext(ctx context.Context, toolBox interface{}) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	built, err := s.buildToolBox(ctx, toolBox)
+	if s.DebugOptions.Enabled && built != nil {
+		s.debug(built.name, built.logs)
+	}
+	return err
+}
+
+// MustBuild is Build which panics on errors,
+// the panic message starts with the debug tree, even if disabled.
+func (s *Builder) MustBuild(toolBox interface{}) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	built, err := s.buildToolBox(context.Background(), toolBox)
+	if err != nil {
+		if built != nil {
+			panic(s.debugTree(built.name, built.logs) + err.Error())
+		}
+		panic(err)
+	}
+	if s.DebugOptions.Enabled {
+		s.debug(built.name, built.logs)
+	}
+}
+
+// builtToolBox holds the toolbox type name and the debug logs of a build.
+type builtToolBox struct {
+	name string
+	logs []string
+}
+
+// buildToolBox build the toolBox and returns its debug logs,
+// nil if no field has been built.
+func (s *Builder) buildToolBox(ctx context.Context, toolBox interface{}) (built *builtToolBox, err error) {
+	s.ctx = ctx
+	defer func() { s.ctx = nil }()
+
 	t := reflect.TypeOf(toolBox).Elem()
 	v := reflect.ValueOf(toolBox).Elem()
 
 	if t.Kind() != reflect.Struct {
-		return errors.New("'toolBox' parameter should be a struct pointer")
+		return nil, errors.New("'toolBox' parameter should be a struct pointer")
 	}
 
 	// nil pointer
 	if !v.CanSet() || !v.IsValid() {
-		return errors.New("'toolBox' parameter should be a struct pointer")
+		return nil, errors.New("'toolBox' parameter should be a struct pointer")
 	}
 
-	debugLogs, err := s.build(nil, v, 0)
-	fmt.Printf("\nSwap: %s\n", s.EnvHandler.Current().Info())
-	if s.DebugOptions.Enabled {
-		s.debug(t.Name(), debugLogs)
+	s.timelineMutex.Lock()
+	s.timeline = nil
+	s.timelineMutex.Unlock()
+
+	s.unavailableMutex.Lock()
+	s.unavailable = nil
+	s.unavailableMutex.Unlock()
+
+	s.loadedFilesMutex.Lock()
+	s.loadedFiles = nil
+	s.loadedFilesMutex.Unlock()
+
+	s.fieldFilesMutex.Lock()
+	s.fieldFiles = nil
+	s.fieldFilesMutex.Unlock()
+
+	s.reportMutex.Lock()
+	s.report = nil
+	s.reportMutex.Unlock()
+
+	if err = s.prepareBuild(); err != nil {
+		return nil, err
 	}
-	return err
+
+	s.toolBox = toolBox
+	debugLogs, err := s.build("", nil, v, 0)
+	if err == nil {
+		err = s.applyPathOverrides("", v)
+	}
+	if err == nil {
+		err = s.validate("", v)
+	}
+	if s.DebugOptions.Enabled && s.DebugOptions.Verbosity >= VerbositySummary {
+		s.printEnvironment()
+	}
+	return &builtToolBox{name: t.Name(), logs: debugLogs}, err
+}
+
+// prepareBuild load the dotenv files and the override token,
+// and reset the concurrency semaphore before building any field.
+func (s *Builder) prepareBuild() (err error) {
+	if err = exportDotEnv(s.fs, s.exportedDotEnvFiles, s.EnvHandler.Current); err != nil {
+		return err
+	}
+
+	if err = s.applyOverrideToken(); err != nil {
+		return err
+	}
+
+	if s.dotEnv, err = s.loadDotEnv(); err != nil {
+		return err
+	}
+
+	s.semaphore = nil
+	if s.concurrency > 1 {
+		s.semaphore = make(chan struct{}, s.concurrency)
+	}
+	return nil
 }
 
 // Struct fields scan --------------------------------------------------------------------------------------------------
 
+// path is the field path from the root object (eg.: `MediaProcessing.Pictures`),
 // level is the parent grade to the initially passed field value
-func (s *Builder) build(sf *reflect.StructField, fv reflect.Value, level int) (logs []string, err error) {
+func (s *Builder) build(path string, sf *reflect.StructField, fv reflect.Value, level int) (logs []string, err error) {
 	switch fv.Kind() {
 	case reflect.Ptr:
 		if !fv.CanSet() {
-			if !s.DebugOptions.HideSkipped {
-				logs = append(logs, getLogString(sf, stateSkipped, nil, level, []string{}))
+			if fieldLog := s.logField(path, sf, stateSkipped, nil, level, []string{}); !s.DebugOptions.HideSkipped {
+				logs = append(logs, fieldLog)
 			}
 			return logs, nil
 		}
 
 		if sf != nil {
-			if tag, found := sf.Tag.Lookup(sftBuilderKey); found && tag == sffBuilderSkip {
-				if !s.DebugOptions.HideSkipped {
-					logs = append(logs, getLogString(sf, stateSkipped, nil, level, []string{}))
+			if tag, found := sf.Tag.Lookup(s.tagKey); found && tag == sffBuilderSkip {
+				if fieldLog := s.logField(path, sf, stateSkipped, nil, level, []string{}); !s.DebugOptions.HideSkipped {
+					logs = append(logs, fieldLog)
 				}
 				return logs, nil
 			}
 
 			if sf.Anonymous || !fv.CanSet() {
-				if !s.DebugOptions.HideSkipped {
-					logs = append(logs, getLogString(sf, stateSkipped, nil, level, []string{}))
+				if fieldLog := s.logField(path, sf, stateSkipped, nil, level, []string{}); !s.DebugOptions.HideSkipped {
+					logs = append(logs, fieldLog)
 				}
 				return logs, nil
 			}
 
 			if !reflect.DeepEqual(fv.Interface(), reflect.Zero(fv.Type()).Interface()) {
-				return []string{getLogString(sf, stateAlreadyConfigured, nil, level, []string{})}, nil
+				return []string{s.logField(path, sf, stateAlreadyConfigured, nil, level, []string{})}, nil
 			}
 		}
 
 		fv.Set(reflect.New(fv.Type().Elem()))
-		return s.build(sf, fv.Elem(), level)
+		return s.build(path, sf, fv.Elem(), level)
 
 	case reflect.Struct:
+		if sf != nil {
+			if err = s.context().Err(); err != nil {
+				return []string{s.logField(path, sf, stateZero, err, level, nil)}, fieldError(path, "", err)
+			}
+		}
+
+		if sf != nil && fv.CanSet() {
+			if lazy, ok := fv.Addr().Interface().(lazyField); ok {
+				return s.buildLazy(path, sf, lazy, level)
+			}
+		}
+
 		var configEnvFiles []string
 		var state state
-		configEnvFiles, state, err = s.setField(sf, fv)
+		start := time.Now()
+		configEnvFiles, state, err = s.setField(path, sf, fv)
 		if state == stateSkipped {
-			if !s.DebugOptions.HideSkipped {
-				logs = append(logs, getLogString(sf, state, nil, level, configEnvFiles))
+			if fieldLog := s.logField(path, sf, state, nil, level, configEnvFiles); !s.DebugOptions.HideSkipped {
+				logs = append(logs, fieldLog)
 			}
 			return logs, err
 		}
+		unhandled := false
+		if sf != nil && state != stateAlreadyConfigured {
+			defer func(start time.Time) {
+				if !unhandled {
+					s.addSpan(TimelineSpan{Path: path, Type: sf.Type.String(), Start: start, End: time.Now(), Err: err})
+				}
+			}(start)
+		}
 		if err != nil ||
-			state == stateAlreadyConfigured ||
+			state == stateAlreadyConfigured || state == stateNoConfigFiles ||
 			state == stateMadeFromInterface || state == stateMadeFromRegisteredFactory {
-			return []string{getLogString(sf, state, err, level, configEnvFiles)}, err
+			if state != stateAlreadyConfigured && state != stateNoConfigFiles {
+				s.observe(path, sf, time.Since(start), err)
+			}
+			if err == nil && state != stateAlreadyConfigured && state != stateNoConfigFiles {
+				s.record(path, sf, fv)
+				s.recordFieldFiles(path, configEnvFiles)
+			}
+			return []string{s.logField(path, sf, state, err, level, configEnvFiles)}, fieldError(path, "", err)
 		}
 
-		subLogs := make([]string, 0)
+		var subLogs []string
+		var order []int
+		var deps [][]int
+		if order, deps, err = s.buildOrder(fv.Type()); err != nil {
+			return []string{s.logField(path, sf, state, err, level, configEnvFiles)}, fieldError(path, "", err)
+		}
 
-		// configure sub-fields first
-		for i := 0; i < fv.NumField(); i++ {
-			ssf := fv.Type().Field(i)
-			sfv := fv.Field(i)
-			//subPath := filepath.Join(configPath, sf.Name)
-			sLogs, err := s.build(&ssf, sfv, level+1)
-			subLogs = append(subLogs, sLogs...)
-			if err != nil {
-				logs = append(logs, subLogs...)
-				return logs, err
-			}
+		// configure sub-fields first, in dependency order
+		if subLogs, err = s.buildFields(path, fv, order, deps, level); err != nil {
+			logs = append(logs, subLogs...)
+			return logs, err
 		}
 
 		if state == stateRoot {
@@ -207,30 +625,123 @@ func (s *Builder) build(sf *reflect.StructField, fv reflect.Value, level int) (l
 			return logs, nil
 		}
 
-		if configEnvFiles, err = s.configure(fv, configEnvFiles); err != nil {
+		start = time.Now()
+		configEnvFiles, err = s.configure(path, fv, configEnvFiles)
+		if err != errNotConfigurable {
+			s.observe(path, sf, time.Since(start), err)
+		}
+		if err != nil {
 			if err == errNotConfigurable {
 				if len(subLogs) > 0 {
-					logs = append(logs, getLogString(sf, stateTraversing, nil, level, configEnvFiles))
+					logs = append(logs, s.logField(path, sf, stateTraversing, nil, level, configEnvFiles))
 					logs = append(logs, subLogs...)
-				} else if !s.DebugOptions.HideUnhandled { //if level <= s.DebugLevel &&
-					logs = append(logs, getLogString(sf, stateUnhandled, nil, level, configEnvFiles))
+				} else {
+					unhandled = true
+					if fieldLog := s.logField(path, sf, stateUnhandled, nil, level, configEnvFiles); !s.DebugOptions.HideUnhandled { //if level <= s.DebugLevel &&
+						logs = append(logs, fieldLog)
+					}
 				}
 				return logs, nil
 			}
-			logs = append(logs, getLogString(sf, state, err, level, configEnvFiles))
-			return
+			logs = append(logs, s.logField(path, sf, state, err, level, configEnvFiles))
+			return logs, fieldError(path, "", err)
 		}
 
-		logs = append(logs, getLogString(sf, stateConfigured, nil, level, configEnvFiles))
+		s.record(path, sf, fv)
+		s.recordFieldFiles(path, configEnvFiles)
+		logs = append(logs, s.logField(path, sf, stateConfigured, nil, level, configEnvFiles))
 		logs = append(logs, subLogs...)
 		return
 
 	default:
-		_, _, err = s.setField(sf, fv)
-		return
+		_, _, err = s.setField(path, sf, fv)
+		return nil, fieldError(path, "", err)
 	}
 }
 
+// buildFields build the struct sub-fields following the given order,
+// independent fields are built concurrently if s.concurrency > 1.
+// Logs are always returned in the given order.
+func (s *Builder) buildFields(path string, fv reflect.Value, order []int, deps [][]int, level int) (logs []string, err error) {
+	if s.concurrency <= 1 {
+		for _, i := range order {
+			ssf := fv.Type().Field(i)
+			sfv := fv.Field(i)
+			sLogs, err := s.buildField(joinFieldPath(path, ssf.Name), &ssf, sfv, level+1)
+			logs = append(logs, sLogs...)
+			if err != nil {
+				return logs, err
+			}
+		}
+		return logs, nil
+	}
+
+	fieldsLogs := make([][]string, fv.NumField())
+	fieldsErrs := make([]error, fv.NumField())
+	done := make([]chan struct{}, fv.NumField())
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var failed int32
+	var wg sync.WaitGroup
+	for _, i := range order {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+
+			// wait for dependencies
+			for _, j := range deps[i] {
+				<-done[j]
+			}
+
+			// stop as soon as possible on errors
+			if atomic.LoadInt32(&failed) == 1 {
+				return
+			}
+
+			ssf := fv.Type().Field(i)
+			subPath := joinFieldPath(path, ssf.Name)
+			if fieldsLogs[i], fieldsErrs[i] = s.buildField(subPath, &ssf, fv.Field(i), level+1); fieldsErrs[i] != nil {
+				atomic.StoreInt32(&failed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, i := range order {
+		logs = append(logs, fieldsLogs[i]...)
+		if fieldsErrs[i] != nil {
+			return logs, fieldsErrs[i]
+		}
+	}
+	return logs, nil
+}
+
+// acquire a concurrency slot before calling
+// the Factory or the Configurable interface.
+func (s *Builder) acquire() {
+	if s.semaphore != nil {
+		s.semaphore <- struct{}{}
+	}
+}
+
+// release a concurrency slot.
+func (s *Builder) release() {
+	if s.semaphore != nil {
+		<-s.semaphore
+	}
+}
+
+// joinFieldPath returns the dot separated field path.
+func joinFieldPath(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
+
 // Basic struct field operations ---------------------------------------------------------------------------------------
 
 // setField set the field value.
@@ -242,7 +753,7 @@ func (s *Builder) build(sf *reflect.StructField, fv reflect.Value, level int) (l
 // - Have the skip `-` tag.
 // - Implement the `Factory` interface.
 // - A `factoryFunc` for the fv.Type() has been registered.
-func (s *Builder) setField(sf *reflect.StructField, fv reflect.Value) (configEnvFiles []string, status state, err error) {
+func (s *Builder) setField(path string, sf *reflect.StructField, fv reflect.Value) (configEnvFiles []string, status state, err error) {
 	// sf is nil for the root object
 	if sf == nil {
 		//fv.Set(reflect.New(fv.Type()).Elem())
@@ -259,18 +770,42 @@ func (s *Builder) setField(sf *reflect.StructField, fv reflect.Value) (configEnv
 		return
 	}
 
-	configEnvFiles = []string{sf.Name}
-	if s.parseTags(&configEnvFiles, sf) {
+	tags := s.parseTags(sf)
+	if tags.skip {
 		status = stateSkipped
 		return
 	}
+	if tags.lazy {
+		err = &FieldError{Tag: sffBuilderLazy, Err: fmt.Errorf("the lazy flag requires a swap.Lazy[%s] field", sf.Type.String())}
+		return
+	}
+	if tags.waitForTimeoutErr != nil {
+		err = &FieldError{Tag: sffBuilderWaitForTimeout, Err: tags.waitForTimeoutErr}
+		return
+	}
+	configEnvFiles = append([]string{sf.Name}, tags.files...)
+
+	if tags.optional {
+		var found bool
+		if found, err = s.hasConfigFiles(configEnvFiles); err != nil || !found {
+			status = stateNoConfigFiles
+			return
+		}
+	}
+
+	if err = waitFor(s.context(), tags.waitFor, tags.waitForTimeout); err != nil {
+		err = &FieldError{Tag: sffBuilderWaitFor, Err: err}
+		return
+	}
 
 	getEnvFiles := func(cf []string) (files []string, err error) {
 		for i, file := range cf {
 			cf[i] = filepath.Join(s.configPath, file)
 		}
 
-		return appendEnvFiles(s.EnvHandler.Current(), cf)
+		files, err = s.parser().appendEnvFiles(s.EnvHandler.Current(), cf)
+		s.recordFiles(files)
+		return
 	}
 
 	if factory, haveFactory := fv.Addr().Interface().(Factory); haveFactory {
@@ -280,7 +815,11 @@ func (s *Builder) setField(sf *reflect.StructField, fv reflect.Value) (configEnv
 			return
 		}
 		var obj interface{}
+		unbind := s.bindPathOverrides(path, fv.Type(), configEnvFiles)
+		s.acquire()
 		obj, err = factory.New(configEnvFiles...)
+		s.release()
+		unbind()
 		if err != nil {
 			return
 		}
@@ -301,7 +840,11 @@ func (s *Builder) setField(sf *reflect.StructField, fv reflect.Value) (configEnv
 			return
 		}
 		var obj interface{}
+		unbind := s.bindPathOverrides(path, fv.Type(), configEnvFiles)
+		s.acquire()
 		obj, err = factory(configEnvFiles...)
+		s.release()
+		unbind()
 		if err != nil {
 			return
 		}
@@ -324,63 +867,309 @@ func (s *Builder) setField(sf *reflect.StructField, fv reflect.Value) (configEnv
 	return
 }
 
-// parseTags returns the config file name and the skip flag.
-// The name will be returned also if not specified in tags,
-// the field name without extension will be returned in that case,
+// fieldTags hold the builder struct field tag values.
+type fieldTags struct {
+	// files are the additional config files.
+	files []string
+
+	// skip is true for the `-` tag.
+	skip bool
+
+	// deps are the sibling fields to build first.
+	deps []string
+
+	// labels are the metrics labels.
+	labels map[string]string
+
+	// degrade is true for optional fields.
+	degrade bool
+
+	// optional is true for fields whose config files may be missing.
+	optional bool
+
+	// lazy is true for swap.Lazy fields configured on the first Get call.
+	lazy bool
+
+	// waitFor are the endpoints to wait for, up to waitForTimeout,
+	// waitForTimeoutErr is the malformed timeout error.
+	waitFor           []string
+	waitForTimeout    time.Duration
+	waitForTimeoutErr error
+}
+
+// parseTags returns the additional config file names,
+// the skip flag and the field dependencies.
+// Only the additional file names are returned,
+// the field name without extension will be used too in setField,
 // loadConfig will look for a file with that prefix and any kind
 // of extension, if necessary (no '.' in file name).
-func (s *Builder) parseTags(configFiles *[]string, f *reflect.StructField) (skip bool) {
-	tag, found := f.Tag.Lookup(sftBuilderKey)
+func (s *Builder) parseTags(f *reflect.StructField) (tags fieldTags) {
+	tag, found := f.Tag.Lookup(s.tagKey)
 	if !found {
 		return
 	}
 
 	if tag == sffBuilderSkip {
-		return true
+		tags.skip = true
+		return
 	}
 
 	tagFields := strings.Split(tag, ",")
 	for _, flag := range tagFields {
+		kv := strings.SplitN(flag, "=", 2)
+
+		if flag == sffBuilderDegrade {
+			tags.degrade = true
+			continue
+		}
+
+		if flag == sffBuilderOptional {
+			tags.optional = true
+			continue
+		}
+
+		if flag == sffBuilderLazy {
+			tags.lazy = true
+			continue
+		}
+
+		if kv[0] == sffBuilderWaitFor && len(kv) == 2 {
+			tags.waitFor = append(tags.waitFor, strings.Split(kv[1], "|")...)
+			continue
+		}
+
+		if kv[0] == sffBuilderWaitForTimeout && len(kv) == 2 {
+			if tags.waitForTimeout, tags.waitForTimeoutErr = time.ParseDuration(kv[1]); tags.waitForTimeoutErr != nil {
+				tags.waitForTimeoutErr = fmt.Errorf("invalid timeout '%s': %s", kv[1], tags.waitForTimeoutErr.Error())
+			}
+			continue
+		}
+
+		if kv[0] == sffBuilderDeps && len(kv) == 2 {
+			tags.deps = append(tags.deps, strings.Split(kv[1], "|")...)
+			continue
+		}
+
+		if kv[0] == sffBuilderMetrics && len(kv) == 2 {
+			if tags.labels == nil {
+				tags.labels = make(map[string]string)
+			}
+			for _, label := range strings.Split(kv[1], "|") {
+				lkv := strings.SplitN(label, ":", 2)
+				if len(lkv) == 2 {
+					tags.labels[lkv[0]] = lkv[1]
+				}
+			}
+			continue
+		}
+
 		files := strings.Split(flag, "|")
-		*configFiles = append(*configFiles, files...)
+		tags.files = append(tags.files, files...)
 	}
 
 	return
 }
 
+// buildOrder returns the struct fields indexes sorted
+// by their dependencies (`deps` tag), declaration order is
+// preserved for independent fields.
+// The dependencies indexes of every field are returned too.
+func (s *Builder) buildOrder(t reflect.Type) (order []int, deps [][]int, err error) {
+	indexes := make(map[string]int, t.NumField())
+	depNames := make([][]string, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		indexes[f.Name] = i
+		depNames[i] = s.parseTags(&f).deps
+	}
+
+	deps = make([][]int, t.NumField())
+	for i, names := range depNames {
+		for _, dep := range names {
+			j, ok := indexes[dep]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown dependency '%s' for field '%s'", dep, t.Field(i).Name)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	order = make([]int, 0, t.NumField())
+	marks := make([]int, t.NumField())
+	var path []string
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		name := t.Field(i).Name
+		switch marks[i] {
+		case visited:
+			return nil
+		case visiting:
+			for j := range path {
+				if path[j] == name {
+					path = path[j:]
+					break
+				}
+			}
+			return fmt.Errorf("dependency cycle detected: %s -> %s", strings.Join(path, " -> "), name)
+		}
+
+		marks[i] = visiting
+		path = append(path, name)
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[i] = visited
+
+		order = append(order, i)
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if err = visit(i); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return order, deps, nil
+}
+
 // Struct fields config ------------------------------------------------------------------------------------------------
 
 // configure will call the 'Configurable' interface on the passed field struct pointer.
-func (s *Builder) configure(fv reflect.Value, configFiles []string) (configEnvFiles []string, err error) {
-	if _, isConfigurable := fv.Addr().Interface().(Configurable); isConfigurable {
-		for i, file := range configFiles {
-			configFiles[i] = filepath.Join(s.configPath, file)
+func (s *Builder) configure(path string, fv reflect.Value, configFiles []string) (configEnvFiles []string, err error) {
+	if configurable, isConfigurable := fv.Addr().Interface().(ConfigurableCtx); isConfigurable {
+		if configEnvFiles, err = s.configEnvFiles(configFiles); err != nil {
+			return configEnvFiles, err
 		}
-		configEnvFiles, err = appendEnvFiles(s.EnvHandler.Current(), configFiles)
-		if err != nil {
+		defer s.bindPathOverrides(path, fv.Type(), configEnvFiles)()
+		s.acquire()
+		defer s.release()
+		return configEnvFiles, configurable.Configure(s.context(), configEnvFiles...)
+	}
+
+	if configurable, isConfigurable := fv.Addr().Interface().(Configurable); isConfigurable {
+		if configEnvFiles, err = s.configEnvFiles(configFiles); err != nil {
 			return configEnvFiles, err
 		}
-		return configEnvFiles, fv.Addr().Interface().(Configurable).Configure(configEnvFiles...)
+		defer s.bindPathOverrides(path, fv.Type(), configEnvFiles)()
+		s.acquire()
+		defer s.release()
+		return configEnvFiles, configurable.Configure(configEnvFiles...)
 	}
 
 	return configEnvFiles, errNotConfigurable
 }
 
+// configEnvFiles returns the config files of a field, environment specific ones included.
+func (s *Builder) configEnvFiles(configFiles []string) (configEnvFiles []string, err error) {
+	for i, file := range configFiles {
+		configFiles[i] = filepath.Join(s.configPath, file)
+	}
+	if configEnvFiles, err = s.parser().appendEnvFiles(s.EnvHandler.Current(), configFiles); err != nil {
+		return configEnvFiles, err
+	}
+	s.recordFiles(configEnvFiles)
+	return configEnvFiles, nil
+}
+
+// context returns the context of the running BuildContext, context.Background() otherwise.
+func (s *Builder) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
 func (s *Builder) debug(objName string, logs []string) {
-	vcs := s.EnvHandler.Sources.Git.Info()
-	fmt.Printf("%s\n", vcs)
+	switch s.DebugOptions.Verbosity {
+	case VerbositySilent:
+		return
+	case VerbosityErrors, VerbositySummary:
+		logs = s.failedFields(logs)
+	}
+
+	if s.slogger != nil {
+		s.slogFields(logs)
+	} else if len(logs) > 0 || s.DebugOptions.Verbosity == VerbosityFull {
+		s.logger.Printf("%s", s.debugTree(objName, logs))
+	}
 
-	fmt.Println(logger.Magenta("type ") + logger.Yellow(objName) + logger.Magenta(" struct") + " {")
+	if s.DebugOptions.Verbosity == VerbositySummary {
+		s.printSummary(objName)
+	}
+}
+
+// printEnvironment prints the current environment info.
+func (s *Builder) printEnvironment() {
+	switch {
+	case s.slogger != nil:
+		s.slogger.Info("swap: environment", "environment", s.EnvHandler.Current().Tag(), "profile", s.ProfileHandler.Current())
+	case s.DebugOptions.Format == DebugFormatJSON:
+		data, _ := json.Marshal(struct {
+			Environment string
+			Profile     []string `json:",omitempty"`
+		}{s.EnvHandler.Current().Tag(), s.ProfileHandler.Current()})
+		s.logger.Printf("%s\n", data)
+	default:
+		s.logger.Printf("\nSwap: %s%s\n", s.EnvHandler.Current().Info(), s.ProfileHandler.Info())
+	}
+}
+
+// structuredDebug returns true if the debug logs are the fields paths,
+// rendered from the report (the JSON format, slog and the reduced verbosities).
+func (s *Builder) structuredDebug() bool {
+	return s.slogger != nil || s.DebugOptions.Format == DebugFormatJSON || s.DebugOptions.Verbosity < VerbosityFull
+}
+
+// debugTree returns the debug tree of the built fields,
+// their JSON lines with the JSON format or slog,
+// or their error lines with the reduced verbosities.
+func (s *Builder) debugTree(objName string, logs []string) string {
+	switch {
+	case s.slogger != nil || s.DebugOptions.Format == DebugFormatJSON:
+		return s.debugJSONLines(logs)
+	case s.DebugOptions.Verbosity < VerbosityFull:
+		return s.debugErrorLines(logs)
+	}
+
+	var tree strings.Builder
+	tree.WriteString(s.EnvHandler.Sources.info() + "\n")
+	tree.WriteString(logger.Magenta("type ") + logger.Yellow(objName) + logger.Magenta(" struct") + " {\n")
 	for _, log := range logs {
-		fmt.Print(log)
+		tree.WriteString(log)
 	}
-	fmt.Print("}\n\n")
+	tree.WriteString("}\n\n")
+	return tree.String()
 }
 
 // Helpers -------------------------------------------------------------------------------------------------------------
 
 var errNotConfigurable = errors.New("`Configurable` interface not implemented")
 
+// hasConfigFiles returns true if any config file is found for the field.
+func (s *Builder) hasConfigFiles(configFiles []string) (bool, error) {
+	files := make([]string, len(configFiles))
+	for i, file := range configFiles {
+		files[i] = filepath.Join(s.configPath, file)
+	}
+
+	_, err := s.parser().appendEnvFiles(s.EnvHandler.Current(), files)
+	if errors.Is(err, errNoConfigFile) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 type state int
 
 const (
@@ -393,6 +1182,9 @@ const (
 	stateConfigured
 	stateMadeFromInterface
 	stateMadeFromRegisteredFactory
+	stateDegraded
+	stateNoConfigFiles
+	stateLazy
 )
 
 func (s state) string() string {
@@ -415,6 +1207,12 @@ func (s state) string() string {
 		return "made with `Factory` interface"
 	case stateMadeFromRegisteredFactory:
 		return "made with registered `FactoryFunc`"
+	case stateDegraded:
+		return "degraded"
+	case stateNoConfigFiles:
+		return "no config files, optional"
+	case stateLazy:
+		return "lazy, configured on first use"
 	default:
 		return ""
 	}
@@ -471,7 +1269,9 @@ func getLogString(sf *reflect.StructField, state state, err error, level int, co
 	objNameType = fmt.Sprintf("%-80v", objNameType)
 
 	if err != nil {
-		switch err {
+		switch state {
+		case stateDegraded:
+			return fmt.Sprintf("%s %s\n", objNameType, "-> "+logger.Yellow(state.string()+": "+err.Error()))
 		default:
 			return fmt.Sprintf("%s %s\n", objNameType, "-> "+logger.Red(err.Error()))
 		}
@@ -486,7 +1286,7 @@ func getLogString(sf *reflect.StructField, state state, err error, level int, co
 		case stateTraversing:
 			return fmt.Sprintf("%s %s\n", objNameType, inArrow+logger.Def(state.string()))
 
-		case stateSkipped:
+		case stateSkipped, stateNoConfigFiles:
 			return fmt.Sprintf("%s %s\n", objNameType, outArrow+logger.Yellow(state.string()))
 
 		case stateAlreadyConfigured:
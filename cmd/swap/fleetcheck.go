@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/oblq/swap"
+)
+
+// fleetCheck fetch the manifest from every target
+// and exit with 1 if they don't match.
+func fleetCheck(args []string) int {
+	flags := flag.NewFlagSet("fleet-check", flag.ContinueOnError)
+	targets := flags.String("targets", "", "comma separated instances debug handler URLs")
+	timeout := flags.Duration("timeout", 10*time.Second, "the whole check timeout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: swap fleet-check --targets http://10.0.0.1:8080/debug/swap,http://10.0.0.2:8080/debug/swap")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if len(*targets) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report := swap.FleetCheck(ctx, &http.Client{}, strings.Split(*targets, ","))
+	fmt.Fprint(os.Stdout, report.String())
+	if !report.Consistent() {
+		return 1
+	}
+	return 0
+}
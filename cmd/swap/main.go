@@ -0,0 +1,50 @@
+// Command swap is the swap command-line tool.
+//
+// Usage:
+//
+//	swap <command> [arguments]
+//
+// The commands are:
+//
+//	fleet-check   compare the config manifest of a fleet of instances
+//	validate      check the config files against their schema before deploy
+//	render        print the merged config files, or which file set every key
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a swap subcommand.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"fleet-check", "compare the config manifest of a fleet of instances", fleetCheck},
+	{"validate", "check the config files against their schema before deploy", validate},
+	{"render", "print the merged config files, or which file set every key", render},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd.run(args[1:])
+			}
+		}
+	}
+
+	fmt.Fprint(os.Stderr, "Usage:\n\n\tswap <command> [arguments]\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-13s %s\n", cmd.name, cmd.usage)
+	}
+	return 2
+}
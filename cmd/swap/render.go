@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oblq/swap"
+)
+
+// render prints the merged config files,
+// or the files setting every key with --sources.
+func render(args []string) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	configPath := flags.String("config", "./config", "the config directory")
+	envTag := flags.String("env", "", "the environment tag, also renders the environment specific files")
+	format := flags.String("format", string(swap.FormatYAML), "the output format: yaml, json or toml")
+	sources := flags.Bool("sources", false, "print the files setting every key instead, the last one won")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: swap render [--config ./config] [--env production] [--format yaml] [--sources] app")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var env *swap.Environment
+	if len(*envTag) > 0 {
+		env = environment(*envTag)
+	}
+
+	files := make([]string, 0, flags.NArg())
+	for _, name := range flags.Args() {
+		files = append(files, filepath.Join(*configPath, name))
+	}
+
+	rendered, err := swap.Render(swap.NewFileSystemLocal(), env, files...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if *sources {
+		for _, key := range rendered.Keys() {
+			fmt.Fprintf(os.Stdout, "%s: %s\n", key, strings.Join(rendered.Sources[key], " < "))
+		}
+		return 0
+	}
+
+	data, err := swap.Dump(rendered.Tree, swap.Format(*format))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	fmt.Fprint(os.Stdout, string(data))
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/oblq/swap"
+)
+
+// validate checks the config files against the schemas of their config structs
+// and exit with 1 if any violation is found.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configPath := flags.String("config", "./config", "the config directory")
+	envTag := flags.String("env", "", "the environment tag, also validates the environment specific files")
+	schemaFile := flags.String("schema", "", "the JSON schema (swap.JSONSchema) of the named config files")
+	manifestFile := flags.String("manifest", "", "the JSON manifest mapping the config file names to their schema")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: swap validate [--config ./config] [--env production] --schema app.schema.json app")
+		fmt.Fprintln(flags.Output(), "       swap validate [--config ./config] [--env production] --manifest manifest.json")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	schemas := make(map[string]string)
+	switch {
+	case len(*schemaFile) > 0 && flags.NArg() > 0:
+		for _, name := range flags.Args() {
+			schemas[name] = *schemaFile
+		}
+	case len(*manifestFile) > 0:
+		data, err := ioutil.ReadFile(*manifestFile)
+		if err == nil {
+			err = json.Unmarshal(data, &schemas)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid manifest: %s\n", err.Error())
+			return 2
+		}
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	var env *swap.Environment
+	if len(*envTag) > 0 {
+		env = environment(*envTag)
+	}
+
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	violations := 0
+	for _, name := range names {
+		schema, err := ioutil.ReadFile(schemas[name])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 2
+		}
+
+		found, err := swap.ValidateFiles(swap.NewFileSystemLocal(), schema, env, filepath.Join(*configPath, name))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", schemas[name], err.Error())
+			return 2
+		}
+		for _, violation := range found {
+			fmt.Fprintln(os.Stdout, violation.String())
+		}
+		violations += len(found)
+	}
+
+	if violations > 0 {
+		return 1
+	}
+	return 0
+}
+
+// environment returns the default environment matching the tag,
+// or a new one otherwise.
+func environment(tag string) *swap.Environment {
+	for _, env := range swap.DefaultEnvs.Slice() {
+		if env.MatchTag(tag) {
+			return env
+		}
+	}
+	return swap.NewEnvironment(tag, "^"+regexp.QuoteMeta(tag)+"$")
+}
@@ -0,0 +1,169 @@
+package swap
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Conditional tags ----------------------------------------------------------------------------------------------------
+
+// parseConditionalTags process the cross-field flags once all the other tags
+// have been processed, so referenced fields hold their final value.
+// References are field paths from the root config (eg.: `TLS.Enabled`, `Hosts[0]`).
+func (p *parser) parseConditionalTags(root reflect.Value, path string, elem reflect.Value) error {
+	elem = reflect.Indirect(elem)
+
+	switch elem.Kind() {
+	case reflect.Struct:
+		for i := 0; i < elem.NumField(); i++ {
+			ft := elem.Type().Field(i)
+			fv := elem.Field(i)
+			if !fv.CanAddr() || !fv.CanInterface() {
+				continue
+			}
+
+			fieldPath := joinFieldPath(path, ft.Name)
+			flags := splitFlags(ft.Tag.Get(p.tagKey))
+
+			if ref := flagValue(flags, sffConfigDefaultFrom); len(ref) > 0 && fv.IsZero() {
+				source, err := lookupPath(root, ref)
+				if err != nil {
+					return &FieldError{Path: fieldPath, Tag: sffConfigDefaultFrom, Err: err}
+				}
+				if err = assign(fv, source); err != nil {
+					return &FieldError{Path: fieldPath, Tag: sffConfigDefaultFrom, Err: err}
+				}
+			}
+
+			if cond := flagValue(flags, sffConfigRequiredIf); len(cond) > 0 && fv.IsZero() {
+				met, err := conditionMet(root, cond)
+				if err != nil {
+					return &FieldError{Path: fieldPath, Tag: sffConfigRequiredIf, Err: err}
+				}
+				if met {
+					return &FieldError{Path: fieldPath, Tag: sffConfigRequiredIf,
+						Err: fmt.Errorf("%w if %s", ErrRequired, cond)}
+				}
+			}
+
+			if err := p.parseConditionalTags(root, fieldPath, fv); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < elem.Len(); i++ {
+			if err := p.parseConditionalTags(root, fmt.Sprintf("%s[%d]", path, i), elem.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// conditionMet returns true if the referenced field is not zero,
+// or equal to the given value (eg.: `Mode=tls`).
+func conditionMet(root reflect.Value, cond string) (bool, error) {
+	kv := strings.SplitN(cond, "=", 2)
+	field, err := lookupPath(root, kv[0])
+	if err != nil {
+		return false, err
+	}
+	if len(kv) == 2 {
+		return field.IsValid() && fmt.Sprint(field.Interface()) == kv[1], nil
+	}
+	return field.IsValid() && !field.IsZero(), nil
+}
+
+// assign set fv to the source value, converting it through yaml
+// if the types differ (eg.: an int into a string field).
+func assign(fv, source reflect.Value) error {
+	if !source.IsValid() {
+		return nil
+	}
+	if source.Type().AssignableTo(fv.Type()) {
+		fv.Set(source)
+		return nil
+	}
+	data, err := yaml.Marshal(source.Interface())
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, fv.Addr().Interface())
+}
+
+// lookupPath returns the field value at path from root (eg.: `TLS.Enabled`, `Hosts[0]`, `Labels[env]`),
+// the invalid Value if a pointer on the path is nil.
+func lookupPath(root reflect.Value, path string) (reflect.Value, error) {
+	value := root
+	for _, token := range splitPath(path) {
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return reflect.Value{}, nil
+			}
+			value = value.Elem()
+		}
+
+		switch {
+		case strings.HasPrefix(token, "["):
+			index := strings.TrimSuffix(strings.TrimPrefix(token, "["), "]")
+			switch value.Kind() {
+			case reflect.Slice, reflect.Array:
+				i, err := strconv.Atoi(index)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("invalid index '%s' in '%s'", index, path)
+				}
+				if i < 0 || i >= value.Len() {
+					return reflect.Value{}, nil
+				}
+				value = value.Index(i)
+			case reflect.Map:
+				key := reflect.New(value.Type().Key()).Elem()
+				if err := yaml.Unmarshal([]byte(index), key.Addr().Interface()); err != nil {
+					return reflect.Value{}, fmt.Errorf("invalid key '%s' in '%s'", index, path)
+				}
+				if value = value.MapIndex(key); !value.IsValid() {
+					return reflect.Value{}, nil
+				}
+			default:
+				return reflect.Value{}, fmt.Errorf("can't index '%s' in '%s'", value.Type(), path)
+			}
+		case value.Kind() == reflect.Struct:
+			if value = value.FieldByName(token); !value.IsValid() {
+				return reflect.Value{}, fmt.Errorf("unknown field '%s' in '%s'", token, path)
+			}
+		default:
+			return reflect.Value{}, fmt.Errorf("unknown field '%s' in '%s'", token, path)
+		}
+	}
+	return value, nil
+}
+
+// splitPath returns the path tokens, eg.: `Hosts[0].Name` -> `Hosts`, `[0]`, `Name`.
+func splitPath(path string) (tokens []string) {
+	for _, part := range strings.Split(path, ".") {
+		for len(part) > 0 {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				tokens = append(tokens, part)
+				break
+			}
+			if i > 0 {
+				tokens = append(tokens, part[:i])
+			}
+			j := strings.Index(part, "]")
+			if j < i {
+				tokens = append(tokens, part[i:])
+				break
+			}
+			tokens = append(tokens, part[i:j+1])
+			part = part[j+1:]
+		}
+	}
+	return
+}
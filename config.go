@@ -9,20 +9,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"strings"
 	"text/template"
 
-	"github.com/BurntSushi/toml"
+	"filippo.io/age"
 	"gopkg.in/yaml.v3"
 )
 
-// todo: replace encoding/json with github.com/json-iterator/go
-
 const (
 	// struct field tag key
 	sftConfigKey = "swapcp"
@@ -33,12 +32,85 @@ const (
 
 	// sffEnv environment var value can be in json format,
 	// it also overrides the default value.
-	// eg.: `swap:"env=env_var_name"`
+	// eg.: `swap:"env=env_var_name"`, or `swap:"env"` to derive
+	// the name from the field path (`PG_PASSWORD` for `PG.Password`)
 	sffConfigEnv = "env"
 
 	// set the default value
 	// eg.: `swap:"default=1"`
 	sffConfigDefault = "default"
+
+	// every field of the nested struct maps to the <prefix><FIELD> env var
+	// eg.: `swapcp:"envPrefix=POSTGRES_"`
+	sffConfigEnvPrefix = "envPrefix"
+
+	// constraints verified once the value is final, the regexp (or match) expressions
+	// can hold commas inside brackets, the other ones must be escaped as `\x2c`
+	// eg.: `swapcp:"min=1,max=65535"`, `swapcp:"match=^[a-z]{3,8}$"`, `swapcp:"oneof=debug|info|warn"`
+	sffConfigMin    = "min"
+	sffConfigMax    = "max"
+	sffConfigRegexp = "regexp"
+	sffConfigMatch  = "match" // alias of regexp
+	sffConfigOneOf  = "oneof"
+
+	// cross-field rules, referencing fields by their path from the root config
+	// eg.: `swapcp:"requiredIf=TLS.Enabled"`, `swapcp:"requiredIf=Mode=tls"`, `swapcp:"defaultFrom=Hosts[0]"`
+	sffConfigRequiredIf  = "requiredIf"
+	sffConfigDefaultFrom = "defaultFrom"
+
+	// the time.Time layout of the field strings, RFC3339 by default
+	// eg.: `swapcp:"layout=2006-01-02"`
+	sffConfigLayout = "layout"
+
+	// read the value from a file, overriding the config files values as env does
+	// eg.: `swapcp:"file=/run/secrets/db_password"`, `swapcp:"file=${DB_PASSWORD_FILE}"`
+	sffConfigFile = "file"
+
+	// read the value from a file, relative paths are resolved in the config FileSystem
+	// eg.: `swapcp:"fromfile=/etc/app/token"`, `swapcp:"fromfile=certs/ca.pem"`
+	sffConfigFromFile = "fromfile"
+
+	// how the slices set by more config files are merged: replace (default), append or unique
+	// eg.: `swapcp:"merge=append"`
+	sffConfigMerge = "merge"
+
+	// on a root struct field, bind the matching documents of the YAML multi-document files
+	// by Kubernetes-style `<kind>` or `<kind>/<metadata.name>` selector
+	// eg.: `swapcp:"document=ConfigMap/app"`
+	sffConfigDocument = "document"
+
+	// the integer field strings are human-readable byte sizes
+	// eg.: `swapcp:"bytes,default=10MB"`
+	sffConfigBytes = "bytes"
+
+	// the env, file and default values are base64 encoded
+	// eg.: `swapcp:"env=TLS_CERT,base64"`
+	sffConfigBase64 = "base64"
+
+	// the env, file and default values are strict JSON, unknown fields are rejected
+	// eg.: `swapcp:"env=ROUTES,json"`
+	sffConfigJSON = "json"
+
+	// the field value is redacted in errors and dumps,
+	// fields with password-like names and Secret fields are always redacted
+	// eg.: `swapcp:"secret"`
+	sffConfigSecret = "secret"
+
+	// the field values are age encrypted, decrypted with the Builder identities
+	// eg.: `swapcp:"age"`
+	sffConfigAge = "age"
+
+	// the command-line flag name of the field, `-` to skip it, see Builder.BindFlags
+	// eg.: `swapcp:"flag=port"`
+	sffConfigFlag = "flag"
+
+	// the field description, the command-line flag usage and the JSON schema description
+	// eg.: `swapcp:"desc=the listening port"`
+	sffConfigDesc = "desc"
+
+	// the field Validate method is called once its tags are processed, see Validator
+	// eg.: `swapcp:"validate"`
+	sffConfigValidate = "validate"
 )
 
 var (
@@ -49,6 +121,9 @@ var (
 	regexpJSON     = regexp.MustCompile(`(?i)(.json)`)
 )
 
+// errNoConfigFile is returned when no config file is found.
+var errNoConfigFile = errors.New("no config file found")
+
 // Parse strictly parse only the specified config files
 // in the exact order they are into the config interface, one by one.
 // The latest files will override the former.
@@ -63,7 +138,290 @@ func Parse(config interface{}, files ...string) (err error) {
 // The latest files passed will override the former.
 // Will also parse fmt template keys and struct flags.
 func ParseByEnv(config interface{}, env *Environment, files ...string) (err error) {
-	files, err = appendEnvFiles(env, files)
+	return newParser().parseByEnv(config, env, files...)
+}
+
+// ParseStrict is Parse, but unknown keys in the config files are errors
+// instead of being silently ignored, so typos fail fast.
+func ParseStrict(config interface{}, files ...string) (err error) {
+	p := newParser()
+	p.strict = true
+	return p.parseByEnv(config, nil, files...)
+}
+
+// ParseEnv fill the config struct from the environment variables alone,
+// without config files, the variable names are derived from the fields path:
+// `<prefix><FIELD>` and `<prefix><FIELD>_<SUBFIELD>` for nested structs
+// (eg.: `APP_PG_HOST` for the PG.Host field with the `APP_` prefix).
+// The `env`, `envPrefix`, `default` and `required` flags still apply.
+func ParseEnv(config interface{}, prefix string) error {
+	if reflect.TypeOf(config).Kind() != reflect.Ptr {
+		return fmt.Errorf("the config argument should be a pointer: `%s`", reflect.TypeOf(config).String())
+	}
+
+	p := newParser()
+	p.deriveEnv = true
+	if err := p.parseConfigTags("", prefix, config); err != nil {
+		return err
+	}
+	return p.parseConditionalTags(reflect.ValueOf(config), "", reflect.ValueOf(config))
+}
+
+// UnmarshalBytes parse the config data into the config interface as a config file,
+// eg.: for the configs arriving over the wire, the format (YAML, TOML or JSON) is detected from the content.
+// Will also parse fmt template keys and struct flags, the data is not trusted:
+// `$ref` references and `${VAR}` env vars are not resolved (see ParseBytesWithOptions).
+func UnmarshalBytes(data []byte, config interface{}) error {
+	if reflect.TypeOf(config).Kind() != reflect.Ptr {
+		return fmt.Errorf("the config argument should be a pointer: `%s`", reflect.TypeOf(config).String())
+	}
+
+	ext, err := sniffFormat(data)
+	if err != nil {
+		return err
+	}
+	p := newParser()
+	p.untrusted = true
+	return p.parseBytes(data, ext, config)
+}
+
+// ParseReader is ParseBytes, with the data read from r (eg.: stdin, HTTP bodies).
+func ParseReader(r io.Reader, format Format, config interface{}) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return ParseBytes(data, format, config)
+}
+
+// ParseBytes parse the config data into the config interface as a config file of the given format,
+// codecs registered formats are supported (eg.: `swap.Format("hcl")`).
+// Will also parse fmt template keys and struct flags, the data is not trusted:
+// `$ref` references and `${VAR}` env vars are not resolved (see ParseBytesWithOptions).
+func ParseBytes(data []byte, format Format, config interface{}) error {
+	return ParseBytesWithOptions(data, format, config, ParseOptions{})
+}
+
+// ParseBytesWithOptions is ParseBytes with the given options,
+// the Env and ReportConflicts options are ignored.
+// References are relative to the working directory and read from opts.FileSystem.
+func ParseBytesWithOptions(data []byte, format Format, config interface{}, opts ParseOptions) error {
+	if reflect.TypeOf(config).Kind() != reflect.Ptr {
+		return fmt.Errorf("the config argument should be a pointer: `%s`", reflect.TypeOf(config).String())
+	}
+
+	ext := "." + strings.TrimPrefix(strings.ToLower(string(format)), ".")
+	if !isValidExt(ext) {
+		return fmt.Errorf("unknown data format: '%s'", format)
+	}
+	p := opts.parser()
+	p.untrusted = !opts.TrustedData
+	return p.parseBytes(data, ext, config)
+}
+
+// parseBytes parse the data of the ext format into the config interface, with the struct flags.
+func (p *parser) parseBytes(data []byte, ext string, config interface{}) error {
+	if err := p.mergeData("data"+ext, data, config); err != nil {
+		return err
+	}
+	return p.parseTags(config)
+}
+
+// sniffFormat returns the extension of the data format, detected from the content.
+func sniffFormat(data []byte) (string, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return ".json", nil
+	}
+
+	if tomlTree, err := decodeTOMLTree(data); err == nil && len(tomlTree) > 0 {
+		return ".toml", nil
+	}
+
+	var yamlTree map[string]interface{}
+	if err := yaml.Unmarshal(data, &yamlTree); err == nil && len(yamlTree) > 0 {
+		return ".yaml", nil
+	}
+	return "", errors.New("unknown data format, YAML, TOML or JSON expected")
+}
+
+// ParseWithFS is Parse, searching and reading the config files in fsys.
+func ParseWithFS(fsys FileSystem, config interface{}, files ...string) (err error) {
+	return ParseByEnvWithFS(fsys, config, nil, files...)
+}
+
+// ParseByEnvWithFS is ParseByEnv, searching and reading the config files in fsys.
+func ParseByEnvWithFS(fsys FileSystem, config interface{}, env *Environment, files ...string) (err error) {
+	p := newParser()
+	p.fs = fsys
+	return p.parseByEnv(config, env, files...)
+}
+
+// ParseOptions are the per call parsing options.
+type ParseOptions struct {
+	// Env, if not nil, add the environment specific files as in ParseByEnv.
+	Env *Environment
+
+	// FileSystem is where the config files are searched and read,
+	// the local file system if nil.
+	FileSystem FileSystem
+
+	// WeakTyping, if not nil, normalize common value spellings
+	// (eg.: "yes" and "off" for bool fields) before decoding.
+	WeakTyping *WeakTyping
+
+	// ReportConflicts make the parsing fail with a *ConflictError if two
+	// same-priority files (eg.: two passed files) set the same key to different values,
+	// instead of silently keeping the last one.
+	ReportConflicts bool
+
+	// Strict make unknown keys in the config files an error.
+	Strict bool
+
+	// AgeIdentities decrypt the `!age:` prefixed values and the `age` flagged fields values.
+	AgeIdentities []age.Identity
+
+	// EncryptionKeyEnv is the env var holding the key decrypting the `enc:` prefixed values,
+	// DefaultEncryptionKeyEnv if empty.
+	EncryptionKeyEnv string
+
+	// EnvPrefix, if not empty, maps every field to the env var named after its path,
+	// without `env` flags (eg.: `APP_PG_PASSWORD` for `PG.Password` with the `APP` prefix),
+	// the env vars override the config files values.
+	EnvPrefix string
+
+	// SliceMerge is how the slices set by more files are merged (SliceMergeReplace by default),
+	// the `merge` flag (eg.: `swapcp:"merge=append"`) takes precedence.
+	SliceMerge SliceMerge
+
+	// LegacyTOML decodes the TOML files with github.com/BurntSushi/toml, as the former versions,
+	// instead of the TOML 1.0 compliant github.com/pelletier/go-toml/v2.
+	LegacyTOML bool
+
+	// ForceTemplates run the text/template pass on every file, as the former versions,
+	// instead of only on the files containing `{{` placeholders.
+	ForceTemplates bool
+
+	// DisableTemplates skip the text/template pass, placeholders are kept as they are.
+	DisableTemplates bool
+
+	// DisableTags skip the struct fields flags (eg.: `swapcp:"env=HOST,default=localhost"`),
+	// the files are just unmarshalled, as with the decoders of their formats.
+	DisableTags bool
+
+	// TrustedData resolve the `$ref` references and the `${VAR}` env vars
+	// in the data parsed by ParseBytesWithOptions, they are always resolved in the config files.
+	TrustedData bool
+}
+
+// ParseWithOptions parse the files into the config interface with the given options.
+func ParseWithOptions(config interface{}, opts ParseOptions, files ...string) (err error) {
+	return opts.parser().parseByEnv(config, opts.Env, files...)
+}
+
+// parser returns a config parser with the options.
+func (opts ParseOptions) parser() *parser {
+	p := newParser()
+	if opts.FileSystem != nil {
+		p.fs = opts.FileSystem
+	}
+	p.reportConflicts = opts.ReportConflicts
+	p.weakTyping = opts.WeakTyping
+	p.strict = opts.Strict
+	p.ageIdentities = opts.AgeIdentities
+	p.encryptionKeyEnv = opts.EncryptionKeyEnv
+	p.envPrefix = envPrefixName(opts.EnvPrefix)
+	p.sliceMerge = opts.SliceMerge
+	p.legacyTOML = opts.LegacyTOML
+	p.forceTemplates = opts.ForceTemplates
+	p.disableTemplates = opts.DisableTemplates
+	p.disableTags = opts.DisableTags
+	return p
+}
+
+// parser hold the parsing options.
+type parser struct {
+	// fs is where the config files are searched and read.
+	fs FileSystem
+
+	// tagKey is the struct field tag key, `swapcp` by default.
+	tagKey string
+
+	// caseSensitive determine the config files search mode.
+	caseSensitive bool
+
+	// recursive enable the config files search in sub-directories.
+	recursive bool
+
+	// strict make unknown keys an error.
+	strict bool
+
+	// reportConflicts make same-priority files conflicts an error.
+	reportConflicts bool
+
+	// weakTyping normalize the values spellings if not nil.
+	weakTyping *WeakTyping
+
+	// dotEnv are the dotenv files variables,
+	// the process env vars take precedence.
+	dotEnv map[string]string
+
+	// deriveEnv read the root fields from the env vars named after them,
+	// even without an env prefix.
+	deriveEnv bool
+
+	// envPrefix maps the fields without an `env` flag to the <envPrefix><FIELD> env vars.
+	envPrefix string
+
+	// ageIdentities decrypt the age encrypted values.
+	ageIdentities []age.Identity
+
+	// encryptionKeyEnv is the env var holding the AES-GCM key, DefaultEncryptionKeyEnv if empty.
+	encryptionKeyEnv string
+
+	// cliFlags are the command-line flags values by field path,
+	// overriding any other value.
+	cliFlags map[string]string
+
+	// pathOverrides are the Builder path overrides of the parsed config,
+	// set once the files are merged and the flags parsed.
+	pathOverrides []pathOverride
+
+	// profiles are the profile dimensions values, see ProfileHandler.
+	profiles []string
+
+	// filesPath is where the relative `fromfile` paths are resolved, the Builder config path.
+	filesPath string
+
+	// sliceMerge is how the slices set by more files are merged, if not set by the `merge` flag.
+	sliceMerge SliceMerge
+
+	// legacyTOML decodes the TOML files with the decoder of the former versions.
+	legacyTOML bool
+
+	// forceTemplates run the template pass on the files without placeholders too.
+	forceTemplates bool
+
+	// disableTemplates and disableTags skip the template pass and the fields flags.
+	disableTemplates bool
+	disableTags      bool
+
+	// untrusted data is parsed without resolving the references and the env vars,
+	// so that it can't read the local files or the process environment.
+	untrusted bool
+}
+
+func newParser() *parser {
+	return &parser{
+		fs:            NewFileSystemLocal(),
+		tagKey:        sftConfigKey,
+		caseSensitive: FileSearchCaseSensitive,
+	}
+}
+
+// parseByEnv is ParseByEnv with the parser options.
+func (p *parser) parseByEnv(config interface{}, env *Environment, files ...string) (err error) {
+	names := files
+	files, err = p.appendEnvFiles(env, files)
 	if err != nil {
 		return fmt.Errorf("no config file found for '%s': %s", strings.Join(files, " | "), err.Error())
 	}
@@ -76,16 +434,80 @@ func ParseByEnv(config interface{}, env *Environment, files ...string) (err erro
 		return fmt.Errorf("the config argument should be a pointer: `%s`", reflect.TypeOf(config).String())
 	}
 
+	if p.reportConflicts {
+		if err = p.checkConflicts(reflect.TypeOf(config), env, names); err != nil {
+			return err
+		}
+	}
+
 	for _, file := range files {
-		if err = unmarshalFile(file, config); err != nil {
+		var data []byte
+		if data, err = p.fs.ReadFile(file); err != nil {
 			return err
 		}
-		if err = parseTemplateFile(file, config); err != nil {
+		if err = p.mergeData(file, data, config); err != nil {
 			return err
 		}
 	}
 
-	return parseConfigTags(config)
+	if err = p.parseTags(config); err != nil {
+		return err
+	}
+	return p.applyPathOverrides(reflect.ValueOf(config))
+}
+
+// mergeData is parseData, the maps and the slices merged by append or unique
+// already set in the config are detached and merged with the data ones, see detachFields.
+func (p *parser) mergeData(file string, data []byte, config interface{}) (err error) {
+	detached, err := p.detachFields("", nil, reflect.ValueOf(config))
+	if err != nil {
+		return err
+	}
+	err = p.parseData(file, data, config)
+	var tree interface{}
+	if len(detached) > 0 {
+		// the explicit nulls are told apart in the decoded file, the typed maps can't hold them
+		tree, _ = decodeTree(file, data)
+	}
+	mergeFields(detached, tree)
+	return err
+}
+
+// parseTags parse the struct fields flags, unless disableTags is set.
+func (p *parser) parseTags(config interface{}) error {
+	if p.disableTags {
+		return nil
+	}
+	if err := p.parseConfigTags("", p.envPrefix, config); err != nil {
+		return err
+	}
+	return p.parseConditionalTags(reflect.ValueOf(config), "", reflect.ValueOf(config))
+}
+
+// parseData parse the file data into the config interface,
+// the struct flags are not parsed.
+func (p *parser) parseData(file string, data []byte, config interface{}) (err error) {
+	if multi, err := p.parseDocuments(file, data, config); multi {
+		return err
+	}
+	if data, err = p.resolveRefs(file, data); err != nil {
+		return err
+	}
+	if data, err = p.expandEnv(file, data, config); err != nil {
+		return err
+	}
+	if data, err = p.decryptValues(file, data, config); err != nil {
+		return err
+	}
+	if p.weakTyping != nil {
+		if data, err = p.normalize(file, data, config); err != nil {
+			return err
+		}
+	}
+	if err = p.unmarshalFile(file, data, config); err != nil {
+		return err
+	}
+	return p.parseTemplateFile(file, data, config)
 }
 
 // File search ---------------------------------------------------------------------------------------------------------
@@ -100,33 +522,54 @@ func ParseByEnv(config interface{}, env *Environment, files ...string) (err erro
 // The 'file' name will be searched as (in that order):
 //  - '<path>/<file>(.* || <the_provided_extension>)'
 //  - '<path>/<file>.<environment>(.* || <the_provided_extension>)'
+//  - '<path>/<file>.<environment>.<profile dimensions...>(.* || <the_provided_extension>)'
+//
+// Glob patterns (eg.: '<path>/conf.d/*.yaml') add all the matching files in lexical order.
+// Sub-directories are searched too for '<path>/**/<file>' or in recursive mode,
+// the files of a directory come before the sub-directories ones.
 //
 // The latest found files will override previous.
-func appendEnvFiles(env *Environment, files []string) (foundFiles []string, err error) {
+func (p *parser) appendEnvFiles(env *Environment, files []string) (foundFiles []string, err error) {
 	for _, file := range files {
 		configPath, fileName := filepath.Split(file)
+
+		// '<path>/**/<file>' search in all the sub-directories too
+		recursive := p.recursive
+		if trimmed := strings.TrimSuffix(filepath.ToSlash(configPath), recursiveDir); trimmed != filepath.ToSlash(configPath) {
+			configPath, recursive = filepath.FromSlash(trimmed), true
+		}
 		if len(configPath) == 0 {
 			configPath = "./"
 		}
 
+		var candidates []string
+		if candidates, err = p.listConfigPath(configPath, recursive); err != nil {
+			break
+		}
+
+		// glob patterns (eg.: conf.d/*.yaml) match all the files, in lexical order
+		if strings.ContainsAny(fileName, "*?[") {
+			var matchedFiles []string
+			if matchedFiles, err = p.globFiles(candidates, fileName); err != nil {
+				break
+			}
+			foundFiles = append(foundFiles, matchedFiles...)
+			continue
+		}
+
 		ext := filepath.Ext(fileName)
 		extTrimmed := strings.TrimSuffix(fileName, ext)
 		if len(ext) == 0 {
-			ext = regexpValidExt.String() // search for any compatible file
+			ext = validExtPattern() // search for any compatible file
 		}
 
 		format := "^%s%s$"
-		if !FileSearchCaseSensitive {
+		if !p.caseSensitive {
 			format = "(?i)(^%s)%s$"
 		}
 		// look for the config file in the config path (eg.: tool.yml)
 		regex := regexp.MustCompile(fmt.Sprintf(format, extTrimmed, ext))
-		var foundFile string
-		foundFile, err = walkConfigPath(configPath, regex)
-		if err != nil {
-			break
-		}
-		if len(foundFile) > 0 {
+		if foundFile := matchFiles(candidates, regex); len(foundFile) > 0 {
 			foundFiles = append(foundFiles, foundFile)
 		}
 
@@ -134,88 +577,171 @@ func appendEnvFiles(env *Environment, files []string) (foundFiles []string, err
 			// look for the env config file in the config path (eg.: tool.development.yml)
 			//regexEnv := regexp.MustCompile(fmt.Sprintf(format, fmt.Sprintf("%s.%s", extTrimmed, Env().ID()), ext))
 			regexEnv := regexp.MustCompile(fmt.Sprintf(format, fmt.Sprintf("%s.%s", extTrimmed, env.Tag()), ext))
-			foundFile, err = walkConfigPath(configPath, regexEnv)
-			if err != nil {
-				break
-			}
-			if len(foundFile) > 0 {
+			if foundFile := matchFiles(candidates, regexEnv); len(foundFile) > 0 {
 				foundFiles = append(foundFiles, foundFile)
 			}
+
+			// look for the profile config files (eg.: tool.production.eu-west-1.yml)
+			profileName := fmt.Sprintf("%s.%s", extTrimmed, env.Tag())
+			for _, value := range p.profiles {
+				profileName += "." + regexp.QuoteMeta(value)
+				regexProfile := regexp.MustCompile(fmt.Sprintf(format, profileName, ext))
+				if foundFile := matchFiles(candidates, regexProfile); len(foundFile) > 0 {
+					foundFiles = append(foundFiles, foundFile)
+				}
+			}
 		}
 	}
 
 	if err == nil && len(foundFiles) == 0 {
-		err = fmt.Errorf("no config file found for '%s'", strings.Join(files, " | "))
+		err = fmt.Errorf("%w for '%s'", errNoConfigFile, strings.Join(files, " | "))
 	}
 	return
 }
 
-// walkConfigPath look for a file matching the passed regex skipping sub-directories.
-func walkConfigPath(configPath string, regex *regexp.Regexp) (matchedFile string, err error) {
-	err = filepath.Walk(configPath, func(path string, info os.FileInfo, err error) error {
-		// nil if the path does not exist
-		if info == nil {
-			return filepath.SkipDir
+// recursiveDir is the path element which enable the recursive search.
+const recursiveDir = "**/"
+
+// listConfigPath returns the files in configPath, in lexical order,
+// then the sub-directories files (recursively) if recursive is true.
+func (p *parser) listConfigPath(configPath string, recursive bool) (files []string, err error) {
+	entries, err := p.fs.ReadDir(configPath)
+	if err != nil {
+		// nothing to match if the path does not exist
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
 		}
+		return nil, err
+	}
 
-		if info.IsDir() && info.Name() != filepath.Base(configPath) {
-			return filepath.SkipDir
+	var dirs []string
+	for _, entry := range entries {
+		switch {
+		case entry.Type().IsRegular():
+			files = append(files, filepath.Join(configPath, entry.Name()))
+		case entry.IsDir() && recursive:
+			dirs = append(dirs, filepath.Join(configPath, entry.Name()))
 		}
+	}
 
-		if !info.Mode().IsRegular() {
-			return nil
+	for _, dir := range dirs {
+		var subFiles []string
+		if subFiles, err = p.listConfigPath(dir, recursive); err != nil {
+			return nil, err
 		}
+		files = append(files, subFiles...)
+	}
+
+	return
+}
 
-		if regex.MatchString(info.Name()) {
-			matchedFile = path
+// matchFiles returns the last file whose name matches the passed regex.
+func matchFiles(files []string, regex *regexp.Regexp) (matchedFile string) {
+	for _, file := range files {
+		if regex.MatchString(filepath.Base(file)) {
+			matchedFile = file
 		}
+	}
+	return
+}
 
-		return nil
-	})
+// globFiles returns the files whose name matches the glob pattern.
+func (p *parser) globFiles(files []string, pattern string) (matchedFiles []string, err error) {
+	if _, err = filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %s", pattern, err.Error())
+	}
+	if !p.caseSensitive {
+		pattern = strings.ToLower(pattern)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		if !p.caseSensitive {
+			name = strings.ToLower(name)
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			matchedFiles = append(matchedFiles, file)
+		}
+	}
 
 	return
 }
 
 // File parse ----------------------------------------------------------------------------------------------------------
 
-func unmarshalFile(file string, config interface{}) (err error) {
-	var in []byte
-	if in, err = ioutil.ReadFile(file); err != nil {
+func (p *parser) unmarshalFile(file string, data []byte, config interface{}) (err error) {
+	ext := filepath.Ext(file)
+	if codec, isCodec := codecFor(file); isCodec {
+		// the registered codecs files are parsed as JSON
+		if data, err = p.codecToJSON(codec, data, config); err != nil {
+			return fmt.Errorf("%s: %s", file, err.Error())
+		}
+		ext = ".json"
+	}
+
+	data, values, err := p.parseTypedStrings(file, ext, data, config)
+	if err != nil {
 		return err
 	}
-	ext := filepath.Ext(file)
 
 	switch {
 	case regexpYAML.MatchString(ext):
-		err = unmarshalYAML(in, config)
+		err = p.unmarshalYAML(data, config)
 	case regexpTOML.MatchString(ext):
-		err = unmarshalTOML(in, config)
+		err = p.unmarshalTOML(data, config)
 	case regexpJSON.MatchString(ext):
-		err = unmarshalJSON(in, config)
+		err = p.unmarshalJSON(data, config)
 	default:
 		err = fmt.Errorf("unknown data format, can't unmarshal file: '%s'", file)
 	}
 
+	if err == nil {
+		err = setTypedValues(config, values)
+	}
+
+	if err != nil && p.strict {
+		err = fmt.Errorf("%s: %s", file, err.Error())
+	}
 	return
 }
 
-func unmarshalJSON(data []byte, config interface{}) (err error) {
-	return json.Unmarshal(data, config)
+func (p *parser) unmarshalJSON(data []byte, config interface{}) (err error) {
+	if !p.strict {
+		return currentJSONDecoder().Unmarshal(data, config)
+	}
+	return currentJSONDecoder().UnmarshalStrict(data, config)
 }
 
-func unmarshalTOML(data []byte, config interface{}) (err error) {
-	_, err = toml.Decode(string(data), config)
-	return err
+func (p *parser) unmarshalTOML(data []byte, config interface{}) (err error) {
+	return unmarshalTOMLData(data, config, p.strict, p.legacyTOML)
 }
 
-func unmarshalYAML(data []byte, config interface{}) (err error) {
-	return yaml.Unmarshal(data, config)
+func (p *parser) unmarshalYAML(data []byte, config interface{}) (err error) {
+	if !p.strict {
+		return yaml.Unmarshal(data, config)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(config); err == io.EOF {
+		return nil
+	}
+	return err
 }
 
+// templateMarker opens the text/template placeholders.
+const templateMarker = "{{"
+
 // parseTemplateFile parse all text/template placeholders
 // (eg.: {{.Key}}) in config files.
-func parseTemplateFile(file string, config interface{}) error {
-	tpl, err := template.ParseFiles(file)
+// Files without placeholders are not parsed again, unless forceTemplates is set,
+// no file is with disableTemplates.
+func (p *parser) parseTemplateFile(file string, data []byte, config interface{}) error {
+	if p.disableTemplates || (!p.forceTemplates && !bytes.Contains(data, []byte(templateMarker))) {
+		return nil
+	}
+
+	tpl, err := template.New(filepath.Base(file)).Parse(string(data))
 	if err != nil {
 		return err
 	}
@@ -225,24 +751,149 @@ func parseTemplateFile(file string, config interface{}) error {
 		return err
 	}
 
-	ext := filepath.Ext(file)
-
-	switch {
-	case regexpYAML.MatchString(ext):
-		return unmarshalYAML(buf.Bytes(), config)
-	case regexpTOML.MatchString(ext):
-		return unmarshalTOML(buf.Bytes(), config)
-	case regexpJSON.MatchString(ext):
-		return unmarshalJSON(buf.Bytes(), config)
-	default:
-		return fmt.Errorf("unknown data format, can't unmarshal file: '%s'", file)
+	if regexpTOML.MatchString(filepath.Ext(file)) && !p.legacyTOML {
+		// the decoder can't decode array tables into the slices held by existing maps,
+		// the maps set by the first pass are set again by the rendered data
+		resetMaps(reflect.ValueOf(config))
 	}
+
+	return p.unmarshalFile(file, buf.Bytes(), config)
 }
 
 // Flags parse ---------------------------------------------------------------------------------------------------------
 
-// parseConfigTags will process the struct field tags.
-func parseConfigTags(elem interface{}) error {
+// Validator is implemented by the config types which can validate themselves,
+// nested struct fields with the `validate` flag are validated after their tags are processed,
+// the swap policy types always are.
+type Validator interface {
+	Validate() error
+}
+
+// alwaysValidator is implemented by the Validator types
+// validated without the `validate` flag, as the swap policy types.
+type alwaysValidator interface {
+	alwaysValidate()
+}
+
+// validateField call Validate on struct and struct pointer fields implementing Validator,
+// if flagged to or always validated.
+func validateField(fv reflect.Value, flags []string) error {
+	var field interface{}
+	switch fv.Kind() {
+	case reflect.Struct:
+		field = fv.Addr().Interface()
+	case reflect.Ptr:
+		if fv.IsNil() {
+			return nil
+		}
+		field = fv.Interface()
+	}
+	if _, always := field.(alwaysValidator); !always && !hasFlag(flags, sffConfigValidate) {
+		return nil
+	}
+	if validator, ok := field.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// splitFlags returns the comma separated flags of the config tag,
+// the commas inside the brackets of the `regexp` (or `match`) expressions
+// are kept (eg.: `match=^[a-z]{3,8}$`), the other ones must be escaped (eg.: `\x2c`).
+func splitFlags(tag string) (flags []string) {
+	pieces := strings.Split(tag, ",")
+	for i := 0; i < len(pieces); i++ {
+		flag := pieces[i]
+		if strings.HasPrefix(flag, sffConfigRegexp+"=") || strings.HasPrefix(flag, sffConfigMatch+"=") {
+			for ; unclosedBrackets(flag) && i+1 < len(pieces); i++ {
+				flag += "," + pieces[i+1]
+			}
+		}
+		flags = append(flags, flag)
+	}
+	return flags
+}
+
+// unclosedBrackets returns true if the expression has unclosed
+// parentheses, braces or character classes, escaped characters are skipped.
+func unclosedBrackets(expr string) bool {
+	depth, class := 0, false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+			// a leading `]` (or `^]`) is a class character
+			if strings.HasPrefix(expr[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(expr[i+1:], "]") {
+				i++
+			}
+		case c == '(' || c == '{':
+			depth++
+		case c == ')' || c == '}':
+			depth--
+		}
+	}
+	return class || depth > 0
+}
+
+// flagValue returns the value of the key=value tag flag, if any.
+func flagValue(flags []string, key string) string {
+	for _, flag := range flags {
+		if kv := strings.SplitN(flag, "=", 2); kv[0] == key && len(kv) == 2 {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+// envKeyFromPath returns the env var name for the field path,
+// eg.: `PG_PASSWORD` for `PG.Password`, `SERVERS_0_HOST` for `Servers[0].Host`.
+func envKeyFromPath(path string) string {
+	key := strings.NewReplacer(".", "_", "[", "_", "]", "").Replace(path)
+	return strings.ToUpper(key)
+}
+
+// envPrefixName returns the env vars prefix with the `_` separator, eg.: `APP_` for `APP`.
+func envPrefixName(prefix string) string {
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return prefix
+}
+
+// hasFlag returns true if the tag flag is present, with or without value.
+func hasFlag(flags []string, key string) bool {
+	for _, flag := range flags {
+		if strings.SplitN(flag, "=", 2)[0] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isNestedConfig returns true for the struct (or struct pointer) fields
+// whose own fields are processed, instead of being decoded as a whole.
+func isNestedConfig(fv reflect.Value) bool {
+	t := fv.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	_, registered := decoderFor(t)
+	return t.Kind() == reflect.Struct && !reflect.PtrTo(t).Implements(textUnmarshalerType) &&
+		t != timeType && t != urlType && t != regexpType && !registered
+}
+
+// parseConfigTags will process the struct field tags,
+// path is the elem field path, errors are *FieldError.
+// If envPrefix is not empty the fields without an `env` flag
+// are read from the <envPrefix><FIELD> env vars.
+func (p *parser) parseConfigTags(path, envPrefix string, elem interface{}) error {
 	elemValue := reflect.Indirect(reflect.ValueOf(elem))
 
 	switch elemValue.Kind() {
@@ -261,48 +912,90 @@ func parseConfigTags(elem interface{}) error {
 				continue
 			}
 
-			tag := ft.Tag.Get(sftConfigKey)
-			tagFields := strings.Split(tag, ",")
+			fieldPath := joinFieldPath(path, ft.Name)
+			tag := ft.Tag.Get(p.tagKey)
+			tagFields := splitFlags(tag)
 			//fmt.Printf("\n%sProcessing FIELD: %s %s = %+v, tags: %s\n", indent, ft.Name, ft.Type.String(), fv.Interface(), tag)
+
+			// command-line flags override any other value
+			cliValue, fromCLI := p.cliFlags[fieldPath]
+			if fromCLI {
+				if err := p.decodeTagValue(cliValue, fv, tagFields); err != nil {
+					return &FieldError{Path: fieldPath, Tag: sffConfigFlag, Err: err}
+				}
+			}
+
+			fieldEnvPrefix := ""
+			if prefix := flagValue(tagFields, sffConfigEnvPrefix); len(prefix) > 0 {
+				fieldEnvPrefix = prefix
+			} else if len(envPrefix) > 0 || (p.deriveEnv && len(path) == 0) {
+				if isNestedConfig(fv) {
+					fieldEnvPrefix = envPrefix + strings.ToUpper(ft.Name) + "_"
+				} else if !hasFlag(tagFields, sffConfigEnv) && !fromCLI {
+					if value, _ := p.lookupEnv(envPrefix + strings.ToUpper(ft.Name)); len(value) > 0 {
+						if err := p.decodeTagValue(value, fv, tagFields); err != nil {
+							return &FieldError{Path: fieldPath, Tag: sffConfigEnv, Err: err}
+						}
+					}
+				}
+			}
+
 			for _, flag := range tagFields {
+				if fromCLI {
+					break
+				}
 
 				kv := strings.Split(flag, "=")
 
 				if kv[0] == sffConfigEnv {
+					// `env` without value, the name is derived from the field path
+					envKey := envKeyFromPath(fieldPath)
 					if len(kv) == 2 {
-						if value := os.Getenv(kv[1]); len(value) > 0 {
-							//debugPrintf("Loading configuration for struct `%v`'s field `%v` from env %v...\n", elemType.Name(), ft.Name, kv[1])
-							if err := yaml.Unmarshal([]byte(value), fv.Addr().Interface()); err != nil {
-								return err
-							}
+						envKey = kv[1]
+					}
+					if value, _ := p.lookupEnv(envKey); len(value) > 0 {
+						//debugPrintf("Loading configuration for struct `%v`'s field `%v` from env %v...\n", elemType.Name(), ft.Name, kv[1])
+						if err := p.decodeTagValue(value, fv, tagFields); err != nil {
+							return &FieldError{Path: fieldPath, Tag: sffConfigEnv, Err: err}
 						}
-					} else {
-						return fmt.Errorf("missing environment variable key value in tag: %s, must be someting like: `%s:\"env=env_var_name\"`",
-							sftConfigKey, flag)
+					}
+				}
+
+				if (kv[0] == sffConfigFile || kv[0] == sffConfigFromFile) && len(kv) > 1 {
+					if err := p.loadFileFlag(strings.SplitN(flag, "=", 2)[1], fv, tagFields, kv[0] == sffConfigFromFile); err != nil {
+						return &FieldError{Path: fieldPath, Tag: kv[0], Err: err}
 					}
 				}
 
 				if empty := reflect.DeepEqual(fv.Interface(), reflect.Zero(fv.Type()).Interface()); empty {
 					if kv[0] == sffConfigDefault {
 						if len(kv) == 2 {
-							if err := yaml.Unmarshal([]byte(kv[1]), fv.Addr().Interface()); err != nil {
-								return err
+							if err := p.decodeTagValue(kv[1], fv, tagFields); err != nil {
+								return &FieldError{Path: fieldPath, Tag: sffConfigDefault, Err: err}
 							}
 						} else {
-							return fmt.Errorf("missing default value in tag: %s, must be someting like: `%s:\"default=true\"`",
-								sftConfigKey, flag)
+							return &FieldError{Path: fieldPath, Tag: sffConfigDefault, Err: fmt.Errorf(
+								"missing default value in tag: %s, must be someting like: `%s:\"default=true\"`",
+								p.tagKey, flag)}
 						}
 					} else if kv[0] == sffConfigRequired {
-						return errors.New(ft.Name + " is required")
+						return &FieldError{Path: fieldPath, Tag: sffConfigRequired, Err: ErrRequired}
 					}
 				}
 			}
 
+			if err := checkConstraints(fieldPath, tagFields, fv, isSecretField(ft, tagFields)); err != nil {
+				return err
+			}
+
 			switch fv.Kind() {
 			case reflect.Ptr, reflect.Struct, reflect.Slice, reflect.Map:
-				if err := parseConfigTags(fv.Addr().Interface()); err != nil {
+				if err := p.parseConfigTags(fieldPath, fieldEnvPrefix, fv.Addr().Interface()); err != nil {
 					return err
 				}
+				if err := validateField(fv, tagFields); err != nil {
+					return &FieldError{Path: fieldPath, Err: err}
+				}
 			}
 
 			//fmt.Printf("%sProcessed  FIELD: %s %s = %+v\n", indent, ft.Name, ft.Type.String(), fv.Interface())
@@ -310,14 +1003,14 @@ func parseConfigTags(elem interface{}) error {
 
 	case reflect.Slice:
 		for i := 0; i < elemValue.Len(); i++ {
-			if err := parseConfigTags(elemValue.Index(i).Addr().Interface()); err != nil {
+			if err := p.parseConfigTags(fmt.Sprintf("%s[%d]", path, i), "", elemValue.Index(i).Addr().Interface()); err != nil {
 				return err
 			}
 		}
 
 	case reflect.Map:
 		for _, key := range elemValue.MapKeys() {
-			if err := parseConfigTags(elemValue.MapIndex(key).Interface()); err != nil {
+			if err := p.parseConfigTags(fmt.Sprintf("%s[%v]", path, key.Interface()), "", elemValue.MapIndex(key).Interface()); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,199 @@
+package swap
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Merge conflicts -----------------------------------------------------------------------------------------------------
+
+// Conflict is a key set to different values by same-priority files.
+type Conflict struct {
+	// Key is the dot separated key path (eg.: `db.host`).
+	Key string
+
+	// Values and Sources are the conflicting values and their files, in parsing order.
+	Values  []interface{}
+	Sources []string
+
+	// secret is true if the key maps to a secret field, the values are redacted.
+	secret bool
+}
+
+// ConflictError is returned when conflicts are reported.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (ce *ConflictError) Error() string {
+	conflicts := make([]string, len(ce.Conflicts))
+	for i, c := range ce.Conflicts {
+		values := make([]string, len(c.Values))
+		for j := range c.Values {
+			value := c.Values[j]
+			if c.secret || isSecretName(c.Key[strings.LastIndex(c.Key, ".")+1:]) {
+				value = redacted
+			}
+			values[j] = fmt.Sprintf("'%v' (%s)", value, c.Sources[j])
+		}
+		conflicts[i] = fmt.Sprintf("%s: %s", c.Key, strings.Join(values, " != "))
+	}
+	return "config conflicts: " + strings.Join(conflicts, "; ")
+}
+
+// checkConflicts returns a *ConflictError if the generic files
+// or the environment specific files of the different passed names
+// set the same key to different values.
+// Environment specific files intentionally override the generic ones.
+// The keys are mapped to the config type fields to tell the secret ones.
+func (p *parser) checkConflicts(config reflect.Type, env *Environment, names []string) error {
+	var generic, specific [][]string
+	for _, name := range names {
+		genericFiles, _ := p.appendEnvFiles(nil, []string{name})
+		generic = append(generic, genericFiles)
+
+		if env != nil {
+			allFiles, _ := p.appendEnvFiles(env, []string{name})
+			specific = append(specific, difference(allFiles, genericFiles))
+		}
+	}
+
+	var conflicts []Conflict
+	for _, group := range [][][]string{generic, specific} {
+		groupConflicts, err := p.groupConflicts(group)
+		if err != nil {
+			return err
+		}
+		conflicts = append(conflicts, groupConflicts...)
+	}
+
+	if len(conflicts) > 0 {
+		for i := range conflicts {
+			conflicts[i].secret = p.isSecretKey(config, conflicts[i].Key)
+		}
+		return &ConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// isSecretKey returns true if the dot separated file key maps to a secret field of t
+// (or to a field of a secret struct), see isSecretField.
+func (p *parser) isSecretKey(t reflect.Type, key string) bool {
+	for _, element := range strings.Split(key, ".") {
+		t = indirectType(t)
+		if t.Kind() == reflect.Map {
+			t = t.Elem()
+			continue
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+
+		found := false
+		for i := 0; !found && i < t.NumField(); i++ {
+			if sf := t.Field(i); len(sf.PkgPath) == 0 && fieldHasKey(sf, element) {
+				if isSecretField(sf, splitFlags(sf.Tag.Get(p.tagKey))) {
+					return true
+				}
+				t, found = sf.Type, true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return false
+}
+
+// groupConflicts compare the files found for every name,
+// keys are compared case-insensitively since YAML keys are lowercased by default.
+func (p *parser) groupConflicts(group [][]string) (conflicts []Conflict, err error) {
+	type setting struct {
+		key     string
+		values  []interface{}
+		sources []string
+		names   map[int]bool
+	}
+
+	settings := make(map[string]*setting)
+	var keys []string
+	for i, files := range group {
+		for _, file := range files {
+			var data []byte
+			if data, err = p.fs.ReadFile(file); err != nil {
+				return nil, err
+			}
+			if data, err = p.resolveRefs(file, data); err != nil {
+				return nil, err
+			}
+			var tree interface{}
+			if tree, err = decodeTree(file, data); err != nil {
+				return nil, err
+			}
+
+			for key, value := range flattenTree("", tree) {
+				lowerKey := strings.ToLower(key)
+				st, found := settings[lowerKey]
+				if !found {
+					st = &setting{key: key, names: make(map[int]bool)}
+					settings[lowerKey] = st
+					keys = append(keys, lowerKey)
+				}
+				st.values = append(st.values, value)
+				st.sources = append(st.sources, file)
+				st.names[i] = true
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		st := settings[key]
+		if len(st.names) < 2 {
+			continue
+		}
+		for _, value := range st.values[1:] {
+			if fmt.Sprint(value) != fmt.Sprint(st.values[0]) {
+				conflicts = append(conflicts, Conflict{Key: st.key, Values: st.values, Sources: st.sources})
+				break
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// flattenTree returns the tree leaves by dot separated key path.
+func flattenTree(prefix string, node interface{}) map[string]interface{} {
+	leaves := make(map[string]interface{})
+	if m, isMap := node.(map[string]interface{}); isMap && len(m) > 0 {
+		for k, v := range m {
+			for key, value := range flattenTree(joinFieldPath(prefix, k), v) {
+				leaves[key] = value
+			}
+		}
+		return leaves
+	}
+	if len(prefix) > 0 {
+		leaves[prefix] = node
+	}
+	return leaves
+}
+
+// difference returns the a elements not in b.
+func difference(a, b []string) (diff []string) {
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, x)
+		}
+	}
+	return
+}
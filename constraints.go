@@ -0,0 +1,140 @@
+package swap
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tag constraints -----------------------------------------------------------------------------------------------------
+
+// checkConstraints verify the `min`, `max`, `regexp` (or `match`) and `oneof`
+// flags of a field once its value is final (files, env and default merged).
+// min and max apply to numbers, durations and to the length of strings, slices and maps.
+// The values of secret fields are redacted in the errors.
+func checkConstraints(fieldPath string, flags []string, fv reflect.Value, secret bool) error {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	for _, flag := range flags {
+		kv := strings.SplitN(flag, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		var err error
+		switch kv[0] {
+		case sffConfigMin:
+			err = checkBound(fv, kv[1], true, hasFlag(flags, sffConfigBytes), secret)
+		case sffConfigMax:
+			err = checkBound(fv, kv[1], false, hasFlag(flags, sffConfigBytes), secret)
+		case sffConfigRegexp, sffConfigMatch:
+			err = checkRegexp(fv, kv[1], secret)
+		case sffConfigOneOf:
+			err = checkOneOf(fv, kv[1], secret)
+		default:
+			continue
+		}
+		if err != nil {
+			return &FieldError{Path: fieldPath, Tag: kv[0], Err: err}
+		}
+	}
+	return nil
+}
+
+// checkBound verify the min (or max) bound of the value,
+// bytes is true for the byte size bounds (eg.: `max=10MB`).
+func checkBound(fv reflect.Value, bound string, min, bytes, secret bool) error {
+	var value, limit float64
+	var err error
+
+	switch {
+	case fv.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(bound)
+		value, limit = float64(fv.Int()), float64(d)
+	case bytes && isIntegerKind(fv.Kind()):
+		var size int64
+		size, err = parseByteSize(bound)
+		if limit = float64(size); fv.Kind() >= reflect.Uint && fv.Kind() <= reflect.Uint64 {
+			value = float64(fv.Uint())
+		} else {
+			value = float64(fv.Int())
+		}
+	default:
+		switch fv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			value = float64(fv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value = float64(fv.Uint())
+		case reflect.Float32, reflect.Float64:
+			value = fv.Float()
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			value = float64(fv.Len())
+		default:
+			return fmt.Errorf("bounds not supported for type %s", fv.Type())
+		}
+		limit, err = strconv.ParseFloat(bound, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid bound '%s': %s", bound, err.Error())
+	}
+
+	subject := "value"
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		subject = "length"
+	}
+
+	if min && value < limit {
+		return fmt.Errorf("%s must be greater than or equal to %s, got %v", subject, bound, describe(fv, subject, secret))
+	}
+	if !min && value > limit {
+		return fmt.Errorf("%s must be less than or equal to %s, got %v", subject, bound, describe(fv, subject, secret))
+	}
+	return nil
+}
+
+// checkRegexp verify that the string value match the expression.
+func checkRegexp(fv reflect.Value, expr string, secret bool) error {
+	if fv.Kind() != reflect.String {
+		return fmt.Errorf("regexp not supported for type %s", fv.Type())
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid regexp '%s': %s", expr, err.Error())
+	}
+	if !re.MatchString(fv.String()) {
+		return fmt.Errorf("value '%v' does not match '%s'", describe(fv, "value", secret), expr)
+	}
+	return nil
+}
+
+// checkOneOf verify that the value is one of the `|` separated options.
+func checkOneOf(fv reflect.Value, options string, secret bool) error {
+	value := fmt.Sprint(fv.Interface())
+	for _, option := range strings.Split(options, "|") {
+		if value == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("value '%v' must be one of: %s", describe(fv, "value", secret), strings.Replace(options, "|", ", ", -1))
+}
+
+// describe returns the value (or its length) for the error messages.
+func describe(fv reflect.Value, subject string, secret bool) interface{} {
+	if subject == "length" {
+		return fv.Len()
+	}
+	if secret {
+		return redacted
+	}
+	return fv.Interface()
+}
@@ -0,0 +1,208 @@
+package swap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Consul KV FileSystem ------------------------------------------------------------------------------------------------
+
+// ConsulConfig is the Consul KV FileSystem configuration.
+type ConsulConfig struct {
+	// Address is the Consul HTTP API address,
+	// `CONSUL_HTTP_ADDR` or `http://127.0.0.1:8500` by default.
+	Address string
+
+	// Token is the ACL token, `CONSUL_HTTP_TOKEN` by default.
+	Token string
+
+	// Datacenter, the agent one if empty.
+	Datacenter string
+
+	// Prefix is the KV prefix (eg.: `myapp/config`) mounted at ConfigPath,
+	// so that the `myapp/config/Tool` key is the `<ConfigPath>/Tool.yaml` file.
+	Prefix     string
+	ConfigPath string
+
+	// Format is the extension of the keys without one, `yaml` by default.
+	Format string
+
+	// Client is the HTTP client, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// ConsulFileSystem is the FileSystem backed by the Consul KV store,
+// the `Tool.<env>` keys override the `Tool` one as environment specific files do.
+type ConsulFileSystem struct {
+	config ConsulConfig
+}
+
+// NewFileSystemConsul returns the FileSystem backed by the Consul KV store.
+func NewFileSystemConsul(config ConsulConfig) *ConsulFileSystem {
+	if len(config.Address) == 0 {
+		config.Address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if len(config.Address) == 0 {
+		config.Address = "http://127.0.0.1:8500"
+	}
+	if !strings.Contains(config.Address, "://") {
+		config.Address = "http://" + config.Address
+	}
+	if len(config.Token) == 0 {
+		config.Token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if len(config.Format) == 0 {
+		config.Format = "yaml"
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &ConsulFileSystem{config: config}
+}
+
+// ReadDir returns the keys and sub-prefixes under the named directory.
+func (c *ConsulFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	key, err := c.key("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	dirPrefix := key + "/"
+	if len(key) == 0 {
+		dirPrefix = ""
+	}
+
+	query := url.Values{"keys": {""}, "separator": {"/"}}
+	data, _, err := c.get(context.Background(), dirPrefix, query)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	var keys []string
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(keys))
+	for _, k := range keys {
+		entryName := strings.TrimPrefix(k, dirPrefix)
+		if len(entryName) == 0 {
+			continue
+		}
+		if strings.HasSuffix(entryName, "/") {
+			entries = append(entries, memDirEntry{name: strings.TrimSuffix(entryName, "/"), dir: true})
+			continue
+		}
+		if !isValidExt(path.Ext(entryName)) {
+			entryName += "." + c.config.Format
+		}
+		entries = append(entries, memDirEntry{name: entryName})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile returns the value of the key.
+func (c *ConsulFileSystem) ReadFile(name string) ([]byte, error) {
+	key, err := c.key("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := c.get(context.Background(), key, url.Values{"raw": {""}})
+	if err == fs.ErrNotExist && path.Ext(key) == "."+c.config.Format {
+		// the key without extension
+		data, _, err = c.get(context.Background(), strings.TrimSuffix(key, path.Ext(key)), url.Values{"raw": {""}})
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Watch block until ctx is done, calling onChange every time
+// a key under the prefix changes (eg.: to Build or Reload the tools),
+// it uses the Consul blocking queries.
+func (c *ConsulFileSystem) Watch(ctx context.Context, onChange func()) error {
+	var index string
+	for {
+		query := url.Values{"keys": {""}, "wait": {"5m"}}
+		if len(index) > 0 {
+			query.Set("index", index)
+		}
+
+		_, next, err := c.get(ctx, c.config.Prefix, query)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && err != fs.ErrNotExist {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		if len(index) > 0 && next != index {
+			onChange()
+		}
+		index = next
+	}
+}
+
+// key returns the KV key of the named file.
+func (c *ConsulFileSystem) key(op, name string) (string, error) {
+	rel, err := mountedPath(ioFSPath(c.config.ConfigPath), op, name)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return c.config.Prefix, nil
+	}
+	return strings.TrimPrefix(c.config.Prefix+"/"+rel, "/"), nil
+}
+
+// get query the KV endpoint, it returns the response body and the Consul index.
+func (c *ConsulFileSystem) get(ctx context.Context, key string, query url.Values) ([]byte, string, error) {
+	if len(c.config.Datacenter) > 0 {
+		query.Set("dc", c.config.Datacenter)
+	}
+	endpoint := strings.TrimSuffix(c.config.Address, "/") + "/v1/kv/" + key + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(c.config.Token) > 0 {
+		req.Header.Set("X-Consul-Token", c.config.Token)
+	}
+
+	resp, err := c.config.Client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	index := resp.Header.Get("X-Consul-Index")
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, index, fs.ErrNotExist
+	case resp.StatusCode != http.StatusOK:
+		return nil, index, fmt.Errorf("consul: unexpected status: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	return data, index, err
+}
@@ -0,0 +1,52 @@
+package swap
+
+import (
+	"reflect"
+)
+
+// Graceful degradation ------------------------------------------------------------------------------------------------
+
+// buildField build a struct sub-field, if it fails and the field
+// has the `degrade` tag the error is recorded, its already built sub-tools
+// are shut down and the field is reset to its zero value,
+// so that Build can go on with reduced functionality.
+func (s *Builder) buildField(path string, sf *reflect.StructField, fv reflect.Value, level int) (logs []string, err error) {
+	if logs, err = s.build(path, sf, fv, level); err == nil || !s.parseTags(sf).degrade {
+		return logs, err
+	}
+
+	s.unrecord(path)
+	fv.Set(reflect.Zero(fv.Type()))
+
+	s.unavailableMutex.Lock()
+	defer s.unavailableMutex.Unlock()
+	if s.unavailable == nil {
+		s.unavailable = make(map[string]error)
+	}
+	s.unavailable[path] = err
+
+	return []string{s.logField(path, sf, stateDegraded, err, level, nil)}, nil
+}
+
+// Unavailable returns the errors of the `degrade` tagged fields
+// which failed during the last Build, by field path (eg.: `Services.Mailer`).
+func (s *Builder) Unavailable() map[string]error {
+	s.unavailableMutex.Lock()
+	defer s.unavailableMutex.Unlock()
+
+	unavailable := make(map[string]error, len(s.unavailable))
+	for path, err := range s.unavailable {
+		unavailable[path] = err
+	}
+	return unavailable
+}
+
+// Available returns false if the field at the given path
+// has been degraded during the last Build.
+func (s *Builder) Available(path string) bool {
+	s.unavailableMutex.Lock()
+	defer s.unavailableMutex.Unlock()
+
+	_, degraded := s.unavailable[path]
+	return !degraded
+}
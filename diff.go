@@ -0,0 +1,92 @@
+package swap
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Environments diff ---------------------------------------------------------------------------------------------------
+
+// ConfigDiff is a config key set to different values by two environments.
+type ConfigDiff struct {
+	// Key is the dot separated key path (eg.: `db.host`).
+	Key string
+
+	// A and B are the values in the two environments, nil if unset,
+	// the secret values are redacted.
+	A, B interface{}
+}
+
+func (cd ConfigDiff) String() string {
+	return fmt.Sprintf("%s: '%v' != '%v'", cd.Key, cd.A, cd.B)
+}
+
+// DiffEnvs parse the files of both environments (see ParseByEnv) into new
+// values of the config type, and returns the keys set to different values, in lexical order,
+// eg.: to review what production changes relative to staging.
+// Keys are named as in Dump, the config itself is not modified.
+func DiffEnvs(config interface{}, envA, envB *Environment, files ...string) ([]ConfigDiff, error) {
+	t := reflect.TypeOf(config)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("the config argument should be a pointer: `%v`", t)
+	}
+
+	var leaves [2]struct{ revealed, redacted map[string]interface{} }
+	for i, env := range []*Environment{envA, envB} {
+		v := reflect.New(t.Elem())
+		if err := newParser().parseByEnv(v.Interface(), env, files...); err != nil {
+			// the nil environments are named as the ConfigDiff values
+			label := []string{"A", "B"}[i]
+			if env != nil {
+				label = env.Tag()
+			}
+			return nil, fmt.Errorf("%s: %s", label, err.Error())
+		}
+
+		var err error
+		if leaves[i].revealed, err = dumpLeaves(v, true); err != nil {
+			return nil, err
+		}
+		if leaves[i].redacted, err = dumpLeaves(v, false); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make(map[string]bool)
+	for i := range leaves {
+		for key := range leaves[i].revealed {
+			keys[key] = true
+		}
+	}
+
+	var diffs []ConfigDiff
+	for key := range keys {
+		a, aFound := leaves[0].revealed[key]
+		b, bFound := leaves[1].revealed[key]
+		if aFound == bFound && fmt.Sprint(a) == fmt.Sprint(b) {
+			continue
+		}
+		diffs = append(diffs, ConfigDiff{Key: key, A: leaves[0].redacted[key], B: leaves[1].redacted[key]})
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs, nil
+}
+
+// dumpLeaves returns the dumped v leaves by dot separated key path,
+// the secret values are redacted unless reveal is true.
+func dumpLeaves(v reflect.Value, reveal bool) (map[string]interface{}, error) {
+	d := dumper{visited: make(map[uintptr]bool), reveal: reveal}
+	node, err := d.node(v, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree interface{}
+	if err = node.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return flattenTree("", tree), nil
+}
@@ -0,0 +1,115 @@
+package swap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML multi-document streams -----------------------------------------------------------------------------------------
+
+// splitDocuments returns the documents of a `---` separated YAML stream, empty ones excluded.
+func splitDocuments(data []byte) ([][]byte, error) {
+	var documents [][]byte
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); errors.Is(err, io.EOF) {
+			return documents, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+
+		document, err := yaml.Marshal(&node)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// parseDocuments parse the documents of a YAML stream in order, as consecutive files,
+// the documents matching the `document` flag of a root struct field are parsed into that field,
+// or appended to it for slice fields.
+// It returns false if the data is not a multi-document YAML stream.
+func (p *parser) parseDocuments(file string, data []byte, config interface{}) (bool, error) {
+	if !regexpYAML.MatchString(filepath.Ext(file)) || !bytes.Contains(data, []byte("---")) {
+		return false, nil
+	}
+
+	documents, err := splitDocuments(data)
+	if err != nil || len(documents) < 2 {
+		return false, nil
+	}
+
+	bound := p.documentFields(config)
+	for _, document := range documents {
+		field, found := bound[documentSelector(document)]
+		if !found {
+			field, found = bound[strings.SplitN(documentSelector(document), "/", 2)[0]]
+		}
+
+		switch {
+		case !found:
+			err = p.parseData(file, document, config)
+		case field.Kind() == reflect.Slice:
+			// every matching document is an item
+			item := reflect.New(field.Type().Elem())
+			if err = p.parseData(file, document, item.Interface()); err == nil {
+				field.Set(reflect.Append(field, item.Elem()))
+			}
+		default:
+			err = p.parseData(file, document, field.Addr().Interface())
+		}
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// documentFields returns the root struct fields with a `document` flag, by selector.
+func (p *parser) documentFields(config interface{}) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		ft := v.Type().Field(i)
+		fv := v.Field(i)
+		if !fv.CanAddr() || !fv.CanInterface() {
+			continue
+		}
+		if selector := flagValue(splitFlags(ft.Tag.Get(p.tagKey)), sffConfigDocument); len(selector) > 0 {
+			fields[selector] = fv
+		}
+	}
+	return fields
+}
+
+// documentSelector returns the `<kind>/<metadata.name>` selector of a Kubernetes-style document.
+func documentSelector(document []byte) string {
+	var header struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal(document, &header); err != nil || len(header.Kind) == 0 {
+		return ""
+	}
+	if len(header.Metadata.Name) == 0 {
+		return header.Kind
+	}
+	return header.Kind + "/" + header.Metadata.Name
+}
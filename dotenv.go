@@ -0,0 +1,166 @@
+package swap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Dotenv files --------------------------------------------------------------------------------------------------------
+
+// WithDotEnv set the dotenv files (eg.: `.env`, `.env.local`) whose variables
+// are available to the `env` flags and the `${VAR}` references
+// parsed by the builder, without exporting them to the process.
+// Missing files are ignored, the latest files override the former
+// while the process environment variables always take precedence.
+func (s *Builder) WithDotEnv(files ...string) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.dotEnvFiles = files
+	return s
+}
+
+// loadDotEnv returns the variables of the builder dotenv files.
+func (s *Builder) loadDotEnv() (map[string]string, error) {
+	if len(s.dotEnvFiles) == 0 {
+		return nil, nil
+	}
+
+	vars := make(map[string]string)
+	for _, file := range s.dotEnvFiles {
+		data, err := s.fs.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if err = parseDotEnv(data, vars); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err.Error())
+		}
+	}
+	return vars, nil
+}
+
+// LoadDotEnv loads the variables of the dotenv files (eg.: `.env`, `.env.local`)
+// into the process environment, so that the `env` flags, the `${VAR}` references
+// and the environment detection (eg.: `BUILD_ENV`) work the same locally as in production.
+// Missing files are ignored, the latest files override the former
+// while the process environment variables always take precedence.
+func LoadDotEnv(files ...string) error {
+	return exportDotEnv(NewFileSystemLocal(), files, nil)
+}
+
+// LoadDotEnv make Build and Parse load the dotenv files, followed by their
+// environment specific ones (eg.: `.env.production`), into the process environment,
+// before the environment detection and the config parsing, see the package level LoadDotEnv.
+func (s *Builder) LoadDotEnv(files ...string) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.exportedDotEnvFiles = files
+	return s
+}
+
+// dotEnvExported are the variables set by exportDotEnv, with their values.
+var dotEnvExported = struct {
+	sync.Mutex
+	vars map[string]string
+}{vars: make(map[string]string)}
+
+// exportDotEnv set the variables of the dotenv files in the process environment, then,
+// if current is not nil, the variables of their current environment specific files.
+func exportDotEnv(fsys FileSystem, files []string, current func() *Environment) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	dotEnvExported.Lock()
+	defer dotEnvExported.Unlock()
+
+	// the process variables set before loading take precedence,
+	// the ones exported by a former load and not changed since are updated
+	preset := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		kv := strings.SplitN(kv, "=", 2)
+		if value, exported := dotEnvExported.vars[kv[0]]; !exported || len(kv) < 2 || value != kv[1] {
+			preset[kv[0]] = true
+		}
+	}
+
+	vars := make(map[string]string)
+	export := func(files []string) error {
+		for _, file := range files {
+			data, err := fsys.ReadFile(file)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return err
+			}
+			if err = parseDotEnv(data, vars); err != nil {
+				return fmt.Errorf("%s: %s", file, err.Error())
+			}
+		}
+		for key, value := range vars {
+			if !preset[key] {
+				if err := os.Setenv(key, value); err != nil {
+					return err
+				}
+				dotEnvExported.vars[key] = value
+			}
+		}
+		return nil
+	}
+
+	if err := export(files); err != nil || current == nil {
+		return err
+	}
+
+	// the environment can be detected by the loaded variables
+	envFiles := make([]string, len(files))
+	for i, file := range files {
+		envFiles[i] = file + "." + current().Tag()
+	}
+	return export(envFiles)
+}
+
+// parseDotEnv parse the `KEY=value` lines into vars,
+// supporting comments, the `export` prefix, single (literal)
+// and double quoted (with escape sequences) values.
+func parseDotEnv(data []byte, vars map[string]string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+
+		kv := strings.SplitN(text, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || len(key) == 0 || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("line %d: invalid variable, must be like `KEY=value`", line)
+		}
+
+		value := strings.TrimSpace(kv[1])
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && strings.LastIndexByte(value, '\'') > 0:
+			value = value[1:strings.LastIndexByte(value, '\'')]
+		case len(value) >= 2 && value[0] == '"' && strings.LastIndexByte(value, '"') > 0:
+			value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).
+				Replace(value[1:strings.LastIndexByte(value, '"')])
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,312 @@
+package swap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config dump ---------------------------------------------------------------------------------------------------------
+
+// Format is a config serialization format.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// Dump returns the config serialized in the given format, eg.: the fully merged
+// and tag-resolved config, so that operators can see what the app actually loaded.
+// The secret values are redacted: the fields with the `secret` flag, Secret fields and
+// the fields or map keys with password-like names (eg.: `DBPassword`, `api_key`),
+// so that the effective config can be safely logged.
+// Functions and channels are omitted, as the cyclic pointers.
+func Dump(config interface{}, format Format) ([]byte, error) {
+	d := dumper{visited: make(map[uintptr]bool)}
+	node, err := d.node(reflect.ValueOf(config), false)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err = encoder.Encode(node); err != nil {
+			return nil, err
+		}
+		err = encoder.Close()
+		return buf.Bytes(), err
+
+	case FormatJSON:
+		var buf bytes.Buffer
+		if err = writeJSONNode(&buf, node); err != nil {
+			return nil, err
+		}
+		var indented bytes.Buffer
+		if err = json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+			return nil, err
+		}
+		indented.WriteByte('\n')
+		return indented.Bytes(), nil
+
+	case FormatTOML:
+		var tree map[string]interface{}
+		if err = node.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("toml: the config is not a table: %s", err.Error())
+		}
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(withoutNulls(tree))
+		return buf.Bytes(), err
+
+	default:
+		return nil, fmt.Errorf("unknown dump format: '%s'", format)
+	}
+}
+
+// DumpToolBoxConfig returns the config of the toolbox of the last Build,
+// serialized in the given format with the secret values redacted, see Dump.
+func (s *Builder) DumpToolBoxConfig(format Format) ([]byte, error) {
+	s.mutex.Lock()
+	toolBox := s.toolBox
+	s.mutex.Unlock()
+
+	if toolBox == nil {
+		return nil, errors.New("no toolbox built")
+	}
+	return Dump(toolBox, format)
+}
+
+// dumper builds the YAML nodes of the dumped values.
+type dumper struct {
+	// visited are the pointers being dumped, to stop the cycles.
+	visited map[uintptr]bool
+
+	// reveal disable the secret values redaction.
+	reveal bool
+}
+
+// node returns the YAML node of v, redacted if secret.
+func (d dumper) node(v reflect.Value, secret bool) (*yaml.Node, error) {
+	// only the pointers of the current branch are visited,
+	// the ones shared by more fields are dumped every time
+	var marked []uintptr
+	defer func() {
+		for _, p := range marked {
+			delete(d.visited, p)
+		}
+	}()
+
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return scalarNode(nil)
+		}
+		if v.Kind() == reflect.Ptr {
+			if d.visited[v.Pointer()] {
+				return scalarNode(nil)
+			}
+			d.visited[v.Pointer()] = true
+			marked = append(marked, v.Pointer())
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return scalarNode(nil)
+	}
+
+	switch {
+	case d.reveal && v.Type() == secretType:
+		return scalarNode(v.Interface().(Secret).Reveal())
+	case d.reveal && v.Type().Implements(secretValueType):
+		return d.node(v.MethodByName("Value").Call(nil)[0], false)
+	case !d.reveal && (secret || v.Type() == secretType || v.Type().Implements(secretValueType)):
+		if v.IsZero() {
+			return scalarNode("")
+		}
+		return scalarNode(redacted)
+	}
+
+	switch {
+	case v.Type() == durationType:
+		return scalarNode(v.Interface().(fmt.Stringer).String())
+	case v.Type() == urlType:
+		u := v.Interface().(url.URL)
+		return scalarNode(u.Redacted())
+	case v.Type() == regexpType:
+		return scalarNode(addressable(v).Interface().(*regexp.Regexp).String())
+	case reflect.PtrTo(v.Type()).Implements(textMarshalerType):
+		text, err := addressable(v).Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return scalarNode(string(text))
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			key := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if len(sf.PkgPath) > 0 || key == "-" || !dumpable(sf.Type) {
+				continue
+			}
+			if len(key) == 0 {
+				key = strings.ToLower(sf.Name)
+			}
+			value, err := d.node(v.Field(i), isSecretField(sf, splitFlags(sf.Tag.Get(sftConfigKey))))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+		}
+		return node, nil
+
+	case reflect.Map:
+		if !dumpable(v.Type().Elem()) {
+			return scalarNode(nil)
+		}
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(key.Interface()))
+			values[keys[len(keys)-1]] = v.MapIndex(key)
+		}
+		sort.Strings(keys)
+
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range keys {
+			value, err := d.node(values[key], isSecretName(key))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+		}
+		return node, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return scalarNode(v.Interface())
+		}
+		if !dumpable(v.Type().Elem()) {
+			return scalarNode(nil)
+		}
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			value, err := d.node(v.Index(i), false)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		return node, nil
+
+	default:
+		return scalarNode(v.Interface())
+	}
+}
+
+// dumpable returns false for the types without a serialized form, functions and channels.
+func dumpable(t reflect.Type) bool {
+	switch indirectType(t).Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	}
+	return true
+}
+
+// scalarNode returns the YAML node of the scalar value.
+func scalarNode(value interface{}) (*yaml.Node, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err = yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return document.Content[0], nil
+}
+
+// addressable returns the pointer to v, or to its copy.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr
+}
+
+// writeJSONNode writes the node as JSON, keeping the keys order.
+func writeJSONNode(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSONNode(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	default:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return nil
+}
+
+// withoutNulls returns the tree without the null values, TOML has no null.
+func withoutNulls(tree map[string]interface{}) map[string]interface{} {
+	for k, v := range tree {
+		switch value := v.(type) {
+		case nil:
+			delete(tree, k)
+		case map[string]interface{}:
+			tree[k] = withoutNulls(value)
+		}
+	}
+	return tree
+}
@@ -0,0 +1,193 @@
+package swap
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Encrypted values ----------------------------------------------------------------------------------------------------
+
+// decryptValues returns the file data with the age and AES-GCM encrypted values decrypted,
+// data is untouched if there is nothing to decrypt.
+func (p *parser) decryptValues(file string, data []byte, config interface{}) ([]byte, error) {
+	if len(p.ageIdentities) == 0 && !bytes.Contains(data, []byte(agePrefix)) && !bytes.Contains(data, []byte(encPrefix)) {
+		return data, nil
+	}
+
+	tree, err := decodeTree(file, data)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	if tree, err = p.decryptNode(tree, reflect.TypeOf(config), false, &changed); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	if !changed {
+		return data, nil
+	}
+	return encodeTree(file, tree)
+}
+
+// decryptNode walks the node along with the config type decrypting the string values,
+// flagged is true for the values of `age` flagged fields.
+func (p *parser) decryptNode(node interface{}, t reflect.Type, flagged bool, changed *bool) (interface{}, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var err error
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			var vt reflect.Type
+			vFlagged := false
+			if t != nil && t.Kind() == reflect.Struct {
+				if sf, found := matchField(t, k); found {
+					vt = sf.Type
+					vFlagged = hasFlag(splitFlags(sf.Tag.Get(p.tagKey)), sffConfigAge)
+				}
+			} else if t != nil && t.Kind() == reflect.Map {
+				vt = t.Elem()
+			}
+			if n[k], err = p.decryptNode(v, vt, vFlagged, changed); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		var vt reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			vt = t.Elem()
+		}
+		for i, v := range n {
+			if n[i], err = p.decryptNode(v, vt, flagged, changed); err != nil {
+				return nil, err
+			}
+		}
+	case string:
+		plain, decrypted, err := p.decrypt(n, flagged)
+		if err != nil || !decrypted {
+			return n, err
+		}
+		*changed = true
+		if t != nil {
+			converter := normalizer{wt: &WeakTyping{}}
+			if converted, ok := converter.convert(plain, t); ok {
+				return converted, nil
+			}
+		}
+		return plain, nil
+	}
+	return node, nil
+}
+
+// decodeTagValue decrypts the `env`, `file` and `default` tags value
+// if needed, then decodes it into fv.
+func (p *parser) decodeTagValue(value string, fv reflect.Value, flags []string) error {
+	plain, _, err := p.decrypt(value, hasFlag(flags, sffConfigAge))
+	if err != nil {
+		return err
+	}
+	return unmarshalTagValue(plain, fv, flags)
+}
+
+// decrypt returns the plaintext of the `!age:` or `enc:` prefixed value,
+// ageFlagged is true for the values of `age` flagged fields,
+// the value is returned as is if not encrypted.
+func (p *parser) decrypt(value string, ageFlagged bool) (plain string, decrypted bool, err error) {
+	switch {
+	case ageFlagged || strings.HasPrefix(value, agePrefix):
+		plain, err = p.decryptAge(value)
+	case strings.HasPrefix(value, encPrefix):
+		plain, err = p.decryptAES(value)
+	default:
+		return value, false, nil
+	}
+	return plain, err == nil, err
+}
+
+// AES-GCM encrypted values --------------------------------------------------------------------------------------------
+
+// encPrefix marks the AES-GCM encrypted values, followed by the base64 of the nonce and the ciphertext,
+// eg.: `password: "enc:3q2+7wAAAAAAAAAA..."`.
+const encPrefix = "enc:"
+
+// DefaultEncryptionKeyEnv is the env var holding the base64 encoded AES key
+// (16, 24 or 32 bytes) decrypting the `enc:` prefixed values.
+const DefaultEncryptionKeyEnv = "SWAP_ENCRYPTION_KEY"
+
+// WithEncryptionKeyEnv set the env var holding the key decrypting,
+// in Parse, the `enc:` prefixed values, DefaultEncryptionKeyEnv by default.
+func (s *Builder) WithEncryptionKeyEnv(name string) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.encryptionKeyEnv = name
+	return s
+}
+
+// Encrypt returns the `enc:` prefixed value of plaintext, encrypted with
+// the AES-GCM key (16, 24 or 32 bytes), to be pasted in the config files.
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptAES returns the plaintext of the `enc:` prefixed value,
+// with the key of the encryption key env var.
+func (p *parser) decryptAES(value string) (string, error) {
+	name := p.encryptionKeyEnv
+	if len(name) == 0 {
+		name = DefaultEncryptionKeyEnv
+	}
+	encodedKey, _ := p.lookupEnv(name)
+	if len(encodedKey) == 0 {
+		return "", fmt.Errorf("enc: no key to decrypt the value, the %s env var is empty", name)
+	}
+	key, err := decodeBase64(encodedKey)
+	if err != nil {
+		return "", fmt.Errorf("enc: invalid %s key: %s", name, err.Error())
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := decodeBase64(strings.TrimPrefix(value, encPrefix))
+	if err != nil {
+		return "", fmt.Errorf("enc: %s", err.Error())
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("enc: invalid value, too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("enc: can't decrypt the value: %s", err.Error())
+	}
+	return string(plain), nil
+}
+
+// newGCM returns the AES-GCM cipher of the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("enc: %s", err.Error())
+	}
+	return cipher.NewGCM(block)
+}
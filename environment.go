@@ -4,8 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	re "regexp"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 
@@ -114,9 +115,41 @@ type Sources struct {
 	// for the build environment tag, the default value is 'BUILD_ENV'.
 	SystemEnvironmentTagKey string
 
-	// Git is the project version control system.
-	// The default path is './' (the working directory).
+	// VCS is the project version control system,
+	// the Git repository is used if nil.
+	VCS VCS
+
+	// Git is the project git repository at './' (the working directory) by default.
+	//
+	// Deprecated: set VCS, Git is used only if VCS is nil.
 	Git *Repository
+
+	// BuildInfo is the binary build info, used when the VCS is not available,
+	// eg.: for binaries built outside a git checkout.
+	BuildInfo *BuildInfo
+}
+
+// info returns the VCS info or,
+// if it is not available, the binary build info.
+func (s *Sources) info() string {
+	if vcs := s.vcs(); vcs != nil && (len(vcs.Branch()) > 0 || s.BuildInfo == nil || s.BuildInfo.Error != nil) {
+		return vcs.Info()
+	}
+	if s.BuildInfo != nil {
+		return s.BuildInfo.Info()
+	}
+	return ""
+}
+
+// vcs returns the VCS, or the Git repository if nil.
+func (s *Sources) vcs() VCS {
+	if s.VCS != nil {
+		return s.VCS
+	}
+	if s.Git != nil {
+		return s.Git
+	}
+	return nil
 }
 
 // EnvironmentHandler is the object that manges the environment.
@@ -124,14 +157,20 @@ type EnvironmentHandler struct {
 	// Sources define the sources used to determine the current environment.
 	Sources *Sources
 
+	// currentTAG is the tag from which environmentsHandler
+	// determine the current environment.
 	currentTAG string
 
-	environments []*Environment
-	// any other custom environment can be added later.
-	// by default, it includes the five standard ones and
 	// environments hold all the environments to check,
-	// determine the current environment.
-	// currentTAG is the tag from which environmentsHandler
+	// by default, it includes the five standard ones and
+	// any other custom environment can be added later.
+	environments []*Environment
+
+	// sources are the custom environment sources, see AddSource.
+	sources []source
+
+	// priorities are the built-in sources priorities, by name, see SetSourcePriority.
+	priorities map[string]int
 
 	mutex sync.Mutex
 }
@@ -142,13 +181,15 @@ type EnvironmentHandler struct {
 // Sources define the sources used to determine the current environment.
 // If DirectEnvironmentTag is empty then the
 // system environment variable SystemEnvironmentTagKey will be checked,
-// if also the system environment variable is empty the Git.BranchName will be used.
+// if also the system environment variable is empty the VCS branch will be used,
+// if the VCS is not available the BuildInfo.Version will be used.
 func NewEnvironmentHandler(environments []*Environment) *EnvironmentHandler {
 	return &EnvironmentHandler{
 		Sources: &Sources{
 			//directEnvironmentTag:    InterpolableEnvTag,
 			SystemEnvironmentTagKey: "BUILD_ENV",
 			Git:                     NewGitRepository("./"),
+			BuildInfo:               NewBuildInfo(),
 		},
 		environments: environments,
 	}
@@ -161,29 +202,22 @@ func (eh *EnvironmentHandler) SetCurrent(tag string) {
 }
 
 // Current returns the current active environment by
-// matching the found tag against any environments regexp.
+// matching the found tag against any environments regexp,
+// the tag is taken from the first source, by priority, returning one.
 func (eh *EnvironmentHandler) Current() *Environment {
 	eh.mutex.Lock()
 	defer eh.mutex.Unlock()
 
-	inferredBy := ""
-
-	if len(eh.Sources.directEnvironmentTag) > 0 {
-		eh.currentTAG = eh.Sources.directEnvironmentTag
-		inferredBy = fmt.Sprintf("'%s', from `SetCurrent()`, set manually.", eh.currentTAG)
-	} else if eh.currentTAG = os.Getenv(eh.Sources.SystemEnvironmentTagKey); len(eh.currentTAG) > 0 {
-		inferredBy = fmt.Sprintf("'%s', from `%s` environment variable.",
-			eh.currentTAG, eh.Sources.SystemEnvironmentTagKey)
-	} else if eh.Sources.Git != nil {
-		if eh.Sources.Git.Error == nil {
-			eh.currentTAG = eh.Sources.Git.BranchName
-			inferredBy = fmt.Sprintf("<empty>, from git.BranchName (%s).", eh.Sources.Git.BranchName)
+	eh.currentTAG = ""
+	inferredBy := "<empty>, default environment is `local`."
+
+	sources := append(eh.builtinSources(), eh.sources...)
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].priority < sources[j].priority })
+	for _, src := range sources {
+		if tag, by, ok := src.detect(); ok {
+			eh.currentTAG, inferredBy = tag, by
+			break
 		}
-	} else if testingRegexp.MatchString(os.Args[0]) {
-		eh.currentTAG = DefaultEnvs.Testing.Tag()
-		inferredBy = fmt.Sprintf("`%s`, from the running file name (%s).", eh.currentTAG, os.Args[0])
-	} else {
-		inferredBy = "<empty>, default environment is `local`."
 	}
 
 	env := DefaultEnvs.Local
@@ -200,9 +234,156 @@ func (eh *EnvironmentHandler) Current() *Environment {
 	return env
 }
 
+// Environment sources -------------------------------------------------------------------------------------------------
+
+// Built-in environment sources names.
+const (
+	SourceManual    = "manual"
+	SourceEnvVar    = "env"
+	SourceVCS       = "vcs"
+	SourceBuildInfo = "build_info"
+	SourceTesting   = "testing"
+)
+
+// Built-in environment sources default priorities, lower priorities are checked first.
+const (
+	// PriorityManual is the priority of the tag set with SetCurrent.
+	PriorityManual = 100
+
+	// PriorityEnvVar is the priority of the SystemEnvironmentTagKey environment variable.
+	PriorityEnvVar = 200
+
+	// PriorityVCS is the priority of the VCS branch.
+	PriorityVCS = 300
+
+	// PriorityBuildInfo is the priority of the BuildInfo version.
+	PriorityBuildInfo = 400
+
+	// PriorityTesting is the priority of the test binaries detection, the `testing` environment.
+	PriorityTesting = 500
+)
+
+// source is an environment detection source.
+type source struct {
+	name     string
+	priority int
+	detect   func() (tag, inferredBy string, ok bool)
+}
+
+// AddSource add a custom environment detection source with the given priority
+// (eg.: to derive the environment from a file, a metadata service or a feature-flag system),
+// a source with the same name is replaced. fn returns ok false if it can't determine the environment tag.
+// Sources are checked by priority, see the built-in ones (PriorityManual, PriorityEnvVar, etc...),
+// the built-in ones come first at the same priority, see also SetSourcePriority.
+func (eh *EnvironmentHandler) AddSource(name string, priority int, fn func() (tag string, ok bool)) {
+	eh.mutex.Lock()
+	defer eh.mutex.Unlock()
+
+	src := source{name: name, priority: priority, detect: func() (string, string, bool) {
+		tag, ok := fn()
+		return tag, fmt.Sprintf("'%s', from the `%s` source.", tag, name), ok && len(tag) > 0
+	}}
+	for i := range eh.sources {
+		if eh.sources[i].name == name {
+			eh.sources[i] = src
+			return
+		}
+	}
+	eh.sources = append(eh.sources, src)
+}
+
+// SetSourcePriority set the priority of the source with the given name,
+// built-in (eg.: SourceEnvVar) or custom, lower priorities are checked first,
+// eg.: to make the environment variable beat SetCurrent in containers:
+//
+//	eh.SetSourcePriority(swap.SourceEnvVar, swap.PriorityManual-1)
+func (eh *EnvironmentHandler) SetSourcePriority(name string, priority int) error {
+	eh.mutex.Lock()
+	defer eh.mutex.Unlock()
+
+	for i := range eh.sources {
+		if eh.sources[i].name == name {
+			eh.sources[i].priority = priority
+			return nil
+		}
+	}
+	for _, src := range eh.builtinSources() {
+		if src.name == name {
+			if eh.priorities == nil {
+				eh.priorities = make(map[string]int)
+			}
+			eh.priorities[name] = priority
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown environment source '%s'", name)
+}
+
+// priority returns the priority of the built-in source with the given name.
+func (eh *EnvironmentHandler) priority(name string, defaultPriority int) int {
+	if priority, found := eh.priorities[name]; found {
+		return priority
+	}
+	return defaultPriority
+}
+
+// builtinSources returns the built-in environment sources.
+func (eh *EnvironmentHandler) builtinSources() []source {
+	return []source{
+		{name: SourceManual, priority: eh.priority(SourceManual, PriorityManual), detect: func() (string, string, bool) {
+			tag := eh.Sources.directEnvironmentTag
+			return tag, fmt.Sprintf("'%s', from `SetCurrent()`, set manually.", tag), len(tag) > 0
+		}},
+		{name: SourceEnvVar, priority: eh.priority(SourceEnvVar, PriorityEnvVar), detect: func() (string, string, bool) {
+			tag := os.Getenv(eh.Sources.SystemEnvironmentTagKey)
+			return tag, fmt.Sprintf("'%s', from `%s` environment variable.",
+				tag, eh.Sources.SystemEnvironmentTagKey), len(tag) > 0
+		}},
+		{name: SourceVCS, priority: eh.priority(SourceVCS, PriorityVCS), detect: func() (string, string, bool) {
+			branch := eh.vcsBranch()
+			return branch, fmt.Sprintf("<empty>, from the VCS branch (%s).", branch), len(branch) > 0
+		}},
+		{name: SourceBuildInfo, priority: eh.priority(SourceBuildInfo, PriorityBuildInfo), detect: func() (string, string, bool) {
+			if eh.Sources.BuildInfo == nil || len(eh.Sources.BuildInfo.Version) == 0 {
+				return "", "", false
+			}
+			tag := eh.Sources.BuildInfo.Version
+			return tag, fmt.Sprintf("'%s', from the build info version.", tag), true
+		}},
+		{name: SourceTesting, priority: eh.priority(SourceTesting, PriorityTesting), detect: func() (string, string, bool) {
+			tag := DefaultEnvs.Testing.Tag()
+			return tag, fmt.Sprintf("`%s`, from the running file name (%s).", tag, os.Args[0]),
+				testingRegexp.MatchString(os.Args[0])
+		}},
+	}
+}
+
+// vcsBranch returns the VCS branch, empty if not available.
+func (eh *EnvironmentHandler) vcsBranch() string {
+	vcs := eh.Sources.vcs()
+	if vcs == nil {
+		return ""
+	}
+	return vcs.Branch()
+}
+
+// VCS -----------------------------------------------------------------------------------------------------------------
+
+// VCS interface allow any version control system (eg.: Mercurial, Fossil)
+// to be used as an environment source, see Repository for git.
+type VCS interface {
+	// Branch returns the current branch name, empty if not available.
+	Branch() string
+
+	// Info returns the VCS info printed in the debug banner.
+	Info() string
+}
+
 // Git -----------------------------------------------------------------------------------------------------------------
 
-// Repository represent a git repository.
+// Repository represent a git repository, it implements the VCS interface.
+// The repository is read with go-git, unless the `swap_nogit` build tag is set
+// to drop the dependency, the Error is always set then.
 type Repository struct {
 	path                           string
 	BranchName, Commit, Build, Tag string
@@ -218,6 +399,17 @@ func NewGitRepository(path string) *Repository {
 	return repo
 }
 
+// Branch returns the current branch name, empty on errors.
+func (g *Repository) Branch() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.Error != nil {
+		return ""
+	}
+	return g.BranchName
+}
+
 // Info return Git repository info.
 func (g *Repository) Info() string {
 	g.mutex.Lock()
@@ -236,33 +428,71 @@ func (g *Repository) updateInfo() {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
-	g.BranchName = g.git("rev-parse", "--abbrev-ref", "HEAD")
-	g.Commit = g.git("rev-parse", "--short", "HEAD")
-	g.Build = g.git("rev-list", "--all", "--count")
-	g.Tag = g.git("describe", "--abbrev=0", "--tags", "--always")
+	err := g.readInfo()
+	if err == nil {
+		err = g.readHistory()
+	}
+	if err != nil {
+		g.Error = err
+		g.BranchName, g.Commit, g.Build, g.Tag = err.Error(), err.Error(), err.Error(), err.Error()
+	}
 }
 
-// Git is the bash git command.
-func (g *Repository) git(params ...string) string {
-	cmd := exec.Command("git", params...)
-	if len(g.path) > 0 {
-		cmd.Dir = g.path
+// Build info ----------------------------------------------------------------------------------------------------------
+
+// develVersion is the main module version of the binaries
+// built from a local checkout, it is not a version.
+const develVersion = "(devel)"
+
+// BuildInfo represent the binary build info,
+// embedded by the go toolchain and read with runtime/debug.ReadBuildInfo.
+type BuildInfo struct {
+	// Version is the main module version (eg.: `v1.2.0`),
+	// empty for the binaries built from a local checkout.
+	Version string
+
+	// Revision, Time and Modified are the `vcs.revision`, `vcs.time`
+	// and `vcs.modified` build settings, if any.
+	Revision, Time string
+	Modified       bool
+
+	Error error
+}
+
+// NewBuildInfo return a new *BuildInfo instance for the running binary.
+func NewBuildInfo() *BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return &BuildInfo{Error: errors.New("build info not available")}
 	}
+	return newBuildInfo(info)
+}
 
-	output, err := cmd.Output()
-	if err != nil {
-		gitErrString := err.Error()
-		// not a repository error...
-		if exitError, ok := err.(*exec.ExitError); ok {
-			gitErrString = string(exitError.Stderr)
+// newBuildInfo return a new *BuildInfo instance from the given build info.
+func newBuildInfo(info *debug.BuildInfo) *BuildInfo {
+	bi := &BuildInfo{}
+	if info.Main.Version != develVersion {
+		bi.Version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			bi.Revision = setting.Value
+		case "vcs.time":
+			bi.Time = setting.Value
+		case "vcs.modified":
+			bi.Modified = setting.Value == "true"
 		}
-		gitErrString = strings.TrimPrefix(gitErrString, "fatal: ")
-		gitErrString = strings.TrimSuffix(gitErrString, "\n")
-		gitErrString = strings.TrimSuffix(gitErrString, ": .git")
-		g.Error = errors.New(gitErrString)
-		return gitErrString
 	}
+	return bi
+}
 
-	out := strings.TrimSuffix(string(output), "\n")
-	return out
+// Info return the build info.
+func (b *BuildInfo) Info() string {
+	buildLog := logger.KVLogger{ValuePainter: logger.Magenta}
+	return fmt.Sprintf("%s\n%s\n%s\n%s\n",
+		buildLog.Sprint("Build Version:", b.Version),
+		buildLog.Sprint("Build Revision:", b.Revision),
+		buildLog.Sprint("Build Time:", b.Time),
+		buildLog.Sprint("Build Modified:", b.Modified))
 }
@@ -0,0 +1,62 @@
+package swap
+
+import (
+	"errors"
+)
+
+// Errors --------------------------------------------------------------------------------------------------------------
+
+// ErrRequired is the error of the `swapcp:"required"` fields without value.
+var ErrRequired = errors.New("required")
+
+// FieldError is a parse or build error of a specific field,
+// use errors.As to inspect it, eg.:
+//
+//	var fe *swap.FieldError
+//	if errors.As(err, &fe) {
+//		log.Println(fe.Path, fe.Tag) // PG.Password required
+//	}
+type FieldError struct {
+	// Path is the full field path (eg.: `PG.Password`, `Servers[0].Host`).
+	Path string
+
+	// Tag is the failed tag flag (eg.: `required`, `env`, `default`, `waitfor`),
+	// empty if the error does not come from a tag.
+	Tag string
+
+	Err error
+}
+
+// Error is the error interface implementation.
+func (fe *FieldError) Error() string {
+	if len(fe.Path) == 0 {
+		return fe.Err.Error()
+	}
+	return fe.Path + ": " + fe.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (fe *FieldError) Unwrap() error {
+	return fe.Err
+}
+
+// fieldError returns err as a *FieldError of the field at path,
+// the path of a *FieldError err (eg.: returned by Parse in Configure)
+// is relative to the field, so it is joined.
+func fieldError(path, tag string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if fe, ok := err.(*FieldError); ok {
+		if len(fe.Path) > 0 {
+			path = joinFieldPath(path, fe.Path)
+		}
+		return &FieldError{Path: path, Tag: fe.Tag, Err: fe.Err}
+	}
+
+	if len(path) == 0 && len(tag) == 0 {
+		return err
+	}
+	return &FieldError{Path: path, Tag: tag, Err: err}
+}
@@ -0,0 +1,356 @@
+package swap
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// etcd v3 FileSystem --------------------------------------------------------------------------------------------------
+
+// EtcdConfig is the etcd v3 FileSystem configuration.
+type EtcdConfig struct {
+	// Endpoints are the etcd gRPC gateway addresses, tried in order,
+	// `ETCDCTL_ENDPOINTS` or `http://127.0.0.1:2379` by default.
+	Endpoints []string
+
+	// Username and Password enable the etcd authentication,
+	// `ETCDCTL_USER` (`user:password`) by default.
+	Username string
+	Password string
+
+	// TLS is the client TLS configuration, if nil it is built
+	// from CAFile, CertFile and KeyFile when set,
+	// `ETCDCTL_CACERT`, `ETCDCTL_CERT` and `ETCDCTL_KEY` by default.
+	TLS      *tls.Config
+	CAFile   string
+	CertFile string
+	KeyFile  string
+
+	// Prefix is the key prefix (eg.: `myapp/config`) mounted at ConfigPath,
+	// so that the `myapp/config/Tool` key is the `<ConfigPath>/Tool.yaml` file.
+	Prefix     string
+	ConfigPath string
+
+	// Format is the extension of the keys without one, `yaml` by default.
+	Format string
+
+	// Client is the HTTP client, if nil a new one is created using TLS.
+	Client *http.Client
+}
+
+// EtcdFileSystem is the FileSystem backed by the etcd v3 KV store, through its JSON gateway,
+// the `Tool.<env>` keys override the `Tool` one as environment specific files do.
+type EtcdFileSystem struct {
+	config EtcdConfig
+
+	mutex sync.Mutex
+	token string
+}
+
+// NewFileSystemEtcd returns the FileSystem backed by the etcd v3 KV store.
+func NewFileSystemEtcd(config EtcdConfig) (*EtcdFileSystem, error) {
+	if len(config.Endpoints) == 0 {
+		if endpoints := os.Getenv("ETCDCTL_ENDPOINTS"); len(endpoints) > 0 {
+			config.Endpoints = strings.Split(endpoints, ",")
+		} else {
+			config.Endpoints = []string{"http://127.0.0.1:2379"}
+		}
+	}
+	if len(config.Username) == 0 {
+		if user := strings.SplitN(os.Getenv("ETCDCTL_USER"), ":", 2); len(user) == 2 {
+			config.Username, config.Password = user[0], user[1]
+		}
+	}
+	if len(config.CAFile) == 0 {
+		config.CAFile = os.Getenv("ETCDCTL_CACERT")
+	}
+	if len(config.CertFile) == 0 {
+		config.CertFile = os.Getenv("ETCDCTL_CERT")
+	}
+	if len(config.KeyFile) == 0 {
+		config.KeyFile = os.Getenv("ETCDCTL_KEY")
+	}
+	if len(config.Format) == 0 {
+		config.Format = "yaml"
+	}
+	config.Prefix = strings.Trim(config.Prefix, "/")
+
+	if config.TLS == nil && (len(config.CAFile) > 0 || len(config.CertFile) > 0) {
+		tlsConfig, err := etcdTLSConfig(config.CAFile, config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.TLS = tlsConfig
+	}
+
+	scheme := "http://"
+	if config.TLS != nil {
+		scheme = "https://"
+	}
+	for i, endpoint := range config.Endpoints {
+		endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = scheme + endpoint
+		}
+		config.Endpoints[i] = endpoint
+	}
+
+	if config.Client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config.TLS
+		config.Client = &http.Client{Transport: transport}
+	}
+
+	return &EtcdFileSystem{config: config}, nil
+}
+
+// etcdTLSConfig returns the TLS configuration with the given CA and client certificate.
+func etcdTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if len(caFile) > 0 {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("etcd: %s", err.Error())
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("etcd: no certificates found in '%s'", caFile)
+		}
+	}
+
+	if len(certFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("etcd: %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// ReadDir returns the keys and sub-prefixes under the named directory.
+func (e *EtcdFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	key, err := e.key("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	dirPrefix := key + "/"
+	if len(key) == 0 {
+		dirPrefix = ""
+	}
+
+	kvs, err := e.rangeKeys(dirPrefix, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if len(kvs) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	seen := make(map[string]bool)
+	entries := make([]fs.DirEntry, 0, len(kvs))
+	for _, kv := range kvs {
+		entryName := strings.TrimPrefix(kv.Key, dirPrefix)
+		if len(entryName) == 0 {
+			continue
+		}
+		if i := strings.Index(entryName, "/"); i >= 0 {
+			if entryName = entryName[:i]; !seen[entryName] {
+				seen[entryName] = true
+				entries = append(entries, memDirEntry{name: entryName, dir: true})
+			}
+			continue
+		}
+		if !isValidExt(path.Ext(entryName)) {
+			entryName += "." + e.config.Format
+		}
+		entries = append(entries, memDirEntry{name: entryName})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile returns the value of the key.
+func (e *EtcdFileSystem) ReadFile(name string) ([]byte, error) {
+	key, err := e.key("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := e.get(key)
+	if err == fs.ErrNotExist && path.Ext(key) == "."+e.config.Format {
+		// the key without extension
+		data, err = e.get(strings.TrimSuffix(key, path.Ext(key)))
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// key returns the etcd key of the named file.
+func (e *EtcdFileSystem) key(op, name string) (string, error) {
+	rel, err := mountedPath(ioFSPath(e.config.ConfigPath), op, name)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return e.config.Prefix, nil
+	}
+	return strings.TrimPrefix(e.config.Prefix+"/"+rel, "/"), nil
+}
+
+// etcdKV is a key-value pair of the range response.
+type etcdKV struct {
+	Key   string
+	Value []byte
+}
+
+// get returns the value of the key.
+func (e *EtcdFileSystem) get(key string) ([]byte, error) {
+	kvs, err := e.rangeKeys(key, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.Value, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// rangeKeys returns the key, or every key with the prefix if prefix is true.
+func (e *EtcdFileSystem) rangeKeys(key string, prefix bool) ([]etcdKV, error) {
+	request := map[string]interface{}{"key": []byte(key)}
+	if prefix && len(key) == 0 {
+		// the whole keyspace
+		request["key"] = []byte{0}
+	}
+	if prefix {
+		request["range_end"] = etcdPrefixEnd(key)
+		request["keys_only"] = true
+	}
+
+	var response struct {
+		Kvs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := e.post("/v3/kv/range", request, &response, true); err != nil {
+		return nil, err
+	}
+
+	kvs := make([]etcdKV, 0, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		kvs = append(kvs, etcdKV{Key: string(kv.Key), Value: kv.Value})
+	}
+	return kvs, nil
+}
+
+// etcdPrefixEnd returns the range end matching every key with the prefix.
+func etcdPrefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// the whole keyspace
+	return []byte{0}
+}
+
+// authenticate returns the auth token, requesting a new one if renew is true.
+func (e *EtcdFileSystem) authenticate(renew bool) (string, error) {
+	if len(e.config.Username) == 0 {
+		return "", nil
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.token) > 0 && !renew {
+		return e.token, nil
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	request := map[string]string{"name": e.config.Username, "password": e.config.Password}
+	if err := e.post("/v3/auth/authenticate", request, &response, false); err != nil {
+		return "", err
+	}
+	e.token = response.Token
+	return e.token, nil
+}
+
+// post send the JSON request, renewing the auth token once if it has expired.
+func (e *EtcdFileSystem) post(api string, request, response interface{}, auth bool) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	var token string
+	if auth {
+		if token, err = e.authenticate(false); err != nil {
+			return err
+		}
+	}
+
+	status, data, err := e.send(api, body, token)
+	if err == nil && status == http.StatusUnauthorized && len(token) > 0 {
+		if token, err = e.authenticate(true); err != nil {
+			return err
+		}
+		status, data, err = e.send(api, body, token)
+	}
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("etcd: unexpected status: %d %s: %s",
+			status, http.StatusText(status), strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, response)
+}
+
+// send the request body to the first reachable endpoint.
+func (e *EtcdFileSystem) send(api string, body []byte, token string) (int, []byte, error) {
+	for _, endpoint := range e.config.Endpoints {
+		req, err := http.NewRequest(http.MethodPost, endpoint+api, bytes.NewReader(body))
+		if err != nil {
+			return 0, nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if len(token) > 0 {
+			req.Header.Set("Authorization", token)
+		}
+
+		resp, err := e.config.Client.Do(req)
+		if err != nil {
+			// try the next endpoint
+			continue
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, data, err
+	}
+	return 0, nil, fmt.Errorf("etcd: no reachable endpoint in %v", e.config.Endpoints)
+}
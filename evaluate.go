@@ -0,0 +1,28 @@
+package swap
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Sandbox evaluation --------------------------------------------------------------------------------------------------
+
+// Evaluate parse the proposed config files in memory, without touching config,
+// and returns the would-be effective config: a pointer to a new instance of the config type.
+// files are the proposed files content by path, the parsed ones are passed as in ParseByEnv
+// (env may be nil), so environment specific files, templates, `$ref`s, tags and validation
+// are all taken into account. Decoding is strict: unknown keys are errors.
+// It is the backend of a "validate before apply" flow.
+func Evaluate(config interface{}, env *Environment, files map[string][]byte, parse ...string) (interface{}, error) {
+	t := reflect.TypeOf(config)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, errors.New("the config argument should be a pointer")
+	}
+
+	p := newParser()
+	p.fs = NewFileSystemMemory(files)
+	p.strict = true
+
+	effective := reflect.New(t.Elem()).Interface()
+	return effective, p.parseByEnv(effective, env, parse...)
+}
@@ -83,7 +83,5 @@ func init() {
 	ToolBox.ManuallyConfigured = tools.ToolConfigurable{Text: "manually set"}
 
 	// Load the toolbox
-	if err := builder.Build(&ToolBox); err != nil {
-		panic(err)
-	}
+	builder.MustBuild(&ToolBox)
 }
@@ -0,0 +1,111 @@
+package swap
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"regexp"
+)
+
+// Env vars expansion --------------------------------------------------------------------------------------------------
+
+// regexpEnvVar match `${VAR}`, `${VAR:-default}` (default if unset or empty),
+// `${VAR-default}` (default if unset) and the `$${VAR}` escape.
+var regexpEnvVar = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?-)([^}]*))?\}`)
+
+// expandEnv replace the shell-style env var references in the
+// string values of the file data, data without references and untrusted data are returned untouched.
+// A value made of a single reference (eg.: `port: ${PORT:-5432}`)
+// is converted to the config field type.
+func (p *parser) expandEnv(file string, data []byte, config interface{}) ([]byte, error) {
+	if p.untrusted || !bytes.Contains(data, []byte("${")) {
+		return data, nil
+	}
+
+	tree, err := decodeTree(file, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeTree(file, p.expandNode(tree, reflect.TypeOf(config)))
+}
+
+// expandNode walks the node along with the config type expanding the string values,
+// t is nil for the nodes without a matching field.
+func (p *parser) expandNode(node interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			var vt reflect.Type
+			if t != nil && t.Kind() == reflect.Struct {
+				if sf, found := matchField(t, k); found {
+					vt = sf.Type
+				}
+			} else if t != nil && t.Kind() == reflect.Map {
+				vt = t.Elem()
+			}
+			n[k] = p.expandNode(v, vt)
+		}
+	case []interface{}:
+		var vt reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			vt = t.Elem()
+		}
+		for i, v := range n {
+			n[i] = p.expandNode(v, vt)
+		}
+	case string:
+		expanded, single := p.expandString(n)
+		if single && t != nil {
+			converter := normalizer{wt: &WeakTyping{}}
+			if converted, ok := converter.convert(expanded, t); ok {
+				return converted
+			}
+		}
+		return expanded
+	}
+	return node
+}
+
+// expandString returns the expanded value, single is true
+// if the value is made of a single reference.
+func (p *parser) expandString(s string) (expanded string, single bool) {
+	if loc := regexpEnvVar.FindStringSubmatchIndex(s); loc != nil {
+		single = loc[0] == 0 && loc[1] == len(s) && loc[3] == loc[2]
+	}
+
+	expanded = regexpEnvVar.ReplaceAllStringFunc(s, func(ref string) string {
+		m := regexpEnvVar.FindStringSubmatch(ref)
+		if len(m[1]) > 0 {
+			return ref[1:]
+		}
+
+		value, found := p.lookupEnv(m[2])
+		switch m[3] {
+		case ":-":
+			if len(value) == 0 {
+				value = m[4]
+			}
+		case "-":
+			if !found {
+				value = m[4]
+			}
+		}
+		return value
+	})
+	return
+}
+
+// lookupEnv returns the env var value, from the process
+// environment or the dotenv files.
+func (p *parser) lookupEnv(key string) (string, bool) {
+	if value, found := os.LookupEnv(key); found {
+		return value, true
+	}
+	value, found := p.dotEnv[key]
+	return value, found
+}
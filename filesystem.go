@@ -0,0 +1,332 @@
+package swap
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSystem interface ------------------------------------------------------------------------------------------------
+
+// FileSystem is the abstraction used to search and read the config files.
+type FileSystem interface {
+	// ReadDir returns the entries of the named directory, sorted by filename.
+	ReadDir(name string) ([]fs.DirEntry, error)
+
+	// ReadFile returns the content of the named file.
+	ReadFile(name string) ([]byte, error)
+}
+
+// StatFileSystem is a FileSystem which can describe its files,
+// it allow the cache invalidation (see NewFileSystemCached).
+type StatFileSystem interface {
+	FileSystem
+
+	// Stat returns the FileInfo of the named file or directory.
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// NewFileSystemLocal returns the FileSystem
+// backed by the local (OS) file system.
+func NewFileSystemLocal() FileSystem {
+	return localFS{}
+}
+
+// localFS is the local (OS) file system.
+type localFS struct{}
+
+func (localFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (localFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (localFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// NewFileSystemFS returns the FileSystem backed by any io/fs.FS
+// (embed.FS, zip.Reader, fstest.MapFS...) mounted at configPath,
+// so that the Builder config path (eg.: `./config`) is the fsys root,
+// an empty configPath mounts it at the working directory.
+// Names are converted to valid io/fs paths.
+func NewFileSystemFS(fsys fs.FS, configPath string) FileSystem {
+	return ioFS{fsys: fsys, root: ioFSPath(configPath)}
+}
+
+// ioFS is an io/fs file system mounted at root.
+type ioFS struct {
+	fsys fs.FS
+	root string
+}
+
+func (i ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsPath, err := i.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(i.fsys, fsPath)
+}
+
+func (i ioFS) ReadFile(name string) ([]byte, error) {
+	fsPath, err := i.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(i.fsys, fsPath)
+}
+
+func (i ioFS) Stat(name string) (fs.FileInfo, error) {
+	fsPath, err := i.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(i.fsys, fsPath)
+}
+
+// path returns the fsys path of name,
+// names outside of the mount point does not exist.
+func (i ioFS) path(op, name string) (string, error) {
+	return mountedPath(i.root, op, name)
+}
+
+// mountedPath returns the path of name relative to the root mount point
+// (an ioFSPath), names outside of the mount point does not exist.
+func mountedPath(root, op, name string) (string, error) {
+	fsPath := ioFSPath(name)
+	switch {
+	case root == ".":
+		return fsPath, nil
+	case fsPath == root:
+		return ".", nil
+	case strings.HasPrefix(fsPath, root+"/"):
+		return strings.TrimPrefix(fsPath, root+"/"), nil
+	default:
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+}
+
+// ioFSPath returns the unrooted, slash-separated, form of name.
+func ioFSPath(name string) string {
+	if name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/"); len(name) == 0 {
+		return "."
+	}
+	return name
+}
+
+// NewFileSystemMemory returns the in-memory FileSystem holding files,
+// by path (eg.: `config/tool.yaml`).
+func NewFileSystemMemory(files map[string][]byte) FileSystem {
+	m := make(memFS, len(files))
+	for name, data := range files {
+		m[ioFSPath(name)] = data
+	}
+	return m
+}
+
+// memFS is an in-memory file system, by cleaned path.
+type memFS map[string][]byte
+
+func (m memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir := ioFSPath(name)
+
+	children := make(map[string]bool)
+	for file := range m {
+		rel := file
+		if dir != "." {
+			if !strings.HasPrefix(file, dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(file, dir+"/")
+		}
+		child := strings.SplitN(rel, "/", 2)
+		children[child[0]] = len(child) == 2
+	}
+	if len(children) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for child, isDir := range children {
+		entries = append(entries, memDirEntry{name: child, dir: isDir})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (m memFS) ReadFile(name string) ([]byte, error) {
+	data, found := m[ioFSPath(name)]
+	if !found {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+	return data, nil
+}
+
+// memDirEntry is a memFS directory entry, it is its own FileInfo.
+type memDirEntry struct {
+	name string
+	dir  bool
+}
+
+func (e memDirEntry) Name() string               { return e.name }
+func (e memDirEntry) IsDir() bool                { return e.dir }
+func (e memDirEntry) Info() (fs.FileInfo, error) { return e, nil }
+func (e memDirEntry) Size() int64                { return 0 }
+func (e memDirEntry) ModTime() time.Time         { return time.Time{} }
+func (e memDirEntry) Sys() interface{}           { return nil }
+
+func (e memDirEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e memDirEntry) Mode() fs.FileMode {
+	return e.Type()
+}
+
+// NewFileSystemOverlay returns the FileSystem where lookups fall through
+// the layers, in the given order (eg.: local overrides on top of embedded defaults),
+// directories content is merged.
+func NewFileSystemOverlay(layers ...FileSystem) FileSystem {
+	return overlayFS(layers)
+}
+
+// overlayFS is a layered file system, the first layer has the priority.
+type overlayFS []FileSystem
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	found := false
+	merged := make(map[string]fs.DirEntry)
+	for _, layer := range o {
+		entries, err := layer.ReadDir(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+		for _, entry := range entries {
+			if _, shadowed := merged[entry.Name()]; !shadowed {
+				merged[entry.Name()] = entry
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (o overlayFS) ReadFile(name string) ([]byte, error) {
+	for _, layer := range o {
+		data, err := layer.ReadFile(name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return data, err
+		}
+	}
+	return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+}
+
+// NewFileSystemCached returns the FileSystem caching directories entries
+// and files content of fsys, so repeated Build and Parse calls don't hammer the disk.
+// Cached entries are invalidated when their modification time or size change
+// if fsys is a StatFileSystem, they never expire otherwise (eg.: for an embed.FS).
+func NewFileSystemCached(fsys FileSystem) FileSystem {
+	return &cachedFS{
+		fsys:  fsys,
+		dirs:  make(map[string]cachedEntry),
+		files: make(map[string]cachedEntry),
+	}
+}
+
+// cachedEntry is a cached directory or file.
+type cachedEntry struct {
+	modTime time.Time
+	size    int64
+	entries []fs.DirEntry
+	data    []byte
+}
+
+// cachedFS is a caching FileSystem decorator.
+type cachedFS struct {
+	fsys  FileSystem
+	mutex sync.Mutex
+	dirs  map[string]cachedEntry
+	files map[string]cachedEntry
+}
+
+func (c *cachedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, found, err := c.lookup(c.dirs, name)
+	if err != nil || found {
+		return entry.entries, err
+	}
+
+	if entry.entries, err = c.fsys.ReadDir(name); err != nil {
+		return nil, err
+	}
+	c.store(c.dirs, name, entry)
+	return entry.entries, nil
+}
+
+func (c *cachedFS) ReadFile(name string) ([]byte, error) {
+	entry, found, err := c.lookup(c.files, name)
+	if err != nil || found {
+		return entry.data, err
+	}
+
+	if entry.data, err = c.fsys.ReadFile(name); err != nil {
+		return nil, err
+	}
+	c.store(c.files, name, entry)
+	return entry.data, nil
+}
+
+// lookup returns the still valid cached entry, if found,
+// or the new entry with the current file info otherwise.
+func (c *cachedFS) lookup(cache map[string]cachedEntry, name string) (entry cachedEntry, found bool, err error) {
+	if statFS, ok := c.fsys.(StatFileSystem); ok {
+		var info fs.FileInfo
+		if info, err = statFS.Stat(name); err != nil {
+			return entry, false, err
+		}
+		entry.modTime, entry.size = info.ModTime(), info.Size()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, found := cache[name]
+	if found && cached.modTime.Equal(entry.modTime) && cached.size == entry.size {
+		return cached, true, nil
+	}
+	return entry, false, nil
+}
+
+// store cache the entry.
+func (c *cachedFS) store(cache map[string]cachedEntry, name string, entry cachedEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cache[name] = entry
+}
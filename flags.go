@@ -0,0 +1,164 @@
+package swap
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/pflag"
+)
+
+// Command-line flags --------------------------------------------------------------------------------------------------
+
+// boundFlag is the command-line flag of a config field,
+// it implements both flag.Value and pflag.Value.
+type boundFlag struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (bf *boundFlag) String() string {
+	if bf == nil {
+		return ""
+	}
+	return bf.value
+}
+
+func (bf *boundFlag) Set(value string) error {
+	bf.value = value
+	bf.set = true
+	return nil
+}
+
+func (bf *boundFlag) Type() string {
+	if bf.isBool {
+		return "bool"
+	}
+	return "value"
+}
+
+// IsBoolFlag make the bool flags valid without value (eg.: `-debug`).
+func (bf *boundFlag) IsBoolFlag() bool {
+	return bf.isBool
+}
+
+// BindFlags define on fs a flag for every config field
+// (eg.: `-db.host` for `DB.Host`, `-max-conns` for `MaxConns`),
+// the flags set on the command line override files, env vars and defaults in Parse.
+// The flag name can be set with the `flag` tag flag (eg.: `swapcp:"flag=port"`), `flag=-` skip the field,
+// the usage with the `desc` one (eg.: `swapcp:"desc=the listening port"`).
+// Values are decoded as env vars values, eg.: `-hosts='[a, b]'`.
+// Call it before fs.Parse.
+func (s *Builder) BindFlags(fs *flag.FlagSet, config interface{}) *Builder {
+	s.bindFlags(config, func(name, usage string, bf *boundFlag) {
+		fs.Var(bf, name, usage)
+	})
+	return s
+}
+
+// BindPFlags is BindFlags for the github.com/spf13/pflag flag sets,
+// the bool flags are valid without value (eg.: `--debug`).
+func (s *Builder) BindPFlags(fs *pflag.FlagSet, config interface{}) *Builder {
+	s.bindFlags(config, func(name, usage string, bf *boundFlag) {
+		if f := fs.VarPF(bf, name, "", usage); bf.isBool {
+			f.NoOptDefVal = "true"
+		}
+	})
+	return s
+}
+
+// bindFlags define the flags of the config fields with define.
+func (s *Builder) bindFlags(config interface{}, define func(name, usage string, bf *boundFlag)) {
+	s.boundFlagsMutex.Lock()
+	defer s.boundFlagsMutex.Unlock()
+
+	if s.boundFlags == nil {
+		s.boundFlags = make(map[string]*boundFlag)
+	}
+	walkFlags(reflect.TypeOf(config), "", "", s.configTagKey, func(path, name string, sf reflect.StructField) {
+		bf := &boundFlag{isBool: indirectType(sf.Type).Kind() == reflect.Bool}
+		tagFields := splitFlags(sf.Tag.Get(s.configTagKey))
+		if value := flagValue(tagFields, sffConfigDefault); len(value) > 0 {
+			bf.value = value
+		}
+
+		usage := path
+		if desc := flagValue(tagFields, sffConfigDesc); len(desc) > 0 {
+			usage = desc
+		}
+		if env := flagValue(tagFields, sffConfigEnv); len(env) > 0 {
+			usage += ", env " + env
+		}
+		define(name, usage, bf)
+		s.boundFlags[path] = bf
+	})
+}
+
+// walkFlags calls fn for every leaf field of t, with its field path and flag name.
+func walkFlags(t reflect.Type, path, prefix, tagKey string, fn func(path, name string, sf reflect.StructField)) {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if len(sf.PkgPath) > 0 {
+			continue
+		}
+
+		name := flagValue(splitFlags(sf.Tag.Get(tagKey)), sffConfigFlag)
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = prefix + kebabCase(sf.Name)
+		}
+
+		fieldPath := joinFieldPath(path, sf.Name)
+		fv := reflect.New(sf.Type).Elem()
+		switch {
+		case sf.Type.Kind() == reflect.Ptr && isNestedConfig(fv):
+			// nil pointers are not traversed
+			continue
+		case isNestedConfig(fv):
+			walkFlags(sf.Type, fieldPath, name+".", tagKey, fn)
+		default:
+			fn(fieldPath, name, sf)
+		}
+	}
+}
+
+// setFlags returns the values of the flags set on the command line, by field path.
+func (s *Builder) setFlags() map[string]string {
+	s.boundFlagsMutex.Lock()
+	defer s.boundFlagsMutex.Unlock()
+
+	values := make(map[string]string)
+	for path, bf := range s.boundFlags {
+		if bf.set {
+			values[path] = bf.value
+		}
+	}
+	return values
+}
+
+// kebabCase returns the kebab-case name, eg.: `max-conns` for `MaxConns`, `db` for `DB`.
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// word boundary: lower to upper (maxConns) or the last upper of an acronym (DBHost)
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+//go:build !swap_nogit
+
+package swap
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// openRepository opens the repository at the given path and returns its HEAD.
+func openRepository(path string) (*git.Repository, *plumbing.Reference, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, head, nil
+}
+
+// readInfo read the repository info, as:
+// `git rev-parse --abbrev-ref HEAD` and `git rev-parse --short HEAD`.
+func (g *Repository) readInfo() error {
+	_, head, err := openRepository(g.path)
+	if err != nil {
+		return err
+	}
+
+	g.BranchName = "HEAD"
+	if head.Name().IsBranch() {
+		g.BranchName = head.Name().Short()
+	}
+	g.Commit = head.Hash().String()[:7]
+	return nil
+}
+
+// readHistory read the repository info that walk the history, as:
+// `git rev-list --all --count` and `git describe --abbrev=0 --tags --always`.
+func (g *Repository) readHistory() error {
+	repo, head, err := openRepository(g.path)
+	if err != nil {
+		return err
+	}
+
+	commits, err := repo.Log(&git.LogOptions{All: true})
+	if err != nil {
+		return err
+	}
+	build := 0
+	err = commits.ForEach(func(*object.Commit) error {
+		build++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	g.Build = strconv.Itoa(build)
+
+	g.Tag, err = describeTag(repo, head.Hash())
+	return err
+}
+
+// describeTag returns the nearest tag reachable from the given commit,
+// or the abbreviated commit hash if there are none.
+func describeTag(repo *git.Repository, from plumbing.Hash) (string, error) {
+	tagged := make(map[plumbing.Hash]string)
+	tags, err := repo.Tags()
+	if err != nil {
+		return "", err
+	}
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		// annotated tags point to a tag object
+		if tag, err := repo.TagObject(target); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return nil
+			}
+			target = commit.Hash
+		}
+		tagged[target] = ref.Name().Short()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	tag := from.String()[:7]
+	if len(tagged) == 0 {
+		return tag, nil
+	}
+
+	commits, err := repo.Log(&git.LogOptions{From: from, Order: git.LogOrderBSF})
+	if err != nil {
+		return "", err
+	}
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if name, found := tagged[commit.Hash]; found {
+			tag = name
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return tag, err
+}
@@ -0,0 +1,15 @@
+//go:build swap_nogit
+
+package swap
+
+import "errors"
+
+// readInfo returns an error, git support is disabled by the `swap_nogit` build tag.
+func (g *Repository) readInfo() error {
+	return errors.New("git support disabled by the swap_nogit build tag")
+}
+
+// readHistory returns an error, git support is disabled by the `swap_nogit` build tag.
+func (g *Repository) readHistory() error {
+	return g.readInfo()
+}
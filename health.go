@@ -0,0 +1,130 @@
+package swap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// HealthChecker interface ---------------------------------------------------------------------------------------------
+
+// HealthChecker interface allow built tools to report their health
+// (eg.: DB ping, broker connection), it is aggregated by HealthReport.
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// Health is the result of HealthReport.
+type Health struct {
+	// Checked are the field paths of the checked tools, sorted.
+	Checked []string
+
+	// Errors are the unhealthy tools errors, by field path.
+	Errors map[string]error
+}
+
+// Healthy returns true if all the checked tools are healthy.
+func (h Health) Healthy() bool {
+	return len(h.Errors) == 0
+}
+
+// MarshalJSON is the json.Marshaler implementation,
+// tools are encoded by path with their error message, or `ok`.
+func (h Health) MarshalJSON() ([]byte, error) {
+	tools := make(map[string]string, len(h.Checked))
+	for _, path := range h.Checked {
+		tools[path] = "ok"
+		if err := h.Errors[path]; err != nil {
+			tools[path] = err.Error()
+		}
+	}
+	return json.Marshal(struct {
+		Healthy bool
+		Tools   map[string]string
+	}{h.Healthy(), tools})
+}
+
+// HealthReport is HealthReportContext with context.Background().
+func HealthReport(toolBox interface{}) Health {
+	return HealthReportContext(context.Background(), toolBox)
+}
+
+// HealthReportContext walks the built toolBox and checks concurrently
+// the health of the tools implementing the `HealthChecker` interface,
+// their fields are not walked. Lazy fields are checked once built.
+func HealthReportContext(ctx context.Context, toolBox interface{}) Health {
+	checkers := make(map[string]HealthChecker)
+	collectHealthCheckers("", reflect.ValueOf(toolBox), checkers, map[uintptr]bool{})
+
+	health := Health{Errors: make(map[string]error)}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for path, checker := range checkers {
+		health.Checked = append(health.Checked, path)
+
+		wg.Add(1)
+		go func(path string, checker HealthChecker) {
+			defer wg.Done()
+			if err := checker.Health(ctx); err != nil {
+				mutex.Lock()
+				health.Errors[path] = err
+				mutex.Unlock()
+			}
+		}(path, checker)
+	}
+	wg.Wait()
+
+	sort.Strings(health.Checked)
+	return health
+}
+
+// HealthHandler returns the http.Handler serving the toolBox HealthReport as JSON,
+// with the 503 status code if any tool is unhealthy,
+// eg.: `mux.Handle("/healthz", swap.HealthHandler(&ToolBox))`.
+func HealthHandler(toolBox interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := HealthReportContext(r.Context(), toolBox)
+		w.Header().Set("Content-Type", "application/json")
+		if !health.Healthy() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(health)
+	})
+}
+
+// collectHealthCheckers add the HealthChecker tools of v to checkers, by field path,
+// visited holds the pointers already walked to stop cyclic references.
+func collectHealthCheckers(path string, v reflect.Value, checkers map[string]HealthChecker, visited map[uintptr]bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() || visited[v.Pointer()] {
+			return
+		}
+		visited[v.Pointer()] = true
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || !v.CanAddr() {
+		return
+	}
+
+	tool := v.Addr().Interface()
+	if lazy, ok := tool.(lazyField); ok {
+		if built := lazy.lazyBuilt(); built != nil {
+			collectHealthCheckers(path, reflect.ValueOf(built), checkers, visited)
+		}
+		return
+	}
+
+	if checker, ok := tool.(HealthChecker); ok && len(path) > 0 {
+		checkers[path] = checker
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		if sf := v.Type().Field(i); sf.IsExported() {
+			collectHealthCheckers(joinFieldPath(path, sf.Name), v.Field(i), checkers, visited)
+		}
+	}
+}
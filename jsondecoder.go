@@ -0,0 +1,74 @@
+package swap
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSON decoding -------------------------------------------------------------------------------------------------------
+
+// JSONDecoder decodes the JSON config files into the config structs,
+// it is pluggable (eg.: with encoding/json/v2) through SetJSONDecoder.
+type JSONDecoder interface {
+	Unmarshal(data []byte, v interface{}) error
+
+	// UnmarshalStrict decodes rejecting the unknown fields, for the strict parsing.
+	UnmarshalStrict(data []byte, v interface{}) error
+}
+
+// StdJSONDecoder is the encoding/json JSONDecoder.
+type StdJSONDecoder struct{}
+
+// Unmarshal is the JSONDecoder implementation.
+func (StdJSONDecoder) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// UnmarshalStrict is the JSONDecoder implementation.
+func (StdJSONDecoder) UnmarshalStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// FastJSONDecoder is the default JSONDecoder, github.com/json-iterator/go in its
+// encoding/json compatible mode, the strict decoding is left to encoding/json for its descriptive errors.
+type FastJSONDecoder struct{}
+
+var jsoniterAPI = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// Unmarshal is the JSONDecoder implementation.
+func (FastJSONDecoder) Unmarshal(data []byte, v interface{}) error {
+	return jsoniterAPI.Unmarshal(data, v)
+}
+
+// UnmarshalStrict is the JSONDecoder implementation, decoding with StdJSONDecoder.
+func (FastJSONDecoder) UnmarshalStrict(data []byte, v interface{}) error {
+	return StdJSONDecoder{}.UnmarshalStrict(data, v)
+}
+
+var jsonDecoder = struct {
+	sync.RWMutex
+	d JSONDecoder
+}{d: FastJSONDecoder{}}
+
+// SetJSONDecoder set the decoder of the JSON config files, a nil decoder restores the default one.
+func SetJSONDecoder(decoder JSONDecoder) {
+	jsonDecoder.Lock()
+	defer jsonDecoder.Unlock()
+
+	if decoder == nil {
+		decoder = FastJSONDecoder{}
+	}
+	jsonDecoder.d = decoder
+}
+
+// currentJSONDecoder returns the decoder of the JSON config files.
+func currentJSONDecoder() JSONDecoder {
+	jsonDecoder.RLock()
+	defer jsonDecoder.RUnlock()
+	return jsonDecoder.d
+}
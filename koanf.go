@@ -0,0 +1,172 @@
+package swap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codecs --------------------------------------------------------------------------------------------------------------
+
+// Codec decodes and encodes the config files of a format,
+// it is the github.com/knadh/koanf Parser interface,
+// so that the koanf parsers (eg.: hcl, hjson, dotenv) are valid codecs.
+type Codec interface {
+	Unmarshal(data []byte) (map[string]interface{}, error)
+	Marshal(tree map[string]interface{}) ([]byte, error)
+}
+
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{m: make(map[string]Codec)}
+
+// RegisterCodec register the codec of the files with the ext extension (eg.: `.hcl`),
+// registered codecs take precedence over the built-in formats, a nil codec unregister it.
+// The files are searched and parsed as the built-in formats ones, through JSON.
+func RegisterCodec(ext string, codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	ext = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+	if codec == nil {
+		delete(codecs.m, ext)
+		return
+	}
+	codecs.m[ext] = codec
+}
+
+// codecFor returns the codec registered for the file extension, if any.
+func codecFor(file string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	codec, found := codecs.m[strings.ToLower(filepath.Ext(file))]
+	return codec, found
+}
+
+// validExtPattern returns the pattern of the supported extensions,
+// the built-in and the registered codecs ones.
+func validExtPattern() string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	if len(codecs.m) == 0 {
+		return regexpValidExt.String()
+	}
+
+	exts := make([]string, 0, len(codecs.m))
+	for ext := range codecs.m {
+		exts = append(exts, regexp.QuoteMeta(ext))
+	}
+	sort.Strings(exts)
+	return strings.TrimSuffix(regexpValidExt.String(), ")") + "|" + strings.Join(exts, "|") + ")"
+}
+
+// isValidExt returns true if the extension is supported.
+func isValidExt(ext string) bool {
+	if regexpValidExt.MatchString(ext) {
+		return true
+	}
+	_, found := codecFor(ext)
+	return found
+}
+
+// codecToJSON returns the codec decoded data as JSON, the strings
+// are converted to the config fields types, since many formats have only strings.
+func (p *parser) codecToJSON(codec Codec, data []byte, config interface{}) ([]byte, error) {
+	tree, err := codec.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	converter := normalizer{wt: &WeakTyping{}}
+	return json.Marshal(converter.walk("", tree, reflect.TypeOf(config)))
+}
+
+// Koanf provider ------------------------------------------------------------------------------------------------------
+
+// KoanfProvider is a github.com/knadh/koanf Provider loading the config files
+// from a FileSystem, with their environment specific files, as ParseByEnv does,
+// eg.: `k.Load(swap.NewKoanfProvider(fsys, env, "config/app"), nil)`.
+type KoanfProvider struct {
+	fs    FileSystem
+	env   *Environment
+	files []string
+}
+
+// NewKoanfProvider returns the KoanfProvider of files, env can be nil.
+func NewKoanfProvider(fsys FileSystem, env *Environment, files ...string) *KoanfProvider {
+	return &KoanfProvider{fs: fsys, env: env, files: files}
+}
+
+// ReadBytes is not supported, the files can have different formats,
+// load the provider with a nil koanf Parser.
+func (kp *KoanfProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("swap koanf provider does not support this method, use a nil parser")
+}
+
+// Read returns the merged files trees, the latest files override the former,
+// references and env vars are resolved.
+func (kp *KoanfProvider) Read() (map[string]interface{}, error) {
+	p := newParser()
+	if kp.fs != nil {
+		p.fs = kp.fs
+	}
+
+	files, err := p.appendEnvFiles(kp.env, kp.files)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]interface{})
+	for _, file := range files {
+		var data []byte
+		if data, err = p.fs.ReadFile(file); err != nil {
+			return nil, err
+		}
+		if data, err = p.resolveRefs(file, data); err != nil {
+			return nil, err
+		}
+		if data, err = p.expandEnv(file, data, nil); err != nil {
+			return nil, err
+		}
+
+		var tree interface{}
+		if tree, err = decodeTree(file, data); err != nil {
+			return nil, err
+		}
+		if tree == nil {
+			continue
+		}
+		m, isMap := tree.(map[string]interface{})
+		if !isMap {
+			return nil, fmt.Errorf("%s: the root is not a map", file)
+		}
+		mergeTrees(merged, m)
+	}
+	return merged, nil
+}
+
+// mergeTrees merge src into dst, recursively, the src null values delete the dst keys.
+func mergeTrees(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeTrees(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
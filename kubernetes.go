@@ -0,0 +1,263 @@
+package swap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kubernetes FileSystems ----------------------------------------------------------------------------------------------
+
+// kubeDataDir is the projected volumes symlink to the current timestamped data dir,
+// swapped atomically on updates.
+const kubeDataDir = "..data"
+
+// kubeServiceAccount is the in-cluster service account mount.
+const kubeServiceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// NewFileSystemKubernetesMount returns the FileSystem serving the ConfigMap or Secret
+// volume mounted at dir (eg.: `/etc/myapp`) at the configPath (eg.: `./config`).
+// The projected volumes layout is handled: files are read from the
+// `..data` snapshot and the `..<timestamp>` entries are hidden.
+// Use NewFileSystemOverlay to merge multiple volumes.
+func NewFileSystemKubernetesMount(dir, configPath string) FileSystem {
+	return kubeMountFS{dir: dir, configPath: ioFSPath(configPath)}
+}
+
+type kubeMountFS struct {
+	dir        string
+	configPath string
+}
+
+func (k kubeMountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir, err := k.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := entries[:0]
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "..") {
+			visible = append(visible, entry)
+		}
+	}
+	return visible, nil
+}
+
+func (k kubeMountFS) ReadFile(name string) ([]byte, error) {
+	file, err := k.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(file)
+}
+
+// path returns the local path of the named file in the current data snapshot.
+func (k kubeMountFS) path(op, name string) (string, error) {
+	rel, err := mountedPath(k.configPath, op, name)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(rel, "..") {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	base := k.dir
+	if snapshot, err := filepath.EvalSymlinks(filepath.Join(k.dir, kubeDataDir)); err == nil {
+		base = snapshot
+	}
+	return filepath.Join(base, filepath.FromSlash(rel)), nil
+}
+
+// KubernetesConfig is the Kubernetes API FileSystem configuration.
+type KubernetesConfig struct {
+	// Namespace, the pod one by default.
+	Namespace string
+
+	// ConfigMaps and Secrets are the names of the objects whose keys
+	// are the config files, the latest objects override the former,
+	// Secrets override ConfigMaps.
+	ConfigMaps []string
+	Secrets    []string
+
+	// ConfigPath is where the keys are served (eg.: `./config`).
+	ConfigPath string
+
+	// Host is the API server address, the in-cluster one by default,
+	// (eg.: `http://127.0.0.1:8001` for `kubectl proxy`).
+	Host string
+
+	// TokenFile is the bearer token file, re-read on every request
+	// since tokens are rotated, the service account one by default.
+	TokenFile string
+
+	// CAFile is the API server CA, the service account one by default.
+	CAFile string
+
+	// Client is the HTTP client, if nil a new one is created using CAFile.
+	Client *http.Client
+}
+
+// KubernetesFileSystem is the FileSystem backed by ConfigMaps and Secrets
+// fetched from the Kubernetes API, so that no volume mount is needed.
+// Objects are fetched on every read, use NewFileSystemCached to fetch them once.
+type KubernetesFileSystem struct {
+	config KubernetesConfig
+}
+
+// NewFileSystemKubernetes returns the FileSystem backed by the ConfigMaps
+// and Secrets of the Kubernetes API, the in-cluster one by default.
+func NewFileSystemKubernetes(config KubernetesConfig) (*KubernetesFileSystem, error) {
+	if len(config.Namespace) == 0 {
+		namespace, _ := ioutil.ReadFile(filepath.Join(kubeServiceAccount, "namespace"))
+		if config.Namespace = strings.TrimSpace(string(namespace)); len(config.Namespace) == 0 {
+			config.Namespace = "default"
+		}
+	}
+	if len(config.Host) == 0 {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if len(host) == 0 || len(port) == 0 {
+			return nil, fmt.Errorf("kubernetes: not running in a cluster, the host is required")
+		}
+		config.Host = "https://" + net.JoinHostPort(host, port)
+	}
+	config.Host = strings.TrimSuffix(config.Host, "/")
+	if len(config.TokenFile) == 0 {
+		config.TokenFile = filepath.Join(kubeServiceAccount, "token")
+	}
+	if len(config.CAFile) == 0 {
+		config.CAFile = filepath.Join(kubeServiceAccount, "ca.crt")
+	}
+	config.ConfigPath = ioFSPath(config.ConfigPath)
+
+	if config.Client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if strings.HasPrefix(config.Host, "https://") {
+			pem, err := ioutil.ReadFile(config.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("kubernetes: %s", err.Error())
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("kubernetes: no certificates found in '%s'", config.CAFile)
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+		config.Client = &http.Client{Transport: transport}
+	}
+
+	return &KubernetesFileSystem{config: config}, nil
+}
+
+// ReadDir returns the keys of the objects.
+func (k *KubernetesFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	if ioFSPath(name) != k.config.ConfigPath {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	files, err := k.fetch()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(files))
+	for key := range files {
+		entries = append(entries, memDirEntry{name: key})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile returns the value of the key.
+func (k *KubernetesFileSystem) ReadFile(name string) ([]byte, error) {
+	key, err := mountedPath(k.config.ConfigPath, "readfile", name)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := k.fetch()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	data, found := files[key]
+	if !found {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+	return data, nil
+}
+
+// fetch returns the keys of all the objects, merged.
+func (k *KubernetesFileSystem) fetch() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	for _, name := range k.config.ConfigMaps {
+		var configMap struct {
+			Data       map[string]string `json:"data"`
+			BinaryData map[string][]byte `json:"binaryData"`
+		}
+		if err := k.get("configmaps", name, &configMap); err != nil {
+			return nil, err
+		}
+		for key, value := range configMap.Data {
+			files[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			files[key] = value
+		}
+	}
+
+	for _, name := range k.config.Secrets {
+		var secret struct {
+			Data map[string][]byte `json:"data"`
+		}
+		if err := k.get("secrets", name, &secret); err != nil {
+			return nil, err
+		}
+		for key, value := range secret.Data {
+			files[key] = value
+		}
+	}
+
+	return files, nil
+}
+
+// get decodes the named object of the resource.
+func (k *KubernetesFileSystem) get(resource, name string, object interface{}) error {
+	endpoint := fmt.Sprintf("%s/api/v1/namespaces/%s/%s/%s",
+		k.config.Host, url.PathEscape(k.config.Namespace), resource, url.PathEscape(name))
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token, err := ioutil.ReadFile(k.config.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := k.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kubernetes: %s %s/%s: unexpected status: %s", resource, k.config.Namespace, name, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(object)
+}
@@ -0,0 +1,198 @@
+package swap
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy fields ---------------------------------------------------------------------------------------------------------
+
+// Lazy wraps a tool configured on the first Get call instead of during Build,
+// if the field has the `lazy` flag (eg.: `swap:"lazy"`) or the Builder the WithLazy option,
+// otherwise the tool is configured by Build as any other field.
+// It cuts the cold-start time of the rarely used tools (eg.: remote connections).
+//
+//	type ToolBox struct {
+//	    Reports swap.Lazy[Warehouse] `swap:"lazy"`
+//	}
+//
+//	warehouse, err := toolBox.Reports.Get()
+//
+// The tool is configured once, copies of a Lazy share it.
+// Get waits for the running Build, it must not be called by the tools during Build.
+type Lazy[T any] struct {
+	state *lazyState[T]
+}
+
+type lazyState[T any] struct {
+	once  sync.Once
+	build func(v reflect.Value) error
+	value *T
+	err   error
+
+	// built is set once value and err are, for the readers outside once.
+	built atomic.Bool
+}
+
+// Get returns the tool, configuring it on the first call.
+// The configuration error is returned on every call.
+func (l *Lazy[T]) Get() (*T, error) {
+	if l.state == nil {
+		return nil, fmt.Errorf("swap.Lazy[%s] not built", reflect.TypeOf((*T)(nil)).Elem().String())
+	}
+
+	l.state.once.Do(func() {
+		value := new(T)
+		if l.state.err = l.state.build(reflect.ValueOf(value).Elem()); l.state.err == nil {
+			l.state.value = value
+		}
+		l.state.built.Store(true)
+	})
+	return l.state.value, l.state.err
+}
+
+// MustGet is Get which panics on errors.
+func (l *Lazy[T]) MustGet() *T {
+	value, err := l.Get()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Built returns true if the tool has already been configured.
+func (l *Lazy[T]) Built() bool {
+	return l.state != nil && l.state.built.Load()
+}
+
+func (l *Lazy[T]) lazyType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (l *Lazy[T]) lazyInit(build func(v reflect.Value) error) {
+	l.state = &lazyState[T]{build: build}
+}
+
+func (l *Lazy[T]) lazyResolve() error {
+	_, err := l.Get()
+	return err
+}
+
+func (l *Lazy[T]) lazyInitialized() bool {
+	return l.state != nil
+}
+
+func (l *Lazy[T]) lazyBuilt() interface{} {
+	if l.state == nil || !l.state.built.Load() || l.state.value == nil {
+		return nil
+	}
+	return l.state.value
+}
+
+// lazyField is implemented by Lazy.
+type lazyField interface {
+	lazyType() reflect.Type
+	lazyInit(build func(v reflect.Value) error)
+	lazyResolve() error
+	lazyInitialized() bool
+	lazyBuilt() interface{}
+}
+
+// buildLazy set the thunk configuring the Lazy field tool,
+// the tool is configured right away unless the field or the Builder are lazy.
+func (s *Builder) buildLazy(path string, sf *reflect.StructField, lazy lazyField, level int) (logs []string, err error) {
+	if lazy.lazyInitialized() {
+		return []string{s.logField(path, sf, stateAlreadyConfigured, nil, level, []string{})}, nil
+	}
+
+	tags := s.parseTags(sf)
+	if tags.skip {
+		if fieldLog := s.logField(path, sf, stateSkipped, nil, level, []string{}); !s.DebugOptions.HideSkipped {
+			logs = append(logs, fieldLog)
+		}
+		return logs, nil
+	}
+
+	// the tool is configured as a field of type T, without the lazy flag
+	field := *sf
+	field.Type = lazy.lazyType()
+	field.Tag = s.withoutLazyFlag(sf.Tag)
+
+	if !tags.lazy && !s.lazy {
+		lazy.lazyInit(func(v reflect.Value) error {
+			logs, err = s.buildLazyValue(path, &field, v, level)
+			return err
+		})
+		_ = lazy.lazyResolve()
+		return logs, err
+	}
+
+	lazy.lazyInit(func(v reflect.Value) error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		_, err := s.buildLazyValue(path, &field, v, level)
+		return err
+	})
+	return []string{s.logField(path, sf, stateLazy, nil, level, []string{})}, nil
+}
+
+// buildLazyValue build the tool of the Lazy field at path, then apply its path overrides
+// and validate it, as Build does for the other fields once built.
+func (s *Builder) buildLazyValue(path string, sf *reflect.StructField, v reflect.Value, level int) (logs []string, err error) {
+	if logs, err = s.build(path, sf, v, level); err != nil {
+		return logs, err
+	}
+	if err = s.applyPathOverrides(path, v); err != nil {
+		return logs, err
+	}
+	return logs, s.validate(path, v)
+}
+
+// withoutLazyFlag returns the tag without the `lazy` flag of the builder key,
+// the other keys are kept as they are.
+func (s *Builder) withoutLazyFlag(tag reflect.StructTag) reflect.StructTag {
+	var pairs []string
+	for rest := strings.TrimLeft(string(tag), " "); len(rest) > 0; rest = strings.TrimLeft(rest, " ") {
+		// key:"value", as parsed by reflect.StructTag.Lookup
+		colon := strings.Index(rest, ":\"")
+		if colon <= 0 {
+			break
+		}
+		end := colon + 2
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			break
+		}
+		key, pair := rest[:colon], rest[:end+1]
+		rest = rest[end+1:]
+
+		if key == s.tagKey {
+			value, err := strconv.Unquote(pair[colon+1:])
+			if err != nil {
+				continue
+			}
+			var flags []string
+			for _, flag := range strings.Split(value, ",") {
+				if flag != sffBuilderLazy && len(flag) > 0 {
+					flags = append(flags, flag)
+				}
+			}
+			if len(flags) == 0 {
+				continue
+			}
+			pair = fmt.Sprintf("%s:%q", key, strings.Join(flags, ","))
+		}
+		pairs = append(pairs, pair)
+	}
+	return reflect.StructTag(strings.Join(pairs, " "))
+}
@@ -0,0 +1,122 @@
+package swap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Shutdowner interface ------------------------------------------------------------------------------------------------
+
+// Shutdowner interface allow built tools to release their resources
+// (DB pools, clients, listeners...) when the application stops.
+// Tools implementing `io.Closer` are torn down as well.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// builtTool is a tool recorded during Build,
+// it may implement Shutdowner or io.Closer.
+type builtTool struct {
+	name string
+	path string
+	tool interface{}
+}
+
+// record keep track of the just built field value
+// if it implements one of the lifecycle interfaces.
+func (s *Builder) record(path string, sf *reflect.StructField, fv reflect.Value) {
+	if sf == nil || !fv.CanAddr() {
+		return
+	}
+
+	tool := fv.Addr().Interface()
+	switch tool.(type) {
+	case Shutdowner, io.Closer:
+		s.builtMutex.Lock()
+		defer s.builtMutex.Unlock()
+		// tools rebuilt in place (eg.: by BuildFields) are recorded once
+		for _, built := range s.built {
+			if built.tool == tool {
+				return
+			}
+		}
+		s.built = append(s.built, builtTool{name: sf.Name, path: path, tool: tool})
+	}
+}
+
+// unrecord shut down and forget the built tools at the given path
+// and below (eg.: the sub-tools of a degraded field), in reverse build order.
+// Errors are ignored, the field one is reported.
+func (s *Builder) unrecord(path string) {
+	var removed []builtTool
+
+	s.builtMutex.Lock()
+	kept := s.built[:0]
+	for _, bt := range s.built {
+		if bt.path == path || strings.HasPrefix(bt.path, path+".") {
+			removed = append(removed, bt)
+		} else {
+			kept = append(kept, bt)
+		}
+	}
+	s.built = kept
+	s.builtMutex.Unlock()
+
+	for i := len(removed) - 1; i >= 0; i-- {
+		_ = shutdownTool(context.Background(), removed[i].tool)
+	}
+}
+
+// unrecordSince shut down and forget the tools recorded after the first n ones
+// (eg.: the new sub-tools of a failed rebuild), in reverse build order.
+func (s *Builder) unrecordSince(n int) {
+	s.builtMutex.Lock()
+	removed := append([]builtTool(nil), s.built[n:]...)
+	s.built = s.built[:n]
+	s.builtMutex.Unlock()
+
+	for i := len(removed) - 1; i >= 0; i-- {
+		_ = shutdownTool(context.Background(), removed[i].tool)
+	}
+}
+
+// Shutdown tears down all the built tools implementing
+// the `Shutdowner` or the `io.Closer` interface in reverse build order.
+// All the tools are shut down even if some of them return an error,
+// errors are then returned altogether.
+func (s *Builder) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var errs []string
+	for i := len(s.built) - 1; i >= 0; i-- {
+		bt := s.built[i]
+
+		if err := shutdownTool(ctx, bt.tool); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", bt.name, err.Error()))
+		}
+	}
+	s.built = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("shutdown failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// shutdownTool tears down the tool if it implements
+// the `Shutdowner` or the `io.Closer` interface.
+func shutdownTool(ctx context.Context, tool interface{}) (err error) {
+	switch tool := tool.(type) {
+	case Shutdowner:
+		err = tool.Shutdown(ctx)
+	case io.Closer:
+		if err = ctx.Err(); err == nil {
+			err = tool.Close()
+		}
+	}
+	return
+}
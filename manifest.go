@@ -0,0 +1,206 @@
+package swap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Config manifest -----------------------------------------------------------------------------------------------------
+
+// Manifest describe the config files loaded by the last Build,
+// instances of the same service loading the same config have the same Hash.
+type Manifest struct {
+	Environment string `json:"environment"`
+
+	// Files are the sha256 of the loaded files, by path relative to the config path.
+	Files map[string]string `json:"files"`
+
+	// Hash is the sha256 of the sorted files paths and hashes.
+	Hash string `json:"hash"`
+}
+
+// recordFiles keep track of the config files found during Build, with their hash.
+func (s *Builder) recordFiles(files []string) {
+	s.observeConfigFiles(files)
+
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		if data, err := s.fs.ReadFile(file); err == nil {
+			sum := sha256.Sum256(data)
+			hashes[file] = hex.EncodeToString(sum[:])
+		}
+	}
+
+	s.loadedFilesMutex.Lock()
+	defer s.loadedFilesMutex.Unlock()
+
+	if s.loadedFiles == nil {
+		s.loadedFiles = make(map[string]string)
+	}
+	for file, hash := range hashes {
+		s.loadedFiles[file] = hash
+	}
+}
+
+// Manifest returns the manifest of the config files loaded by the last Build.
+func (s *Builder) Manifest() Manifest {
+	s.loadedFilesMutex.Lock()
+	defer s.loadedFilesMutex.Unlock()
+
+	manifest := Manifest{
+		Environment: s.EnvHandler.Current().Tag(),
+		Files:       make(map[string]string, len(s.loadedFiles)),
+	}
+
+	paths := make([]string, 0, len(s.loadedFiles))
+	for file, hash := range s.loadedFiles {
+		path := file
+		if rel, err := filepath.Rel(s.configPath, file); err == nil {
+			path = filepath.ToSlash(rel)
+		}
+		manifest.Files[path] = hash
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(hash, "%s\x00%s\n", path, manifest.Files[path])
+	}
+	manifest.Hash = hex.EncodeToString(hash.Sum(nil))
+	return manifest
+}
+
+// DebugHandler returns the http.Handler serving the Manifest as JSON,
+// eg.: `mux.Handle("/debug/swap", builder.DebugHandler())`.
+func (s *Builder) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Manifest())
+	})
+}
+
+// Fleet check ---------------------------------------------------------------------------------------------------------
+
+// FleetReport is the result of FleetCheck.
+type FleetReport struct {
+	// Targets are the instances debug handlers URLs, by manifest hash.
+	Targets map[string][]string
+
+	// Manifests are the fetched manifests, by target.
+	Manifests map[string]Manifest
+
+	// Errors are the unreachable targets errors, by target.
+	Errors map[string]error
+}
+
+// Consistent returns true if all the targets are reachable
+// and have loaded the same config files.
+func (fr FleetReport) Consistent() bool {
+	return len(fr.Errors) == 0 && len(fr.Targets) <= 1
+}
+
+// String returns the human readable report.
+func (fr FleetReport) String() string {
+	var report strings.Builder
+
+	hashes := make([]string, 0, len(fr.Targets))
+	for hash := range fr.Targets {
+		hashes = append(hashes, hash)
+	}
+	// the majority first
+	sort.Slice(hashes, func(i, j int) bool {
+		if len(fr.Targets[hashes[i]]) == len(fr.Targets[hashes[j]]) {
+			return hashes[i] < hashes[j]
+		}
+		return len(fr.Targets[hashes[i]]) > len(fr.Targets[hashes[j]])
+	})
+	for _, hash := range hashes {
+		fmt.Fprintf(&report, "%s (%d):\n", hash, len(fr.Targets[hash]))
+		for _, target := range fr.Targets[hash] {
+			fmt.Fprintf(&report, "  %s\n", target)
+		}
+	}
+
+	targets := make([]string, 0, len(fr.Errors))
+	for target := range fr.Errors {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		fmt.Fprintf(&report, "unreachable %s: %s\n", target, fr.Errors[target].Error())
+	}
+
+	if fr.Consistent() {
+		report.WriteString("consistent\n")
+	} else {
+		report.WriteString("drift detected\n")
+	}
+	return report.String()
+}
+
+// FleetCheck fetch the Manifest from every target (the instances DebugHandler URL)
+// concurrently and group them by hash, detecting the instances which loaded stale config.
+func FleetCheck(ctx context.Context, client *http.Client, targets []string) FleetReport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	report := FleetReport{
+		Targets:   make(map[string][]string),
+		Manifests: make(map[string]Manifest),
+		Errors:    make(map[string]error),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+
+			manifest, err := fetchManifest(ctx, client, target)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				report.Errors[target] = err
+				return
+			}
+			report.Manifests[target] = manifest
+			report.Targets[manifest.Hash] = append(report.Targets[manifest.Hash], target)
+		}(target)
+	}
+	wg.Wait()
+
+	for _, hashTargets := range report.Targets {
+		sort.Strings(hashTargets)
+	}
+	return report
+}
+
+func fetchManifest(ctx context.Context, client *http.Client, target string) (manifest Manifest, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return manifest, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return manifest, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return manifest, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	return manifest, err
+}
@@ -0,0 +1,217 @@
+package swap
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Slices and maps merge -----------------------------------------------------------------------------------------------
+
+// SliceMerge is how the slices set by more config files are merged.
+type SliceMerge string
+
+const (
+	// SliceMergeReplace keep the latest file slice, the default.
+	SliceMergeReplace SliceMerge = "replace"
+
+	// SliceMergeAppend append the latest file items to the former ones.
+	SliceMergeAppend SliceMerge = "append"
+
+	// SliceMergeUnique append the latest file items not already there.
+	SliceMergeUnique SliceMerge = "unique"
+)
+
+// detachedField is a slice or map field detached from the config while a file is parsed,
+// fields are the struct fields path from the config to it.
+type detachedField struct {
+	fv       reflect.Value
+	saved    reflect.Value
+	strategy SliceMerge
+	fields   []reflect.StructField
+}
+
+// detachFields reset the map fields and the slice fields merged by append or unique,
+// so that the fields set by the next file can be told apart and merged by mergeFields,
+// whatever the file format decoder.
+func (p *parser) detachFields(path string, parents []reflect.StructField, v reflect.Value) (detached []detachedField, err error) {
+	v = reflect.Indirect(v)
+	if v.Kind() == reflect.Map && v.CanSet() && v.Len() > 0 {
+		// map configs merge as the map fields
+		detached = append(detached, detachedField{fv: v, saved: reflect.ValueOf(v.Interface())})
+		v.Set(reflect.Zero(v.Type()))
+		return detached, nil
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		ft := v.Type().Field(i)
+		fv := v.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		fieldPath := joinFieldPath(path, ft.Name)
+		fields := append(append([]reflect.StructField{}, parents...), ft)
+
+		switch {
+		case fv.Kind() == reflect.Slice:
+			strategy := p.sliceMerge
+			if merge := flagValue(splitFlags(ft.Tag.Get(p.tagKey)), sffConfigMerge); len(merge) > 0 {
+				strategy = SliceMerge(merge)
+			}
+			switch strategy {
+			case "", SliceMergeReplace:
+				continue
+			case SliceMergeAppend, SliceMergeUnique:
+			default:
+				return nil, &FieldError{Path: fieldPath, Tag: sffConfigMerge, Err: fmt.Errorf(
+					"unknown merge strategy '%s', must be one of: replace, append, unique", strategy)}
+			}
+			if fv.Len() > 0 {
+				detached = append(detached, detachedField{fv: fv, saved: reflect.ValueOf(fv.Interface()), strategy: strategy, fields: fields})
+				fv.Set(reflect.Zero(fv.Type()))
+			}
+
+		case fv.Kind() == reflect.Map:
+			if fv.Len() > 0 {
+				detached = append(detached, detachedField{fv: fv, saved: reflect.ValueOf(fv.Interface()), fields: fields})
+				fv.Set(reflect.Zero(fv.Type()))
+			}
+
+		case fv.Kind() == reflect.Struct || (fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct):
+			nested, err := p.detachFields(fieldPath, fields, fv)
+			if err != nil {
+				return nil, err
+			}
+			detached = append(detached, nested...)
+		}
+	}
+	return detached, nil
+}
+
+// mergeFields merge the detached fields with the file ones, the fields not set by the file are restored.
+// Maps deep-merge, an explicit null in the file deletes the key, tree is the decoded file, if any.
+func mergeFields(detached []detachedField, tree interface{}) {
+	for _, d := range detached {
+		switch {
+		case d.fv.IsNil():
+			d.fv.Set(d.saved)
+		case d.fv.Kind() == reflect.Map:
+			d.fv.Set(mergeMaps(d.saved, d.fv, treeNode(tree, d.fields)))
+		default:
+			d.fv.Set(mergeSlices(d.saved, d.fv, d.strategy))
+		}
+	}
+}
+
+// mergeSlices returns the src items appended to dst, skipping the ones already there for SliceMergeUnique.
+func mergeSlices(dst, src reflect.Value, strategy SliceMerge) reflect.Value {
+	merged := reflect.AppendSlice(reflect.MakeSlice(dst.Type(), 0, dst.Len()+src.Len()), dst)
+	for i := 0; i < src.Len(); i++ {
+		item := src.Index(i)
+		if strategy == SliceMergeUnique && containsValue(merged, item) {
+			continue
+		}
+		merged = reflect.Append(merged, item)
+	}
+	return merged
+}
+
+// mergeMaps returns a new map with the src entries deep-merged into the dst ones,
+// node is the src map in the decoded file: its null values delete the dst keys,
+// whatever the map values type. The nil src values delete the dst keys too.
+func mergeMaps(dst, src reflect.Value, node interface{}) reflect.Value {
+	nodeMap, _ := node.(map[string]interface{})
+
+	merged := reflect.MakeMapWithSize(dst.Type(), dst.Len())
+	iter := dst.MapRange()
+	for iter.Next() {
+		merged.SetMapIndex(iter.Key(), iter.Value())
+	}
+
+	iter = src.MapRange()
+	for iter.Next() {
+		value := iter.Value()
+		child, inNode := nodeMap[fmt.Sprint(iter.Key().Interface())]
+		if isNil(value) || (inNode && child == nil) {
+			merged.SetMapIndex(iter.Key(), reflect.Value{})
+			continue
+		}
+		if existing := merged.MapIndex(iter.Key()); existing.IsValid() {
+			existingMap, valueMap := unwrapInterface(existing), unwrapInterface(value)
+			if existingMap.Kind() == reflect.Map && valueMap.Kind() == reflect.Map && existingMap.Type() == valueMap.Type() {
+				value = mergeMaps(existingMap, valueMap, child)
+			}
+		}
+		merged.SetMapIndex(iter.Key(), value)
+	}
+
+	// the null keys not decoded in src
+	if dst.Type().Key().Kind() == reflect.String {
+		for key, child := range nodeMap {
+			if child == nil {
+				merged.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), reflect.Value{})
+			}
+		}
+	}
+	return merged
+}
+
+// treeNode returns the node of the decoded file tree at the struct fields path,
+// the keys match the fields `yaml`, `json` or `toml` tag names or the fields names, case-insensitively.
+func treeNode(tree interface{}, fields []reflect.StructField) interface{} {
+	node := tree
+	for _, sf := range fields {
+		m, isMap := node.(map[string]interface{})
+		if !isMap {
+			return nil
+		}
+		node = nil
+		for key, child := range m {
+			if fieldHasKey(sf, key) {
+				node = child
+				break
+			}
+		}
+	}
+	return node
+}
+
+// fieldHasKey returns true if the file key maps to the struct field.
+func fieldHasKey(sf reflect.StructField, key string) bool {
+	for _, tagKey := range []string{"yaml", "json", "toml"} {
+		if name := strings.Split(sf.Tag.Get(tagKey), ",")[0]; len(name) > 0 && name != "-" && strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return strings.EqualFold(sf.Name, key)
+}
+
+// isNil returns true for the nil interfaces, pointers, maps and slices.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// unwrapInterface returns the value held by the interface v, or v itself.
+func unwrapInterface(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		return v.Elem()
+	}
+	return v
+}
+
+// containsValue returns true if the slice contains a deeply equal item.
+func containsValue(slice, item reflect.Value) bool {
+	for i := 0; i < slice.Len(); i++ {
+		if reflect.DeepEqual(slice.Index(i).Interface(), item.Interface()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package swap
+
+import (
+	"expvar"
+	"reflect"
+	"time"
+)
+
+// MetricsHook interface -----------------------------------------------------------------------------------------------
+
+// MetricsHook receive the time spent to make or configure every field,
+// labels are taken from the `metrics` field tag
+// (eg.: `swap:"metrics=component:db|team:core"`), so that
+// dashboards can break down the startup latency by component.
+// It must be safe for concurrent use if the Builder Concurrency is > 1.
+type MetricsHook interface {
+	ObserveField(path string, labels map[string]string, duration time.Duration, err error)
+}
+
+// WithMetricsHook set the Builder MetricsHook.
+func WithMetricsHook(hook MetricsHook) Option {
+	return func(s *Builder) {
+		s.metricsHook = hook
+	}
+}
+
+// observe call the MetricsHook, if any.
+func (s *Builder) observe(path string, sf *reflect.StructField, duration time.Duration, err error) {
+	if s.metricsHook == nil || sf == nil {
+		return
+	}
+	s.metricsHook.ObserveField(path, s.parseTags(sf).labels, duration, err)
+}
+
+// ConfigMetricsHook interface -----------------------------------------------------------------------------------------
+
+// ConfigMetricsHook is an optional MetricsHook extension, it receives the config operations,
+// eg.: to alert when hot-reload starts failing in production.
+// The configured fields are received by ObserveField.
+type ConfigMetricsHook interface {
+	MetricsHook
+
+	// ObserveConfigFiles receive the config files read to configure a field.
+	ObserveConfigFiles(files []string)
+
+	// ObserveParse receive the config files parsed by Builder.Parse and the time spent.
+	ObserveParse(files []string, duration time.Duration, err error)
+
+	// ObserveReload receive the field reloaded by Reload or Rebuild and the time spent,
+	// the Reload drain period included.
+	ObserveReload(path string, duration time.Duration, err error)
+}
+
+// configMetrics returns the ConfigMetricsHook, nil if the MetricsHook does not implement it.
+func (s *Builder) configMetrics() ConfigMetricsHook {
+	hook, _ := s.metricsHook.(ConfigMetricsHook)
+	return hook
+}
+
+// observeConfigFiles call the ConfigMetricsHook, if any.
+func (s *Builder) observeConfigFiles(files []string) {
+	if hook := s.configMetrics(); hook != nil && len(files) > 0 {
+		hook.ObserveConfigFiles(files)
+	}
+}
+
+// observeParse call the ConfigMetricsHook, if any.
+func (s *Builder) observeParse(files []string, start time.Time, err error) {
+	if hook := s.configMetrics(); hook != nil {
+		hook.ObserveParse(files, time.Since(start), err)
+	}
+}
+
+// observeReload call the ConfigMetricsHook, if any.
+func (s *Builder) observeReload(path string, start time.Time, err error) {
+	if hook := s.configMetrics(); hook != nil {
+		hook.ObserveReload(path, time.Since(start), err)
+	}
+}
+
+// Expvar metrics ------------------------------------------------------------------------------------------------------
+
+// ExpvarMetrics is a ConfigMetricsHook publishing its counters with expvar
+// (served at `/debug/vars` by the expvar package handler).
+// The successes and the failures are counted apart (eg.: `parses` and `parse_failures`),
+// the durations of both are published as cumulative histograms
+// (eg.: `parse_duration`: {"count": 3, "sum_ns": 1520334, "le_1ms": 2, "le_10ms": 3, ...}).
+type ExpvarMetrics struct {
+	vars *expvar.Map
+}
+
+// durationBuckets are the ExpvarMetrics histograms buckets upper bounds.
+var durationBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// NewExpvarMetrics return a new *ExpvarMetrics publishing its counters with the given name,
+// it panics if the name is already in use, as expvar.Publish.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{vars: expvar.NewMap(name)}
+	for _, histogram := range []string{"fields_duration", "parse_duration", "reload_duration"} {
+		h := new(expvar.Map).Init()
+		h.Set("count", new(expvar.Int))
+		h.Set("sum_ns", new(expvar.Int))
+		for _, bucket := range durationBuckets {
+			h.Set("le_"+bucket.String(), new(expvar.Int))
+		}
+		m.vars.Set(histogram, h)
+	}
+	return m
+}
+
+// Vars returns the published counters.
+func (m *ExpvarMetrics) Vars() *expvar.Map {
+	return m.vars
+}
+
+// ObserveField is the MetricsHook implementation.
+func (m *ExpvarMetrics) ObserveField(path string, labels map[string]string, duration time.Duration, err error) {
+	m.observe("fields_configured", "fields_failed", "fields_duration", duration, err)
+}
+
+// ObserveConfigFiles is the ConfigMetricsHook implementation.
+func (m *ExpvarMetrics) ObserveConfigFiles(files []string) {
+	m.vars.Add("config_files_read", int64(len(files)))
+}
+
+// ObserveParse is the ConfigMetricsHook implementation,
+// the parsed files are not counted in `config_files_read`, the fields ones only.
+func (m *ExpvarMetrics) ObserveParse(files []string, duration time.Duration, err error) {
+	m.observe("parses", "parse_failures", "parse_duration", duration, err)
+}
+
+// ObserveReload is the ConfigMetricsHook implementation.
+func (m *ExpvarMetrics) ObserveReload(path string, duration time.Duration, err error) {
+	m.observe("reloads", "reload_failures", "reload_duration", duration, err)
+}
+
+// observe increments the successes counter, or the failures one on errors,
+// and adds the duration to the histogram.
+func (m *ExpvarMetrics) observe(successes, failures, histogram string, duration time.Duration, err error) {
+	if err != nil {
+		m.vars.Add(failures, 1)
+	} else {
+		m.vars.Add(successes, 1)
+	}
+
+	h := m.vars.Get(histogram).(*expvar.Map)
+	h.Add("count", 1)
+	h.Add("sum_ns", duration.Nanoseconds())
+	for _, bucket := range durationBuckets {
+		if duration <= bucket {
+			h.Add("le_"+bucket.String(), 1)
+		}
+	}
+}
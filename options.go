@@ -0,0 +1,128 @@
+package swap
+
+import (
+	"log"
+	"log/slog"
+	"os"
+)
+
+// Logger interface ----------------------------------------------------------------------------------------------------
+
+// Logger is the Builder output interface, *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// defaultLogger prints to the stdOut.
+var defaultLogger Logger = log.New(os.Stdout, "", 0)
+
+// Functional options --------------------------------------------------------------------------------------------------
+
+// Option is a Builder functional option.
+type Option func(*Builder)
+
+// WithFileSystem set the FileSystem used to search the config files,
+// the local file system is used by default.
+func WithFileSystem(fs FileSystem) Option {
+	return func(s *Builder) {
+		s.fs = fs
+	}
+}
+
+// WithEnvHandler set a custom EnvironmentHandler,
+// by default it handles the `DefaultEnvs`.
+func WithEnvHandler(eh *EnvironmentHandler) Option {
+	return func(s *Builder) {
+		s.EnvHandler = eh
+	}
+}
+
+// WithProfileHandler set the ProfileHandler,
+// the config files dimensions after the environment one (eg.: region, cluster).
+func WithProfileHandler(ph *ProfileHandler) Option {
+	return func(s *Builder) {
+		s.ProfileHandler = ph
+	}
+}
+
+// WithLogger set the Logger used to print the environment
+// info and the debug tree, the stdOut is used by default.
+func WithLogger(logger Logger) Option {
+	return func(s *Builder) {
+		s.logger = logger
+	}
+}
+
+// WithSlog set the *slog.Logger used instead of the Logger, the destination,
+// level and format of the output are controlled by its handler:
+// the environment is logged at the Info level, the override tokens at the Warn level,
+// the debug tree fields at the Debug level (Warn if degraded, Error if failed) with their report as attributes.
+func WithSlog(logger *slog.Logger) Option {
+	return func(s *Builder) {
+		s.slogger = logger
+	}
+}
+
+// WithDebug enable or disable the debug tree.
+func WithDebug(enabled bool) Option {
+	return func(s *Builder) {
+		s.DebugOptions.Enabled = enabled
+	}
+}
+
+// WithVerbosity set the amount of debug output, VerbosityFull by default.
+func WithVerbosity(verbosity Verbosity) Option {
+	return func(s *Builder) {
+		s.DebugOptions.Verbosity = verbosity
+	}
+}
+
+// WithCaseSensitiveFileSearch enable or disable the case sensitive
+// config files search, it is disabled by default.
+func WithCaseSensitiveFileSearch(enabled bool) Option {
+	return func(s *Builder) {
+		s.caseSensitive = enabled
+	}
+}
+
+// WithRecursiveFileSearch enable or disable the config files search
+// in the config path sub-directories, it is disabled by default.
+// Single fields can be searched recursively with the `**/` tag prefix (eg.: `swap:"**/Tool"`).
+func WithRecursiveFileSearch(enabled bool) Option {
+	return func(s *Builder) {
+		s.recursive = enabled
+	}
+}
+
+// WithLegacyTOML enable or disable the decoding of the TOML files with github.com/BurntSushi/toml,
+// as the former versions, instead of the TOML 1.0 compliant github.com/pelletier/go-toml/v2.
+func WithLegacyTOML(enabled bool) Option {
+	return func(s *Builder) {
+		s.legacyTOML = enabled
+	}
+}
+
+// WithLazy enable or disable the lazy configuration of all the swap.Lazy fields,
+// without the `lazy` flag they are configured by Build.
+func WithLazy(enabled bool) Option {
+	return func(s *Builder) {
+		s.lazy = enabled
+	}
+}
+
+// WithForceTemplates enable or disable the text/template pass on every config file, as the former versions,
+// by default only the files containing `{{` placeholders are parsed as templates.
+func WithForceTemplates(enabled bool) Option {
+	return func(s *Builder) {
+		s.forceTemplates = enabled
+	}
+}
+
+// WithTagKey set the builder struct field tag key, `swap` by default,
+// the config parser tag key used by Builder.Parse will be `<key>cp`.
+func WithTagKey(key string) Option {
+	return func(s *Builder) {
+		s.tagKey = key
+		s.configTagKey = key + "cp"
+	}
+}
@@ -0,0 +1,252 @@
+package swap
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signed override tokens ----------------------------------------------------------------------------------------------
+
+// OverrideTokenEnvKey is the system environment variable key
+// of the operator-supplied override token.
+const OverrideTokenEnvKey = "SWAP_OVERRIDE_TOKEN"
+
+// ErrOverrideTokenExpired is returned by VerifyOverrideToken for the expired tokens,
+// they are ignored by Build with a warning.
+var ErrOverrideTokenExpired = errors.New("expired override token")
+
+// protectedEnvKeys are the env vars the override token can't set,
+// protectedEnvPrefixes the env vars prefixes.
+var (
+	protectedEnvKeys = map[string]bool{
+		OverrideTokenEnvKey: true, "PATH": true, "HOME": true, "SHELL": true, "IFS": true, "USER": true, "TMPDIR": true,
+		"GODEBUG": true, "GOTRACEBACK": true, "GOMAXPROCS": true, "GOGC": true, "GOMEMLIMIT": true,
+		"SSL_CERT_FILE": true, "SSL_CERT_DIR": true, "HTTP_PROXY": true, "HTTPS_PROXY": true, "NO_PROXY": true,
+	}
+	protectedEnvPrefixes = []string{"LD_", "DYLD_"}
+)
+
+// OverrideClaims are the override token (JWT) claims.
+type OverrideClaims struct {
+	// Env, if not empty, force the current environment tag.
+	Env string `json:"env,omitempty"`
+
+	// Overrides are system environment variables to set before Build,
+	// so they override the `swapcp:"env=..."` tagged config fields.
+	// System variables such as PATH and LD_PRELOAD can't be set.
+	Overrides map[string]string `json:"overrides,omitempty"`
+
+	// Subject is the operator issuing the token.
+	Subject string `json:"sub"`
+
+	// Reason is the override reason, for auditing.
+	Reason string `json:"reason,omitempty"`
+
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// overrideHeader is the only accepted JWT header.
+var overrideHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA","typ":"JWT"}`))
+
+// WithOverrideKey set the public key verifying the override token
+// found in the `SWAP_OVERRIDE_TOKEN` environment variable,
+// tokens are ignored if no key is provided.
+// It panics if the key is not ed25519.PublicKeySize bytes long.
+func WithOverrideKey(key ed25519.PublicKey) Option {
+	if err := checkOverrideKey(key); key != nil && err != nil {
+		panic(err)
+	}
+	return func(s *Builder) {
+		s.overrideKey = key
+	}
+}
+
+// SignOverrideToken returns the override token (an EdDSA signed JWT) for the given claims,
+// the expiration time is mandatory.
+func SignOverrideToken(key ed25519.PrivateKey, claims OverrideClaims) (string, error) {
+	if claims.ExpiresAt == 0 {
+		return "", errors.New("missing override token expiration time")
+	}
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = time.Now().Unix()
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := overrideHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(key, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyOverrideToken verify the override token signature
+// and expiration time, then returns its claims.
+func VerifyOverrideToken(key ed25519.PublicKey, token string) (*OverrideClaims, error) {
+	if err := checkOverrideKey(key); err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed override token")
+	}
+	if parts[0] != overrideHeader {
+		return nil, errors.New("unsupported override token header, must be EdDSA signed")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed override token signature")
+	}
+	if !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, errors.New("invalid override token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed override token payload")
+	}
+	claims := &OverrideClaims{}
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("malformed override token payload: %s", err.Error())
+	}
+	if claims.ExpiresAt == 0 || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrOverrideTokenExpired
+	}
+
+	return claims, nil
+}
+
+// checkOverrideKey returns an error if the key is not an ed25519 public key,
+// ed25519.Verify panics on them.
+func checkOverrideKey(key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid override key length %d, must be %d bytes", len(key), ed25519.PublicKeySize)
+	}
+	return nil
+}
+
+// applyOverrideToken verify and apply the override token, if any.
+// The env vars and the environment set by the previous token are restored first,
+// so a dropped or expired token has no lasting effect and Override returns nil.
+// An invalid token make Build fail, it is never silently ignored,
+// an expired one is ignored with a warning.
+func (s *Builder) applyOverrideToken() error {
+	if s.overrideRestore != nil {
+		s.overrideRestore()
+		s.overrideRestore = nil
+	}
+	s.override = nil
+
+	token := os.Getenv(OverrideTokenEnvKey)
+	if len(token) == 0 || s.overrideKey == nil {
+		return nil
+	}
+
+	claims, err := VerifyOverrideToken(s.overrideKey, token)
+	if err == ErrOverrideTokenExpired {
+		s.warnOverride("expired override token ignored")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(claims.Overrides))
+	for key := range claims.Overrides {
+		if isProtectedEnvKey(key) {
+			return fmt.Errorf("the override token can't set the '%s' env var", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	previousEnv := make(map[string]*string, len(keys))
+	for _, key := range keys {
+		if value, found := os.LookupEnv(key); found {
+			previousEnv[key] = &value
+		} else {
+			previousEnv[key] = nil
+		}
+	}
+	previousTag := s.EnvHandler.Sources.directEnvironmentTag
+	s.overrideRestore = func() {
+		for key, value := range previousEnv {
+			if value == nil {
+				_ = os.Unsetenv(key)
+			} else {
+				_ = os.Setenv(key, *value)
+			}
+		}
+		if len(claims.Env) > 0 {
+			s.EnvHandler.SetCurrent(previousTag)
+		}
+	}
+
+	for _, key := range keys {
+		if err = os.Setenv(key, claims.Overrides[key]); err != nil {
+			return err
+		}
+	}
+	if len(claims.Env) > 0 {
+		s.EnvHandler.SetCurrent(claims.Env)
+	}
+
+	s.override = claims
+	if s.DebugOptions.Verbosity == VerbositySilent {
+		return nil
+	}
+	if s.slogger != nil {
+		s.slogger.Warn("swap: override token", "subject", claims.Subject, "reason", claims.Reason,
+			"expires", time.Unix(claims.ExpiresAt, 0).UTC(), "env", claims.Env, "overrides", keys)
+		return nil
+	}
+	s.logger.Printf("\nSwap: override token by '%s' (%s), expiring %s: env: '%s', overrides: %s\n",
+		claims.Subject, claims.Reason, time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339),
+		claims.Env, strings.Join(keys, ", "))
+	return nil
+}
+
+// warnOverride log the override token warning, unless silent.
+func (s *Builder) warnOverride(msg string) {
+	if s.DebugOptions.Verbosity == VerbositySilent {
+		return
+	}
+	if s.slogger != nil {
+		s.slogger.Warn("swap: " + msg)
+		return
+	}
+	s.logger.Printf("\nSwap: %s.\n", msg)
+}
+
+// isProtectedEnvKey returns true for the env vars the override token can't set.
+func isProtectedEnvKey(key string) bool {
+	key = strings.ToUpper(key)
+	if protectedEnvKeys[key] {
+		return true
+	}
+	for _, prefix := range protectedEnvPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Override returns the claims of the override token
+// applied during the last Build, nil if none.
+func (s *Builder) Override() *OverrideClaims {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.override
+}
@@ -0,0 +1,152 @@
+package swap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Partial build -------------------------------------------------------------------------------------------------------
+
+// BuildFields (re)configure only the fields of the toolBox at the given
+// dot separated paths (eg.: `MediaProcessing.Pictures`), the other fields are left untouched.
+// Already configured fields are reset and built again,
+// it allows targeted reloads and faster test setups of huge toolboxes.
+func (s *Builder) BuildFields(toolBox interface{}, paths ...string) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(paths) == 0 {
+		return errors.New("no field paths to build")
+	}
+
+	// look up all the fields first, nothing is built on wrong paths
+	fields := make([]*reflect.StructField, len(paths))
+	values := make([]reflect.Value, len(paths))
+	for i, path := range paths {
+		if fields[i], values[i], err = lookupSettableField(toolBox, path); err != nil {
+			return err
+		}
+	}
+
+	if err = s.prepareBuild(); err != nil {
+		return err
+	}
+
+	s.toolBox = toolBox
+	var debugLogs []string
+	for i, path := range paths {
+		var logs []string
+		_, logs, err = s.rebuild(path, fields[i], values[i])
+		debugLogs = append(debugLogs, logs...)
+		if err != nil {
+			break
+		}
+	}
+
+	if s.DebugOptions.Enabled {
+		s.debug(reflect.TypeOf(toolBox).Elem().Name(), debugLogs)
+	}
+	return err
+}
+
+// Rebuild reset the field of the toolBox at the given path (eg.: `Services.Mailer`)
+// and configure it again, returning its config files, eg.: to rotate credentials at runtime.
+// Pointer fields are reset and configured in place, so their holders get the new values.
+// The previous value is restored on errors and it is not shut down,
+// see Reload for the zero-downtime replacement of `Swappable` tools.
+func (s *Builder) Rebuild(toolBox interface{}, fieldPath string) (configFiles []string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sf, fv, err := lookupSettableField(toolBox, fieldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.prepareBuild(); err != nil {
+		return nil, err
+	}
+
+	s.toolBox = toolBox
+	start := time.Now()
+	configFiles, logs, err := s.rebuild(fieldPath, sf, fv)
+	s.observeReload(fieldPath, start, err)
+	if s.DebugOptions.Enabled {
+		s.debug(reflect.TypeOf(toolBox).Elem().Name(), logs)
+	}
+	return configFiles, err
+}
+
+// rebuild reset and build the field at path, the previous value is restored on errors.
+// The field is not degraded: the previous value and its sub-tools are kept alive,
+// only the tools recorded by the failed build are shut down.
+func (s *Builder) rebuild(path string, sf *reflect.StructField, fv reflect.Value) (configFiles []string, logs []string, err error) {
+	target := fv
+	if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+		target = fv.Elem()
+	}
+
+	previous := reflect.New(target.Type()).Elem()
+	previous.Set(target)
+	target.Set(reflect.Zero(target.Type()))
+
+	s.unavailableMutex.Lock()
+	delete(s.unavailable, path)
+	s.unavailableMutex.Unlock()
+
+	s.fieldFilesMutex.Lock()
+	delete(s.fieldFiles, path)
+	s.fieldFilesMutex.Unlock()
+
+	s.builtMutex.Lock()
+	recorded := len(s.built)
+	s.builtMutex.Unlock()
+
+	logs, err = s.build(path, sf, target, 1)
+	if err == nil {
+		err = s.applyPathOverrides(path, target)
+	}
+	if err == nil {
+		err = s.validate(path, target)
+	}
+	if err != nil {
+		s.unrecordSince(recorded)
+		target.Set(previous)
+		return nil, logs, err
+	}
+
+	s.fieldFilesMutex.Lock()
+	configFiles = s.fieldFiles[path]
+	s.fieldFilesMutex.Unlock()
+	return configFiles, logs, nil
+}
+
+// recordFieldFiles keep track of the config files of the field at path.
+func (s *Builder) recordFieldFiles(path string, files []string) {
+	s.fieldFilesMutex.Lock()
+	defer s.fieldFilesMutex.Unlock()
+
+	if s.fieldFiles == nil {
+		s.fieldFiles = make(map[string][]string)
+	}
+	// copied, the debug logs trim the files path in place
+	s.fieldFiles[path] = append([]string(nil), files...)
+}
+
+// lookupSettableField returns the settable struct field of the toolBox at the given path.
+func lookupSettableField(toolBox interface{}, path string) (sf *reflect.StructField, fv reflect.Value, err error) {
+	v := reflect.ValueOf(toolBox)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fv, errors.New("'toolBox' parameter should be a struct pointer")
+	}
+
+	if sf, fv, err = lookupField(v, path); err != nil {
+		return nil, fv, err
+	}
+	if !fv.CanSet() {
+		return nil, fv, fmt.Errorf("field '%s' can't be set", path)
+	}
+	return sf, fv, nil
+}
@@ -0,0 +1,252 @@
+package swap
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path overrides ------------------------------------------------------------------------------------------------------
+
+// DefaultPathOverridePrefix is the prefix of the env vars overriding any toolbox field by path,
+// the path elements are separated by `__`, eg.: `SWAP__MediaProcessing__Pictures__Port=8080`.
+const DefaultPathOverridePrefix = "SWAP__"
+
+// pathSeparator separates the path elements in the path override env vars.
+const pathSeparator = "__"
+
+// WithPathOverridePrefix return the same instance of the Builder but with
+// a custom path override env vars prefix, DefaultPathOverridePrefix by default,
+// an empty prefix disable the path overrides.
+func (s *Builder) WithPathOverridePrefix(prefix string) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pathOverridePrefix = prefix
+	return s
+}
+
+// pathOverride is a path override env var,
+// elements are the path elements relative to the overridden field.
+type pathOverride struct {
+	key      string
+	elements []string
+	value    string
+}
+
+// boundOverrides are the path overrides of a field of type t.
+type boundOverrides struct {
+	t         reflect.Type
+	overrides []pathOverride
+}
+
+// pathOverrides returns the path override env vars (eg.: `SWAP__Services__Mailer__Port=25`)
+// of the toolbox field at the given path, sorted by key.
+func (s *Builder) pathOverrides(path string) (overrides []pathOverride) {
+	if len(s.pathOverridePrefix) == 0 {
+		return nil
+	}
+
+	vars := make(map[string]string)
+	for key, value := range s.dotEnv {
+		vars[key] = value
+	}
+	for _, kv := range os.Environ() {
+		if kv := strings.SplitN(kv, "=", 2); len(kv) == 2 {
+			vars[kv[0]] = kv[1]
+		}
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		if strings.HasPrefix(key, s.pathOverridePrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var root []string
+	if len(path) > 0 {
+		root = strings.Split(path, ".")
+	}
+
+	for _, key := range keys {
+		elements := strings.Split(strings.TrimPrefix(key, s.pathOverridePrefix), pathSeparator)
+		if len(elements) <= len(root) || !pathHasPrefix(elements, root) {
+			continue
+		}
+		overrides = append(overrides, pathOverride{key: key, elements: elements[len(root):], value: vars[key]})
+	}
+	return overrides
+}
+
+// applyPathOverrides set the fields of v, at the given path of the toolbox,
+// overridden by the path override env vars once the fields are built.
+// Field names are case-insensitive, slices elements and map values are addressed
+// by index and key (eg.: `SWAP__Hosts__0`), values are decoded as the `env` ones.
+func (s *Builder) applyPathOverrides(path string, v reflect.Value) error {
+	p := s.parser()
+	p.pathOverrides = s.pathOverrides(path)
+	return p.applyPathOverrides(v)
+}
+
+// bindPathOverrides make the path overrides of the field at path, of type t,
+// available to the Builder.Parse calls with its config files until unbind is called,
+// so that factories and Configure methods get the overridden values.
+func (s *Builder) bindPathOverrides(path string, t reflect.Type, files []string) (unbind func()) {
+	overrides := s.pathOverrides(path)
+	if len(overrides) == 0 {
+		return func() {}
+	}
+
+	key := strings.Join(files, "\n")
+	s.fieldOverridesMutex.Lock()
+	if s.fieldOverrides == nil {
+		s.fieldOverrides = make(map[string]boundOverrides)
+	}
+	s.fieldOverrides[key] = boundOverrides{t: t, overrides: overrides}
+	s.fieldOverridesMutex.Unlock()
+
+	return func() {
+		s.fieldOverridesMutex.Lock()
+		delete(s.fieldOverrides, key)
+		s.fieldOverridesMutex.Unlock()
+	}
+}
+
+// boundPathOverrides returns the path overrides bound to the config files,
+// relative to the config type. The ones of other sub-fields of the tool
+// are left to the post-build pass.
+func (s *Builder) boundPathOverrides(config reflect.Type, files []string) (overrides []pathOverride) {
+	s.fieldOverridesMutex.Lock()
+	bound, found := s.fieldOverrides[strings.Join(files, "\n")]
+	s.fieldOverridesMutex.Unlock()
+	if !found {
+		return nil
+	}
+
+	prefix, found := typePath(bound.t, config)
+	if !found {
+		return nil
+	}
+	for _, o := range bound.overrides {
+		if len(o.elements) > len(prefix) && pathHasPrefix(o.elements, prefix) {
+			overrides = append(overrides, pathOverride{key: o.key, elements: o.elements[len(prefix):], value: o.value})
+		}
+	}
+	return overrides
+}
+
+// typePath returns the field names path from t to its shallowest field of the target type,
+// eg.: `[Config]` for the Config field of a tool, pointers are dereferenced.
+func typePath(t, target reflect.Type) ([]string, bool) {
+	type node struct {
+		t    reflect.Type
+		path []string
+	}
+
+	visited := make(map[reflect.Type]bool)
+	queue := []node{{t: t}}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for n.t.Kind() == reflect.Ptr {
+			n.t = n.t.Elem()
+		}
+		if n.t == target {
+			return n.path, true
+		}
+		if n.t.Kind() != reflect.Struct || visited[n.t] {
+			continue
+		}
+		visited[n.t] = true
+		for i := 0; i < n.t.NumField(); i++ {
+			if f := n.t.Field(i); len(f.PkgPath) == 0 {
+				queue = append(queue, node{t: f.Type, path: append(append([]string{}, n.path...), f.Name)})
+			}
+		}
+	}
+	return nil, false
+}
+
+// applyPathOverrides set the path overrides fields of v.
+func (p *parser) applyPathOverrides(v reflect.Value) error {
+	for _, o := range p.pathOverrides {
+		if err := p.overridePath(v, o.elements, o.value, nil); err != nil {
+			return fmt.Errorf("%s: %s", o.key, err.Error())
+		}
+	}
+	return nil
+}
+
+// pathHasPrefix returns true if the path elements start with the prefix ones, case-insensitively.
+func pathHasPrefix(elements, prefix []string) bool {
+	for i := range prefix {
+		if !strings.EqualFold(elements[i], prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// overridePath decodes the value into the field at the keys path of v,
+// flags are the v tag flags.
+func (p *parser) overridePath(v reflect.Value, keys []string, value string, flags []string) error {
+	if len(keys) == 0 {
+		return p.decodeTagValue(value, v, flags)
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		// Lazy tools get their overrides once built, by their thunk
+		if v.CanAddr() {
+			if lazy, ok := v.Addr().Interface().(lazyField); ok {
+				if built := lazy.lazyBuilt(); built != nil {
+					return p.overridePath(reflect.ValueOf(built), keys, value, flags)
+				}
+				return nil
+			}
+		}
+		sf, found := matchField(v.Type(), keys[0])
+		if !found || len(sf.PkgPath) > 0 {
+			return fmt.Errorf("unknown key '%s'", keys[0])
+		}
+		return p.overridePath(v.FieldByIndex(sf.Index), keys[1:], value, splitFlags(sf.Tag.Get(p.tagKey)))
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(keys[0])
+		if err != nil || i < 0 || i >= v.Len() {
+			return fmt.Errorf("invalid index '%s'", keys[0])
+		}
+		return p.overridePath(v.Index(i), keys[1:], value, nil)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(keys[0]).Convert(v.Type().Key())
+		// map elements are not addressable
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if current := v.MapIndex(key); current.IsValid() {
+			elem.Set(current)
+		}
+		if err := p.overridePath(elem, keys[1:], value, nil); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	default:
+		return fmt.Errorf("can't set key '%s' of %s", keys[0], v.Type())
+	}
+}
@@ -0,0 +1,42 @@
+// Package pflags binds the swap config structs to the github.com/spf13/pflag flag sets,
+// eg.: the cobra commands flags, so that the flags set on the command line
+// override the env vars, the config files and the default values.
+package pflags
+
+import (
+	"github.com/oblq/swap"
+	"github.com/spf13/pflag"
+)
+
+// Binding is a config struct bound to the flags of a FlagSet.
+type Binding struct {
+	builder *swap.Builder
+	config  interface{}
+}
+
+// Bind define on fs (eg.: `cmd.Flags()` of a cobra command) a flag for every config field,
+// named after the field path (eg.: `--db.host` for `DB.Host`, `--max-conns` for `MaxConns`)
+// or the `flag` tag flag, with the `default` value and the `desc` usage,
+// see swap.Builder.BindPFlags. opts configure the Builder parsing the config files.
+func Bind(fs *pflag.FlagSet, config interface{}, opts ...swap.Option) *Binding {
+	builder := swap.NewBuilder(".", opts...).BindPFlags(fs, config)
+	return &Binding{builder: builder, config: config}
+}
+
+// NewFlagSet returns the FlagSet with the config fields flags, see Bind.
+func NewFlagSet(name string, config interface{}, opts ...swap.Option) (*pflag.FlagSet, *Binding) {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	return fs, Bind(fs, config, opts...)
+}
+
+// Builder returns the Builder parsing the config files,
+// eg.: to set its dotenv files or env prefix.
+func (b *Binding) Builder() *swap.Builder {
+	return b.builder
+}
+
+// Parse parse the config files into the config, then the flags set on the command line,
+// call it once the FlagSet is parsed (eg.: in the cobra command `RunE`).
+func (b *Binding) Parse(files ...string) error {
+	return b.builder.Parse(b.config, files...)
+}
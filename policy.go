@@ -0,0 +1,122 @@
+package swap
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Resilience policies -------------------------------------------------------------------------------------------------
+
+// RetryPolicy is a reusable retry configuration type
+// which tools can embed in their config structs,
+// every zero value but Jitter is replaced by a sensible default.
+//
+//	retry:
+//	  maxAttempts: 5
+//	  initialBackoff: 200ms
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts, the first one included.
+	MaxAttempts int `swapcp:"default=3" yaml:"maxAttempts" json:"maxAttempts" toml:"maxAttempts"`
+
+	// InitialBackoff is the wait time before the first retry.
+	InitialBackoff time.Duration `swapcp:"default=100ms" yaml:"initialBackoff" json:"initialBackoff" toml:"initialBackoff"`
+
+	// MaxBackoff is the max wait time between two attempts.
+	MaxBackoff time.Duration `swapcp:"default=10s" yaml:"maxBackoff" json:"maxBackoff" toml:"maxBackoff"`
+
+	// Multiplier increase the backoff after every attempt.
+	Multiplier float64 `swapcp:"default=2" yaml:"multiplier" json:"multiplier" toml:"multiplier"`
+
+	// Jitter is the random backoff variation ratio, from 0 to 1,
+	// the zero value disables it.
+	Jitter float64 `yaml:"jitter" json:"jitter" toml:"jitter"`
+}
+
+// Validate is the Validator interface implementation.
+func (rp RetryPolicy) Validate() error {
+	switch {
+	case rp.MaxAttempts < 1:
+		return errors.New("maxAttempts must be greater than 0")
+	case rp.InitialBackoff < 0 || rp.MaxBackoff < 0:
+		return errors.New("backoff durations can't be negative")
+	case rp.MaxBackoff < rp.InitialBackoff:
+		return errors.New("maxBackoff must be greater than or equal to initialBackoff")
+	case rp.Multiplier < 1:
+		return errors.New("multiplier must be greater than or equal to 1")
+	case rp.Jitter < 0 || rp.Jitter > 1:
+		return errors.New("jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// alwaysValidate is the alwaysValidator marker.
+func (rp RetryPolicy) alwaysValidate() {}
+
+// Backoff returns the wait time after the given attempt (starting from 1).
+func (rp RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := float64(rp.InitialBackoff) * math.Pow(rp.Multiplier, float64(attempt-1))
+	if backoff > float64(rp.MaxBackoff) {
+		backoff = float64(rp.MaxBackoff)
+	}
+	if rp.Jitter > 0 {
+		backoff += backoff * rp.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// Do call fn until it succeeds, MaxAttempts is reached or ctx is done,
+// the last error is returned.
+func (rp RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil || attempt >= rp.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(rp.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// CircuitBreakerPolicy is a reusable circuit breaker configuration type
+// which tools can embed in their config structs,
+// every zero value is replaced by a sensible default.
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures which open the circuit.
+	FailureThreshold int `swapcp:"default=5" yaml:"failureThreshold" json:"failureThreshold" toml:"failureThreshold"`
+
+	// SuccessThreshold is the number of consecutive successes
+	// in the half-open state which close the circuit.
+	SuccessThreshold int `swapcp:"default=1" yaml:"successThreshold" json:"successThreshold" toml:"successThreshold"`
+
+	// OpenTimeout is the time after which an open circuit becomes half-open.
+	OpenTimeout time.Duration `swapcp:"default=30s" yaml:"openTimeout" json:"openTimeout" toml:"openTimeout"`
+
+	// HalfOpenMaxRequests is the max number of requests allowed in the half-open state.
+	HalfOpenMaxRequests int `swapcp:"default=1" yaml:"halfOpenMaxRequests" json:"halfOpenMaxRequests" toml:"halfOpenMaxRequests"`
+}
+
+// Validate is the Validator interface implementation.
+func (cbp CircuitBreakerPolicy) Validate() error {
+	switch {
+	case cbp.FailureThreshold < 1:
+		return errors.New("failureThreshold must be greater than 0")
+	case cbp.SuccessThreshold < 1:
+		return errors.New("successThreshold must be greater than 0")
+	case cbp.OpenTimeout <= 0:
+		return errors.New("openTimeout must be greater than 0")
+	case cbp.HalfOpenMaxRequests < 1:
+		return errors.New("halfOpenMaxRequests must be greater than 0")
+	}
+	return nil
+}
+
+// alwaysValidate is the alwaysValidator marker.
+func (cbp CircuitBreakerPolicy) alwaysValidate() {}
@@ -0,0 +1,117 @@
+package swap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/oblq/swap/internal/logger"
+)
+
+// Profiles ------------------------------------------------------------------------------------------------------------
+
+// Dimension is an additional config files dimension,
+// after the environment one (eg.: region, cluster).
+type Dimension struct {
+	// Name is the dimension name (eg.: `region`).
+	Name string
+
+	// SystemEnvironmentKey is the system environment variable
+	// key for the dimension value (eg.: `REGION`).
+	SystemEnvironmentKey string
+
+	// value is the dimension value set manually.
+	value string
+}
+
+// NewDimension create a new instance of Dimension.
+func NewDimension(name, systemEnvironmentKey string) *Dimension {
+	return &Dimension{Name: name, SystemEnvironmentKey: systemEnvironmentKey}
+}
+
+// Value returns the dimension value set with SetCurrent
+// or, if empty, the one of the SystemEnvironmentKey environment variable.
+func (d *Dimension) Value() string {
+	if len(d.value) > 0 {
+		return d.value
+	}
+	if len(d.SystemEnvironmentKey) > 0 {
+		return os.Getenv(d.SystemEnvironmentKey)
+	}
+	return ""
+}
+
+// ProfileHandler is the object that manages the profile dimensions, analogous to the EnvironmentHandler.
+// After the environment specific files the config files are also searched
+// for the dimensions values, in order (eg.: for the `region` and `cluster` dimensions:
+// tool.yaml, tool.production.yaml, tool.production.eu-west-1.yaml, tool.production.eu-west-1.blue.yaml).
+type ProfileHandler struct {
+	dimensions []*Dimension
+
+	mutex sync.Mutex
+}
+
+// NewProfileHandler return a new instance of ProfileHandler with the passed dimensions,
+// in config files name order.
+func NewProfileHandler(dimensions ...*Dimension) *ProfileHandler {
+	return &ProfileHandler{dimensions: dimensions}
+}
+
+// SetCurrent set the value of the dimension with the given name,
+// it takes precedence over its environment variable.
+func (ph *ProfileHandler) SetCurrent(name, value string) error {
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	for _, d := range ph.dimensions {
+		if d.Name == name {
+			d.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown profile dimension '%s'", name)
+}
+
+// Current returns the dimensions values in config files name order,
+// up to the first dimension without a value.
+func (ph *ProfileHandler) Current() (values []string) {
+	if ph == nil {
+		return nil
+	}
+
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	for _, d := range ph.dimensions {
+		value := d.Value()
+		if len(value) == 0 {
+			break
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// Info returns the dimensions values, empty if there are no dimensions.
+func (ph *ProfileHandler) Info() string {
+	if ph == nil {
+		return ""
+	}
+
+	ph.mutex.Lock()
+	defer ph.mutex.Unlock()
+
+	if len(ph.dimensions) == 0 {
+		return ""
+	}
+	values := make([]string, 0, len(ph.dimensions))
+	for _, d := range ph.dimensions {
+		value := d.Value()
+		if len(value) == 0 {
+			value = "<empty>"
+		}
+		values = append(values, fmt.Sprintf("%s: %s", d.Name, logger.Green(value)))
+	}
+	return fmt.Sprintf("Profile: %s\n", strings.Join(values, ", "))
+}
@@ -0,0 +1,57 @@
+package swap
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Secrets redaction ---------------------------------------------------------------------------------------------------
+
+// secretNames are the password-like names, redacted even without the `secret` flag.
+var secretNames = []string{"password", "passwd", "secret", "token", "apikey", "privatekey", "credential"}
+
+// secretPatterns are the registered secret names patterns.
+var secretPatterns = struct {
+	sync.RWMutex
+	patterns []*regexp.Regexp
+}{}
+
+// RegisterSecretPattern redact the fields and map keys whose name matches pattern
+// (eg.: `(?i)^(dsn|webhook_url)$`) as the password-like ones,
+// in dumps, conflict reports and constraint errors,
+// so that the effective config can be attached to bug reports and support tickets safely.
+func RegisterSecretPattern(pattern *regexp.Regexp) {
+	secretPatterns.Lock()
+	defer secretPatterns.Unlock()
+
+	secretPatterns.patterns = append(secretPatterns.patterns, pattern)
+}
+
+// isSecretName returns true if the field or key name is password-like,
+// eg.: `Password`, `db_password`, `API-Key`, `AccessToken`, or matches a registered pattern.
+func isSecretName(name string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "", ".", "").Replace(name))
+	for _, secretName := range secretNames {
+		if strings.Contains(normalized, secretName) {
+			return true
+		}
+	}
+
+	secretPatterns.RLock()
+	defer secretPatterns.RUnlock()
+
+	for _, pattern := range secretPatterns.patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecretField returns true if the field value must be redacted.
+func isSecretField(sf reflect.StructField, flags []string) bool {
+	t := indirectType(sf.Type)
+	return hasFlag(flags, sffConfigSecret) || t == secretType || t.Implements(secretValueType) || isSecretName(sf.Name)
+}
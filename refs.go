@@ -0,0 +1,191 @@
+package swap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config values references --------------------------------------------------------------------------------------------
+
+// refKey is the key of the reference nodes,
+// eg.: `address: {$ref: "common.yaml#/cluster/address"}`.
+const refKey = "$ref"
+
+// resolveRefs replace every reference node in the file data
+// with the referenced value, the referenced file path is relative
+// to the referencing one and the same file is used if omitted
+// (eg.: `#/cluster/address`).
+// Data without references and untrusted data are returned untouched.
+func (p *parser) resolveRefs(file string, data []byte) ([]byte, error) {
+	if p.untrusted || !bytes.Contains(data, []byte(refKey)) {
+		return data, nil
+	}
+
+	tree, err := decodeTree(file, data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := refResolver{p: p, docs: map[string]interface{}{file: tree}}
+	if tree, err = r.resolve(file, tree, nil); err != nil {
+		return nil, err
+	}
+
+	return encodeTree(file, tree)
+}
+
+// refResolver resolves the references of a document tree,
+// referenced documents are read once.
+type refResolver struct {
+	p    *parser
+	docs map[string]interface{}
+}
+
+// resolve walks the node replacing the references,
+// stack holds the references being resolved to detect cycles.
+func (r *refResolver) resolve(file string, node interface{}, stack []string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if ref, isRef := n[refKey].(string); isRef && len(n) == 1 {
+			return r.follow(file, ref, stack)
+		}
+		for k, v := range n {
+			resolved, err := r.resolve(file, v, stack)
+			if err != nil {
+				return nil, err
+			}
+			n[k] = resolved
+		}
+	case []interface{}:
+		for i, v := range n {
+			resolved, err := r.resolve(file, v, stack)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = resolved
+		}
+	}
+	return node, nil
+}
+
+// follow returns the (resolved) value referenced by ref.
+func (r *refResolver) follow(file, ref string, stack []string) (interface{}, error) {
+	refFile, pointer := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		refFile, pointer = ref[:i], ref[i+1:]
+	}
+	if len(refFile) == 0 {
+		refFile = file
+	} else if !filepath.IsAbs(refFile) {
+		refFile = filepath.Join(filepath.Dir(file), refFile)
+	}
+
+	id := refFile + "#" + pointer
+	for _, s := range stack {
+		if s == id {
+			return nil, fmt.Errorf("reference cycle detected: %s -> %s", strings.Join(stack, " -> "), id)
+		}
+	}
+	stack = append(stack, id)
+
+	doc, found := r.docs[refFile]
+	if !found {
+		data, err := r.p.fs.ReadFile(refFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve reference '%s': %s", ref, err.Error())
+		}
+		if doc, err = decodeTree(refFile, data); err != nil {
+			return nil, fmt.Errorf("can't resolve reference '%s': %s", ref, err.Error())
+		}
+		r.docs[refFile] = doc
+	}
+
+	value, err := lookupPointer(doc, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve reference '%s': %s", ref, err.Error())
+	}
+	return r.resolve(refFile, value, stack)
+}
+
+// lookupPointer returns the node at the given
+// JSON pointer like path (eg.: `/cluster/nodes/0`).
+func lookupPointer(node interface{}, pointer string) (interface{}, error) {
+	if len(pointer) == 0 || pointer == "/" {
+		return node, nil
+	}
+
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch n := node.(type) {
+		case map[string]interface{}:
+			value, found := n[token]
+			if !found {
+				return nil, fmt.Errorf("key '%s' not found", token)
+			}
+			node = value
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("invalid index '%s'", token)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("key '%s' not found", token)
+		}
+	}
+	return node, nil
+}
+
+// decodeTree decodes the file data to a generic tree.
+func decodeTree(file string, data []byte) (tree interface{}, err error) {
+	ext := filepath.Ext(file)
+	codec, isCodec := codecFor(file)
+
+	switch {
+	case isCodec:
+		var m map[string]interface{}
+		m, err = codec.Unmarshal(data)
+		tree = m
+	case regexpYAML.MatchString(ext):
+		err = yaml.Unmarshal(data, &tree)
+	case regexpTOML.MatchString(ext):
+		var m map[string]interface{}
+		m, err = decodeTOMLTree(data)
+		tree = m
+	case regexpJSON.MatchString(ext):
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	default:
+		err = fmt.Errorf("unknown data format, can't unmarshal file: '%s'", file)
+	}
+
+	return
+}
+
+// encodeTree encodes the tree in the file format.
+func encodeTree(file string, tree interface{}) ([]byte, error) {
+	ext := filepath.Ext(file)
+	codec, isCodec := codecFor(file)
+
+	switch {
+	case isCodec:
+		m, _ := tree.(map[string]interface{})
+		return codec.Marshal(m)
+	case regexpYAML.MatchString(ext):
+		return yaml.Marshal(tree)
+	case regexpTOML.MatchString(ext):
+		return toml.Marshal(tree)
+	case regexpJSON.MatchString(ext):
+		return json.Marshal(tree)
+	default:
+		return nil, fmt.Errorf("unknown data format, can't unmarshal file: '%s'", file)
+	}
+}
@@ -0,0 +1,151 @@
+package swap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Swappable interface -------------------------------------------------------------------------------------------------
+
+// Swappable interface allow the zero-downtime replacement of a tool:
+// Swap must atomically replace the tool state with the state
+// of next (a fully configured instance of the same type),
+// the old state is returned to be drained and then shut down
+// through the `Shutdowner` or the `io.Closer` interface.
+type Swappable interface {
+	Swap(next interface{}) (old interface{}, err error)
+}
+
+// Reload build a new instance of the Swappable field at the given path
+// (eg.: `Services.Mailer`) of the already built toolBox, then swaps it
+// in the live field, so in-flight requests are not dropped.
+// The old instance is shut down after the drain period, or when ctx is done,
+// the builder is not locked in the meantime.
+func (s *Builder) Reload(ctx context.Context, toolBox interface{}, path string, drain time.Duration) (err error) {
+	defer func(start time.Time) { s.observeReload(path, start, err) }(time.Now())
+
+	old, err := s.swapField(toolBox, path)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(drain)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+	case <-timer.C:
+	}
+
+	if err = shutdownTool(context.Background(), old); err != nil {
+		return fmt.Errorf("can't shutdown the replaced '%s': %s", path, err.Error())
+	}
+	return nil
+}
+
+// swapField build the next instance of the Swappable field at path
+// and swaps it in the live field, the old state is returned.
+func (s *Builder) swapField(toolBox interface{}, path string) (old interface{}, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sf, fv, err := lookupField(reflect.ValueOf(toolBox), path)
+	if err != nil {
+		return nil, err
+	}
+
+	field := fv
+	if field.Kind() != reflect.Ptr {
+		field = field.Addr()
+	}
+	swappable, ok := field.Interface().(Swappable)
+	if !ok || field.IsNil() {
+		return nil, fmt.Errorf("can't reload '%s': `Swappable` interface not implemented", path)
+	}
+
+	// build the next instance aside, its state is moved into the live field,
+	// so it is not recorded for Shutdown, its sub-tools are.
+	s.builtMutex.Lock()
+	built := len(s.built)
+	s.builtMutex.Unlock()
+
+	next := reflect.New(sf.Type)
+	_, err = s.build(path, sf, next.Elem(), 0)
+	if err == nil {
+		err = s.applyPathOverrides(path, next.Elem())
+	}
+	if err == nil {
+		err = s.validate(path, next.Elem())
+	}
+
+	nextTool := next.Interface()
+	if sf.Type.Kind() == reflect.Ptr {
+		nextTool = next.Elem().Interface()
+	}
+
+	s.builtMutex.Lock()
+	var nested []builtTool
+	for _, bt := range s.built[built:] {
+		if bt.tool != nextTool {
+			nested = append(nested, bt)
+		}
+	}
+	s.built = s.built[:built]
+	s.builtMutex.Unlock()
+
+	if err == nil {
+		old, err = swappable.Swap(nextTool)
+	}
+	if err != nil {
+		// the next instance is dropped, with its sub-tools
+		for i := len(nested) - 1; i >= 0; i-- {
+			_ = shutdownTool(context.Background(), nested[i].tool)
+		}
+		_ = shutdownTool(context.Background(), nextTool)
+		return nil, fmt.Errorf("can't reload '%s': %s", path, err.Error())
+	}
+
+	// the next sub-tools replace the old ones for Shutdown,
+	// they are recorded before the live field as during Build
+	s.builtMutex.Lock()
+	var replaced []builtTool
+	inserted := false
+	for _, bt := range s.built {
+		if strings.HasPrefix(bt.path, path+".") {
+			continue
+		}
+		if bt.path == path && !inserted {
+			replaced = append(replaced, nested...)
+			inserted = true
+		}
+		replaced = append(replaced, bt)
+	}
+	if !inserted {
+		replaced = append(replaced, nested...)
+	}
+	s.built = replaced
+	s.builtMutex.Unlock()
+	return old, nil
+}
+
+// lookupField returns the struct field at the given dot separated path.
+func lookupField(v reflect.Value, path string) (sf *reflect.StructField, fv reflect.Value, err error) {
+	fv = v
+	for _, name := range strings.Split(path, ".") {
+		fv = reflect.Indirect(fv)
+		if fv.Kind() != reflect.Struct {
+			return nil, fv, errors.New("'toolBox' parameter should be a struct pointer")
+		}
+
+		field, found := fv.Type().FieldByName(name)
+		if !found {
+			return nil, fv, fmt.Errorf("field '%s' not found", path)
+		}
+		sf = &field
+		fv = fv.FieldByIndex(field.Index)
+	}
+	return
+}
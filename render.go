@@ -0,0 +1,135 @@
+package swap
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Config files rendering ----------------------------------------------------------------------------------------------
+
+// RenderedConfig is the result of the config files parsing without a config struct.
+type RenderedConfig struct {
+	// Files are the parsed files, by ascending priority.
+	Files []string
+
+	// Tree is the merged config, with references, env vars, encrypted values and templates resolved.
+	Tree map[string]interface{}
+
+	// Sources are the files setting every leaf key (eg.: `db.host`),
+	// the last one is the file whose value won.
+	Sources map[string][]string
+}
+
+// Keys returns the sorted leaf keys.
+func (rc *RenderedConfig) Keys() []string {
+	keys := make([]string, 0, len(rc.Sources))
+	for key := range rc.Sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Render parse the config files (with their environment specific ones, if env is not nil)
+// as ParseByEnv does, but into a generic tree, to inspect the merged result and which file won for every key.
+// Templates are executed against the tree merged so far, as they are against the config struct.
+func Render(fsys FileSystem, env *Environment, files ...string) (*RenderedConfig, error) {
+	p := newParser()
+	if fsys != nil {
+		p.fs = fsys
+	}
+
+	names := files
+	files, err := p.appendEnvFiles(env, files)
+	if err != nil {
+		return nil, fmt.Errorf("no config file found for '%s': %s", strings.Join(names, " | "), err.Error())
+	}
+
+	rendered := &RenderedConfig{
+		Files:   files,
+		Tree:    make(map[string]interface{}),
+		Sources: make(map[string][]string),
+	}
+	for _, file := range files {
+		var data []byte
+		if data, err = p.fs.ReadFile(file); err != nil {
+			return nil, err
+		}
+		if data, err = p.resolveRefs(file, data); err != nil {
+			return nil, err
+		}
+		if data, err = p.expandEnv(file, data, nil); err != nil {
+			return nil, err
+		}
+		if data, err = p.decryptValues(file, data, nil); err != nil {
+			return nil, err
+		}
+		if data, err = p.renderTemplate(file, data, rendered.Tree); err != nil {
+			return nil, err
+		}
+
+		var tree interface{}
+		if tree, err = decodeTree(file, data); err != nil {
+			return nil, err
+		}
+		if tree == nil {
+			continue
+		}
+		m, isMap := tree.(map[string]interface{})
+		if !isMap {
+			return nil, fmt.Errorf("%s: the root is not a map", file)
+		}
+		mergeTrees(rendered.Tree, m)
+
+		for key := range flattenTree("", m) {
+			rendered.Sources[key] = append(rendered.Sources[key], file)
+		}
+	}
+	return rendered, nil
+}
+
+// renderTemplate execute the file template against the tree merged so far, with the file values.
+func (p *parser) renderTemplate(file string, data []byte, merged map[string]interface{}) ([]byte, error) {
+	tree, err := decodeTree(file, data)
+	if err != nil {
+		return nil, err
+	}
+
+	context := deepCopyTree(merged).(map[string]interface{})
+	if m, isMap := tree.(map[string]interface{}); isMap {
+		mergeTrees(context, m)
+	}
+
+	tpl, err := template.New(filepath.Base(file)).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, context); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deepCopyTree returns a copy of the tree maps and slices.
+func deepCopyTree(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			c[k] = deepCopyTree(v)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(n))
+		for i, v := range n {
+			c[i] = deepCopyTree(v)
+		}
+		return c
+	}
+	return node
+}
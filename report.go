@@ -0,0 +1,304 @@
+package swap
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Build report --------------------------------------------------------------------------------------------------------
+
+// FieldState is the outcome of a field build.
+type FieldState string
+
+// Field states, `made` fields are returned by a Factory or a registered FactoryFunc.
+const (
+	FieldStateConfigured        FieldState = "configured"
+	FieldStateMade              FieldState = "made"
+	FieldStateTraversed         FieldState = "traversed"
+	FieldStateUnhandled         FieldState = "unhandled"
+	FieldStateSkipped           FieldState = "skipped"
+	FieldStateAlreadyConfigured FieldState = "already_configured"
+	FieldStateNoConfigFiles     FieldState = "no_config_files"
+	FieldStateLazy              FieldState = "lazy"
+	FieldStateDegraded          FieldState = "degraded"
+	FieldStateFailed            FieldState = "failed"
+)
+
+// fieldStates are the field states in display order.
+var fieldStates = []FieldState{
+	FieldStateConfigured,
+	FieldStateMade,
+	FieldStateTraversed,
+	FieldStateUnhandled,
+	FieldStateSkipped,
+	FieldStateAlreadyConfigured,
+	FieldStateNoConfigFiles,
+	FieldStateLazy,
+	FieldStateDegraded,
+	FieldStateFailed,
+}
+
+// ReportField is the build outcome of a toolbox field.
+type ReportField struct {
+	// Path is the field path from the root object (eg.: `MediaProcessing.Pictures`).
+	Path  string
+	Type  string
+	State FieldState
+
+	// Files are the resolved config files, environment specific ones included.
+	Files []string
+
+	Err error
+
+	// Duration is the time spent building the field, sub-fields time included.
+	Duration time.Duration
+}
+
+// MarshalJSON is the json.Marshaler implementation, Err is encoded as its message.
+func (rf ReportField) MarshalJSON() ([]byte, error) {
+	type reportField ReportField
+	field := struct {
+		reportField
+		Err string `json:",omitempty"`
+	}{reportField: reportField(rf)}
+	if rf.Err != nil {
+		field.Err = rf.Err.Error()
+	}
+	return json.Marshal(field)
+}
+
+// Report is the structured outcome of the last Build,
+// eg.: to expose it over an admin endpoint or to assert on it in tests.
+type Report struct {
+	Environment string
+
+	// Fields are sorted by path.
+	Fields []ReportField
+}
+
+// Field returns the report of the field at the given path.
+func (r Report) Field(path string) (field ReportField, found bool) {
+	for _, field = range r.Fields {
+		if field.Path == path {
+			return field, true
+		}
+	}
+	return ReportField{}, false
+}
+
+// Report returns the report of the last Build,
+// the fields (re)built by BuildFields and Rebuild are updated.
+func (s *Builder) Report() Report {
+	fields := s.reportFields()
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return Report{Environment: s.EnvHandler.Current().Tag(), Fields: fields}
+}
+
+// reportFields returns the recorded fields, with their duration.
+func (s *Builder) reportFields() []ReportField {
+	s.reportMutex.Lock()
+	fields := make([]ReportField, len(s.report))
+	copy(fields, s.report)
+	s.reportMutex.Unlock()
+
+	durations := make(map[string]time.Duration)
+	for _, span := range s.Timeline() {
+		durations[span.Path] = span.Duration()
+	}
+	for i := range fields {
+		fields[i].Duration = durations[fields[i].Path]
+	}
+	return fields
+}
+
+// slogFields logs the fields at the given paths with slog,
+// failed fields at the Error level, degraded ones at the Warn level, the others at the Debug level.
+func (s *Builder) slogFields(paths []string) {
+	fields := make(map[string]ReportField)
+	for _, field := range s.reportFields() {
+		fields[field.Path] = field
+	}
+
+	for _, path := range paths {
+		field, found := fields[path]
+		if !found {
+			continue
+		}
+
+		level := slog.LevelDebug
+		switch field.State {
+		case FieldStateFailed:
+			level = slog.LevelError
+		case FieldStateDegraded:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("path", field.Path),
+			slog.String("type", field.Type),
+			slog.String("state", string(field.State)),
+			slog.Any("files", field.Files),
+			slog.Duration("duration", field.Duration),
+		}
+		if field.Err != nil {
+			attrs = append(attrs, slog.String("error", field.Err.Error()))
+		}
+		s.slogger.LogAttrs(s.context(), level, "swap: field", attrs...)
+	}
+}
+
+// failedFields returns the paths of the failed and degraded fields.
+func (s *Builder) failedFields(paths []string) (failed []string) {
+	fields := make(map[string]ReportField)
+	for _, field := range s.reportFields() {
+		fields[field.Path] = field
+	}
+
+	for _, path := range paths {
+		if field, found := fields[path]; found && field.Err != nil {
+			failed = append(failed, path)
+		}
+	}
+	return failed
+}
+
+// debugErrorLines returns the error lines of the fields at the given paths,
+// the debug logs of the reduced verbosities.
+func (s *Builder) debugErrorLines(paths []string) string {
+	fields := make(map[string]ReportField)
+	for _, field := range s.reportFields() {
+		fields[field.Path] = field
+	}
+
+	var lines strings.Builder
+	for _, path := range paths {
+		if field, found := fields[path]; found && field.Err != nil {
+			lines.WriteString(fmt.Sprintf("Swap: %s %s: %s\n", field.Path, field.State, field.Err.Error()))
+		}
+	}
+	return lines.String()
+}
+
+// printSummary prints the number of fields of the last build by state.
+func (s *Builder) printSummary(objName string) {
+	counts := make(map[FieldState]int)
+	fields := s.reportFields()
+	for _, field := range fields {
+		counts[field.State]++
+	}
+
+	switch {
+	case s.slogger != nil:
+		attrs := []slog.Attr{slog.String("toolbox", objName), slog.Int("fields", len(fields))}
+		for _, state := range fieldStates {
+			if counts[state] > 0 {
+				attrs = append(attrs, slog.Int(string(state), counts[state]))
+			}
+		}
+		s.slogger.LogAttrs(s.context(), slog.LevelInfo, "swap: summary", attrs...)
+	case s.DebugOptions.Format == DebugFormatJSON:
+		summary := map[string]interface{}{"Toolbox": objName, "Fields": len(fields)}
+		for _, state := range fieldStates {
+			if counts[state] > 0 {
+				summary[string(state)] = counts[state]
+			}
+		}
+		if data, err := json.Marshal(summary); err == nil {
+			s.logger.Printf("%s\n", data)
+		}
+	default:
+		var states []string
+		for _, state := range fieldStates {
+			if counts[state] > 0 {
+				states = append(states, fmt.Sprintf("%d %s", counts[state], state))
+			}
+		}
+		s.logger.Printf("Swap: %s, %d fields: %s\n", objName, len(fields), strings.Join(states, ", "))
+	}
+}
+
+// debugJSONLines returns the JSON lines of the fields at the given paths,
+// the debug logs of the DebugFormatJSON format.
+func (s *Builder) debugJSONLines(paths []string) string {
+	fields := make(map[string]ReportField)
+	for _, field := range s.reportFields() {
+		fields[field.Path] = field
+	}
+
+	var lines strings.Builder
+	for _, path := range paths {
+		field, found := fields[path]
+		if !found {
+			continue
+		}
+		if data, err := json.Marshal(field); err == nil {
+			lines.Write(data)
+			lines.WriteString("\n")
+		}
+	}
+	return lines.String()
+}
+
+// logField record the field outcome in the report and returns its debug log.
+func (s *Builder) logField(path string, sf *reflect.StructField, state state, err error, level int, configFiles []string) string {
+	if sf != nil || err != nil {
+		field := ReportField{Path: path, State: state.fieldState(err), Files: append([]string(nil), configFiles...), Err: err}
+		if sf != nil {
+			field.Type = sf.Type.String()
+		}
+		s.recordReportField(field)
+	}
+
+	// the structured logs are rendered by debug, once the durations are known
+	if s.structuredDebug() {
+		return path
+	}
+	return getLogString(sf, state, err, level, configFiles)
+}
+
+// recordReportField add the field to the report, replacing the previous outcome of the same path.
+func (s *Builder) recordReportField(field ReportField) {
+	s.reportMutex.Lock()
+	defer s.reportMutex.Unlock()
+
+	for i := range s.report {
+		if s.report[i].Path == field.Path {
+			s.report[i] = field
+			return
+		}
+	}
+	s.report = append(s.report, field)
+}
+
+// fieldState returns the exported state.
+func (s state) fieldState(err error) FieldState {
+	if err != nil {
+		if s == stateDegraded {
+			return FieldStateDegraded
+		}
+		return FieldStateFailed
+	}
+
+	switch s {
+	case stateConfigured:
+		return FieldStateConfigured
+	case stateMadeFromInterface, stateMadeFromRegisteredFactory:
+		return FieldStateMade
+	case stateTraversing:
+		return FieldStateTraversed
+	case stateSkipped:
+		return FieldStateSkipped
+	case stateAlreadyConfigured:
+		return FieldStateAlreadyConfigured
+	case stateNoConfigFiles:
+		return FieldStateNoConfigFiles
+	case stateLazy:
+		return FieldStateLazy
+	default:
+		return FieldStateUnhandled
+	}
+}
@@ -0,0 +1,259 @@
+package swap
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Editors schema ------------------------------------------------------------------------------------------------------
+
+// jsonSchemaDraft is the generated schemas version.
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	secretType          = reflect.TypeOf(Secret{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	secretValueType     = reflect.TypeOf((*secretValue)(nil)).Elem()
+)
+
+// JSONSchema returns the JSON schema of the config struct,
+// keys are named as in YAML files (the yaml tag or the lowercased field name),
+// `swapcp` defaults, required flags and constraints are included.
+// Feed it to the editors (see YAMLSchemaModeline and VSCodeSettings)
+// to get completion and inline validation while editing the config files.
+func JSONSchema(config interface{}) ([]byte, error) {
+	t := reflect.TypeOf(config)
+	if t == nil {
+		return nil, fmt.Errorf("nil config, can't generate the schema")
+	}
+
+	schema := typeSchema(t, map[reflect.Type]bool{})
+	schema["$schema"] = jsonSchemaDraft
+	if title := indirectType(t).Name(); len(title) > 0 {
+		schema["title"] = title
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// YAMLSchemaModeline returns the YAML language server modeline
+// associating a YAML config file to its schema,
+// to be placed at the top of the file.
+func YAMLSchemaModeline(schemaPath string) string {
+	return "# yaml-language-server: $schema=" + schemaPath
+}
+
+// VSCodeSettings returns the `.vscode/settings.json` snippet associating
+// every schema path to the matching config files glob patterns,
+// for both YAML (yaml language server) and JSON files,
+// eg.: {"schemas/pg.json": {"config/pg*.yaml", "config/pg*.json"}}.
+func VSCodeSettings(schemas map[string][]string) ([]byte, error) {
+	schemaPaths := make([]string, 0, len(schemas))
+	for schemaPath := range schemas {
+		schemaPaths = append(schemaPaths, schemaPath)
+	}
+	sort.Strings(schemaPaths)
+
+	yamlSchemas := make(map[string][]string)
+	jsonSchemas := make([]map[string]interface{}, 0)
+	for _, schemaPath := range schemaPaths {
+		var yamlFiles, jsonFiles []string
+		for _, pattern := range schemas[schemaPath] {
+			if regexpJSON.MatchString(pattern) {
+				jsonFiles = append(jsonFiles, pattern)
+			} else {
+				yamlFiles = append(yamlFiles, pattern)
+			}
+		}
+		if len(yamlFiles) > 0 {
+			yamlSchemas[schemaPath] = yamlFiles
+		}
+		if len(jsonFiles) > 0 {
+			jsonSchemas = append(jsonSchemas, map[string]interface{}{"fileMatch": jsonFiles, "url": schemaPath})
+		}
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"yaml.schemas": yamlSchemas,
+		"json.schemas": jsonSchemas,
+	}, "", "  ")
+}
+
+// typeSchema returns the JSON schema of t,
+// seen holds the structs being described to stop recursive types.
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	t = indirectType(t)
+
+	switch {
+	case t == durationType:
+		return map[string]interface{}{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`}
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == urlType:
+		return map[string]interface{}{"type": "string", "format": "uri"}
+	case t == regexpType:
+		return map[string]interface{}{"type": "string", "format": "regex"}
+	case t.Implements(secretValueType):
+		return typeSchema(reflect.Zero(t).Interface().(secretValue).valueType(), seen)
+	case t == secretType, reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the JSON schema of the t struct.
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if len(sf.PkgPath) > 0 {
+			continue
+		}
+
+		key := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if key == "-" {
+			continue
+		}
+		if len(key) == 0 {
+			key = strings.ToLower(sf.Name)
+		}
+
+		property := typeSchema(sf.Type, seen)
+		isRequired, hasEnv := false, false
+		for _, flag := range splitFlags(sf.Tag.Get(sftConfigKey)) {
+			kv := strings.SplitN(flag, "=", 2)
+			switch {
+			case kv[0] == sffConfigDefault && len(kv) == 2:
+				var value interface{} = kv[1]
+				if property["type"] != "string" {
+					_ = yaml.Unmarshal([]byte(kv[1]), &value)
+				}
+				property["default"] = value
+			case kv[0] == sffConfigFile || kv[0] == sffConfigFromFile:
+				hasEnv = true
+				if len(kv) == 2 {
+					property["description"] = "Overridden by the " + kv[1] + " file content."
+				}
+			case kv[0] == sffConfigEnv:
+				hasEnv = true
+				if len(kv) == 2 {
+					property["description"] = "Overridden by the " + kv[1] + " environment variable."
+				}
+			case kv[0] == sffConfigRequired:
+				isRequired = true
+			case (kv[0] == sffConfigMin || kv[0] == sffConfigMax) && len(kv) == 2 && indirectType(sf.Type) != durationType:
+				boundSchema(property, kv[0] == sffConfigMin, kv[1])
+			case kv[0] == sffConfigLayout && indirectType(sf.Type) == timeType:
+				// not RFC3339
+				delete(property, "format")
+			case (kv[0] == sffConfigRegexp || kv[0] == sffConfigMatch) && len(kv) == 2:
+				property["pattern"] = kv[1]
+			case kv[0] == sffConfigOneOf && len(kv) == 2:
+				var enum []interface{}
+				for _, option := range strings.Split(kv[1], "|") {
+					var value interface{} = option
+					if property["type"] != "string" {
+						_ = yaml.Unmarshal([]byte(option), &value)
+					}
+					enum = append(enum, value)
+				}
+				property["enum"] = enum
+			}
+		}
+		if desc := flagValue(splitFlags(sf.Tag.Get(sftConfigKey)), sffConfigDesc); len(desc) > 0 {
+			if description, found := property["description"].(string); found {
+				desc += ". " + description
+			}
+			property["description"] = desc
+		}
+		// byte sizes can be human-readable strings (eg.: `10MB`)
+		if hasFlag(splitFlags(sf.Tag.Get(sftConfigKey)), sffConfigBytes) && property["type"] == "integer" {
+			property["type"] = []string{"integer", "string"}
+		}
+		// the environment variable or the file can provide required values
+		if isRequired && !hasEnv {
+			required = append(required, key)
+		}
+		properties[key] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// boundSchema add the min (or max) constraint to the property schema.
+func boundSchema(property map[string]interface{}, min bool, bound string) {
+	limit, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return
+	}
+
+	keys := map[interface{}][2]string{
+		"integer": {"minimum", "maximum"},
+		"number":  {"minimum", "maximum"},
+		"string":  {"minLength", "maxLength"},
+		"array":   {"minItems", "maxItems"},
+		"object":  {"minProperties", "maxProperties"},
+	}[property["type"]]
+	if len(keys[0]) == 0 {
+		return
+	}
+
+	if min {
+		property[keys[0]] = limit
+	} else {
+		property[keys[1]] = limit
+	}
+}
+
+// indirectType returns the type pointed by t, if any.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
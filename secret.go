@@ -0,0 +1,208 @@
+package swap
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Secret --------------------------------------------------------------------------------------------------------------
+
+// redacted is the Secret placeholder in any output.
+const redacted = "*****"
+
+// Secret is a string config value which is kept obscured in memory
+// and redacted in fmt, JSON, YAML and TOML outputs,
+// the real value is only returned by Reveal.
+// It is unmarshalled from any config source (files, `env=` and `default=` tags).
+type Secret struct {
+	// value is the secret xor-ed with key.
+	value []byte
+	key   []byte
+}
+
+// NewSecret returns the Secret holding s.
+func NewSecret(s string) Secret {
+	if len(s) == 0 {
+		return Secret{}
+	}
+
+	key := make([]byte, len(s))
+	if _, err := rand.Read(key); err != nil {
+		panic("swap: can't generate the secret key: " + err.Error())
+	}
+
+	value := []byte(s)
+	for i := range value {
+		value[i] ^= key[i]
+	}
+	return Secret{value: value, key: key}
+}
+
+// Reveal returns the real secret value.
+func (s Secret) Reveal() string {
+	value := make([]byte, len(s.value))
+	for i := range value {
+		value[i] = s.value[i] ^ s.key[i]
+	}
+	return string(value)
+}
+
+// IsZero returns true if the secret is empty.
+func (s Secret) IsZero() bool {
+	return len(s.value) == 0
+}
+
+// String is the fmt.Stringer implementation.
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString is the fmt.GoStringer implementation.
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// MarshalJSON is the json.Marshaler implementation.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// UnmarshalJSON is the json.Unmarshaler implementation.
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*s = NewSecret(value)
+	return nil
+}
+
+// MarshalYAML is the yaml.Marshaler implementation.
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return redacted, nil
+}
+
+// UnmarshalYAML is the yaml.Unmarshaler implementation.
+func (s *Secret) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	*s = NewSecret(value)
+	return nil
+}
+
+// MarshalText is the encoding.TextMarshaler implementation, used by TOML.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// UnmarshalText is the encoding.TextUnmarshaler implementation, used by TOML.
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = NewSecret(string(text))
+	return nil
+}
+
+// Generic secret ------------------------------------------------------------------------------------------------------
+
+// SecretOf is the Secret of any type (eg.: `SecretOf[[]byte]`, `SecretOf[int]`,
+// `SecretOf[map[string]string]`), redacted in fmt, JSON, YAML and TOML outputs,
+// the real value is only returned by Value.
+// It is unmarshalled from any config source, `env=`, `file=` and `default=` tags values
+// are decoded as the other fields of type T.
+type SecretOf[T any] struct {
+	value T
+	set   bool
+}
+
+// NewSecretOf returns the SecretOf holding value.
+func NewSecretOf[T any](value T) SecretOf[T] {
+	return SecretOf[T]{value: value, set: true}
+}
+
+// Value returns the real secret value.
+func (s SecretOf[T]) Value() T {
+	return s.value
+}
+
+// IsZero returns true if the secret has not been set.
+func (s SecretOf[T]) IsZero() bool {
+	return !s.set
+}
+
+// String is the fmt.Stringer implementation.
+func (s SecretOf[T]) String() string {
+	return redacted
+}
+
+// GoString is the fmt.GoStringer implementation.
+func (s SecretOf[T]) GoString() string {
+	return redacted
+}
+
+// MarshalJSON is the json.Marshaler implementation.
+func (s SecretOf[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// UnmarshalJSON is the json.Unmarshaler implementation.
+func (s *SecretOf[T]) UnmarshalJSON(data []byte) error {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*s = NewSecretOf(value)
+	return nil
+}
+
+// MarshalYAML is the yaml.Marshaler implementation.
+func (s SecretOf[T]) MarshalYAML() (interface{}, error) {
+	return redacted, nil
+}
+
+// UnmarshalYAML is the yaml.Unmarshaler implementation.
+func (s *SecretOf[T]) UnmarshalYAML(node *yaml.Node) error {
+	var value T
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	*s = NewSecretOf(value)
+	return nil
+}
+
+// MarshalText is the encoding.TextMarshaler implementation, used by TOML.
+func (s SecretOf[T]) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// UnmarshalText is the encoding.TextUnmarshaler implementation,
+// used by TOML and for the tags values.
+func (s *SecretOf[T]) UnmarshalText(text []byte) error {
+	var value T
+	fv := reflect.ValueOf(&value).Elem()
+	switch {
+	case fv.Kind() == reflect.String:
+		fv.SetString(string(text))
+	case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8:
+		fv.SetBytes(append([]byte(nil), text...))
+	default:
+		if err := unmarshalTagValue(string(text), fv, nil); err != nil {
+			return err
+		}
+	}
+	*s = NewSecretOf(value)
+	return nil
+}
+
+// secretValue is implemented by every SecretOf.
+type secretValue interface {
+	valueType() reflect.Type
+}
+
+// valueType returns the type of the secret value, for the JSON schema.
+func (s SecretOf[T]) valueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -0,0 +1,49 @@
+package swap
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Secret files --------------------------------------------------------------------------------------------------------
+
+// loadFileFlag set fv to the content of the `file=<path>` flag file,
+// the path can reference env vars (eg.: `file=${DB_PASSWORD_FILE:-/run/secrets/db_password}`).
+// Missing files are ignored, so the `default` and `required` flags still apply.
+// []byte fields get the raw content, the trailing newline is trimmed for the others.
+// With fromFS (the `fromfile` flag) the relative paths are read from the config FileSystem,
+// in the Builder config path.
+func (p *parser) loadFileFlag(path string, fv reflect.Value, flags []string, fromFS bool) error {
+	path, _ = p.expandString(path)
+	if len(path) == 0 {
+		return nil
+	}
+
+	var content []byte
+	var err error
+	if fromFS && !filepath.IsAbs(path) {
+		content, err = p.fs.ReadFile(filepath.Join(p.filesPath, path))
+	} else {
+		// secrets are mounted locally (eg.: Docker and Kubernetes secrets),
+		// whatever the config FileSystem.
+		content, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 &&
+		!hasFlag(flags, sffConfigBase64) && !hasFlag(flags, sffConfigAge) && !bytes.HasPrefix(content, []byte(agePrefix)) {
+		fv.SetBytes(content)
+		return nil
+	}
+	return p.decodeTagValue(strings.TrimRight(string(content), "\r\n"), fv, flags)
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// ageEncrypt returns the base64 of the age file of plain, armored if requested.
+func ageEncrypt(t *testing.T, recipient age.Recipient, plain string, armored bool) string {
+	var buf bytes.Buffer
+	var out io.WriteCloser = nopWriteCloser{&buf}
+	if armored {
+		out = armor.NewWriter(&buf)
+	}
+	w, err := age.Encrypt(out, recipient)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, plain)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, out.Close())
+
+	if armored {
+		return buf.String()
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestParseAge(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recipient := identity.Recipient()
+
+	type DB struct {
+		Host     string
+		Password string
+		Port     int
+		Cert     string `swapcp:"age"`
+	}
+
+	type Config struct {
+		DB     DB
+		Tokens []string
+		Key    swap.Secret `swapcp:"env=AGE_KEY"`
+		Token  string      `swapcp:"env=AGE_TOKEN,age"`
+	}
+
+	files := map[string][]byte{
+		"config.yaml": []byte(strings.Join([]string{
+			"db:",
+			"  host: db.example.com",
+			"  password: \"!age:" + ageEncrypt(t, recipient, "pg-pass", false) + "\"",
+			"  port: \"!age:" + ageEncrypt(t, recipient, "5432", false) + "\"",
+			"  cert: |",
+			"    " + strings.ReplaceAll(strings.TrimSpace(ageEncrypt(t, recipient, "-----BEGIN CERT-----", true)), "\n", "\n    "),
+			"tokens: [\"!age:" + ageEncrypt(t, recipient, "t0k3n", false) + "\", plain]",
+		}, "\n")),
+	}
+
+	require.NoError(t, os.Setenv("AGE_KEY", "!age:"+ageEncrypt(t, recipient, "k3y", false)))
+	defer os.Unsetenv("AGE_KEY")
+	require.NoError(t, os.Setenv("AGE_TOKEN", ageEncrypt(t, recipient, "env-token", true)))
+	defer os.Unsetenv("AGE_TOKEN")
+
+	var config Config
+	opts := swap.ParseOptions{
+		FileSystem:    swap.NewFileSystemMemory(files),
+		AgeIdentities: []age.Identity{identity},
+	}
+	require.NoError(t, swap.ParseWithOptions(&config, opts, "config"))
+	require.Equal(t, "db.example.com", config.DB.Host)
+	require.Equal(t, "pg-pass", config.DB.Password)
+	require.Equal(t, 5432, config.DB.Port)
+	require.Equal(t, "-----BEGIN CERT-----", config.DB.Cert)
+	require.Equal(t, []string{"t0k3n", "plain"}, config.Tokens)
+	require.Equal(t, "k3y", config.Key.Reveal())
+	require.Equal(t, "env-token", config.Token)
+
+	require.NoError(t, os.Setenv("AGE_TOKEN", "plain"))
+	err = swap.ParseWithOptions(&Config{}, opts, "config")
+	require.EqualError(t, err, "Token: age: invalid base64 value")
+	require.NoError(t, os.Setenv("AGE_TOKEN", ageEncrypt(t, recipient, "env-token", true)))
+
+	// without identities
+	err = swap.ParseWithOptions(&Config{}, swap.ParseOptions{FileSystem: opts.FileSystem}, "config")
+	require.EqualError(t, err, "config.yaml: age: no identity to decrypt the value")
+
+	// with the wrong identity
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	opts.AgeIdentities = []age.Identity{other}
+	err = swap.ParseWithOptions(&Config{}, opts, "config")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no identity matched any of the recipients")
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+var archiveFiles = map[string]string{
+	"config/Tool.yaml":             "teststring: generic",
+	"config/Tool.development.yaml": "teststring: development",
+	"config/sub/Nested.json":       `{"TestString": "nested"}`,
+}
+
+func writeZip(t *testing.T, path string) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	_, err := w.Create("config/")
+	require.NoError(t, err)
+	for name, content := range archiveFiles {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func writeTarGz(t *testing.T, path string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "config/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range archiveFiles {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestFileSystemArchive(t *testing.T) {
+	dir := t.TempDir()
+	writeZip(t, filepath.Join(dir, "config.zip"))
+	writeTarGz(t, filepath.Join(dir, "config.tar.gz"))
+
+	type Box struct {
+		Tool   ToolConfigurable
+		Nested ToolConfigurable `swap:"sub/Nested"`
+	}
+
+	for _, archive := range []string{"config.zip", "config.tar.gz"} {
+		fsys, err := swap.NewFileSystemArchive(filepath.Join(dir, archive))
+		require.NoError(t, err, archive)
+
+		builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys), swap.WithDebug(false))
+		builder.EnvHandler.SetCurrent("development")
+		builder.RegisterType(reflect.TypeOf(ToolConfigurable{}), func(configFiles ...string) (interface{}, error) {
+			tool := &ToolConfigurable{}
+			return tool, builder.Parse(&tool.Config, configFiles...)
+		})
+
+		var test Box
+		require.NoError(t, builder.Build(&test), archive)
+		require.Equal(t, "development", test.Tool.Config.TestString, archive)
+		require.Equal(t, "nested", test.Nested.Config.TestString, archive)
+	}
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config.txt"), []byte("not an archive"), 0644))
+	_, err := swap.NewFileSystemArchive(filepath.Join(dir, "config.txt"))
+	require.EqualError(t, err, filepath.Join(dir, "config.txt")+": unknown archive format, must be zip, tar.gz or tar")
+
+	_, err = swap.NewFileSystemArchive(filepath.Join(dir, "missing.zip"))
+	require.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// Helpers -------------------------------------------------------------------------------------------------------------
+
+func writeBenchmarkFile(b *testing.B, object interface{}, fileName string) string {
+	b.Helper()
+
+	data, err := yaml.Marshal(object)
+	require.NoError(b, err)
+
+	filePath := filepath.Join(configPath, fileName)
+	require.NoError(b, os.MkdirAll(configPath, os.ModePerm))
+	require.NoError(b, ioutil.WriteFile(filePath, data, os.ModePerm))
+	b.Cleanup(func() { _ = os.RemoveAll(configPath) })
+	return filePath
+}
+
+// Benchmarks ----------------------------------------------------------------------------------------------------------
+
+func BenchmarkParse(b *testing.B) {
+	filePath := writeBenchmarkFile(b, defaultConfig(), "config.yaml")
+
+	cases := []struct {
+		name string
+		opts swap.ParseOptions
+	}{
+		{"Default", swap.ParseOptions{}},
+		{"ForceTemplates", swap.ParseOptions{ForceTemplates: true}},
+		{"DisableTemplatesAndTags", swap.ParseOptions{DisableTemplates: true, DisableTags: true}},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var config TestConfig
+				if err := swap.ParseWithOptions(&config, c.opts, filePath); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	writeBenchmarkFile(b, ToolConfig{TestString: "0"}, "Tool.yaml")
+
+	type Box struct {
+		Tool    ToolConfigurable
+		SubBox  struct{ Tool ToolConfigurable }
+		PTRTool *ToolConfigurable `swap:"Tool"`
+	}
+
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(ioutil.Discard, "", 0)), swap.WithDebug(false))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var box Box
+		if err := builder.Build(&box); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,10 +1,18 @@
 package tests
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/oblq/swap"
 	"github.com/oblq/swap/internal/logger"
@@ -143,9 +151,7 @@ func TestMixedBox(t *testing.T) {
 	createYAML(defaultToolConfig, "SubBox/Tool4.yaml", t)
 	defer removeConfigFiles(t)
 
-	swap.FileSearchCaseSensitive = true
-
-	builder := swap.NewBuilder(configPath)
+	builder := swap.NewBuilder(configPath, swap.WithCaseSensitiveFileSearch(true))
 	builder.DebugOptions.Enabled = true
 	//builder.DebugLevel = 3
 	builder.DebugOptions.HideUnhandled = false
@@ -396,3 +402,389 @@ func TestBoxAfterConfig(t *testing.T) {
 	require.Equal(t, tString, test.Tool2.Config.TestString)
 	require.Equal(t, tString, test.Tool3.Config.TestString)
 }
+
+// ToolOrdered is a struct implementing 'Configurable' interface
+// which keep track of the configuration order.
+type ToolOrdered struct {
+	Config ToolConfig
+}
+
+var (
+	configureOrder      []string
+	configureOrderMutex sync.Mutex
+)
+
+// Configure is the 'Configurable' interface implementation.
+func (c *ToolOrdered) Configure(configFiles ...string) error {
+	if err := swap.Parse(&c.Config, configFiles...); err != nil {
+		return err
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	configureOrderMutex.Lock()
+	defer configureOrderMutex.Unlock()
+	configureOrder = append(configureOrder, c.Config.TestString)
+	return nil
+}
+
+func TestBoxDeps(t *testing.T) {
+	createYAML(ToolConfig{TestString: "API"}, "API.yml", t)
+	createYAML(ToolConfig{TestString: "DB"}, "DB.yml", t)
+	createYAML(ToolConfig{TestString: "Cache"}, "Cache.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		API   ToolOrdered  `swap:"deps=DB|Cache"`
+		Cache *ToolOrdered `swap:"deps=DB"`
+		DB    ToolOrdered
+	}
+
+	configureOrder = nil
+
+	var test Box
+	require.NoError(t, swap.NewBuilder(configPath).Build(&test))
+	require.Equal(t, []string{"DB", "Cache", "API"}, configureOrder)
+
+	type BoxCycle struct {
+		API   ToolOrdered `swap:"deps=Cache"`
+		Cache ToolOrdered `swap:"deps=DB"`
+		DB    ToolOrdered `swap:"deps=Cache"`
+	}
+
+	var testCycle BoxCycle
+	err := swap.NewBuilder(configPath).Build(&testCycle)
+	require.EqualError(t, err, "dependency cycle detected: Cache -> DB -> Cache")
+
+	type BoxUnknown struct {
+		API ToolOrdered `swap:"deps=Unknown"`
+	}
+
+	var testUnknown BoxUnknown
+	err = swap.NewBuilder(configPath).Build(&testUnknown)
+	require.EqualError(t, err, "unknown dependency 'Unknown' for field 'API'")
+}
+
+func TestBoxConcurrency(t *testing.T) {
+	createYAML(ToolConfig{TestString: "API"}, "API.yml", t)
+	createYAML(ToolConfig{TestString: "DB"}, "DB.yml", t)
+	createYAML(ToolConfig{TestString: "Cache"}, "Cache.yml", t)
+	createYAML(ToolConfig{TestString: "Queue"}, "Queue.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		API    ToolOrdered `swap:"deps=DB|Cache|Nested"`
+		DB     ToolOrdered
+		Cache  *ToolOrdered
+		Nested struct {
+			Queue ToolOrdered
+		}
+	}
+
+	configureOrder = nil
+
+	var test Box
+	start := time.Now()
+	require.NoError(t, swap.NewBuilder(configPath).Concurrency(3).Build(&test))
+	require.Less(t, int64(time.Since(start)), int64(350*time.Millisecond))
+	require.Len(t, configureOrder, 4)
+	require.Equal(t, "API", configureOrder[3])
+	require.Equal(t, "Queue", test.Nested.Queue.Config.TestString)
+
+	type BoxError struct {
+		DB        ToolOrdered
+		ToolError ToolError
+		API       ToolOrdered `swap:"deps=ToolError"`
+	}
+
+	configureOrder = nil
+
+	var testError BoxError
+	require.Error(t, swap.NewBuilder(configPath).Concurrency(3).Build(&testError))
+	require.Empty(t, testError.API.Config.TestString)
+}
+
+// countingFS is a swap.FileSystem counting directory reads.
+type countingFS struct {
+	swap.FileSystem
+	reads int
+}
+
+func (c *countingFS) ReadDir(name string) ([]os.DirEntry, error) {
+	c.reads++
+	return c.FileSystem.ReadDir(name)
+}
+
+func TestBuilderOptions(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool1.yml", t)
+	createYAML(ToolConfig{TestString: "1"}, "Custom.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool1 ToolConfigurable `myapp:"Custom" swap:"-"`
+	}
+
+	var out bytes.Buffer
+	fs := &countingFS{FileSystem: swap.NewFileSystemLocal()}
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.SetCurrent(swap.DefaultEnvs.Staging.Tag())
+
+	builder := swap.NewBuilder(configPath,
+		swap.WithFileSystem(fs),
+		swap.WithEnvHandler(eh),
+		swap.WithLogger(log.New(&out, "", 0)),
+		swap.WithDebug(true),
+		swap.WithTagKey("myapp"),
+	)
+	require.Equal(t, eh, builder.EnvHandler)
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "1", test.Tool1.Config.TestString)
+	require.NotZero(t, fs.reads)
+	require.Contains(t, out.String(), "STAGING")
+	require.Contains(t, out.String(), "Tool1")
+
+	out.Reset()
+	test = Box{}
+	require.NoError(t, swap.NewBuilder(configPath, swap.WithLogger(log.New(&out, "", 0)), swap.WithDebug(false)).Build(&test))
+	require.NotContains(t, out.String(), "Tool1")
+	require.Equal(t, "", test.Tool1.Config.TestString)
+}
+
+func TestBuilderCaseSensitiveFileSearch(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "tool1.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool1 ToolConfigurable
+	}
+
+	var test Box
+	require.NoError(t, swap.NewBuilder(configPath, swap.WithDebug(false)).Build(&test))
+	require.Equal(t, "0", test.Tool1.Config.TestString)
+
+	test = Box{}
+	err := swap.NewBuilder(configPath, swap.WithDebug(false), swap.WithCaseSensitiveFileSearch(true)).Build(&test)
+	require.Error(t, err)
+}
+
+// ToolCustomTags is a configurable tool using custom tag keys.
+type ToolCustomTags struct {
+	Config struct {
+		TestString string `myappcp:"default=custom"`
+		Required   string `swapcp:"required"`
+	}
+}
+
+var customTagsBuilder = swap.NewBuilder(configPath).WithTagKey("myapp")
+
+// Configure is the 'Configurable' interface implementation.
+func (c *ToolCustomTags) Configure(configFiles ...string) error {
+	return customTagsBuilder.Parse(&c.Config, configFiles...)
+}
+
+func TestBuilderTagKey(t *testing.T) {
+	createYAML(map[string]string{}, "Custom.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool ToolCustomTags `myapp:"Custom"`
+		Skip ToolCustomTags `myapp:"-"`
+	}
+
+	var test Box
+	require.NoError(t, customTagsBuilder.Build(&test))
+	require.Equal(t, "custom", test.Tool.Config.TestString)
+}
+
+func TestBoxGlobTag(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool1.yml", t)
+	createYAML(ToolConfig{TestString: "1"}, "conf.d/01-base.yaml", t)
+	createYAML(ToolConfig{TestString: "2"}, "conf.d/02-override.yaml", t)
+	createYAML(ToolConfig{TestString: "x"}, "conf.d/03-ignored.json", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool1 ToolConfigurable `swap:"conf.d/*.yaml"`
+		Tool2 ToolConfigurable `swap:"conf.d/01-*"`
+	}
+
+	var test Box
+	require.NoError(t, swap.NewBuilder(configPath).Build(&test))
+	require.Equal(t, "2", test.Tool1.Config.TestString)
+	require.Equal(t, "1", test.Tool2.Config.TestString)
+}
+
+func TestBoxRecursiveFileSearch(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "services/mail/Tool1.yml", t)
+	createYAML(ToolConfig{TestString: "2"}, "services/push/deep/Tool2.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool1 ToolConfigurable
+		Tool2 ToolConfigurable
+	}
+
+	var test Box
+	require.Error(t, swap.NewBuilder(configPath, swap.WithDebug(false)).Build(&test))
+
+	test = Box{}
+	require.NoError(t, swap.NewBuilder(configPath, swap.WithRecursiveFileSearch(true)).Build(&test))
+	require.Equal(t, "1", test.Tool1.Config.TestString)
+	require.Equal(t, "2", test.Tool2.Config.TestString)
+
+	type BoxTag struct {
+		Tool1 ToolConfigurable `swap:"-"`
+		Tool2 ToolConfigurable `swap:"**/Tool2"`
+	}
+
+	var testTag BoxTag
+	require.NoError(t, swap.NewBuilder(configPath).Build(&testTag))
+	require.Equal(t, "2", testTag.Tool2.Config.TestString)
+}
+
+func TestBuilderFileSystemFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Tool1.yml":              {Data: []byte("teststring: root")},
+		"Tool1.production.yml":   {Data: []byte("teststring: production")},
+		"nested/Tool2.json":      {Data: []byte(`{"TestString": "nested"}`)},
+		"nested/deep/Tool3.toml": {Data: []byte(`TestString = "deep"`)},
+	}
+
+	type Box struct {
+		Tool1 ToolConfigurable
+		Tool2 ToolConfigurable `swap:"nested/Tool2"`
+	}
+
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.SetCurrent(swap.DefaultEnvs.Production.Tag())
+
+	fs := swap.NewFileSystemFS(fsys, "./config")
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fs), swap.WithEnvHandler(eh))
+	builder.RegisterType(reflect.TypeOf(ToolConfigurable{}), func(configFiles ...string) (interface{}, error) {
+		tool := ToolConfigurable{}
+		err := swap.ParseWithFS(fs, &tool.Config, configFiles...)
+		return tool, err
+	})
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "production", test.Tool1.Config.TestString)
+	require.Equal(t, "nested", test.Tool2.Config.TestString)
+
+	var config ToolConfig
+	require.NoError(t, swap.ParseWithFS(fs, &config, "config/nested/deep/Tool3"))
+	require.Equal(t, "deep", config.TestString)
+	require.Error(t, swap.ParseWithFS(fs, &config, "other/Tool1.yml"))
+}
+
+func TestBoxOptionalConfigFiles(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yaml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool     ToolConfigurable
+		Missing  ToolConfigurable  `swap:"optional"`
+		PTRTool  *ToolConfigurable `swap:"Missing,optional"`
+		Makeable ToolMakeable      `swap:"optional"`
+		Found    ToolConfigurable  `swap:"Tool,optional"`
+	}
+
+	var test Box
+	require.NoError(t, swap.NewBuilder(configPath).Build(&test))
+	require.Equal(t, "0", test.Tool.Config.TestString)
+	require.Zero(t, test.Missing)
+	require.NotNil(t, test.PTRTool)
+	require.Zero(t, *test.PTRTool)
+	require.Zero(t, test.Makeable)
+	require.Equal(t, "0", test.Found.Config.TestString)
+
+	type BoxRequired struct {
+		Missing ToolConfigurable
+	}
+
+	require.Error(t, swap.NewBuilder(configPath).Build(&BoxRequired{}))
+}
+
+func TestMustBuild(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool ToolConfigurable
+	}
+
+	type BoxError struct {
+		Tool      ToolConfigurable
+		ToolError ToolError
+	}
+
+	swap.SetColoredLogs(false)
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(ioutil.Discard, "", 0)), swap.WithDebug(false))
+
+	var box Box
+	require.NotPanics(t, func() { builder.MustBuild(&box) })
+	require.Equal(t, "0", box.Tool.Config.TestString)
+
+	var boxError BoxError
+	defer func() {
+		msg, ok := recover().(string)
+		require.True(t, ok)
+		require.Contains(t, msg, "type BoxError struct {")
+		require.Contains(t, msg, "Tool tests.ToolConfigurable")
+		require.True(t, strings.HasSuffix(msg, "}\n\nToolError: no config file found for '/tmp/swap/ToolError'"), msg)
+	}()
+	builder.MustBuild(&boxError)
+}
+
+func TestSetOutput(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool ToolConfigurable
+	}
+
+	swap.SetColoredLogs(false)
+	var out bytes.Buffer
+	var box Box
+	require.NoError(t, swap.NewBuilder(configPath).SetOutput(&out).Build(&box))
+	require.Contains(t, out.String(), "Swap: ")
+	require.Contains(t, out.String(), "type Box struct {")
+	require.Contains(t, out.String(), "Tool tests.ToolConfigurable")
+}
+
+func TestVerbosity(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool   ToolConfigurable
+		Mailer ToolError `swap:"degrade"`
+	}
+
+	swap.SetColoredLogs(false)
+	build := func(options ...swap.Option) string {
+		var out bytes.Buffer
+		var box Box
+		options = append(options, swap.WithLogger(log.New(&out, "", 0)))
+		require.NoError(t, swap.NewBuilder(configPath, options...).Build(&box))
+		return out.String()
+	}
+
+	full := build()
+	require.Contains(t, full, "Swap: ")
+	require.Contains(t, full, "type Box struct {")
+
+	require.Empty(t, build(swap.WithVerbosity(swap.VerbositySilent)))
+	require.Empty(t, build(swap.WithDebug(false)))
+
+	errorsOnly := build(swap.WithVerbosity(swap.VerbosityErrors))
+	require.True(t, strings.HasPrefix(errorsOnly, "Swap: Mailer degraded: "), errorsOnly)
+	require.Equal(t, 1, strings.Count(errorsOnly, "\n"))
+
+	summary := build(swap.WithVerbosity(swap.VerbositySummary))
+	require.NotContains(t, summary, "type Box struct {")
+	require.Contains(t, summary, "Swap: Mailer degraded: ")
+	require.Contains(t, summary, "Swap: Box, 3 fields: 1 configured, 1 unhandled, 1 degraded\n")
+}
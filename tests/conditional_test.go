@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+type ConditionalConfig struct {
+	TLS struct {
+		Enabled bool
+		Cert    string `swapcp:"requiredIf=TLS.Enabled"`
+	}
+	Mode     string
+	CA       string   `swapcp:"requiredIf=Mode=verify"`
+	Primary  string   `swapcp:"defaultFrom=Hosts[0]"`
+	Hosts    []string `swapcp:"default=[localhost]"`
+	Port     int      `swapcp:"default=5432"`
+	PortName string   `swapcp:"defaultFrom=Port"`
+}
+
+func TestParseConditionalTags(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	cases := []struct {
+		yaml string
+		path string
+		tag  string
+		err  string
+	}{
+		{"tls: {enabled: false}", "", "", ""},
+		{"tls: {enabled: true, cert: server.pem}", "", "", ""},
+		{"tls: {enabled: true}", "TLS.Cert", "requiredIf", "TLS.Cert: required if TLS.Enabled"},
+		{"mode: verify", "CA", "requiredIf", "CA: required if Mode=verify"},
+		{"mode: verify\nca: ca.pem", "", "", ""},
+	}
+
+	for _, c := range cases {
+		writeFiles("conditional.yaml", []byte(c.yaml), t)
+
+		var config ConditionalConfig
+		err := swap.Parse(&config, configPath+"/conditional.yaml")
+		if len(c.err) == 0 {
+			require.NoError(t, err, c.yaml)
+			continue
+		}
+
+		require.EqualError(t, err, c.err, c.yaml)
+		var fe *swap.FieldError
+		require.True(t, errors.As(err, &fe))
+		require.Equal(t, c.path, fe.Path)
+		require.Equal(t, c.tag, fe.Tag)
+		require.True(t, errors.Is(err, swap.ErrRequired))
+	}
+
+	writeFiles("conditional.yaml", []byte("hosts: [db1, db2]"), t)
+	var config ConditionalConfig
+	require.NoError(t, swap.Parse(&config, configPath+"/conditional.yaml"))
+	require.Equal(t, "db1", config.Primary)
+	require.Equal(t, "5432", config.PortName)
+
+	writeFiles("conditional.yaml", []byte("primary: db2\nhosts: [db1, db2]"), t)
+	config = ConditionalConfig{}
+	require.NoError(t, swap.Parse(&config, configPath+"/conditional.yaml"))
+	require.Equal(t, "db2", config.Primary)
+
+	type BrokenConfig struct {
+		Host string `swapcp:"defaultFrom=Missing"`
+	}
+	writeFiles("conditional.yaml", []byte("{}"), t)
+	err := swap.Parse(&BrokenConfig{}, configPath+"/conditional.yaml")
+	require.EqualError(t, err, "Host: unknown field 'Missing' in 'Missing'")
+}
@@ -2,12 +2,22 @@ package tests
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/oblq/swap"
@@ -381,30 +391,166 @@ func TestMapNoFiles(t *testing.T) {
 	require.NotNil(t, err)
 }
 
-//func TestUnmarshal(t *testing.T) {
-//	defaultConfig := defaultConfig()
-//	var configUnmarshal TestConfig
-//
-//	var tomlMarsh bytes.Buffer
-//	err := toml.NewEncoder(&tomlMarsh).Encode(defaultConfig)
-//	require.Nil(t, err)
-//	err = swap.ConfigParser.Unmarshal(tomlMarsh.Bytes(), &configUnmarshal)
-//	require.Nil(t, err)
-//
-//	confBytes, err := json.Marshal(defaultConfig)
-//	require.Nil(t, err)
-//	err = swap.ConfigParser.Unmarshal(confBytes, &configUnmarshal)
-//	require.Nil(t, err)
-//
-//	confBytes, err = yaml.Marshal(defaultConfig)
-//	require.Nil(t, err)
-//	err = swap.ConfigParser.Unmarshal(confBytes, &configUnmarshal)
-//	require.Nil(t, err)
-//
-//	// wrong bytes
-//	err = swap.ConfigParser.Unmarshal([]byte("wrong"), &configUnmarshal)
-//	require.NotNil(t, err)
-//}
+func TestUnmarshalBytes(t *testing.T) {
+	defaultConfig := defaultConfig()
+
+	var tomlMarsh bytes.Buffer
+	err := toml.NewEncoder(&tomlMarsh).Encode(defaultConfig)
+	require.Nil(t, err)
+	var configUnmarshal TestConfig
+	err = swap.UnmarshalBytes(tomlMarsh.Bytes(), &configUnmarshal)
+	require.Nil(t, err)
+	require.Equal(t, defaultConfig, configUnmarshal)
+
+	confBytes, err := json.Marshal(defaultConfig)
+	require.Nil(t, err)
+	configUnmarshal = TestConfig{}
+	err = swap.UnmarshalBytes(confBytes, &configUnmarshal)
+	require.Nil(t, err)
+	require.Equal(t, defaultConfig, configUnmarshal)
+
+	confBytes, err = yaml.Marshal(defaultConfig)
+	require.Nil(t, err)
+	configUnmarshal = TestConfig{}
+	err = swap.UnmarshalBytes(confBytes, &configUnmarshal)
+	require.Nil(t, err)
+	require.Equal(t, defaultConfig, configUnmarshal)
+
+	// wrong bytes
+	err = swap.UnmarshalBytes([]byte("wrong"), &configUnmarshal)
+	require.NotNil(t, err)
+
+	err = swap.UnmarshalBytes(confBytes, configUnmarshal)
+	require.NotNil(t, err)
+}
+
+func TestParseReader(t *testing.T) {
+	type Config struct {
+		Host string `swapcp:"required"`
+		Port int    `swapcp:"default=8080"`
+		URL  string
+	}
+
+	var config Config
+	err := swap.ParseReader(strings.NewReader("host = \"localhost\"\nurl = \"http://{{.Host}}\""), swap.FormatTOML, &config)
+	require.NoError(t, err)
+	require.Equal(t, Config{Host: "localhost", Port: 8080, URL: "http://localhost"}, config)
+
+	config = Config{}
+	err = swap.ParseReader(strings.NewReader(`{"host": "example.com", "port": 80}`), "JSON", &config)
+	require.NoError(t, err)
+	require.Equal(t, Config{Host: "example.com", Port: 80}, config)
+
+	err = swap.ParseReader(strings.NewReader("port: 80"), swap.FormatYAML, &Config{})
+	require.True(t, errors.Is(err, swap.ErrRequired))
+
+	err = swap.ParseReader(strings.NewReader("host: x"), "xml", &Config{})
+	require.EqualError(t, err, "unknown data format: 'xml'")
+}
+
+func TestParseBytes(t *testing.T) {
+	type Config struct {
+		Hosts   []string
+		Timeout time.Duration `swapcp:"default=5s"`
+	}
+
+	var config Config
+	require.NoError(t, swap.ParseBytes([]byte("hosts: [a, b]"), swap.FormatYAML, &config))
+	require.Equal(t, Config{Hosts: []string{"a", "b"}, Timeout: 5 * time.Second}, config)
+
+	// the format is not sniffed
+	err := swap.ParseBytes([]byte("hosts: [a, b]"), swap.FormatJSON, &Config{})
+	require.Error(t, err)
+
+	err = swap.ParseBytes([]byte("hosts: [a, b]"), swap.FormatYAML, Config{})
+	require.Error(t, err)
+}
+
+func TestUnmarshalBytesUntrusted(t *testing.T) {
+	type Config struct {
+		Secret string
+		Common map[string]string
+	}
+
+	t.Setenv("SWAP_BYTES_SECRET", "s3cr3t")
+	data := []byte("secret: ${SWAP_BYTES_SECRET}\ncommon: {$ref: 'common.yaml#/common'}\n")
+
+	// the data references and env vars are not resolved
+	var config Config
+	require.NoError(t, swap.UnmarshalBytes(data, &config))
+	require.Equal(t, Config{Secret: "${SWAP_BYTES_SECRET}", Common: map[string]string{"$ref": "common.yaml#/common"}}, config)
+}
+
+func TestParseBytesUntrusted(t *testing.T) {
+	type Config struct {
+		Secret string
+		Common map[string]string
+	}
+
+	t.Setenv("SWAP_BYTES_SECRET", "s3cr3t")
+	data := []byte("secret: ${SWAP_BYTES_SECRET}\ncommon: {$ref: 'common.yaml#/common'}\n")
+
+	// the data references and env vars are not resolved by default
+	var config Config
+	require.NoError(t, swap.ParseBytes(data, swap.FormatYAML, &config))
+	require.Equal(t, Config{Secret: "${SWAP_BYTES_SECRET}", Common: map[string]string{"$ref": "common.yaml#/common"}}, config)
+
+	config = Config{}
+	require.NoError(t, swap.ParseReader(bytes.NewReader(data), swap.FormatYAML, &config))
+	require.Equal(t, "${SWAP_BYTES_SECRET}", config.Secret)
+
+	// explicitly trusted
+	fsys := fstest.MapFS{"common.yaml": {Data: []byte("common: {host: localhost}")}}
+	config = Config{}
+	opts := swap.ParseOptions{FileSystem: fsys, TrustedData: true}
+	require.NoError(t, swap.ParseBytesWithOptions(data, swap.FormatYAML, &config, opts))
+	require.Equal(t, Config{Secret: "s3cr3t", Common: map[string]string{"host": "localhost"}}, config)
+}
+
+func TestConfigWithoutTemplates(t *testing.T) {
+	type Config struct {
+		Pattern string
+		Format  string
+	}
+
+	data := []byte(`{"Pattern": "^[a-z]{2,3}$", "Format": "{\"level\": \"%s\"}}"}`)
+	fileName := "config.json"
+	require.NoError(t, os.MkdirAll(configPath, os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configPath, fileName), data, os.ModePerm))
+	defer removeConfigFiles(t)
+
+	expected := Config{Pattern: "^[a-z]{2,3}$", Format: `{"level": "%s"}}`}
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, fileName)))
+	require.Equal(t, expected, config)
+
+	var forced Config
+	opts := swap.ParseOptions{ForceTemplates: true}
+	require.NoError(t, swap.ParseWithOptions(&forced, opts, filepath.Join(configPath, fileName)))
+	require.Equal(t, expected, forced)
+}
+
+func TestParseDisableTemplatesAndTags(t *testing.T) {
+	type Config struct {
+		Text1 string
+		Text2 string
+		Port  int `swapcp:"default=5432"`
+	}
+
+	fileName := "config.yaml"
+	createYAML(Config{Text1: "Hello", Text2: "{{.Text1}} world!"}, fileName, t)
+	defer removeConfigFiles(t)
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, fileName)))
+	require.Equal(t, Config{Text1: "Hello", Text2: "Hello world!", Port: 5432}, config)
+
+	var fast Config
+	opts := swap.ParseOptions{DisableTemplates: true, DisableTags: true}
+	require.NoError(t, swap.ParseWithOptions(&fast, opts, filepath.Join(configPath, fileName)))
+	require.Equal(t, Config{Text1: "Hello", Text2: "{{.Text1}} world!"}, fast)
+}
 
 func TestConfigWTemplates(t *testing.T) {
 	config := defaultConfigWTemplates()
@@ -423,18 +569,18 @@ func TestConfigWTemplates(t *testing.T) {
 	require.Equal(t, expected, result.TStruct.Text, "error in template parsing: %+v", result.TStruct.Text)
 	require.Equal(t, expected, result.TStruct.TStruct2.Text, "error in template parsing: %+v", result.TStruct.TStruct2.Text)
 
-	//var uResult ConfigWTemplates
-	//
-	//confBytes, err := yaml.Marshal(config)
-	//require.Nil(t, err)
-	//err = swap.ConfigParser.Unmarshal(confBytes, &uResult)
-	//require.Nil(t, err)
-	//
-	//require.Equal(t, expected, uResult.Text2, "error in template parsing: %+v", uResult.Text2)
-	//require.Equal(t, expected, uResult.TextSlice[0], "error in template parsing: %+v", uResult.TextSlice[0])
-	//require.Equal(t, expected, uResult.TextMap["text"], "error in template parsing: %+v", uResult.TextMap["text"])
-	//require.Equal(t, expected, uResult.TStruct.Text, "error in template parsing: %+v", uResult.TStruct.Text)
-	//require.Equal(t, expected, uResult.TStruct.TStruct2.Text, "error in template parsing: %+v", uResult.TStruct.TStruct2.Text)
+	var uResult ConfigWTemplates
+
+	confBytes, err := yaml.Marshal(config)
+	require.Nil(t, err)
+	err = swap.UnmarshalBytes(confBytes, &uResult)
+	require.Nil(t, err)
+
+	require.Equal(t, expected, uResult.Text2, "error in template parsing: %+v", uResult.Text2)
+	require.Equal(t, expected, uResult.TextSlice[0], "error in template parsing: %+v", uResult.TextSlice[0])
+	require.Equal(t, expected, uResult.TextMap["text"], "error in template parsing: %+v", uResult.TextMap["text"])
+	require.Equal(t, expected, uResult.TStruct.Text, "error in template parsing: %+v", uResult.TStruct.Text)
+	require.Equal(t, expected, uResult.TStruct.TStruct2.Text, "error in template parsing: %+v", uResult.TStruct.TStruct2.Text)
 }
 
 // SFT = struct field tags
@@ -531,3 +677,953 @@ func TestSFTEnv(t *testing.T) {
 //	require.Equal(t, 2, len(files5))
 //	require.Equal(t, filepath.Join(configPath, "tool."+env.Tag+".json"), files5[1])
 //}
+
+func TestParseWithFS(t *testing.T) {
+	fsys := swap.NewFileSystemFS(fstest.MapFS{
+		"config/tool.yaml":            {Data: []byte("teststring: generic")},
+		"config/tool.production.yaml": {Data: []byte("teststring: production")},
+	}, "")
+
+	var config ToolConfig
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/tool"))
+	require.Equal(t, "generic", config.TestString)
+
+	require.NoError(t, swap.ParseByEnvWithFS(fsys, &config, swap.DefaultEnvs.Production, "./config/tool.yaml"))
+	require.Equal(t, "production", config.TestString)
+
+	// the local file system is untouched
+	require.Error(t, swap.Parse(&config, "config/tool"))
+}
+
+func TestParseRefs(t *testing.T) {
+	writeFiles("common.yaml", []byte("cluster:\n  address: 10.0.0.1\n  nodes: [a, b]\nproject: swap\n"), t)
+	writeFiles("tool.yaml", []byte("teststring: {$ref: 'common.yaml#/cluster/address'}\n"), t)
+	writeFiles("tool.json", []byte(`{"TestString": {"$ref": "common.yaml#/cluster/nodes/1"}}`), t)
+	writeFiles("tool.toml", []byte("TestString = { \"$ref\" = \"#/Project\" }\nProject = { \"$ref\" = \"common.yaml#/project\" }\n"), t)
+	writeFiles("cycle.yaml", []byte("teststring: {$ref: '#/a'}\na: {$ref: '#/teststring'}\n"), t)
+	writeFiles("missing.yaml", []byte("teststring: {$ref: 'common.yaml#/missing'}\n"), t)
+	defer removeConfigFiles(t)
+
+	var config ToolConfig
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "tool.yaml")))
+	require.Equal(t, "10.0.0.1", config.TestString)
+
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "tool.json")))
+	require.Equal(t, "b", config.TestString)
+
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "tool.toml")))
+	require.Equal(t, "swap", config.TestString)
+
+	require.Error(t, swap.Parse(&config, filepath.Join(configPath, "cycle.yaml")))
+	require.EqualError(t, swap.Parse(&config, filepath.Join(configPath, "missing.yaml")),
+		"can't resolve reference 'common.yaml#/missing': key 'missing' not found")
+}
+
+func TestFileSystemOverlay(t *testing.T) {
+	defaults := swap.NewFileSystemFS(fstest.MapFS{
+		"tool.yaml":            {Data: []byte("teststring: default")},
+		"tool.production.yaml": {Data: []byte("teststring: default production")},
+		"other.yaml":           {Data: []byte("teststring: other")},
+	}, "config")
+	overrides := swap.NewFileSystemMemory(map[string][]byte{
+		"config/tool.yaml": []byte("teststring: override"),
+	})
+	fsys := swap.NewFileSystemOverlay(overrides, defaults)
+
+	var config ToolConfig
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/tool"))
+	require.Equal(t, "override", config.TestString)
+
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/other"))
+	require.Equal(t, "other", config.TestString)
+
+	require.NoError(t, swap.ParseByEnvWithFS(fsys, &config, swap.DefaultEnvs.Production, "config/tool"))
+	require.Equal(t, "default production", config.TestString)
+
+	entries, err := fsys.ReadDir("config")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	_, err = fsys.ReadFile("config/missing.yaml")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestParseConflicts(t *testing.T) {
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"a.yaml":            []byte("teststring: a\nshared: same\n"),
+		"b.json":            []byte(`{"TestString": "b", "Shared": "same"}`),
+		"a.production.yaml": []byte("teststring: a production\n"),
+		"c.yaml":            []byte("shared: same\n"),
+	})
+
+	var config ToolConfig
+	opts := swap.ParseOptions{FileSystem: fsys, ReportConflicts: true}
+	err := swap.ParseWithOptions(&config, opts, "a", "b")
+	require.EqualError(t, err, "config conflicts: teststring: 'a' (a.yaml) != 'b' (b.json)")
+
+	var conflictErr *swap.ConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	require.Len(t, conflictErr.Conflicts, 1)
+	require.Equal(t, []string{"a.yaml", "b.json"}, conflictErr.Conflicts[0].Sources)
+
+	// environment specific files override the generic ones
+	opts.Env = swap.DefaultEnvs.Production
+	require.NoError(t, swap.ParseWithOptions(&config, opts, "a", "c"))
+	require.Equal(t, "a production", config.TestString)
+
+	// last wins by default
+	opts.ReportConflicts = false
+	require.NoError(t, swap.ParseWithOptions(&config, opts, "a", "b"))
+	require.Equal(t, "b", config.TestString)
+}
+
+// countingStatFS is a swap.StatFileSystem counting files reads.
+type countingStatFS struct {
+	swap.StatFileSystem
+	reads int
+}
+
+func (c *countingStatFS) ReadFile(name string) ([]byte, error) {
+	c.reads++
+	return c.StatFileSystem.ReadFile(name)
+}
+
+func TestFileSystemCached(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "tool.yaml", t)
+	defer removeConfigFiles(t)
+
+	local := &countingStatFS{StatFileSystem: swap.NewFileSystemLocal().(swap.StatFileSystem)}
+	fsys := swap.NewFileSystemCached(local)
+
+	var config ToolConfig
+	for i := 0; i < 3; i++ {
+		require.NoError(t, swap.ParseWithFS(fsys, &config, filepath.Join(configPath, "tool")))
+		require.Equal(t, "0", config.TestString)
+	}
+	require.Equal(t, 1, local.reads)
+
+	createYAML(ToolConfig{TestString: "updated"}, "tool.yaml", t)
+	require.NoError(t, swap.ParseWithFS(fsys, &config, filepath.Join(configPath, "tool")))
+	require.Equal(t, "updated", config.TestString)
+	require.Equal(t, 2, local.reads)
+
+	createYAML(ToolConfig{TestString: "0"}, "other.yaml", t)
+	require.NoError(t, swap.ParseWithFS(fsys, &config, filepath.Join(configPath, "other")))
+	require.Equal(t, "0", config.TestString)
+}
+
+func TestParseWeakTyping(t *testing.T) {
+	type weakConfig struct {
+		Enabled bool                `yaml:"enabled"`
+		Debug   *bool               `yaml:"debug"`
+		Ratio   float64             `yaml:"ratio"`
+		Port    int                 `yaml:"port"`
+		Flags   map[string]bool     `yaml:"flags"`
+		Nested  []struct{ On bool } `yaml:"nested"`
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"weak.yaml": []byte("enabled: 'yes'\ndebug: off\nratio: '1,5'\nport: '8080'\nflags: {a: 'on', b: 'N'}\nnested: [{on: 'y'}]\n"),
+		"weak.json": []byte(`{"Enabled": "Yes", "Ratio": "0,25", "Port": "80"}`),
+	})
+
+	var normalizations []swap.Normalization
+	opts := swap.ParseOptions{
+		FileSystem: fsys,
+		WeakTyping: &swap.WeakTyping{
+			DecimalComma: true,
+			Report:       func(n swap.Normalization) { normalizations = append(normalizations, n) },
+		},
+	}
+
+	var config weakConfig
+	require.NoError(t, swap.ParseWithOptions(&config, opts, "weak.yaml"))
+	require.True(t, config.Enabled)
+	require.False(t, *config.Debug)
+	require.Equal(t, 1.5, config.Ratio)
+	require.Equal(t, 8080, config.Port)
+	require.Equal(t, map[string]bool{"a": true, "b": false}, config.Flags)
+	require.True(t, config.Nested[0].On)
+	require.Len(t, normalizations, 7)
+	require.Contains(t, normalizations, swap.Normalization{File: "weak.yaml", Key: "ratio", From: "1,5", To: 1.5})
+
+	config = weakConfig{}
+	require.NoError(t, swap.ParseWithOptions(&config, opts, "weak.json"))
+	require.True(t, config.Enabled)
+	require.Equal(t, 0.25, config.Ratio)
+	require.Equal(t, 80, config.Port)
+
+	config = weakConfig{}
+	require.Error(t, swap.ParseWithOptions(&config, swap.ParseOptions{FileSystem: fsys}, "weak.json"))
+}
+
+func TestParseStrict(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	writeFiles("strict.yaml", []byte("teststring: ok\ntestsring: typo\n"), t)
+	writeFiles("strict.json", []byte(`{"TestString": "ok", "TestSring": "typo"}`), t)
+	writeFiles("strict.toml", []byte("TestString = \"ok\"\nTestSring = \"typo\"\n"), t)
+
+	for _, file := range []string{"strict.yaml", "strict.json", "strict.toml"} {
+		var config ToolConfig
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, file)), file)
+		require.Equal(t, "ok", config.TestString)
+
+		err := swap.ParseStrict(&config, filepath.Join(configPath, file))
+		require.Error(t, err, file)
+		require.Contains(t, strings.ToLower(err.Error()), "testsring", file)
+	}
+
+	builder := swap.NewBuilder(configPath).Strict(true)
+	require.Error(t, builder.Parse(&ToolConfig{}, filepath.Join(configPath, "strict.yaml")))
+
+	err := swap.ParseWithOptions(&ToolConfig{}, swap.ParseOptions{Strict: true}, filepath.Join(configPath, "strict.json"))
+	require.Error(t, err)
+}
+
+func TestSFTEnvPrefix(t *testing.T) {
+	writeFiles("prefix.yaml", []byte("pg:\n  host: file\n  port: 1\n  user: file"), t)
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		PG struct {
+			Host string
+			Port int
+			User string `swapcp:"env=PG_EXPLICIT_USER"`
+			DB   string `swapcp:"default=postgres"`
+			Pool struct {
+				Size int
+			}
+		} `swapcp:"envPrefix=POSTGRES_"`
+	}
+
+	env := map[string]string{
+		"POSTGRES_HOST":      "env",
+		"POSTGRES_PORT":      "5432",
+		"POSTGRES_USER":      "ignored",
+		"PG_EXPLICIT_USER":   "explicit",
+		"POSTGRES_POOL_SIZE": "10",
+	}
+	for key, value := range env {
+		require.NoError(t, os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "prefix.yaml")))
+	require.Equal(t, "env", config.PG.Host)
+	require.Equal(t, 5432, config.PG.Port)
+	require.Equal(t, "explicit", config.PG.User)
+	require.Equal(t, "postgres", config.PG.DB)
+	require.Equal(t, 10, config.PG.Pool.Size)
+
+	require.NoError(t, os.Setenv("POSTGRES_PORT", "not a number"))
+	err := swap.Parse(&config, filepath.Join(configPath, "prefix.yaml"))
+	var fe *swap.FieldError
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "PG.Port", fe.Path)
+}
+
+func TestParseEnv(t *testing.T) {
+	type Config struct {
+		Host    string
+		Port    int  `swapcp:"default=8080"`
+		Debug   bool `swapcp:"env=DEBUG"`
+		Timeout time.Duration
+		PG      struct {
+			User     string `swapcp:"required"`
+			Password swap.Secret
+		}
+		Cache *struct {
+			Size int
+		}
+	}
+
+	env := map[string]string{
+		"APP_HOST":        "example.com",
+		"DEBUG":           "true",
+		"APP_TIMEOUT":     "5s",
+		"APP_PG_USER":     "admin",
+		"APP_PG_PASSWORD": "secret",
+	}
+	for key, value := range env {
+		require.NoError(t, os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+
+	var config Config
+	require.NoError(t, swap.ParseEnv(&config, "APP_"))
+	require.Equal(t, "example.com", config.Host)
+	require.Equal(t, 8080, config.Port)
+	require.True(t, config.Debug)
+	require.Equal(t, 5*time.Second, config.Timeout)
+	require.Equal(t, "admin", config.PG.User)
+	require.Equal(t, "secret", config.PG.Password.Reveal())
+	require.Nil(t, config.Cache)
+
+	// without prefix
+	require.NoError(t, os.Setenv("HOST", "no-prefix"))
+	defer os.Unsetenv("HOST")
+	require.NoError(t, os.Setenv("PG_USER", "root"))
+	defer os.Unsetenv("PG_USER")
+
+	config = Config{}
+	require.NoError(t, swap.ParseEnv(&config, ""))
+	require.Equal(t, "no-prefix", config.Host)
+	require.Equal(t, "root", config.PG.User)
+
+	require.EqualError(t, swap.ParseEnv(&Config{}, "MISSING_"), "PG.User: required")
+	require.Error(t, swap.ParseEnv(Config{}, "APP_"))
+}
+
+func TestParseEnvPrefix(t *testing.T) {
+	type Config struct {
+		Host  string
+		Port  int
+		Debug bool `swapcp:"env=PREFIX_DEBUG"`
+		PG    struct {
+			User     string
+			Password string `swapcp:"required"`
+		}
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml": []byte("host: localhost\nport: 80\npg: {user: root}\n"),
+	})
+
+	env := map[string]string{
+		"PFX_PORT":        "8080",
+		"PFX_DEBUG":       "true",
+		"PREFIX_DEBUG":    "false",
+		"PFX_PG_PASSWORD": "s3cr3t",
+	}
+	for key, value := range env {
+		require.NoError(t, os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+
+	var config Config
+	require.NoError(t, swap.ParseWithOptions(&config, swap.ParseOptions{FileSystem: fsys, EnvPrefix: "PFX"}, "config/app"))
+	require.Equal(t, "localhost", config.Host)
+	require.Equal(t, 8080, config.Port)
+	require.False(t, config.Debug)
+	require.Equal(t, "root", config.PG.User)
+	require.Equal(t, "s3cr3t", config.PG.Password)
+
+	config = Config{}
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys)).WithEnvPrefix("PFX_")
+	require.NoError(t, builder.Parse(&config, "config/app"))
+	require.Equal(t, 8080, config.Port)
+	require.Equal(t, "s3cr3t", config.PG.Password)
+
+	// without prefix the env vars are not read
+	err := swap.ParseWithOptions(&Config{}, swap.ParseOptions{FileSystem: fsys}, "config/app")
+	require.EqualError(t, err, "PG.Password: required")
+}
+
+func TestParseEnvExpansion(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Host    string
+		Port    int
+		Debug   bool
+		URL     string
+		Name    string
+		Empty   string
+		Literal string
+		Tags    []string
+	}
+
+	require.NoError(t, os.Setenv("EXP_HOST", "db.example.com"))
+	defer os.Unsetenv("EXP_HOST")
+	require.NoError(t, os.Setenv("EXP_NAME", "123"))
+	defer os.Unsetenv("EXP_NAME")
+	require.NoError(t, os.Setenv("EXP_EMPTY", ""))
+	defer os.Unsetenv("EXP_EMPTY")
+
+	files := map[string]string{
+		"expand.yaml": "host: ${EXP_HOST}\nport: ${EXP_PORT:-5432}\ndebug: ${EXP_DEBUG:-true}\n" +
+			"url: http://${EXP_HOST}:${EXP_PORT:-5432}/api\nname: ${EXP_NAME}\nempty: ${EXP_EMPTY-unset}\n" +
+			"literal: $${EXP_HOST}\ntags: [\"${EXP_HOST}\"]",
+		"expand.json": `{"Host": "${EXP_HOST}", "Port": "${EXP_PORT:-5432}", "Debug": "${EXP_DEBUG:-true}", ` +
+			`"URL": "http://${EXP_HOST}:${EXP_PORT:-5432}/api", "Name": "${EXP_NAME}", "Empty": "${EXP_EMPTY-unset}", ` +
+			`"Literal": "$${EXP_HOST}", "Tags": ["${EXP_HOST}"]}`,
+		"expand.toml": "Host = \"${EXP_HOST}\"\nPort = \"${EXP_PORT:-5432}\"\nDebug = \"${EXP_DEBUG:-true}\"\n" +
+			"URL = \"http://${EXP_HOST}:${EXP_PORT:-5432}/api\"\nName = \"${EXP_NAME}\"\nEmpty = \"${EXP_EMPTY-unset}\"\n" +
+			"Literal = \"$${EXP_HOST}\"\nTags = [\"${EXP_HOST}\"]",
+	}
+
+	for file, content := range files {
+		writeFiles(file, []byte(content), t)
+
+		var config Config
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, file)), file)
+		require.Equal(t, Config{
+			Host:    "db.example.com",
+			Port:    5432,
+			Debug:   true,
+			URL:     "http://db.example.com:5432/api",
+			Name:    "123",
+			Empty:   "",
+			Literal: "${EXP_HOST}",
+			Tags:    []string{"db.example.com"},
+		}, config, file)
+	}
+}
+
+func TestSFTEnvDerived(t *testing.T) {
+	writeFiles("derived.yaml", []byte("pg:\n  password: file\nservers: [{host: file}]"), t)
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		PG struct {
+			Password string `swapcp:"env"`
+			User     string `swapcp:"env,default=postgres"`
+		}
+		Servers []struct {
+			Host string `swapcp:"env"`
+		}
+	}
+
+	for key, value := range map[string]string{"PG_PASSWORD": "env", "SERVERS_0_HOST": "env"} {
+		require.NoError(t, os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "derived.yaml")))
+	require.Equal(t, "env", config.PG.Password)
+	require.Equal(t, "postgres", config.PG.User)
+	require.Equal(t, "env", config.Servers[0].Host)
+}
+
+func TestParseDurations(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Timeout  time.Duration `swapcp:"default=30s"`
+		Interval time.Duration `swapcp:"env=DUR_INTERVAL"`
+		TTL      time.Duration
+		Backoff  *time.Duration
+		Steps    []time.Duration
+		Limits   map[string]time.Duration
+		Retries  int
+	}
+
+	require.NoError(t, os.Setenv("DUR_INTERVAL", "1h30m"))
+	defer os.Unsetenv("DUR_INTERVAL")
+
+	files := map[string]string{
+		"durations.yaml": "ttl: 1h30m\nbackoff: 2s\nsteps: [1s, 2m]\nlimits: {read: 3s}\nretries: 3",
+		"durations.json": `{"TTL": "1h30m", "Backoff": "2s", "Steps": ["1s", 120000000000], "Limits": {"read": "3s"}, "Retries": 3}`,
+		"durations.toml": "TTL = \"1h30m\"\nBackoff = \"2s\"\nSteps = [\"1s\", \"2m\"]\nRetries = 3\n[Limits]\nread = \"3s\"",
+	}
+
+	backoff := 2 * time.Second
+	for file, content := range files {
+		writeFiles(file, []byte(content), t)
+
+		var config Config
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, file)), file)
+		require.Equal(t, Config{
+			Timeout:  30 * time.Second,
+			Interval: 90 * time.Minute,
+			TTL:      90 * time.Minute,
+			Backoff:  &backoff,
+			Steps:    []time.Duration{time.Second, 2 * time.Minute},
+			Limits:   map[string]time.Duration{"read": 3 * time.Second},
+			Retries:  3,
+		}, config, file)
+	}
+
+	writeFiles("durations.json", []byte(`{"TTL": "forever"}`), t)
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "durations.json"))
+	require.EqualError(t, err, filepath.Join(configPath, "durations.json")+": TTL: invalid duration 'forever'")
+}
+
+func TestParseTimeLayout(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Release  time.Time   `swapcp:"layout=2006-01-02"`
+		Deadline time.Time   `swapcp:"layout=02/01/2006 15:04,default=31/12/2030 23:59"`
+		Created  time.Time   `swapcp:"env=TIME_CREATED"`
+		Holidays []time.Time `swapcp:"layout=2006-01-02"`
+		Expiry   *time.Time  `swapcp:"layout=2006-01-02,env=TIME_EXPIRY"`
+		Updated  time.Time
+	}
+
+	require.NoError(t, os.Setenv("TIME_CREATED", "2020-05-06T07:08:09Z"))
+	defer os.Unsetenv("TIME_CREATED")
+	require.NoError(t, os.Setenv("TIME_EXPIRY", "2031-01-01"))
+	defer os.Unsetenv("TIME_EXPIRY")
+
+	files := map[string]string{
+		"times.yaml": "release: 2024-01-02\nholidays: [\"2024-12-25\", 2024-12-26]\nupdated: 2021-02-03T04:05:06Z",
+		"times.json": `{"Release": "2024-01-02", "Holidays": ["2024-12-25", "2024-12-26"], "Updated": "2021-02-03T04:05:06Z"}`,
+		"times.toml": "Release = \"2024-01-02\"\nHolidays = [\"2024-12-25\", \"2024-12-26\"]\nUpdated = 2021-02-03T04:05:06Z",
+	}
+
+	date := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	expiry := date(2031, 1, 1, 0, 0, 0)
+
+	for file, content := range files {
+		writeFiles(file, []byte(content), t)
+
+		var config Config
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, file)), file)
+		require.True(t, date(2024, 1, 2, 0, 0, 0).Equal(config.Release), file)
+		require.True(t, date(2030, 12, 31, 23, 59, 0).Equal(config.Deadline), file)
+		require.True(t, date(2020, 5, 6, 7, 8, 9).Equal(config.Created), file)
+		require.Len(t, config.Holidays, 2, file)
+		require.True(t, date(2024, 12, 25, 0, 0, 0).Equal(config.Holidays[0]), file)
+		require.True(t, date(2024, 12, 26, 0, 0, 0).Equal(config.Holidays[1]), file)
+		require.True(t, expiry.Equal(*config.Expiry), file)
+		require.True(t, date(2021, 2, 3, 4, 5, 6).Equal(config.Updated), file)
+	}
+
+	writeFiles("times.json", []byte(`{"Release": "02/01/2024"}`), t)
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "times.json"))
+	require.EqualError(t, err, filepath.Join(configPath, "times.json")+": Release: invalid time '02/01/2024', must be like '2006-01-02'")
+
+	writeFiles("times.json", []byte(`{}`), t)
+	require.NoError(t, os.Setenv("TIME_CREATED", "yesterday"))
+	err = swap.Parse(&Config{}, filepath.Join(configPath, "times.json"))
+	require.EqualError(t, err, "Created: invalid time 'yesterday', must be like '2006-01-02T15:04:05Z07:00'")
+}
+
+func TestParseByteSizes(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Buffer   int64  `swapcp:"bytes,default=512KiB"`
+		Limit    uint64 `swapcp:"bytes,env=BYTES_LIMIT"`
+		Upload   int    `swapcp:"bytes,max=10MB"`
+		Chunks   []int  `swapcp:"bytes"`
+		Plain    int64  `swapcp:"bytes"`
+		Fraction int64  `swapcp:"bytes"`
+	}
+
+	require.NoError(t, os.Setenv("BYTES_LIMIT", "1 GiB"))
+	defer os.Unsetenv("BYTES_LIMIT")
+
+	files := map[string]string{
+		"bytes.yaml": "upload: 10MB\nchunks: [1kb, 2KiB]\nplain: 100\nfraction: 1.5KB",
+		"bytes.json": `{"Upload": "10MB", "Chunks": ["1kb", "2KiB"], "Plain": 100, "Fraction": "1.5KB"}`,
+		"bytes.toml": "Upload = \"10MB\"\nChunks = [\"1kb\", \"2KiB\"]\nPlain = 100\nFraction = \"1.5KB\"",
+	}
+
+	for file, content := range files {
+		writeFiles(file, []byte(content), t)
+
+		var config Config
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, file)), file)
+		require.Equal(t, Config{
+			Buffer:   512 * 1024,
+			Limit:    1 << 30,
+			Upload:   10 * 1000 * 1000,
+			Chunks:   []int{1000, 2048},
+			Plain:    100,
+			Fraction: 1500,
+		}, config, file)
+	}
+
+	writeFiles("bytes.yaml", []byte("upload: 11MB"), t)
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "bytes.yaml"))
+	require.EqualError(t, err, "Upload: value must be less than or equal to 10MB, got 11000000")
+
+	writeFiles("bytes.yaml", []byte("upload: 10 apples"), t)
+	err = swap.Parse(&Config{}, filepath.Join(configPath, "bytes.yaml"))
+	require.EqualError(t, err, filepath.Join(configPath, "bytes.yaml")+": upload: invalid byte size '10 apples', must be like '10MB' or '512KiB'")
+}
+
+func TestParseStdTypes(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		IP        net.IP
+		URL       url.URL
+		Endpoint  *url.URL `swapcp:"env=STD_ENDPOINT"`
+		Mirrors   []*url.URL
+		Services  map[string]url.URL
+		Pattern   *regexp.Regexp `swapcp:"default=^[a-z]+$"`
+		Allowed   []regexp.Regexp
+		Gateway   net.IP `swapcp:"default=10.0.0.1"`
+		Fallback  *url.URL
+		Untouched string
+	}
+
+	require.NoError(t, os.Setenv("STD_ENDPOINT", "https://api.example.com/v1"))
+	defer os.Unsetenv("STD_ENDPOINT")
+
+	files := map[string]string{
+		"std.yaml": "ip: 192.168.1.10\nurl: postgres://user:secret@db:5432/app?sslmode=disable\n" +
+			"mirrors: [\"https://a.example.com\", \"https://b.example.com\"]\nservices: {auth: \"http://auth:8080\"}\n" +
+			"allowed: [\"^/api\", \"^/health$\"]\nuntouched: ok",
+		"std.json": `{"IP": "192.168.1.10", "URL": "postgres://user:secret@db:5432/app?sslmode=disable", ` +
+			`"Mirrors": ["https://a.example.com", "https://b.example.com"], "Services": {"auth": "http://auth:8080"}, ` +
+			`"Allowed": ["^/api", "^/health$"], "Untouched": "ok"}`,
+		"std.toml": "IP = \"192.168.1.10\"\nURL = \"postgres://user:secret@db:5432/app?sslmode=disable\"\n" +
+			"Mirrors = [\"https://a.example.com\", \"https://b.example.com\"]\nAllowed = [\"^/api\", \"^/health$\"]\n" +
+			"Untouched = \"ok\"\n[Services]\nauth = \"http://auth:8080\"",
+	}
+
+	for file, content := range files {
+		writeFiles(file, []byte(content), t)
+
+		var config Config
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, file)), file)
+		require.Equal(t, "192.168.1.10", config.IP.String(), file)
+		require.Equal(t, "postgres://user:secret@db:5432/app?sslmode=disable", config.URL.String(), file)
+		password, _ := config.URL.User.Password()
+		require.Equal(t, "secret", password, file)
+		require.Equal(t, "https://api.example.com/v1", config.Endpoint.String(), file)
+		require.Len(t, config.Mirrors, 2, file)
+		require.Equal(t, "b.example.com", config.Mirrors[1].Host, file)
+		require.Equal(t, "auth:8080", config.Services["auth"].Host, file)
+		require.True(t, config.Pattern.MatchString("abc"), file)
+		require.Len(t, config.Allowed, 2, file)
+		require.True(t, config.Allowed[1].MatchString("/health"), file)
+		require.Equal(t, "10.0.0.1", config.Gateway.String(), file)
+		require.Nil(t, config.Fallback, file)
+		require.Equal(t, "ok", config.Untouched, file)
+	}
+
+	writeFiles("std.yaml", []byte("allowed: [\"(\"]"), t)
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "std.yaml"))
+	require.EqualError(t, err, filepath.Join(configPath, "std.yaml")+": allowed.0: error parsing regexp: missing closing ): `(`")
+
+	writeFiles("std.yaml", []byte("{}"), t)
+	require.NoError(t, os.Setenv("STD_ENDPOINT", "::invalid"))
+	err = swap.Parse(&Config{}, filepath.Join(configPath, "std.yaml"))
+	require.EqualError(t, err, "Endpoint: parse \"::invalid\": missing protocol scheme")
+
+	type IPConfig struct {
+		IP net.IP `swapcp:"default=not-an-ip"`
+	}
+	err = swap.Parse(&IPConfig{}, filepath.Join(configPath, "std.yaml"))
+	require.EqualError(t, err, "IP: invalid IP address 'not-an-ip'")
+}
+
+// LogLevel is a TextUnmarshaler enum.
+type LogLevel int
+
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	for i, name := range []string{"debug", "info", "warn"} {
+		if strings.EqualFold(string(text), name) {
+			*l = LogLevel(i + 1)
+			return nil
+		}
+	}
+	return errors.New("unknown level: " + string(text))
+}
+
+// AccountID is a TextUnmarshaler ID, text is kept verbatim.
+type AccountID struct {
+	raw string
+}
+
+func (id *AccountID) UnmarshalText(text []byte) error {
+	id.raw = string(text)
+	return nil
+}
+
+func TestSFTTextUnmarshaler(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Level    LogLevel    `swapcp:"default=WARN"`
+		Verbose  *LogLevel   `swapcp:"env=TU_VERBOSE"`
+		Account  AccountID   `swapcp:"env=TU_ACCOUNT"`
+		Fallback *AccountID  `swapcp:"default=[acme]: #1"`
+		Password swap.Secret `swapcp:"env=TU_PASSWORD"`
+	}
+
+	require.NoError(t, os.Setenv("TU_VERBOSE", "debug"))
+	defer os.Unsetenv("TU_VERBOSE")
+	require.NoError(t, os.Setenv("TU_ACCOUNT", "007"))
+	defer os.Unsetenv("TU_ACCOUNT")
+	require.NoError(t, os.Setenv("TU_PASSWORD", "p4ss: #word"))
+	defer os.Unsetenv("TU_PASSWORD")
+
+	writeFiles("text.yaml", []byte("{}"), t)
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "text.yaml")))
+	require.Equal(t, LogLevel(3), config.Level)
+	require.Equal(t, LogLevel(1), *config.Verbose)
+	require.Equal(t, "007", config.Account.raw)
+	require.Equal(t, "[acme]: #1", config.Fallback.raw)
+	require.Equal(t, "p4ss: #word", config.Password.Reveal())
+
+	require.NoError(t, os.Setenv("TU_VERBOSE", "trace"))
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "text.yaml"))
+	require.EqualError(t, err, "Verbose: unknown level: trace")
+}
+
+// Upstream is a json.Unmarshaler, from an object or a `host:port` string.
+type Upstream struct {
+	Host string
+	Port int
+}
+
+func (u *Upstream) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		type plain Upstream
+		return json.Unmarshal(data, (*plain)(u))
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return err
+	}
+	u.Host = host
+	_, err = fmt.Sscanf(port, "%d", &u.Port)
+	return err
+}
+
+func TestSFTJSONUnmarshaler(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Primary  Upstream  `swapcp:"env=JU_PRIMARY"`
+		Fallback *Upstream `swapcp:"default=localhost:8080"`
+	}
+
+	require.NoError(t, os.Setenv("JU_PRIMARY", `{"Host": "example.com", "Port": 443}`))
+	defer os.Unsetenv("JU_PRIMARY")
+
+	writeFiles("json_unmarshaler.yaml", []byte("{}"), t)
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "json_unmarshaler.yaml")))
+	require.Equal(t, Upstream{Host: "example.com", Port: 443}, config.Primary)
+	require.Equal(t, &Upstream{Host: "localhost", Port: 8080}, config.Fallback)
+
+	require.NoError(t, os.Setenv("JU_PRIMARY", "example.com"))
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "json_unmarshaler.yaml"))
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "Primary: "), err.Error())
+}
+
+// Money is a third-party like type, without any unmarshaler.
+type Money struct {
+	cents int64
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	swap.RegisterDecoder(reflect.TypeOf(Money{}), func(value string) (interface{}, error) {
+		var units, cents int64
+		if _, err := fmt.Sscanf(value, "%d.%d", &units, &cents); err != nil {
+			return nil, fmt.Errorf("invalid amount '%s'", value)
+		}
+		return Money{cents: units*100 + cents}, nil
+	})
+	defer swap.RegisterDecoder(reflect.TypeOf(Money{}), nil)
+
+	type Config struct {
+		Price    Money  `swapcp:"default=9.99"`
+		Discount *Money `swapcp:"env=DEC_DISCOUNT"`
+		Tiers    []Money
+		Fees     map[string]Money
+		Name     string
+	}
+
+	require.NoError(t, os.Setenv("DEC_DISCOUNT", "1.50"))
+	defer os.Unsetenv("DEC_DISCOUNT")
+
+	files := map[string]string{
+		"decoder.yaml": "tiers: [\"10.00\", \"20.00\"]\nfees: {shipping: \"4.99\"}\nname: plan",
+		"decoder.json": `{"Tiers": ["10.00", "20.00"], "Fees": {"shipping": "4.99"}, "Name": "plan"}`,
+		"decoder.toml": "Tiers = [\"10.00\", \"20.00\"]\nName = \"plan\"\n[Fees]\nshipping = \"4.99\"",
+	}
+
+	for file, content := range files {
+		writeFiles(file, []byte(content), t)
+
+		var config Config
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, file)), file)
+		require.Equal(t, Config{
+			Price:    Money{cents: 999},
+			Discount: &Money{cents: 150},
+			Tiers:    []Money{{cents: 1000}, {cents: 2000}},
+			Fees:     map[string]Money{"shipping": {cents: 499}},
+			Name:     "plan",
+		}, config, file)
+	}
+
+	writeFiles("decoder.yaml", []byte("tiers: [free]"), t)
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "decoder.yaml"))
+	require.EqualError(t, err, filepath.Join(configPath, "decoder.yaml")+": tiers.0: invalid amount 'free'")
+}
+
+func TestSFTFile(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	dir := t.TempDir()
+	secrets := map[string]string{
+		"db_password": "s3cr3t: #1\n",
+		"db_port":     "5433\n",
+		"cert.pem":    "-----BEGIN-----\n",
+	}
+	for name, content := range secrets {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	type Config struct {
+		Password swap.Secret `swapcp:"file=${FILE_DIR}/db_password"`
+		Port     int         `swapcp:"default=5432,file=${FILE_DIR}/db_port"`
+		Cert     []byte      `swapcp:"file=${FILE_CERT:-/missing/cert.pem}"`
+		User     string      `swapcp:"file=/missing/db_user,default=postgres"`
+		Host     string      `swapcp:"file=${FILE_HOST}"`
+	}
+
+	require.NoError(t, os.Setenv("FILE_DIR", dir))
+	defer os.Unsetenv("FILE_DIR")
+	require.NoError(t, os.Setenv("FILE_CERT", filepath.Join(dir, "cert.pem")))
+	defer os.Unsetenv("FILE_CERT")
+
+	writeFiles("file.yaml", []byte("port: 80\nhost: localhost"), t)
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "file.yaml")))
+	require.Equal(t, "s3cr3t: #1", config.Password.Reveal())
+	require.Equal(t, 5433, config.Port)
+	require.Equal(t, []byte("-----BEGIN-----\n"), config.Cert)
+	require.Equal(t, "postgres", config.User)
+	require.Equal(t, "localhost", config.Host)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db_port"), []byte("not a number"), 0600))
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "file.yaml"))
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "Port: "), err.Error())
+}
+
+func TestSFTFromFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("t0k3n\n"), 0600))
+
+	type Config struct {
+		Token string      `swapcp:"fromfile=${FROMFILE_DIR}/token"`
+		CA    []byte      `swapcp:"fromfile=certs/ca.pem"`
+		Key   swap.Secret `swapcp:"fromfile=certs/missing.pem,default=none"`
+		Port  int         `swapcp:"fromfile=port"`
+	}
+
+	require.NoError(t, os.Setenv("FROMFILE_DIR", dir))
+	defer os.Unsetenv("FROMFILE_DIR")
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml":     []byte("port: 80"),
+		"config/port":         []byte("8080\n"),
+		"config/certs/ca.pem": []byte("-----BEGIN-----\n-----END-----\n"),
+		"certs/ca.pem":        []byte("-----ROOT-----\n"),
+	})
+
+	// relative paths are resolved in the builder config path
+	var config Config
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys))
+	require.NoError(t, builder.Parse(&config, "config/app"))
+	require.Equal(t, "t0k3n", config.Token)
+	require.Equal(t, []byte("-----BEGIN-----\n-----END-----\n"), config.CA)
+	require.Equal(t, "none", config.Key.Reveal())
+	require.Equal(t, 8080, config.Port)
+
+	// or in the FileSystem root
+	config = Config{}
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/app"))
+	require.Equal(t, []byte("-----ROOT-----\n"), config.CA)
+	require.Equal(t, 80, config.Port)
+}
+
+func TestSFTBase64(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Cert     []byte      `swapcp:"env=B64_CERT,base64"`
+		Key      string      `swapcp:"env=B64_KEY,base64"`
+		Password swap.Secret `swapcp:"env=B64_PASSWORD,base64"`
+		Port     int         `swapcp:"env=B64_PORT,base64"`
+		Salt     []byte      `swapcp:"base64,default=c2FsdA"`
+	}
+
+	pem := "-----BEGIN CERTIFICATE-----\nMIIB: #1\n-----END CERTIFICATE-----\n"
+	encoded := base64.StdEncoding.EncodeToString([]byte(pem))
+	env := map[string]string{
+		"B64_CERT":     encoded[:20] + "\n" + encoded[20:],
+		"B64_KEY":      base64.URLEncoding.EncodeToString([]byte(pem)),
+		"B64_PASSWORD": base64.RawStdEncoding.EncodeToString([]byte("p4ss: #word")),
+		"B64_PORT":     base64.StdEncoding.EncodeToString([]byte("5432")),
+	}
+	for key, value := range env {
+		require.NoError(t, os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+
+	writeFiles("base64.yaml", []byte("{}"), t)
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "base64.yaml")))
+	require.Equal(t, []byte(pem), config.Cert)
+	require.Equal(t, pem, config.Key)
+	require.Equal(t, "p4ss: #word", config.Password.Reveal())
+	require.Equal(t, 5432, config.Port)
+	require.Equal(t, []byte("salt"), config.Salt)
+
+	require.NoError(t, os.Setenv("B64_CERT", "not base64!"))
+	err := swap.Parse(&Config{}, filepath.Join(configPath, "base64.yaml"))
+	require.EqualError(t, err, "Cert: invalid base64 value")
+}
+
+func TestSFTJSON(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Route struct {
+		Path    string `json:"path"`
+		Backend string `json:"backend"`
+	}
+
+	type Config struct {
+		Routes  []Route           `swapcp:"env=JSON_ROUTES,json"`
+		Labels  map[string]string `swapcp:"env=JSON_LABELS,json"`
+		Primary *Route            `swapcp:"env=JSON_PRIMARY,json,base64"`
+		Weights []int             `swapcp:"json,default=[1]"`
+	}
+
+	env := map[string]string{
+		"JSON_ROUTES":  `[{"path": "/api", "backend": "api:8080"}, {"path": "/", "backend": "web:80"}]`,
+		"JSON_LABELS":  `{"team": "core", "tier": "1"}`,
+		"JSON_PRIMARY": base64.StdEncoding.EncodeToString([]byte(`{"path": "/", "backend": "web:80"}`)),
+	}
+	for key, value := range env {
+		require.NoError(t, os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+
+	writeFiles("json_env.yaml", []byte("labels: {env: prod}"), t)
+
+	var config Config
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "json_env.yaml")))
+	require.Equal(t, Config{
+		Routes:  []Route{{Path: "/api", Backend: "api:8080"}, {Path: "/", Backend: "web:80"}},
+		Labels:  map[string]string{"env": "prod", "team": "core", "tier": "1"},
+		Primary: &Route{Path: "/", Backend: "web:80"},
+		Weights: []int{1},
+	}, config)
+
+	errs := map[string]string{
+		`[{"path": "/api", "host": "api"}]`: `Routes: json: unknown field "host"`,
+		`[{"path": "/api"}] []`:             "Routes: invalid JSON value, unexpected data after the top-level value",
+		`path: /api`:                        "Routes: invalid character 'p' looking for beginning of value",
+	}
+	for value, expected := range errs {
+		require.NoError(t, os.Setenv("JSON_ROUTES", value))
+		err := swap.Parse(&Config{}, filepath.Join(configPath, "json_env.yaml"))
+		require.EqualError(t, err, expected, value)
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+type ConstrainedConfig struct {
+	Port    int           `swapcp:"default=8080,min=1,max=65535"`
+	Name    string        `swapcp:"regexp=^[a-z]+$,min=3"`
+	Level   string        `swapcp:"default=info,oneof=debug|info|warn"`
+	Timeout time.Duration `swapcp:"default=5s,min=1s,max=1m"`
+	Ratio   *float64      `swapcp:"min=0,max=1"`
+	Hosts   []string      `swapcp:"max=2"`
+	Region  string        `swapcp:"default=eu-1,match=^[a-z]+-[0-9]$"`
+}
+
+func TestParseConstraints(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	cases := []struct {
+		yaml string
+		path string
+		tag  string
+		err  string
+	}{
+		{"name: api", "", "", ""},
+		{"name: api\nport: -1", "Port", "min", "Port: value must be greater than or equal to 1, got -1"},
+		{"name: api\nport: 70000", "Port", "max", "Port: value must be less than or equal to 65535, got 70000"},
+		{"name: API", "Name", "regexp", "Name: value 'API' does not match '^[a-z]+$'"},
+		{"name: ab", "Name", "min", "Name: length must be greater than or equal to 3, got 2"},
+		{"name: api\nlevel: trace", "Level", "oneof", "Level: value 'trace' must be one of: debug, info, warn"},
+		{"name: api\ntimeout: 2m", "Timeout", "max", "Timeout: value must be less than or equal to 1m, got 2m0s"},
+		{"name: api\nratio: 1.5", "Ratio", "max", "Ratio: value must be less than or equal to 1, got 1.5"},
+		{"name: api\nhosts: [a, b, c]", "Hosts", "max", "Hosts: length must be less than or equal to 2, got 3"},
+		{"name: api\nregion: EU", "Region", "match", "Region: value 'EU' does not match '^[a-z]+-[0-9]$'"},
+	}
+
+	for _, c := range cases {
+		writeFiles("constrained.yaml", []byte(c.yaml), t)
+
+		var config ConstrainedConfig
+		err := swap.Parse(&config, configPath+"/constrained.yaml")
+		if len(c.err) == 0 {
+			require.NoError(t, err, c.yaml)
+			continue
+		}
+
+		require.EqualError(t, err, c.err, c.yaml)
+		var fe *swap.FieldError
+		require.True(t, errors.As(err, &fe))
+		require.Equal(t, c.path, fe.Path)
+		require.Equal(t, c.tag, fe.Tag)
+	}
+
+	data, err := swap.JSONSchema(ConstrainedConfig{})
+	require.NoError(t, err)
+	var schema struct {
+		Properties map[string]map[string]interface{}
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	require.Equal(t, float64(65535), schema.Properties["port"]["maximum"])
+	require.Equal(t, "^[a-z]+$", schema.Properties["name"]["pattern"])
+	require.Equal(t, float64(3), schema.Properties["name"]["minLength"])
+	require.Equal(t, "^[a-z]+-[0-9]$", schema.Properties["region"]["pattern"])
+	require.Equal(t, []interface{}{"debug", "info", "warn"}, schema.Properties["level"]["enum"])
+	require.NotContains(t, schema.Properties["timeout"], "minLength")
+}
+
+func TestParseConstraintsCommas(t *testing.T) {
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Code  string `swapcp:"regexp=^[a-z]{3,8}$,required"`
+		Sep   string `swapcp:"match=^[,;]$"`
+		Pairs string `swapcp:"match=^(a,b|c)\x2cd$"`
+	}
+
+	writeFiles("commas.yaml", []byte("code: abcd\nsep: ','\npairs: a,b,d"), t)
+	var config Config
+	require.NoError(t, swap.Parse(&config, configPath+"/commas.yaml"))
+
+	writeFiles("commas.yaml", []byte("code: ab\nsep: ';'\npairs: c,d"), t)
+	err := swap.Parse(&Config{}, configPath+"/commas.yaml")
+	require.EqualError(t, err, "Code: value 'ab' does not match '^[a-z]{3,8}$'")
+
+	// the following flags still apply
+	writeFiles("commas.yaml", []byte("sep: ';'"), t)
+	var fe *swap.FieldError
+	require.True(t, errors.As(swap.Parse(&Config{}, configPath+"/commas.yaml"), &fe))
+	require.Equal(t, "Code", fe.Path)
+	require.Equal(t, "required", fe.Tag)
+
+	data, err := swap.JSONSchema(Config{})
+	require.NoError(t, err)
+	var schema struct {
+		Properties map[string]map[string]interface{}
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	require.Equal(t, "^[a-z]{3,8}$", schema.Properties["code"]["pattern"])
+}
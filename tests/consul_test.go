@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsul is a minimal Consul KV HTTP API.
+type fakeConsul struct {
+	mutex sync.Mutex
+	kv    map[string]string
+	index int
+}
+
+func (c *fakeConsul) set(key, value string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.kv[key] = value
+	c.index++
+}
+
+func (c *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.Itoa(c.index))
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	query := r.URL.Query()
+
+	if _, raw := query["raw"]; raw {
+		value, found := c.kv[key]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(value))
+		return
+	}
+
+	keys := map[string]bool{}
+	for k := range c.kv {
+		if !strings.HasPrefix(k, key) {
+			continue
+		}
+		if separator := query.Get("separator"); len(separator) > 0 {
+			if i := strings.Index(k[len(key):], separator); i >= 0 {
+				k = k[:len(key)+i+1]
+			}
+		}
+		keys[k] = true
+	}
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	list := make([]string, 0, len(keys))
+	for k := range keys {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func TestFileSystemConsul(t *testing.T) {
+	consul := &fakeConsul{kv: map[string]string{
+		"myapp/config/Tool":             "teststring: generic",
+		"myapp/config/Tool.development": "teststring: development",
+		"myapp/config/Other.json":       `{"TestString": "json"}`,
+		"myapp/config/sub/Nested":       "teststring: nested",
+	}}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	fsys := swap.NewFileSystemConsul(swap.ConsulConfig{
+		Address:    server.URL,
+		Token:      "token",
+		Prefix:     "myapp/config",
+		ConfigPath: "./config",
+	})
+
+	entries, err := fsys.ReadDir("./config")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"Other.json", "Tool.development.yaml", "Tool.yaml", "sub"}, names)
+	require.True(t, entries[3].IsDir())
+
+	_, err = fsys.ReadFile("./elsewhere/Tool.yaml")
+	require.Error(t, err)
+
+	type RemoteTool struct {
+		Config ToolConfig
+	}
+
+	type Box struct {
+		Tool   RemoteTool
+		Other  RemoteTool
+		Nested RemoteTool `swap:"sub/Nested"`
+	}
+
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys), swap.WithDebug(false))
+	builder.EnvHandler.SetCurrent("development")
+	builder.RegisterType(reflect.TypeOf(RemoteTool{}), func(configFiles ...string) (interface{}, error) {
+		tool := &RemoteTool{}
+		return tool, builder.Parse(&tool.Config, configFiles...)
+	})
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "development", test.Tool.Config.TestString)
+	require.Equal(t, "json", test.Other.Config.TestString)
+	require.Equal(t, "nested", test.Nested.Config.TestString)
+
+	// watch
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changed := make(chan struct{})
+	go func() {
+		_ = fsys.Watch(ctx, func() { close(changed) })
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	consul.set("myapp/config/Tool", "teststring: updated")
+
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		t.Fatal("change not detected")
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKey struct{}
+
+// ToolCtx implements the 'ConfigurableCtx' interface.
+type ToolCtx struct {
+	Config ToolConfig
+	Trace  string
+}
+
+func (c *ToolCtx) Configure(ctx context.Context, configFiles ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Trace, _ = ctx.Value(ctxKey{}).(string)
+	return swap.Parse(&c.Config, configFiles...)
+}
+
+func TestBuildContext(t *testing.T) {
+	createYAML(ToolConfig{TestString: "ctx"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool    ToolCtx          `swap:"Tool"`
+		PTRTool *ToolCtx         `swap:"Tool"`
+		Static  ToolConfigurable `swap:"Tool"`
+	}
+
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	var test Box
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace-id")
+	require.NoError(t, builder.BuildContext(ctx, &test))
+	require.Equal(t, "ctx", test.Tool.Config.TestString)
+	require.Equal(t, "trace-id", test.Tool.Trace)
+	require.Equal(t, "trace-id", test.PTRTool.Trace)
+	require.Equal(t, "ctx", test.Static.Config.TestString)
+
+	// Build passes context.Background()
+	var background Box
+	require.NoError(t, builder.Build(&background))
+	require.Equal(t, "ctx", background.Tool.Config.TestString)
+	require.Empty(t, background.Tool.Trace)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var canceled Box
+	err := builder.BuildContext(cancelled, &canceled)
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Empty(t, canceled.Static.Config.TestString)
+
+	// waitfor stops waiting
+	type BoxWaitFor struct {
+		Tool ToolCtx `swap:"Tool,waitfor=tcp://127.0.0.1:1,timeout=1m"`
+	}
+
+	var waitFor BoxWaitFor
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancelTimeout()
+	err = builder.BuildContext(timeout, &waitFor)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// a pending probe stops waiting too
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	box := boxWaitingFor("Tool,waitfor=" + hanging.URL + ",timeout=1m")
+	timeout, cancelTimeout = context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancelTimeout()
+	start := time.Now()
+	err = builder.BuildContext(timeout, box)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// ToolErrorWithSubTool fails once its sub-tool is built.
+type ToolErrorWithSubTool struct {
+	Conn ToolShutdowner `swap:"Tool"`
+}
+
+// Configure is the 'Configurable' interface implementation.
+func (t *ToolErrorWithSubTool) Configure(configFiles ...string) error {
+	return errors.New("fake error for test")
+}
+
+func TestBoxDegrade(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yaml", t)
+	createYAML(ToolConfig{TestString: "1"}, "Mailer.yaml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool   ToolConfigurable
+		Mailer *ToolError `swap:"degrade"`
+		Nested struct {
+			Pusher ToolError `swap:"Mailer,degrade"`
+		}
+		Queue ToolErrorWithSubTool `swap:"Mailer,degrade"`
+	}
+
+	shutdownOrder = nil
+	var test Box
+	builder := swap.NewBuilder(configPath)
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "0", test.Tool.Config.TestString)
+	require.Nil(t, test.Mailer)
+	require.Zero(t, test.Nested.Pusher)
+	require.Zero(t, test.Queue)
+
+	// the sub-tools of the degraded fields are shut down once, right away
+	require.Equal(t, []string{"0"}, shutdownOrder)
+	require.NoError(t, builder.Shutdown(context.Background()))
+	require.Equal(t, []string{"0"}, shutdownOrder)
+
+	unavailable := builder.Unavailable()
+	require.Len(t, unavailable, 3)
+	require.EqualError(t, unavailable["Mailer"], "Mailer: fake error for test")
+	require.EqualError(t, unavailable["Nested.Pusher"], "Nested.Pusher: fake error for test")
+	require.False(t, builder.Available("Nested.Pusher"))
+	require.True(t, builder.Available("Tool"))
+
+	type BoxRequired struct {
+		Mailer ToolError
+	}
+
+	require.Error(t, builder.Build(&BoxRequired{}))
+	require.Empty(t, builder.Unavailable())
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffEnvs(t *testing.T) {
+	writeFiles("diff.yaml", []byte("host: localhost\nport: 5432\nuser: admin\npassword: dev-pass\ntoken: t0k3n\nhosts: [a]\n"), t)
+	writeFiles("diff.staging.yaml", []byte("host: staging.example.com\n"), t)
+	writeFiles("diff.production.yaml", []byte("host: example.com\npassword: prod-pass\nhosts: [a, b]\ndebug: true\n"), t)
+	defer removeConfigFiles(t)
+
+	type Config struct {
+		Host     string
+		Port     int
+		User     string
+		Password string
+		Token    swap.Secret
+		Hosts    []string
+		Debug    *bool
+	}
+
+	diffs, err := swap.DiffEnvs(&Config{}, swap.DefaultEnvs.Staging, swap.DefaultEnvs.Production, filepath.Join(configPath, "diff"))
+	require.NoError(t, err)
+	require.Equal(t, []swap.ConfigDiff{
+		{Key: "debug", A: nil, B: true},
+		{Key: "host", A: "staging.example.com", B: "example.com"},
+		{Key: "hosts", A: []interface{}{"a"}, B: []interface{}{"a", "b"}},
+		{Key: "password", A: "*****", B: "*****"},
+	}, diffs)
+	require.Equal(t, "host: 'staging.example.com' != 'example.com'", diffs[1].String())
+
+	diffs, err = swap.DiffEnvs(&Config{}, swap.DefaultEnvs.Production, swap.DefaultEnvs.Production, filepath.Join(configPath, "diff"))
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+
+	_, err = swap.DiffEnvs(Config{}, swap.DefaultEnvs.Staging, swap.DefaultEnvs.Production, filepath.Join(configPath, "diff"))
+	require.Error(t, err)
+	_, err = swap.DiffEnvs(nil, swap.DefaultEnvs.Staging, swap.DefaultEnvs.Production, filepath.Join(configPath, "diff"))
+	require.Error(t, err)
+
+	// nil environments, no environment specific files
+	diffs, err = swap.DiffEnvs(&Config{}, nil, swap.DefaultEnvs.Production, filepath.Join(configPath, "diff"))
+	require.NoError(t, err)
+	require.Equal(t, swap.ConfigDiff{Key: "host", A: "localhost", B: "example.com"}, diffs[1])
+	_, err = swap.DiffEnvs(&Config{}, nil, swap.DefaultEnvs.Production, filepath.Join(configPath, "missing"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "A: ")
+}
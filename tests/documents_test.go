@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYAMLDocuments(t *testing.T) {
+	type ConfigMap struct {
+		Data map[string]string
+	}
+
+	type Config struct {
+		Host     string
+		Port     int `swapcp:"default=80"`
+		Tags     []string
+		Settings ConfigMap `swapcp:"document=ConfigMap/settings"`
+		Secrets  []struct {
+			Metadata struct {
+				Name string
+			}
+		} `swapcp:"document=Secret"`
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml": []byte(`---
+host: localhost
+tags: [a]
+---
+# comments only
+---
+host: example.com
+url: http://{{.Host}}
+---
+kind: ConfigMap
+metadata: {name: settings}
+data: {mode: fast}
+---
+kind: ConfigMap
+metadata: {name: other}
+data: {mode: slow}
+---
+kind: Secret
+metadata: {name: db}
+`),
+	})
+
+	var config Config
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/app"))
+	require.Equal(t, "example.com", config.Host)
+	require.Equal(t, 80, config.Port)
+	require.Equal(t, []string{"a"}, config.Tags)
+	require.Equal(t, map[string]string{"mode": "fast"}, config.Settings.Data)
+	require.Len(t, config.Secrets, 1)
+	require.Equal(t, "db", config.Secrets[0].Metadata.Name)
+
+	// a single document is a plain file
+	fsys = swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml": []byte("---\nhost: localhost\n"),
+	})
+	config = Config{}
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/app"))
+	require.Equal(t, "localhost", config.Host)
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderDotEnv(t *testing.T) {
+	writeFiles(".env", []byte(`# local development
+export DOTENV_HOST=localhost
+DOTENV_PORT=5432 # inline comment
+DOTENV_USER='admin user'
+DOTENV_PASSWORD="p\"ss"
+DOTENV_OVERRIDDEN=dotenv
+`), t)
+	writeFiles(".env.local", []byte("DOTENV_PORT=6543"), t)
+	writeFiles("dotenv.yaml", []byte("host: ${DOTENV_HOST}\nport: ${DOTENV_PORT}"), t)
+	defer removeConfigFiles(t)
+
+	require.NoError(t, os.Setenv("DOTENV_OVERRIDDEN", "process"))
+	defer os.Unsetenv("DOTENV_OVERRIDDEN")
+
+	type Config struct {
+		Host       string
+		Port       int
+		User       string `swapcp:"env=DOTENV_USER"`
+		Password   string `swapcp:"env=DOTENV_PASSWORD"`
+		Overridden string `swapcp:"env=DOTENV_OVERRIDDEN"`
+	}
+
+	builder := swap.NewBuilder(configPath).WithDotEnv(
+		filepath.Join(configPath, ".env"),
+		filepath.Join(configPath, ".env.local"),
+		filepath.Join(configPath, ".env.missing"))
+
+	var config Config
+	require.NoError(t, builder.Parse(&config, filepath.Join(configPath, "dotenv.yaml")))
+	require.Equal(t, Config{
+		Host:       "localhost",
+		Port:       6543,
+		User:       "admin user",
+		Password:   `p"ss`,
+		Overridden: "process",
+	}, config)
+
+	// not exported to the process
+	_, exported := os.LookupEnv("DOTENV_HOST")
+	require.False(t, exported)
+
+	writeFiles(".env.local", []byte("not a variable"), t)
+	require.EqualError(t, builder.Parse(&config, filepath.Join(configPath, "dotenv.yaml")),
+		filepath.Join(configPath, ".env.local")+": line 1: invalid variable, must be like `KEY=value`")
+	require.Error(t, builder.Build(&struct{}{}))
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	writeFiles(".env", []byte("LOADENV_HOST=localhost\nLOADENV_PORT=5432\nLOADENV_OVERRIDDEN=dotenv\n"), t)
+	writeFiles(".env.local", []byte("LOADENV_PORT=6543"), t)
+	defer removeConfigFiles(t)
+
+	require.NoError(t, os.Setenv("LOADENV_OVERRIDDEN", "process"))
+	defer os.Unsetenv("LOADENV_OVERRIDDEN")
+	defer os.Unsetenv("LOADENV_HOST")
+	defer os.Unsetenv("LOADENV_PORT")
+
+	require.NoError(t, swap.LoadDotEnv(
+		filepath.Join(configPath, ".env"),
+		filepath.Join(configPath, ".env.local"),
+		filepath.Join(configPath, ".env.missing")))
+	require.Equal(t, "localhost", os.Getenv("LOADENV_HOST"))
+	require.Equal(t, "6543", os.Getenv("LOADENV_PORT"))
+	require.Equal(t, "process", os.Getenv("LOADENV_OVERRIDDEN"))
+
+	writeFiles(".env.local", []byte("not a variable"), t)
+	require.EqualError(t, swap.LoadDotEnv(filepath.Join(configPath, ".env.local")),
+		filepath.Join(configPath, ".env.local")+": line 1: invalid variable, must be like `KEY=value`")
+}
+
+func TestBuilderLoadDotEnv(t *testing.T) {
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		".env":            []byte("BUILD_ENV=staging\nLOADENV_USER=dev\nLOADENV_MODE=base\n"),
+		".env.staging":    []byte("LOADENV_USER=staging-user\n"),
+		".env.production": []byte("LOADENV_USER=production-user\n"),
+		"config/app.yaml": []byte("mode: ${LOADENV_MODE}"),
+	})
+
+	for _, key := range []string{"BUILD_ENV", "LOADENV_USER", "LOADENV_MODE"} {
+		defer os.Unsetenv(key)
+	}
+
+	type Config struct {
+		Mode string
+		User string `swapcp:"env=LOADENV_USER"`
+	}
+
+	// the environment is detected from the loaded BUILD_ENV
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys)).LoadDotEnv(".env")
+	var config Config
+	require.NoError(t, builder.Parse(&config, "config/app"))
+	require.Equal(t, Config{Mode: "base", User: "staging-user"}, config)
+	require.Equal(t, "staging", builder.EnvHandler.Current().Tag())
+	require.Equal(t, "staging-user", os.Getenv("LOADENV_USER"))
+
+	// the edited files are loaded again, the variables changed by the process are kept
+	require.NoError(t, os.Setenv("LOADENV_USER", "process-user"))
+	fsys = swap.NewFileSystemMemory(map[string][]byte{
+		".env":            []byte("BUILD_ENV=staging\nLOADENV_USER=dev\nLOADENV_MODE=edited\n"),
+		"config/app.yaml": []byte("mode: ${LOADENV_MODE}"),
+	})
+	builder = swap.NewBuilder("./config", swap.WithFileSystem(fsys)).LoadDotEnv(".env")
+	config = Config{}
+	require.NoError(t, builder.Parse(&config, "config/app"))
+	require.Equal(t, Config{Mode: "edited", User: "process-user"}, config)
+}
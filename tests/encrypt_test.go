@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEncrypted(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	encrypt := func(plain string) string {
+		value, err := swap.Encrypt(key, plain)
+		require.NoError(t, err)
+		return value
+	}
+
+	type Config struct {
+		Password string
+		Port     int
+		Hosts    []string
+		Token    swap.Secret `swapcp:"env=ENC_TOKEN"`
+		User     string
+	}
+
+	password, port, host, token := encrypt("pg: #pass"), encrypt("5432"), encrypt("db-1"), encrypt("t0k3n")
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"enc.yaml": []byte("password: \"" + password + "\"\nport: \"" + port + "\"\nhosts: [\"" + host + "\", db-2]\nuser: admin\n"),
+		"enc.json": []byte(`{"Password": "` + password + `", "Port": "` + port + `", "Hosts": ["` + host + `", "db-2"], "User": "admin"}`),
+		"enc.toml": []byte("Password = \"" + password + "\"\nPort = \"" + port + "\"\nHosts = [\"" + host + "\", \"db-2\"]\nUser = \"admin\"\n"),
+	})
+
+	require.NoError(t, os.Setenv("ENC_TOKEN", token))
+	defer os.Unsetenv("ENC_TOKEN")
+	require.NoError(t, os.Setenv(swap.DefaultEncryptionKeyEnv, base64.StdEncoding.EncodeToString(key)))
+	defer os.Unsetenv(swap.DefaultEncryptionKeyEnv)
+
+	for _, file := range []string{"enc.yaml", "enc.json", "enc.toml"} {
+		var config Config
+		require.NoError(t, swap.ParseWithOptions(&config, swap.ParseOptions{FileSystem: fsys}, file), file)
+		require.Equal(t, "pg: #pass", config.Password, file)
+		require.Equal(t, 5432, config.Port, file)
+		require.Equal(t, []string{"db-1", "db-2"}, config.Hosts, file)
+		require.Equal(t, "t0k3n", config.Token.Reveal(), file)
+		require.Equal(t, "admin", config.User, file)
+	}
+
+	// custom key env var
+	require.NoError(t, os.Setenv("ENC_APP_KEY", base64.StdEncoding.EncodeToString(key)))
+	defer os.Unsetenv("ENC_APP_KEY")
+	require.NoError(t, os.Unsetenv(swap.DefaultEncryptionKeyEnv))
+	opts := swap.ParseOptions{FileSystem: fsys, EncryptionKeyEnv: "ENC_APP_KEY"}
+	require.NoError(t, swap.ParseWithOptions(&Config{}, opts, "enc.yaml"))
+
+	// missing key
+	err = swap.ParseWithOptions(&Config{}, swap.ParseOptions{FileSystem: fsys}, "enc.yaml")
+	require.EqualError(t, err, "enc.yaml: enc: no key to decrypt the value, the SWAP_ENCRYPTION_KEY env var is empty")
+
+	// wrong key
+	wrong := make([]byte, 32)
+	require.NoError(t, os.Setenv("ENC_APP_KEY", base64.StdEncoding.EncodeToString(wrong)))
+	err = swap.ParseWithOptions(&Config{}, opts, "enc.yaml")
+	require.EqualError(t, err, "enc.yaml: enc: can't decrypt the value: cipher: message authentication failed")
+
+	// invalid key size
+	_, err = swap.Encrypt([]byte("short"), "value")
+	require.EqualError(t, err, "enc: crypto/aes: invalid key size 5")
+}
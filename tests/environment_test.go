@@ -3,8 +3,12 @@ package tests
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/oblq/swap"
 	"github.com/stretchr/testify/require"
 )
@@ -69,3 +73,147 @@ func TestNewWrongRepository(t *testing.T) {
 	repo := swap.NewGitRepository("nonexistentFolder")
 	require.Error(t, repo.Error)
 }
+
+func TestRepositoryWithoutGitBinary(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	signature := &object.Signature{Name: "swap", Email: "swap@example.com", When: time.Now()}
+	commit := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), []byte(content), 0644))
+		_, err = worktree.Add("file")
+		require.NoError(t, err)
+		_, err = worktree.Commit(content, &git.CommitOptions{Author: signature})
+		require.NoError(t, err)
+	}
+
+	commit("1")
+	untagged := swap.NewGitRepository(dir)
+	require.NoError(t, untagged.Error)
+	require.Equal(t, "master", untagged.BranchName)
+	require.Equal(t, "1", untagged.Build)
+	require.Equal(t, untagged.Commit, untagged.Tag)
+
+	head, err := repo.Head()
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), &git.CreateTagOptions{Tagger: signature, Message: "v1.0.0"})
+	require.NoError(t, err)
+	commit("2")
+
+	tagged := swap.NewGitRepository(dir)
+	require.NoError(t, tagged.Error)
+	require.Equal(t, "2", tagged.Build)
+	require.Equal(t, "v1.0.0", tagged.Tag)
+	require.NotEqual(t, untagged.Commit, tagged.Commit)
+}
+
+func TestBuildInfo(t *testing.T) {
+	buildInfo := swap.NewBuildInfo()
+	require.NoError(t, buildInfo.Error)
+	require.Contains(t, buildInfo.Info(), "Build Revision:")
+
+	release := swap.NewEnvironment("release", `(release)|(^v\d+\.\d+\.\d+$)`)
+	eh := swap.NewEnvironmentHandler(append(swap.DefaultEnvs.Slice(), release))
+	eh.Sources.VCS = swap.NewGitRepository("nonexistentFolder")
+	eh.Sources.BuildInfo = &swap.BuildInfo{Version: "v1.2.0", Revision: "83da5f14d0b3f68babf57fa2c31696f9e611d282"}
+	require.Equal(t, release, eh.Current())
+	require.Contains(t, eh.Current().Info(), "from the build info version")
+
+	// the git branch name comes first
+	eh.Sources.VCS = swap.NewGitRepository("./")
+	require.NotEqual(t, release, eh.Current())
+}
+
+func TestDeprecatedGitSource(t *testing.T) {
+	_ = os.Unsetenv("BUILD_ENV")
+	release := swap.NewEnvironment("release", `(release)|(^v\d+\.\d+\.\d+$)`)
+	eh := swap.NewEnvironmentHandler(append(swap.DefaultEnvs.Slice(), release))
+	eh.Sources.BuildInfo = &swap.BuildInfo{Version: "v1.2.0"}
+
+	// Git is used if the VCS is nil
+	eh.Sources.Git = swap.NewGitRepository("nonexistentFolder")
+	require.Equal(t, release, eh.Current())
+	eh.Sources.Git = nil
+	require.Equal(t, release, eh.Current())
+	eh.Sources.Git = swap.NewGitRepository("./")
+	require.NotEqual(t, release, eh.Current())
+
+	// the VCS comes first
+	eh.Sources.VCS = mercurial{branch: "release/1.0"}
+	require.Equal(t, swap.DefaultEnvs.Staging, eh.Current())
+}
+
+// mercurial is a custom VCS.
+type mercurial struct {
+	branch string
+}
+
+func (m mercurial) Branch() string {
+	return m.branch
+}
+
+func (m mercurial) Info() string {
+	return "Mercurial Branch: " + m.branch + "\n"
+}
+
+func TestCustomVCS(t *testing.T) {
+	_ = os.Unsetenv("BUILD_ENV")
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.Sources.VCS = mercurial{branch: "release/1.0"}
+	require.Equal(t, swap.DefaultEnvs.Staging, eh.Current())
+	require.Contains(t, eh.Current().Info(), "from the VCS branch (release/1.0)")
+
+	// not available, the build info and then the test detection are used
+	eh.Sources.VCS = mercurial{}
+	eh.Sources.BuildInfo = nil
+	require.Equal(t, swap.DefaultEnvs.Testing, eh.Current())
+}
+
+func TestAddSource(t *testing.T) {
+	_ = os.Unsetenv("BUILD_ENV")
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.Sources.VCS = mercurial{branch: "develop"}
+
+	metadata := ""
+	eh.AddSource("metadata", swap.PriorityEnvVar+1, func() (string, bool) {
+		return metadata, len(metadata) > 0
+	})
+
+	// not available, the next source is used
+	require.Equal(t, swap.DefaultEnvs.Development, eh.Current())
+
+	metadata = "staging"
+	require.Equal(t, swap.DefaultEnvs.Staging, eh.Current())
+	require.Contains(t, eh.Current().Info(), "from the `metadata` source")
+
+	// higher priority sources come first
+	_ = os.Setenv("BUILD_ENV", "production")
+	defer os.Unsetenv("BUILD_ENV")
+	require.Equal(t, swap.DefaultEnvs.Production, eh.Current())
+
+	// replaced
+	eh.AddSource("metadata", 0, func() (string, bool) { return "local", true })
+	require.Equal(t, swap.DefaultEnvs.Local, eh.Current())
+	require.Contains(t, eh.Current().Info(), "from the `metadata` source")
+}
+
+func TestSetSourcePriority(t *testing.T) {
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.SetCurrent(swap.DefaultEnvs.Staging.Tag())
+	_ = os.Setenv("BUILD_ENV", "production")
+	defer os.Unsetenv("BUILD_ENV")
+	require.Equal(t, swap.DefaultEnvs.Staging, eh.Current())
+
+	// the environment variable beats SetCurrent
+	require.NoError(t, eh.SetSourcePriority(swap.SourceEnvVar, swap.PriorityManual-1))
+	require.Equal(t, swap.DefaultEnvs.Production, eh.Current())
+
+	eh.AddSource("metadata", swap.PriorityTesting, func() (string, bool) { return "develop", true })
+	require.NoError(t, eh.SetSourcePriority("metadata", 0))
+	require.Equal(t, swap.DefaultEnvs.Development, eh.Current())
+
+	require.EqualError(t, eh.SetSourcePriority("unknown", 0), "unknown environment source 'unknown'")
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+type PGConfig struct {
+	Host     string `swapcp:"default=localhost"`
+	Password string `swapcp:"required"`
+	Replicas []struct {
+		Port int `swapcp:"default=port"`
+	}
+}
+
+type ToolPG struct {
+	Config PGConfig
+}
+
+func (c *ToolPG) Configure(configFiles ...string) error {
+	return swap.Parse(&c.Config, configFiles...)
+}
+
+func TestFieldError(t *testing.T) {
+	writeFiles("PG.yaml", []byte("host: db"), t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Services struct {
+			PG ToolPG
+		}
+	}
+
+	err := swap.NewBuilder(configPath).Build(&Box{})
+	require.EqualError(t, err, "Services.PG.Password: required")
+
+	var fe *swap.FieldError
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "Services.PG.Password", fe.Path)
+	require.Equal(t, "required", fe.Tag)
+	require.True(t, errors.Is(err, swap.ErrRequired))
+
+	// slices elements
+	writeFiles("PG.yaml", []byte("password: secret\nreplicas: [{}]"), t)
+	err = swap.NewBuilder(configPath).Build(&Box{})
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "Services.PG.Replicas[0].Port", fe.Path)
+	require.Equal(t, "default", fe.Tag)
+
+	// build errors
+	type BoxError struct {
+		Nested struct {
+			Tool ToolError
+		}
+	}
+
+	writeFiles("Tool.yaml", []byte("teststring: ok"), t)
+	err = swap.NewBuilder(configPath).Build(&BoxError{})
+	require.EqualError(t, err, "Nested.Tool: fake error for test")
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "Nested.Tool", fe.Path)
+	require.Empty(t, fe.Tag)
+}
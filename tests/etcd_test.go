@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEtcd is a minimal etcd v3 JSON gateway.
+type fakeEtcd struct {
+	mutex sync.Mutex
+	kv    map[string]string
+	token string
+}
+
+func (e *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/v3/auth/authenticate":
+		var req struct{ Name, Password string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Name != "root" || req.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		e.token = "token-" + strings.Repeat("x", len(e.token)+1)
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": e.token})
+
+	case "/v3/kv/range":
+		if r.Header.Get("Authorization") != e.token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Key      []byte `json:"key"`
+			RangeEnd []byte `json:"range_end"`
+			KeysOnly bool   `json:"keys_only"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		type kv struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value,omitempty"`
+		}
+		var kvs []kv
+		for k, v := range e.kv {
+			match := k == string(req.Key)
+			if len(req.RangeEnd) > 0 {
+				match = bytes.Compare([]byte(k), req.Key) >= 0 && bytes.Compare([]byte(k), req.RangeEnd) < 0
+			}
+			if !match {
+				continue
+			}
+			if req.KeysOnly {
+				v = ""
+			}
+			kvs = append(kvs, kv{Key: []byte(k), Value: []byte(v)})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"kvs": kvs})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestFileSystemEtcd(t *testing.T) {
+	etcd := &fakeEtcd{kv: map[string]string{
+		"myapp/config/Tool":             "teststring: generic",
+		"myapp/config/Tool.development": "teststring: development",
+		"myapp/config/Other.json":       `{"TestString": "json"}`,
+		"myapp/config/sub/Nested":       "teststring: nested",
+		"other/Tool":                    "teststring: other",
+	}}
+	server := httptest.NewTLSServer(etcd)
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(caFile, caPEM, 0600))
+
+	_, err := swap.NewFileSystemEtcd(swap.EtcdConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	require.Error(t, err)
+
+	fsys, err := swap.NewFileSystemEtcd(swap.EtcdConfig{
+		Endpoints:  []string{"https://127.0.0.1:1", server.URL},
+		Username:   "root",
+		Password:   "secret",
+		CAFile:     caFile,
+		Prefix:     "myapp/config",
+		ConfigPath: "./config",
+	})
+	require.NoError(t, err)
+
+	entries, err := fsys.ReadDir("./config")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"Other.json", "Tool.development.yaml", "Tool.yaml", "sub"}, names)
+	require.True(t, entries[3].IsDir())
+
+	_, err = fsys.ReadFile("./elsewhere/Tool.yaml")
+	require.Error(t, err)
+
+	// expired token
+	etcd.mutex.Lock()
+	etcd.token = "renewed"
+	etcd.mutex.Unlock()
+
+	data, err := fsys.ReadFile("./config/Tool.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "teststring: generic", string(data))
+
+	type RemoteTool struct {
+		Config ToolConfig
+	}
+
+	type Box struct {
+		Tool   RemoteTool
+		Other  RemoteTool
+		Nested RemoteTool `swap:"sub/Nested"`
+	}
+
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys), swap.WithDebug(false))
+	builder.EnvHandler.SetCurrent("development")
+	builder.RegisterType(reflect.TypeOf(RemoteTool{}), func(configFiles ...string) (interface{}, error) {
+		tool := &RemoteTool{}
+		return tool, builder.Parse(&tool.Config, configFiles...)
+	})
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "development", test.Tool.Config.TestString)
+	require.Equal(t, "json", test.Other.Config.TestString)
+	require.Equal(t, "nested", test.Nested.Config.TestString)
+
+	// wrong credentials
+	fsys, err = swap.NewFileSystemEtcd(swap.EtcdConfig{
+		Endpoints: []string{server.URL},
+		Username:  "root",
+		Password:  "wrong",
+		CAFile:    caFile,
+	})
+	require.NoError(t, err)
+	_, err = fsys.ReadFile("Tool.yaml")
+	require.Error(t, err)
+}
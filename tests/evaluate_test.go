@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+type evaluateConfig struct {
+	Host  string           `yaml:"host" swapcp:"required"`
+	URL   string           `yaml:"url"`
+	Port  int              `yaml:"port" swapcp:"default=5432"`
+	Retry swap.RetryPolicy `yaml:"retry"`
+}
+
+func TestEvaluate(t *testing.T) {
+	running := evaluateConfig{Host: "running"}
+
+	effective, err := swap.Evaluate(&running, swap.DefaultEnvs.Production, map[string][]byte{
+		"config/db.yaml":            []byte("host: db\nurl: \"{{.Host}}/api\"\n"),
+		"config/db.production.yaml": []byte("port: 6543\n"),
+	}, "config/db")
+	require.NoError(t, err)
+	require.Equal(t, "running", running.Host)
+
+	config := effective.(*evaluateConfig)
+	require.Equal(t, "db", config.Host)
+	require.Equal(t, 6543, config.Port)
+	require.Equal(t, "db/api", config.URL)
+	require.Equal(t, 3, config.Retry.MaxAttempts)
+
+	_, err = swap.Evaluate(&running, nil, map[string][]byte{
+		"db.yaml": []byte("host: db\nprot: 1234\n"),
+	}, "db")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "field prot not found")
+
+	_, err = swap.Evaluate(&running, nil, map[string][]byte{
+		"db.json": []byte(`{"Host": "db", "Unknown": true}`),
+	}, "db.json")
+	require.EqualError(t, err, `db.json: json: unknown field "Unknown"`)
+
+	_, err = swap.Evaluate(&running, nil, map[string][]byte{
+		"db.toml": []byte("Host = \"db\"\nUnknown = true\n"),
+	}, "db.toml")
+	require.EqualError(t, err, "db.toml: unknown keys: Unknown")
+
+	_, err = swap.Evaluate(&running, nil, map[string][]byte{
+		"db.yaml": []byte("retry:\n  maxAttempts: -1\n"),
+	}, "db.yaml")
+	require.EqualError(t, err, "Host: required")
+
+	_, err = swap.Evaluate(&running, nil, map[string][]byte{
+		"db.yaml": []byte("host: db\nretry:\n  maxAttempts: -1\n"),
+	}, "db.yaml")
+	require.EqualError(t, err, "Retry: maxAttempts must be greater than 0")
+}
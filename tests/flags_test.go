@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+type flagsConfig struct {
+	Host     string `swapcp:"default=localhost"`
+	Port     int    `swapcp:"env=FLAGS_PORT,flag=port"`
+	Debug    bool   `swapcp:"default=true"`
+	Timeout  time.Duration
+	MaxConns int
+	Hosts    []string
+	Internal string `swapcp:"flag=-"`
+	DB       struct {
+		User     string `swapcp:"required"`
+		Password swap.Secret
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml": []byte("host: file-host\nport: 80\nmaxconns: 10\n"),
+	})
+
+	require.NoError(t, os.Setenv("FLAGS_PORT", "8080"))
+	defer os.Unsetenv("FLAGS_PORT")
+
+	var config flagsConfig
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys)).BindFlags(fs, &config)
+
+	for _, name := range []string{"host", "port", "debug", "timeout", "max-conns", "hosts", "db.user", "db.password"} {
+		require.NotNil(t, fs.Lookup(name), name)
+	}
+	require.Nil(t, fs.Lookup("internal"))
+	require.Equal(t, "localhost", fs.Lookup("host").DefValue)
+
+	require.NoError(t, fs.Parse([]string{
+		"-port=9090", "-debug=false", "-timeout=1m30s", "-hosts=[a, b]", "-db.user=admin", "-db.password=s3cr3t",
+	}))
+	require.NoError(t, builder.Parse(&config, "./config/app"))
+	require.Equal(t, "file-host", config.Host)
+	require.Equal(t, 9090, config.Port)
+	require.False(t, config.Debug)
+	require.Equal(t, 90*time.Second, config.Timeout)
+	require.Equal(t, 10, config.MaxConns)
+	require.Equal(t, []string{"a", "b"}, config.Hosts)
+	require.Equal(t, "admin", config.DB.User)
+	require.Equal(t, "s3cr3t", config.DB.Password.Reveal())
+
+	// unset flags don't override
+	config = flagsConfig{}
+	fs = flag.NewFlagSet("app", flag.ContinueOnError)
+	builder = swap.NewBuilder("./config", swap.WithFileSystem(fsys)).BindFlags(fs, &config)
+	require.NoError(t, fs.Parse([]string{"-debug"}))
+	err := builder.Parse(&config, "./config/app")
+	require.EqualError(t, err, "DB.User: required")
+	require.Equal(t, 8080, config.Port)
+	require.True(t, config.Debug)
+}
+
+func TestBindPFlags(t *testing.T) {
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml": []byte("host: file-host\ndb: {user: root}\n"),
+	})
+
+	var config flagsConfig
+	fs := pflag.NewFlagSet("app", pflag.ContinueOnError)
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys)).BindPFlags(fs, &config)
+
+	require.NoError(t, fs.Parse([]string{"--host", "cli-host", "--debug", "--max-conns=5"}))
+	require.NoError(t, builder.Parse(&config, "./config/app"))
+	require.Equal(t, "cli-host", config.Host)
+	require.True(t, config.Debug)
+	require.Equal(t, 5, config.MaxConns)
+	require.Equal(t, "root", config.DB.User)
+
+	require.NoError(t, fs.Parse([]string{"--max-conns=many"}))
+	err := builder.Parse(&flagsConfig{}, "./config/app")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "MaxConns: ")
+}
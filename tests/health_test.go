@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// ToolHealth implements the 'HealthChecker' interface.
+type ToolHealth struct {
+	Err error
+}
+
+func (t *ToolHealth) Health(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return t.Err
+}
+
+func (t *ToolHealth) Configure(configFiles ...string) error {
+	return nil
+}
+
+func TestHealthReport(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		DB       ToolHealth       `swap:"Tool"`
+		Static   ToolConfigurable `swap:"Tool"`
+		Services struct {
+			Broker  *ToolHealth `swap:"Tool"`
+			Missing *ToolHealth `swap:"-"`
+		}
+		Reports swap.Lazy[ToolHealth] `swap:"Tool,lazy"`
+	}
+
+	var box Box
+	require.NoError(t, swap.NewBuilder(configPath, swap.WithDebug(false)).Build(&box))
+
+	health := swap.HealthReport(&box)
+	require.True(t, health.Healthy())
+	require.Equal(t, []string{"DB", "Services.Broker"}, health.Checked)
+
+	// lazy fields are checked once built
+	box.Reports.MustGet()
+	box.Services.Broker.Err = errors.New("connection refused")
+	health = swap.HealthReport(&box)
+	require.False(t, health.Healthy())
+	require.Equal(t, []string{"DB", "Reports", "Services.Broker"}, health.Checked)
+	require.EqualError(t, health.Errors["Services.Broker"], "connection refused")
+
+	rec := httptest.NewRecorder()
+	swap.HealthHandler(&box).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var body struct {
+		Healthy bool
+		Tools   map[string]string
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.False(t, body.Healthy)
+	require.Equal(t, map[string]string{"DB": "ok", "Reports": "ok", "Services.Broker": "connection refused"}, body.Tools)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	health = swap.HealthReportContext(ctx, &box)
+	require.True(t, errors.Is(health.Errors["DB"], context.Canceled))
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// countingDecoder is a swap.JSONDecoder counting the decoded files.
+type countingDecoder struct {
+	swap.StdJSONDecoder
+	calls int32
+}
+
+func (c *countingDecoder) Unmarshal(data []byte, v interface{}) error {
+	atomic.AddInt32(&c.calls, 1)
+	return c.StdJSONDecoder.Unmarshal(data, v)
+}
+
+func TestJSONDecoder(t *testing.T) {
+	type Config struct {
+		Host  string
+		Ports []int
+		DB    struct {
+			User string `json:"username"`
+		}
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.json": []byte(`{"host": "localhost", "ports": [80, 443], "db": {"username": "root"}}`),
+		"config/bad.json": []byte(`{"host": "localhost", "hots": "x"}`),
+	})
+
+	// the default decoder
+	var config Config
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/app"))
+	require.Equal(t, "localhost", config.Host)
+	require.Equal(t, []int{80, 443}, config.Ports)
+	require.Equal(t, "root", config.DB.User)
+
+	err := swap.ParseWithOptions(&Config{}, swap.ParseOptions{FileSystem: fsys, Strict: true}, "config/bad")
+	require.EqualError(t, err, `config/bad.json: json: unknown field "hots"`)
+
+	decoder := &countingDecoder{}
+	swap.SetJSONDecoder(decoder)
+	defer swap.SetJSONDecoder(nil)
+
+	config = Config{}
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/app"))
+	require.Equal(t, "root", config.DB.User)
+	require.NotZero(t, atomic.LoadInt32(&decoder.calls))
+}
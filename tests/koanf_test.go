@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// propsCodec is a koanf-like parser of `a.b=value` lines.
+type propsCodec struct{}
+
+func (propsCodec) Unmarshal(data []byte) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+		keys := strings.Split(strings.TrimSpace(kv[0]), ".")
+		node := tree
+		for _, key := range keys[:len(keys)-1] {
+			child, isMap := node[key].(map[string]interface{})
+			if !isMap {
+				child = make(map[string]interface{})
+				node[key] = child
+			}
+			node = child
+		}
+		node[keys[len(keys)-1]] = strings.TrimSpace(kv[1])
+	}
+	return tree, scanner.Err()
+}
+
+func (propsCodec) Marshal(tree map[string]interface{}) ([]byte, error) {
+	var lines []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if child, isMap := v.(map[string]interface{}); isMap {
+				walk(prefix+k+".", child)
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s%s=%v", prefix, k, v))
+		}
+	}
+	walk("", tree)
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	swap.RegisterCodec("props", propsCodec{})
+	defer swap.RegisterCodec(".props", nil)
+
+	type Config struct {
+		Host    string
+		Timeout time.Duration
+		DB      struct {
+			User     string
+			Password string `swapcp:"required"`
+		}
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.props":            []byte("host = localhost\ntimeout = 1m\ndb.user = ${KOANF_DB_USER}\ndb.password = s3cr3t\n"),
+		"config/app.production.props": []byte("host = example.com\n"),
+	})
+
+	require.NoError(t, os.Setenv("KOANF_DB_USER", "admin"))
+	defer os.Unsetenv("KOANF_DB_USER")
+
+	var config Config
+	env := swap.NewEnvironment("production", `(production)|(master)`)
+	require.NoError(t, swap.ParseByEnvWithFS(fsys, &config, env, "config/app"))
+	require.Equal(t, "example.com", config.Host)
+	require.Equal(t, time.Minute, config.Timeout)
+	require.Equal(t, "admin", config.DB.User)
+	require.Equal(t, "s3cr3t", config.DB.Password)
+
+	// strict mode
+	fsys = swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.props": []byte("hots = localhost\n"),
+	})
+	err := swap.ParseWithOptions(&Config{}, swap.ParseOptions{FileSystem: fsys, Strict: true}, "config/app")
+	require.EqualError(t, err, `config/app.props: json: unknown field "hots"`)
+}
+
+func TestKoanfProvider(t *testing.T) {
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml":            []byte("host: localhost\ndb: {user: root, port: 5432}\nlegacy: true\n"),
+		"config/app.production.json": []byte(`{"host": "example.com", "db": {"user": "${KOANF_DB_USER:-admin}"}, "legacy": null}`),
+	})
+
+	env := swap.NewEnvironment("production", `(production)|(master)`)
+	provider := swap.NewKoanfProvider(fsys, env, "config/app")
+
+	tree, err := provider.Read()
+	require.NoError(t, err)
+	require.Equal(t, "example.com", tree["host"])
+	db := tree["db"].(map[string]interface{})
+	require.Equal(t, "admin", db["user"])
+	require.EqualValues(t, 5432, db["port"])
+	require.NotContains(t, tree, "legacy")
+
+	_, err = provider.ReadBytes()
+	require.Error(t, err)
+
+	_, err = swap.NewKoanfProvider(fsys, nil, "config/missing").Read()
+	require.Error(t, err)
+}
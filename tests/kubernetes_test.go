@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProjectedVolume writes files with the projected volume layout:
+// the keys are symlinks to `..data/<key>`, `..data` links the timestamped dir.
+func writeProjectedVolume(t *testing.T, dir, timestamp string, files map[string]string) {
+	snapshot := filepath.Join(dir, timestamp)
+	require.NoError(t, os.MkdirAll(snapshot, 0755))
+	for key, value := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(snapshot, key), []byte(value), 0644))
+		_ = os.Symlink(filepath.Join("..data", key), filepath.Join(dir, key))
+	}
+
+	// atomic swap
+	tmp := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(timestamp, tmp))
+	require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+}
+
+func TestFileSystemKubernetesMount(t *testing.T) {
+	configMap, secret := t.TempDir(), t.TempDir()
+	writeProjectedVolume(t, configMap, "..2024_01_01_00_00_00.1", map[string]string{
+		"Tool.yaml":             "teststring: generic",
+		"Tool.development.yaml": "teststring: development",
+	})
+	writeProjectedVolume(t, secret, "..2024_01_01_00_00_00.2", map[string]string{
+		"Other.json": `{"TestString": "secret"}`,
+	})
+
+	fsys := swap.NewFileSystemOverlay(
+		swap.NewFileSystemKubernetesMount(configMap, "./config"),
+		swap.NewFileSystemKubernetesMount(secret, "./config"),
+	)
+
+	entries, err := swap.NewFileSystemKubernetesMount(configMap, "./config").ReadDir("./config")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"Tool.development.yaml", "Tool.yaml"}, names)
+
+	_, err = fsys.ReadFile("./config/..data/Tool.yaml")
+	require.Error(t, err)
+
+	type Box struct {
+		Tool  ToolConfigurable
+		Other ToolConfigurable
+	}
+
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys), swap.WithDebug(false))
+	builder.EnvHandler.SetCurrent("development")
+	builder.RegisterType(reflect.TypeOf(ToolConfigurable{}), func(configFiles ...string) (interface{}, error) {
+		tool := &ToolConfigurable{}
+		return tool, builder.Parse(&tool.Config, configFiles...)
+	})
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "development", test.Tool.Config.TestString)
+	require.Equal(t, "secret", test.Other.Config.TestString)
+
+	// ConfigMap update
+	writeProjectedVolume(t, configMap, "..2024_01_02_00_00_00.1", map[string]string{
+		"Tool.yaml":             "teststring: generic",
+		"Tool.development.yaml": "teststring: updated",
+	})
+	data, err := fsys.ReadFile("./config/Tool.development.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "teststring: updated", string(data))
+}
+
+func TestFileSystemKubernetesAPI(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("token\n"), 0600))
+
+	objects := map[string]interface{}{
+		"/api/v1/namespaces/myapp/configmaps/tools": map[string]interface{}{
+			"data": map[string]string{
+				"Tool.yaml":             "teststring: generic",
+				"Tool.development.yaml": "teststring: development",
+				"Other.json":            `{"TestString": "configmap"}`,
+			},
+		},
+		"/api/v1/namespaces/myapp/secrets/credentials": map[string]interface{}{
+			"data": map[string][]byte{"Other.json": []byte(`{"TestString": "secret"}`)},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		object, found := objects[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(object)
+	}))
+	defer server.Close()
+
+	fsys, err := swap.NewFileSystemKubernetes(swap.KubernetesConfig{
+		Namespace:  "myapp",
+		ConfigMaps: []string{"tools"},
+		Secrets:    []string{"credentials"},
+		ConfigPath: "./config",
+		Host:       server.URL,
+		TokenFile:  tokenFile,
+	})
+	require.NoError(t, err)
+
+	entries, err := fsys.ReadDir("./config")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	_, err = fsys.ReadDir("./config/sub")
+	require.Error(t, err)
+
+	type Box struct {
+		Tool  ToolConfigurable
+		Other ToolConfigurable
+	}
+
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(swap.NewFileSystemCached(fsys)), swap.WithDebug(false))
+	builder.EnvHandler.SetCurrent("development")
+	builder.RegisterType(reflect.TypeOf(ToolConfigurable{}), func(configFiles ...string) (interface{}, error) {
+		tool := &ToolConfigurable{}
+		return tool, builder.Parse(&tool.Config, configFiles...)
+	})
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "development", test.Tool.Config.TestString)
+	require.Equal(t, "secret", test.Other.Config.TestString)
+
+	fsys, err = swap.NewFileSystemKubernetes(swap.KubernetesConfig{
+		Namespace:  "myapp",
+		ConfigMaps: []string{"missing"},
+		Host:       server.URL,
+		TokenFile:  tokenFile,
+	})
+	require.NoError(t, err)
+	_, err = fsys.ReadFile("Tool.yaml")
+	require.Error(t, err)
+}
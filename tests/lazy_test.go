@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazy(t *testing.T) {
+	createJSON(ToolConfig{TestString: "lazy"}, "Tool.json", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Lazy    swap.Lazy[ToolConfigurable]  `swap:"Tool,lazy"`
+		Eager   swap.Lazy[ToolConfigurable]  `swap:"Tool"`
+		PTRLazy *swap.Lazy[ToolConfigurable] `swap:"Tool,lazy"`
+	}
+
+	quiet := swap.WithLogger(log.New(ioutil.Discard, "", 0))
+
+	var box Box
+	require.NoError(t, swap.NewBuilder(configPath, quiet).Build(&box))
+	require.False(t, box.Lazy.Built())
+	require.True(t, box.Eager.Built())
+	require.False(t, box.PTRLazy.Built())
+
+	tool, err := box.Lazy.Get()
+	require.NoError(t, err)
+	require.Equal(t, "lazy", tool.Config.TestString)
+	require.True(t, box.Lazy.Built())
+	require.Equal(t, "lazy", box.Eager.MustGet().Config.TestString)
+	require.Equal(t, "lazy", box.PTRLazy.MustGet().Config.TestString)
+
+	// configured once, copies share the tool
+	lazyCopy := box.Lazy
+	require.Same(t, tool, lazyCopy.MustGet())
+
+	// all the swap.Lazy fields
+	var lazyBox Box
+	require.NoError(t, swap.NewBuilder(configPath, quiet, swap.WithLazy(true)).Build(&lazyBox))
+	require.False(t, lazyBox.Eager.Built())
+	require.Equal(t, "lazy", lazyBox.Eager.MustGet().Config.TestString)
+
+	// not built
+	var unbuilt swap.Lazy[ToolConfigurable]
+	_, err = unbuilt.Get()
+	require.Error(t, err)
+}
+
+func TestLazyErrors(t *testing.T) {
+	createJSON(ToolConfig{TestString: "lazy"}, "Tool.json", t)
+	defer removeConfigFiles(t)
+
+	quiet := swap.WithLogger(log.New(ioutil.Discard, "", 0))
+
+	type MissingBox struct {
+		Missing swap.Lazy[ToolConfigurable] `swap:"lazy"`
+	}
+
+	// the error is returned by Get
+	var missingBox MissingBox
+	require.NoError(t, swap.NewBuilder(configPath, quiet).Build(&missingBox))
+	_, err := missingBox.Missing.Get()
+	require.Error(t, err)
+	var fe *swap.FieldError
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "Missing", fe.Path)
+	_, again := missingBox.Missing.Get()
+	require.Equal(t, err, again)
+
+	type WrongBox struct {
+		Tool ToolConfigurable `swap:"lazy"`
+	}
+
+	var wrongBox WrongBox
+	err = swap.NewBuilder(configPath, quiet).Build(&wrongBox)
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "lazy", fe.Tag)
+	require.Equal(t, "Tool", fe.Path)
+}
+
+func TestLazyPathOverridesAndValidators(t *testing.T) {
+	createJSON(ToolConfig{TestString: "lazy"}, "Tool.json", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Lazy  swap.Lazy[ToolConfigurable] `swap:"Tool,lazy"`
+		Eager swap.Lazy[ToolConfigurable] `swap:"Tool"`
+	}
+
+	quiet := swap.WithLogger(log.New(ioutil.Discard, "", 0))
+
+	for _, key := range []string{"SWAP__Lazy__Config__TestString", "SWAP__Eager__Config__TestString"} {
+		require.NoError(t, os.Setenv(key, "override"))
+		defer os.Unsetenv(key)
+	}
+
+	// the overrides are applied to the lazy tools once built
+	var box Box
+	require.NoError(t, swap.NewBuilder(configPath, quiet).Build(&box))
+	require.Equal(t, "override", box.Eager.MustGet().Config.TestString)
+	require.Equal(t, "override", box.Lazy.MustGet().Config.TestString)
+
+	// the lazy tools are validated once built
+	validator := func(v interface{}) error {
+		if v.(ToolConfig).TestString == "override" {
+			return errors.New("invalid TestString")
+		}
+		return nil
+	}
+	newBuilder := func(options ...swap.Option) *swap.Builder {
+		return swap.NewBuilder(configPath, append(options, quiet)...).
+			RegisterValidator(reflect.TypeOf(ToolConfig{}), validator)
+	}
+
+	require.EqualError(t, newBuilder().Build(&Box{}), "Eager.Config: invalid TestString")
+
+	var lazyBox Box
+	require.NoError(t, newBuilder(swap.WithLazy(true)).Build(&lazyBox))
+	_, err := lazyBox.Lazy.Get()
+	require.EqualError(t, err, "Lazy.Config: invalid TestString")
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+var shutdownOrder []string
+
+// ToolShutdowner is a struct implementing 'Configurable' and 'Shutdowner' interfaces.
+type ToolShutdowner struct {
+	Config ToolConfig
+}
+
+// Configure is the 'Configurable' interface implementation.
+func (c *ToolShutdowner) Configure(configFiles ...string) error {
+	return swap.Parse(&c.Config, configFiles...)
+}
+
+// Shutdown is the 'Shutdowner' interface implementation.
+func (c *ToolShutdowner) Shutdown(ctx context.Context) error {
+	shutdownOrder = append(shutdownOrder, c.Config.TestString)
+	return nil
+}
+
+// ToolCloser is a struct implementing 'Configurable' and 'io.Closer' interfaces.
+type ToolCloser struct {
+	Config ToolConfig
+}
+
+// Configure is the 'Configurable' interface implementation.
+func (c *ToolCloser) Configure(configFiles ...string) error {
+	return swap.Parse(&c.Config, configFiles...)
+}
+
+// Close is the 'io.Closer' interface implementation.
+func (c *ToolCloser) Close() error {
+	shutdownOrder = append(shutdownOrder, c.Config.TestString)
+	return errors.New("fake error for test")
+}
+
+func TestShutdown(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "Tool1.yml", t)
+	createYAML(ToolConfig{TestString: "2"}, "Tool2.yml", t)
+	createYAML(ToolConfig{TestString: "3"}, "Tool3.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool1 ToolShutdowner
+		Tool2 *ToolCloser
+		Tool3 *ToolShutdowner
+		Tool4 Tool
+	}
+
+	shutdownOrder = nil
+
+	var test Box
+	builder := swap.NewBuilder(configPath)
+	require.NoError(t, builder.Build(&test))
+
+	err := builder.Shutdown(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Tool2")
+	require.Equal(t, []string{"3", "2", "1"}, shutdownOrder)
+
+	// tools are shut down only once
+	shutdownOrder = nil
+	require.NoError(t, builder.Shutdown(context.Background()))
+	require.Empty(t, shutdownOrder)
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestFleetCheck(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool1.yml", t)
+	createYAML(ToolConfig{TestString: "1"}, "SubBox/Tool2.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool1 ToolConfigurable
+		Tool2 ToolConfigurable `swap:"SubBox/Tool2"`
+		Tool3 ToolConfigurable `swap:"Tool1"`
+	}
+
+	builder := swap.NewBuilder(configPath, swap.WithDebug(false))
+	require.NoError(t, builder.Build(&Box{}))
+
+	manifest := builder.Manifest()
+	require.Len(t, manifest.Files, 2)
+	require.Contains(t, manifest.Files, "Tool1.yml")
+	require.Contains(t, manifest.Files, "SubBox/Tool2.yml")
+	require.Len(t, manifest.Hash, 64)
+
+	instance1 := httptest.NewServer(builder.DebugHandler())
+	defer instance1.Close()
+	instance2 := httptest.NewServer(builder.DebugHandler())
+	defer instance2.Close()
+
+	report := swap.FleetCheck(context.Background(), nil, []string{instance1.URL, instance2.URL})
+	require.True(t, report.Consistent(), report.String())
+	require.ElementsMatch(t, []string{instance1.URL, instance2.URL}, report.Targets[manifest.Hash])
+
+	// a stale instance
+	staleBuilder := swap.NewBuilder(configPath, swap.WithDebug(false))
+	require.NoError(t, staleBuilder.Build(&Box{}))
+	createYAML(ToolConfig{TestString: "updated"}, "Tool1.yml", t)
+	require.NoError(t, builder.Build(&Box{}))
+
+	stale := httptest.NewServer(staleBuilder.DebugHandler())
+	defer stale.Close()
+
+	report = swap.FleetCheck(context.Background(), nil, []string{instance1.URL, instance2.URL, stale.URL})
+	require.False(t, report.Consistent())
+	require.Len(t, report.Targets, 2)
+	require.Equal(t, []string{stale.URL}, report.Targets[manifest.Hash])
+	require.Contains(t, report.String(), "drift detected")
+
+	unreachable := httptest.NewServer(nil)
+	unreachable.Close()
+	report = swap.FleetCheck(context.Background(), nil, []string{instance1.URL, unreachable.URL})
+	require.False(t, report.Consistent())
+	require.Contains(t, report.Errors, unreachable.URL)
+}
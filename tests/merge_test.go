@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSliceMerge(t *testing.T) {
+	type Route struct {
+		Path string
+	}
+
+	type Config struct {
+		Hosts   []string
+		Origins []string `swapcp:"merge=unique"`
+		Routes  []Route  `swapcp:"merge=append"`
+		Ports   []int    `swapcp:"merge=replace"`
+		Nested  struct {
+			Tags []string `swapcp:"merge=append"`
+		}
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml":            []byte("hosts: [a]\norigins: [x, y]\nroutes: [{path: /}]\nports: [80]\nnested: {tags: [t1]}\n"),
+		"config/app.production.json": []byte(`{"hosts": ["b"], "origins": ["y", "z"], "routes": [{"path": "/api"}], "ports": [443]}`),
+	})
+	env := swap.NewEnvironment("production", `(production)|(master)`)
+
+	var config Config
+	require.NoError(t, swap.ParseByEnvWithFS(fsys, &config, env, "config/app"))
+	require.Equal(t, []string{"b"}, config.Hosts)
+	require.Equal(t, []string{"x", "y", "z"}, config.Origins)
+	require.Equal(t, []Route{{Path: "/"}, {Path: "/api"}}, config.Routes)
+	require.Equal(t, []int{443}, config.Ports)
+	// not set by the production file
+	require.Equal(t, []string{"t1"}, config.Nested.Tags)
+
+	// per parse default, the flags take precedence
+	config = Config{}
+	opts := swap.ParseOptions{FileSystem: fsys, Env: env, SliceMerge: swap.SliceMergeAppend}
+	require.NoError(t, swap.ParseWithOptions(&config, opts, "config/app"))
+	require.Equal(t, []string{"a", "b"}, config.Hosts)
+	require.Equal(t, []string{"x", "y", "z"}, config.Origins)
+	require.Equal(t, []int{443}, config.Ports)
+
+	type Invalid struct {
+		Hosts []string `swapcp:"merge=concat"`
+	}
+	err := swap.ParseByEnvWithFS(fsys, &Invalid{}, env, "config/app")
+	require.EqualError(t, err, "Hosts: unknown merge strategy 'concat', must be one of: replace, append, unique")
+}
+
+func TestMapDeepMerge(t *testing.T) {
+	type Limits struct {
+		Rate int
+	}
+
+	type Config struct {
+		Features map[string]interface{}
+		Quotas   map[string]map[string]int
+		Limits   map[string]*Limits
+		Labels   map[string]string
+		Ports    map[string]int `yaml:"ports" json:"ports"`
+	}
+
+	expected := Config{
+		Features: map[string]interface{}{"search": map[string]interface{}{"enabled": true, "engine": "bleve"}, "beta": "on"},
+		Quotas:   map[string]map[string]int{"free": {"requests": 10, "storage": 2}, "pro": {"requests": 100}},
+		Limits:   map[string]*Limits{"api": {Rate: 5}},
+		Labels:   map[string]string{"team": "core", "tier": "1"},
+		Ports:    map[string]int{"http": 80},
+	}
+
+	base := []byte("features: {search: {enabled: false, engine: bleve}, legacy: true}\n" +
+		"quotas: {free: {requests: 1, storage: 2}}\n" +
+		"limits: {api: {rate: 1}, admin: {rate: 2}}\n" +
+		"labels: {team: core, owner: ops}\n" +
+		"ports: {http: 80, debug: 6060}\n")
+
+	overrides := map[string][]byte{
+		"yaml": []byte("features: {search: {enabled: true}, legacy: null, beta: 'on'}\n" +
+			"quotas: {free: {requests: 10}, pro: {requests: 100}}\n" +
+			"limits: {api: {rate: 5}, admin: ~}\n" +
+			"labels: {tier: '1', owner: null}\n" +
+			"ports: {debug: ~}\n"),
+		"json": []byte(`{"features": {"search": {"enabled": true}, "legacy": null, "beta": "on"},
+			"quotas": {"free": {"requests": 10}, "pro": {"requests": 100}},
+			"limits": {"api": {"rate": 5}, "admin": null},
+			"labels": {"tier": "1", "owner": null},
+			"ports": {"debug": null}}`),
+	}
+
+	for ext, override := range overrides {
+		fsys := swap.NewFileSystemMemory(map[string][]byte{
+			"config/app.yaml":              base,
+			"config/app.production." + ext: override,
+		})
+
+		var config Config
+		require.NoError(t, swap.ParseByEnvWithFS(fsys, &config, swap.NewEnvironment("production", "production"), "config/app"), ext)
+		require.Equal(t, expected, config, ext)
+	}
+
+	// TOML has no null, nested tables deep-merge
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml":            base,
+		"config/app.production.toml": []byte("[quotas.free]\nrequests = 10\n"),
+	})
+	var config Config
+	require.NoError(t, swap.ParseByEnvWithFS(fsys, &config, swap.NewEnvironment("production", "production"), "config/app"))
+	require.Equal(t, map[string]map[string]int{"free": {"requests": 10, "storage": 2}}, config.Quotas)
+	require.Len(t, config.Limits, 2)
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"expvar"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+type observation struct {
+	labels map[string]string
+	err    error
+}
+
+type metricsHook struct {
+	observations map[string]observation
+	mutex        sync.Mutex
+}
+
+func (m *metricsHook) ObserveField(path string, labels map[string]string, duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.observations[path] = observation{labels: labels, err: err}
+}
+
+func TestMetricsHook(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		DB     ToolConfigurable `swap:"Tool,metrics=component:db|team:core"`
+		Nested struct {
+			Tool  *ToolConfigurable
+			Error ToolError `swap:"Tool"`
+		}
+	}
+
+	hook := &metricsHook{observations: make(map[string]observation)}
+
+	var test Box
+	require.Error(t, swap.NewBuilder(configPath, swap.WithMetricsHook(hook)).Build(&test))
+
+	require.Equal(t, map[string]string{"component": "db", "team": "core"}, hook.observations["DB"].labels)
+	require.NoError(t, hook.observations["Nested.Tool"].err)
+	require.Error(t, hook.observations["Nested.Error"].err)
+	require.NotContains(t, hook.observations, "Nested")
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool   ToolSwappable
+		Static ToolConfigurable `swap:"Tool"`
+		Error  ToolError        `swap:"Tool,degrade"`
+	}
+
+	metrics := swap.NewExpvarMetrics("swap_test_metrics")
+	builder := swap.NewBuilder(configPath, swap.WithMetricsHook(metrics), swap.WithLogger(log.New(ioutil.Discard, "", 0)))
+	counter := func(key string) int64 {
+		if v := metrics.Vars().Get(key); v != nil {
+			return v.(interface{ Value() int64 }).Value()
+		}
+		return 0
+	}
+
+	histogram := func(key, bucket string) int64 {
+		return metrics.Vars().Get(key).(*expvar.Map).Get(bucket).(*expvar.Int).Value()
+	}
+
+	var box Box
+	require.NoError(t, builder.Build(&box))
+	require.Equal(t, int64(2), counter("fields_configured"))
+	require.Equal(t, int64(1), counter("fields_failed"))
+	require.Equal(t, int64(3), counter("config_files_read"))
+	require.Equal(t, int64(3), histogram("fields_duration", "count"))
+	require.NotZero(t, histogram("fields_duration", "sum_ns"))
+	require.Equal(t, int64(3), histogram("fields_duration", "le_10s"))
+
+	var config ToolConfig
+	require.NoError(t, builder.Parse(&config, configPath+"/Tool.yml"))
+	require.Error(t, builder.Parse(&config, configPath+"/Unknown.yml"))
+	require.Equal(t, int64(1), counter("parses"))
+	require.Equal(t, int64(1), counter("parse_failures"))
+	require.Equal(t, int64(2), histogram("parse_duration", "count"))
+	require.NotZero(t, histogram("parse_duration", "sum_ns"))
+	// the parsed files are not counted twice
+	require.Equal(t, int64(3), counter("config_files_read"))
+
+	require.NoError(t, builder.Reload(context.Background(), &box, "Tool", 0))
+	_, err := builder.Rebuild(&box, "Static")
+	require.NoError(t, err)
+	require.Error(t, builder.Reload(context.Background(), &box, "Static", 0))
+	require.Equal(t, int64(2), counter("reloads"))
+	require.Equal(t, int64(3), histogram("reload_duration", "count"))
+	require.Equal(t, int64(1), counter("reload_failures"))
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// ToolEnvConfigurable is a configurable tool with an env tagged field.
+type ToolEnvConfigurable struct {
+	Config struct {
+		TestString string
+		Password   string `swapcp:"env=SWAP_TEST_OVERRIDE_PASSWORD"`
+	}
+}
+
+func (c *ToolEnvConfigurable) Configure(configFiles ...string) error {
+	return swap.Parse(&c.Config, configFiles...)
+}
+
+func TestOverrideToken(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool1.yml", t)
+	createYAML(ToolConfig{TestString: "1"}, "Tool1.production.yml", t)
+	defer removeConfigFiles(t)
+	defer os.Unsetenv(swap.OverrideTokenEnvKey)
+	defer os.Unsetenv("SWAP_TEST_OVERRIDE_PASSWORD")
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	type Box struct {
+		Tool1 ToolEnvConfigurable
+	}
+
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.SetCurrent(swap.DefaultEnvs.Staging.Tag())
+	builder := swap.NewBuilder(configPath, swap.WithEnvHandler(eh), swap.WithOverrideKey(publicKey), swap.WithDebug(false))
+
+	token, err := swap.SignOverrideToken(privateKey, swap.OverrideClaims{
+		Env:       swap.DefaultEnvs.Production.Tag(),
+		Overrides: map[string]string{"SWAP_TEST_OVERRIDE_PASSWORD": "emergency"},
+		Subject:   "ops@example.com",
+		Reason:    "incident 42",
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.Setenv(swap.OverrideTokenEnvKey, token))
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, "1", test.Tool1.Config.TestString)
+	require.Equal(t, "emergency", test.Tool1.Config.Password)
+	require.Equal(t, "ops@example.com", builder.Override().Subject)
+	require.Equal(t, swap.DefaultEnvs.Production.Tag(), eh.Current().Tag())
+
+	// tampered
+	require.NoError(t, os.Setenv(swap.OverrideTokenEnvKey, token[:len(token)-4]+"AAAA"))
+	require.EqualError(t, builder.Build(&Box{}), "invalid override token signature")
+
+	// signed by another key
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	token, err = swap.SignOverrideToken(otherKey, swap.OverrideClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	require.NoError(t, os.Setenv(swap.OverrideTokenEnvKey, token))
+	require.EqualError(t, builder.Build(&Box{}), "invalid override token signature")
+
+	// dropped, the previous env vars and environment are restored
+	require.NoError(t, os.Unsetenv(swap.OverrideTokenEnvKey))
+	test = Box{}
+	require.NoError(t, builder.Build(&test))
+	require.Nil(t, builder.Override())
+	require.Equal(t, "0", test.Tool1.Config.TestString)
+	require.Empty(t, test.Tool1.Config.Password)
+	_, found := os.LookupEnv("SWAP_TEST_OVERRIDE_PASSWORD")
+	require.False(t, found)
+	require.Equal(t, swap.DefaultEnvs.Staging.Tag(), eh.Current().Tag())
+
+	// expired, ignored
+	token, err = swap.SignOverrideToken(privateKey, swap.OverrideClaims{
+		Env:       swap.DefaultEnvs.Production.Tag(),
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.Setenv(swap.OverrideTokenEnvKey, token))
+	test = Box{}
+	require.NoError(t, builder.Build(&test))
+	require.Nil(t, builder.Override())
+	require.Equal(t, "0", test.Tool1.Config.TestString)
+	_, err = swap.VerifyOverrideToken(publicKey, token)
+	require.Equal(t, swap.ErrOverrideTokenExpired, err)
+
+	// system env vars can't be set
+	for _, key := range []string{"PATH", "LD_PRELOAD", "dyld_insert_libraries", swap.OverrideTokenEnvKey} {
+		token, err = swap.SignOverrideToken(privateKey, swap.OverrideClaims{
+			Overrides: map[string]string{key: "/tmp/evil"},
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.Setenv(swap.OverrideTokenEnvKey, token))
+		require.EqualError(t, builder.Build(&Box{}), "the override token can't set the '"+key+"' env var")
+	}
+	require.NoError(t, os.Unsetenv(swap.OverrideTokenEnvKey))
+
+	_, err = swap.SignOverrideToken(privateKey, swap.OverrideClaims{})
+	require.Error(t, err)
+
+	// ignored without a public key
+	require.NoError(t, swap.NewBuilder(configPath, swap.WithDebug(false)).Build(&Box{}))
+
+	// wrong key length
+	_, err = swap.VerifyOverrideToken(publicKey[:16], token)
+	require.EqualError(t, err, "invalid override key length 16, must be 32 bytes")
+	require.Panics(t, func() { swap.WithOverrideKey(publicKey[:16]) })
+}
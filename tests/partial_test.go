@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildFields(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool     ToolConfigurable
+		Services struct {
+			PTRTool *ToolConfigurable `swap:"Tool"`
+			Static  ToolConfigurable  `swap:"Tool"`
+		}
+	}
+
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	// only the given fields are built
+	var partial Box
+	require.NoError(t, builder.BuildFields(&partial, "Services.Static"))
+	require.Equal(t, "1", partial.Services.Static.Config.TestString)
+	require.Empty(t, partial.Tool.Config.TestString)
+	require.Nil(t, partial.Services.PTRTool)
+
+	// already configured fields are built again
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	createYAML(ToolConfig{TestString: "2"}, "Tool.yml", t)
+	require.NoError(t, builder.BuildFields(&test, "Tool", "Services.PTRTool"))
+	require.Equal(t, "2", test.Tool.Config.TestString)
+	require.Equal(t, "2", test.Services.PTRTool.Config.TestString)
+	require.Equal(t, "1", test.Services.Static.Config.TestString)
+
+	require.EqualError(t, builder.BuildFields(&test, "Tool", "Unknown"), "field 'Unknown' not found")
+	require.Equal(t, "2", test.Tool.Config.TestString)
+	require.Error(t, builder.BuildFields(&test))
+	require.Error(t, builder.BuildFields(test, "Tool"))
+}
+
+func TestRebuild(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool     ToolConfigurable
+		Services struct {
+			PTRTool *ToolConfigurable `swap:"Tool"`
+		}
+	}
+
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	holder := test.Services.PTRTool
+
+	createYAML(ToolConfig{TestString: "2"}, "Tool.yml", t)
+	files, err := builder.Rebuild(&test, "Services.PTRTool")
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(configPath, "Tool.yml")}, files)
+	require.Same(t, holder, test.Services.PTRTool)
+	require.Equal(t, "2", holder.Config.TestString)
+	require.Equal(t, "1", test.Tool.Config.TestString)
+
+	// the previous value is restored on errors
+	removeConfigFiles(t)
+	_, err = builder.Rebuild(&test, "Tool")
+	require.Error(t, err)
+	require.Equal(t, "1", test.Tool.Config.TestString)
+
+	_, err = builder.Rebuild(&test, "Unknown")
+	require.EqualError(t, err, "field 'Unknown' not found")
+}
+
+func TestRebuildDegrade(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool ToolShutdowner `swap:"degrade"`
+	}
+
+	shutdownOrder = nil
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+
+	// a failed rebuild of a degrade field is returned, not degraded
+	removeConfigFiles(t)
+	_, err := builder.Rebuild(&test, "Tool")
+	require.Error(t, err)
+	require.Equal(t, "1", test.Tool.Config.TestString)
+	require.True(t, builder.Available("Tool"))
+	require.Empty(t, shutdownOrder)
+
+	// the previous value is still recorded
+	require.NoError(t, builder.Shutdown(context.Background()))
+	require.Equal(t, []string{"1"}, shutdownOrder)
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathOverrides(t *testing.T) {
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/Tool.yaml": []byte("teststring: file"),
+	})
+
+	type Box struct {
+		Services struct {
+			Tool ToolConfigurable
+		}
+		Hosts []string
+		Ports map[string]int
+	}
+
+	// the value seen by the factory
+	var parsed string
+	newBuilder := func() *swap.Builder {
+		builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys), swap.WithDebug(false))
+		builder.RegisterType(reflect.TypeOf(ToolConfigurable{}), func(configFiles ...string) (interface{}, error) {
+			tool := &ToolConfigurable{}
+			err := builder.Parse(&tool.Config, configFiles...)
+			parsed = tool.Config.TestString
+			return tool, err
+		})
+		return builder
+	}
+
+	env := map[string]string{
+		"SWAP__services__Tool__Config__TestString": "override",
+		"SWAP__Ports__http":                        "8080",
+	}
+	for key, value := range env {
+		require.NoError(t, os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+
+	var box Box
+	require.NoError(t, newBuilder().Build(&box))
+	require.Equal(t, "override", box.Services.Tool.Config.TestString)
+	require.Equal(t, "override", parsed)
+	require.Equal(t, map[string]int{"http": 8080}, box.Ports)
+
+	// custom prefix, the default one is ignored
+	require.NoError(t, os.Setenv("APP__Hosts", "[a, b]"))
+	defer os.Unsetenv("APP__Hosts")
+	box = Box{}
+	require.NoError(t, newBuilder().WithPathOverridePrefix("APP__").Build(&box))
+	require.Equal(t, "file", box.Services.Tool.Config.TestString)
+	require.Equal(t, "file", parsed)
+	require.Equal(t, []string{"a", "b"}, box.Hosts)
+
+	// unknown paths and invalid values
+	require.NoError(t, os.Setenv("SWAP__Services__Missing", "value"))
+	err := newBuilder().Build(&Box{})
+	require.EqualError(t, err, "SWAP__Services__Missing: unknown key 'Missing'")
+	require.NoError(t, os.Unsetenv("SWAP__Services__Missing"))
+
+	require.NoError(t, os.Setenv("SWAP__Ports__http", "eighty"))
+	require.Error(t, newBuilder().Build(&Box{}))
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/oblq/swap/pflags"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPFlags(t *testing.T) {
+	type Config struct {
+		Host     string `swapcp:"default=localhost,desc=the listening host"`
+		Port     int    `swapcp:"env=PFLAGS_PORT,flag=port,desc=the listening port"`
+		Debug    bool
+		MaxConns int `swapcp:"default=10"`
+		DB       struct {
+			User string
+		}
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml": []byte("host: file-host\nport: 80\ndb: {user: root}\n"),
+	})
+
+	require.NoError(t, os.Setenv("PFLAGS_PORT", "8080"))
+	defer os.Unsetenv("PFLAGS_PORT")
+
+	var config Config
+	fs, binding := pflags.NewFlagSet("app", &config, swap.WithFileSystem(fsys))
+	require.Equal(t, "the listening host", fs.Lookup("host").Usage)
+	require.Equal(t, "the listening port, env PFLAGS_PORT", fs.Lookup("port").Usage)
+	require.Equal(t, "localhost", fs.Lookup("host").DefValue)
+	require.NotNil(t, fs.Lookup("db.user"))
+	require.NotNil(t, binding.Builder())
+
+	// flags > env > files > defaults
+	require.NoError(t, fs.Parse([]string{"--debug", "--db.user", "admin"}))
+	require.NoError(t, binding.Parse("config/app"))
+	require.Equal(t, Config{Host: "file-host", Port: 8080, Debug: true, MaxConns: 10, DB: struct{ User string }{"admin"}}, config)
+
+	require.NoError(t, fs.Parse([]string{"--port=9090"}))
+	require.NoError(t, binding.Parse("config/app"))
+	require.Equal(t, 9090, config.Port)
+}
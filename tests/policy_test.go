@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+type policyConfig struct {
+	Retry   swap.RetryPolicy          `yaml:"retry"`
+	Breaker swap.CircuitBreakerPolicy `yaml:"breaker"`
+}
+
+func TestPolicyDefaults(t *testing.T) {
+	writeFiles("policy.yaml", []byte("retry:\n  maxAttempts: 5\n  initialBackoff: 200ms\n"), t)
+	defer removeConfigFiles(t)
+
+	var config policyConfig
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "policy.yaml")))
+
+	require.Equal(t, 5, config.Retry.MaxAttempts)
+	require.Equal(t, 200*time.Millisecond, config.Retry.InitialBackoff)
+	require.Equal(t, 10*time.Second, config.Retry.MaxBackoff)
+	require.Equal(t, 2.0, config.Retry.Multiplier)
+
+	require.Equal(t, 5, config.Breaker.FailureThreshold)
+	require.Equal(t, 1, config.Breaker.SuccessThreshold)
+	require.Equal(t, 30*time.Second, config.Breaker.OpenTimeout)
+	require.Equal(t, 1, config.Breaker.HalfOpenMaxRequests)
+
+	require.Equal(t, 200*time.Millisecond, config.Retry.Backoff(1))
+	require.Equal(t, 800*time.Millisecond, config.Retry.Backoff(3))
+	require.Equal(t, 10*time.Second, config.Retry.Backoff(20))
+}
+
+func TestPolicyValidation(t *testing.T) {
+	writeFiles("policy.yaml", []byte("retry:\n  initialBackoff: 1m\n  maxBackoff: 1s\n"), t)
+	defer removeConfigFiles(t)
+
+	var config policyConfig
+	err := swap.Parse(&config, filepath.Join(configPath, "policy.yaml"))
+	require.EqualError(t, err, "Retry: maxBackoff must be greater than or equal to initialBackoff")
+
+	writeFiles("policy.yaml", []byte("breaker:\n  failureThreshold: -1\n"), t)
+	config = policyConfig{}
+	err = swap.Parse(&config, filepath.Join(configPath, "policy.yaml"))
+	require.EqualError(t, err, "Breaker: failureThreshold must be greater than 0")
+}
+
+// portRange validates itself.
+type portRange struct {
+	From int `yaml:"from"`
+	To   int `yaml:"to"`
+}
+
+func (pr portRange) Validate() error {
+	if pr.To < pr.From {
+		return errors.New("to must be greater than or equal to from")
+	}
+	return nil
+}
+
+func TestValidatorOptIn(t *testing.T) {
+	writeFiles("ports.yaml", []byte("ports:\n  from: 9000\n  to: 8000\n"), t)
+	defer removeConfigFiles(t)
+
+	// not validated without the flag
+	var config struct {
+		Ports portRange `yaml:"ports"`
+	}
+	require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "ports.yaml")))
+
+	var validated struct {
+		Ports *portRange `yaml:"ports" swapcp:"validate"`
+	}
+	err := swap.Parse(&validated, filepath.Join(configPath, "ports.yaml"))
+	require.EqualError(t, err, "Ports: to must be greater than or equal to from")
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := swap.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}
+
+	attempts := 0
+	err := policy.Do(context.Background(), func(ctx context.Context) error {
+		if attempts++; attempts < 2 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+
+	attempts = 0
+	err = policy.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return errors.New("permanent")
+	})
+	require.EqualError(t, err, "permanent")
+	require.Equal(t, 3, attempts)
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileHandler(t *testing.T) {
+	createYAML(ToolConfig{TestString: "base"}, "Tool.yml", t)
+	createYAML(ToolConfig{TestString: "staging"}, "Tool.staging.yml", t)
+	createYAML(ToolConfig{TestString: "eu-west-1"}, "Tool.staging.eu-west-1.yml", t)
+	createYAML(ToolConfig{TestString: "blue"}, "Tool.staging.eu-west-1.blue.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool ToolConfigurable
+	}
+
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.SetCurrent(swap.DefaultEnvs.Staging.Tag())
+	ph := swap.NewProfileHandler(swap.NewDimension("region", "SWAP_TEST_REGION"), swap.NewDimension("cluster", ""))
+	builder := swap.NewBuilder(configPath, swap.WithEnvHandler(eh), swap.WithProfileHandler(ph),
+		swap.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	// no dimension values, the environment files only
+	var box Box
+	require.NoError(t, builder.Build(&box))
+	require.Equal(t, "staging", box.Tool.Config.TestString)
+
+	_ = os.Setenv("SWAP_TEST_REGION", "eu-west-1")
+	defer os.Unsetenv("SWAP_TEST_REGION")
+	box = Box{}
+	require.NoError(t, builder.Build(&box))
+	require.Equal(t, "eu-west-1", box.Tool.Config.TestString)
+
+	require.NoError(t, ph.SetCurrent("cluster", "blue"))
+	require.Equal(t, []string{"eu-west-1", "blue"}, ph.Current())
+	box = Box{}
+	require.NoError(t, builder.Build(&box))
+	require.Equal(t, "blue", box.Tool.Config.TestString)
+
+	// the dimensions come in order
+	require.NoError(t, ph.SetCurrent("region", "us-east-1"))
+	box = Box{}
+	require.NoError(t, builder.Build(&box))
+	require.Equal(t, "staging", box.Tool.Config.TestString)
+
+	require.EqualError(t, ph.SetCurrent("zone", "a"), "unknown profile dimension 'zone'")
+
+	swap.SetColoredLogs(false)
+	var out bytes.Buffer
+	box = Box{}
+	require.NoError(t, builder.SetOutput(&out).Build(&box))
+	require.Contains(t, out.String(), "Profile: region: us-east-1, cluster: blue")
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// swappableConn is the state of ToolSwappable.
+type swappableConn struct {
+	config ToolConfig
+	closed int32
+}
+
+func (c *swappableConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+// ToolSwappable is a configurable tool implementing 'Swappable'.
+type ToolSwappable struct {
+	conn atomic.Value
+}
+
+func (t *ToolSwappable) Configure(configFiles ...string) error {
+	conn := &swappableConn{}
+	if err := swap.Parse(&conn.config, configFiles...); err != nil {
+		return err
+	}
+	t.conn.Store(conn)
+	return nil
+}
+
+func (t *ToolSwappable) Swap(next interface{}) (interface{}, error) {
+	return t.conn.Swap(next.(*ToolSwappable).conn.Load()), nil
+}
+
+func (t *ToolSwappable) Conn() *swappableConn {
+	return t.conn.Load().(*swappableConn)
+}
+
+func TestReload(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool     ToolSwappable
+		Services struct {
+			PTRTool *ToolSwappable   `swap:"Tool"`
+			Static  ToolConfigurable `swap:"Tool"`
+		}
+	}
+
+	var test Box
+	builder := swap.NewBuilder(configPath)
+	require.NoError(t, builder.Build(&test))
+
+	old := test.Tool.Conn()
+	oldPTR := test.Services.PTRTool.Conn()
+	require.Equal(t, "1", old.config.TestString)
+
+	createYAML(ToolConfig{TestString: "2"}, "Tool.yml", t)
+	require.NoError(t, builder.Reload(context.Background(), &test, "Tool", 10*time.Millisecond))
+	require.NoError(t, builder.Reload(context.Background(), &test, "Services.PTRTool", 0))
+
+	require.Equal(t, "2", test.Tool.Conn().config.TestString)
+	require.Equal(t, "2", test.Services.PTRTool.Conn().config.TestString)
+	require.Equal(t, int32(1), atomic.LoadInt32(&old.closed))
+	require.Equal(t, int32(1), atomic.LoadInt32(&oldPTR.closed))
+	require.Equal(t, int32(0), atomic.LoadInt32(&test.Tool.Conn().closed))
+
+	// the builder is not locked while the old instance is drained
+	ctx, cancel := context.WithCancel(context.Background())
+	current := test.Tool.Conn()
+	drained := make(chan error)
+	go func() { drained <- builder.Reload(ctx, &test, "Tool", time.Minute) }()
+	require.Eventually(t, func() bool { return test.Tool.Conn() != current }, time.Second, time.Millisecond)
+	done := make(chan error)
+	go func() { done <- builder.Reload(context.Background(), &test, "Services.PTRTool", 0) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Reload blocked by the draining one")
+	}
+	cancel()
+	require.NoError(t, <-drained)
+	require.Equal(t, int32(1), atomic.LoadInt32(&current.closed))
+
+	require.EqualError(t, builder.Reload(context.Background(), &test, "Services.Static", 0),
+		"can't reload 'Services.Static': `Swappable` interface not implemented")
+	require.EqualError(t, builder.Reload(context.Background(), &test, "Unknown", 0),
+		"field 'Unknown' not found")
+}
+
+// closedTools are the tools closed by reloadCloser and ToolSwappableNested.
+var closedTools []string
+
+// reloadCloser is a sub-tool implementing 'io.Closer'.
+type reloadCloser struct {
+	Config ToolConfig
+}
+
+func (c *reloadCloser) Configure(configFiles ...string) error {
+	return swap.Parse(&c.Config, configFiles...)
+}
+
+func (c *reloadCloser) Close() error {
+	closedTools = append(closedTools, "sub:"+c.Config.TestString)
+	return nil
+}
+
+// ToolSwappableNested is a 'Swappable' tool with a sub-tool,
+// it fails to configure with `fail` and its Swap rejects `reject`.
+type ToolSwappableNested struct {
+	Sub    reloadCloser `swap:"Tool"`
+	config ToolConfig
+}
+
+func (t *ToolSwappableNested) Configure(configFiles ...string) error {
+	if err := swap.Parse(&t.config, configFiles...); err != nil {
+		return err
+	}
+	if t.config.TestString == "fail" {
+		return errors.New("fake error for test")
+	}
+	return nil
+}
+
+func (t *ToolSwappableNested) Swap(next interface{}) (interface{}, error) {
+	n := next.(*ToolSwappableNested)
+	if n.config.TestString == "reject" {
+		return nil, errors.New("rejected")
+	}
+	old := &ToolSwappableNested{config: t.config}
+	t.config = n.config
+	return old, nil
+}
+
+func (t *ToolSwappableNested) Close() error {
+	closedTools = append(closedTools, "tool:"+t.config.TestString)
+	return nil
+}
+
+func TestReloadFailures(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool ToolSwappableNested
+	}
+
+	closedTools = nil
+	var test Box
+	builder := swap.NewBuilder(configPath, swap.WithDebug(false))
+	require.NoError(t, builder.Build(&test))
+
+	// the failed next instance is closed, after its sub-tools
+	createYAML(ToolConfig{TestString: "fail"}, "Tool.yml", t)
+	require.Error(t, builder.Reload(context.Background(), &test, "Tool", 0))
+	require.Equal(t, []string{"sub:fail", "tool:fail"}, closedTools)
+
+	// the rejected next instance is closed, after its sub-tools
+	closedTools = nil
+	createYAML(ToolConfig{TestString: "reject"}, "Tool.yml", t)
+	require.EqualError(t, builder.Reload(context.Background(), &test, "Tool", 0), "can't reload 'Tool': rejected")
+	require.Equal(t, []string{"sub:reject", "tool:reject"}, closedTools)
+	require.Equal(t, "1", test.Tool.config.TestString)
+
+	// the old instance is closed, the next sub-tools are shut down with the toolbox
+	closedTools = nil
+	createYAML(ToolConfig{TestString: "2"}, "Tool.yml", t)
+	require.NoError(t, builder.Reload(context.Background(), &test, "Tool", 0))
+	require.Equal(t, []string{"tool:1"}, closedTools)
+
+	closedTools = nil
+	require.NoError(t, builder.Shutdown(context.Background()))
+	require.Equal(t, []string{"tool:2", "sub:2"}, closedTools)
+}
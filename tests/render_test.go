@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml":            []byte("host: localhost\nport: 80\ndb: {user: root, url: 'postgres://{{.db.user}}@{{.host}}'}\n"),
+		"config/app.production.toml": []byte("host = \"${RENDER_HOST}\"\nport = 443\n[db]\nuser = \"admin\"\n"),
+	})
+
+	require.NoError(t, os.Setenv("RENDER_HOST", "example.com"))
+	defer os.Unsetenv("RENDER_HOST")
+
+	env := swap.NewEnvironment("production", `(production)|(master)`)
+	rendered, err := swap.Render(fsys, env, "config/app")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"config/app.yaml", "config/app.production.toml"}, rendered.Files)
+	require.Equal(t, []string{"db.url", "db.user", "host", "port"}, rendered.Keys())
+	require.Equal(t, []string{"config/app.yaml", "config/app.production.toml"}, rendered.Sources["port"])
+	require.Equal(t, []string{"config/app.yaml"}, rendered.Sources["db.url"])
+
+	data, err := swap.Dump(rendered.Tree, swap.FormatYAML)
+	require.NoError(t, err)
+	require.Equal(t, "db:\n  url: postgres://root@localhost\n  user: admin\nhost: example.com\nport: 443\n", string(data))
+
+	_, err = swap.Render(fsys, nil, "config/missing")
+	require.Error(t, err)
+}
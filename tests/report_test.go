@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool    ToolConfigurable
+		Skipped ToolConfigurable `swap:"-"`
+		Mailer  ToolError        `swap:"degrade"`
+		SubBox  struct {
+			Tool ToolMakeable `swap:"Tool"`
+		}
+	}
+
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(ioutil.Discard, "", 0)))
+	builder.DebugOptions.HideSkipped = true
+
+	var box Box
+	require.NoError(t, builder.Build(&box))
+
+	report := builder.Report()
+	require.Equal(t, builder.EnvHandler.Current().Tag(), report.Environment)
+
+	tool, found := report.Field("Tool")
+	require.True(t, found)
+	require.Equal(t, swap.FieldStateConfigured, tool.State)
+	require.Equal(t, "tests.ToolConfigurable", tool.Type)
+	require.Equal(t, []string{filepath.Join(configPath, "Tool.yml")}, tool.Files)
+	require.Nil(t, tool.Err)
+	require.NotZero(t, tool.Duration)
+
+	// hidden in the debug tree, still reported
+	skipped, found := report.Field("Skipped")
+	require.True(t, found)
+	require.Equal(t, swap.FieldStateSkipped, skipped.State)
+
+	mailer, _ := report.Field("Mailer")
+	require.Equal(t, swap.FieldStateDegraded, mailer.State)
+	require.Error(t, mailer.Err)
+
+	subBox, _ := report.Field("SubBox")
+	require.Equal(t, swap.FieldStateTraversed, subBox.State)
+	made, _ := report.Field("SubBox.Tool")
+	require.Equal(t, swap.FieldStateMade, made.State)
+
+	// sorted by path
+	for i := 1; i < len(report.Fields); i++ {
+		require.Less(t, report.Fields[i-1].Path, report.Fields[i].Path)
+	}
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	var decoded struct {
+		Fields []struct {
+			Path  string
+			State string
+			Err   string
+		}
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	for _, field := range decoded.Fields {
+		if field.Path == "Mailer" {
+			require.Equal(t, "degraded", field.State)
+			require.Equal(t, mailer.Err.Error(), field.Err)
+		}
+	}
+}
+
+func TestDebugFormatJSON(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool    ToolConfigurable
+		Skipped ToolConfigurable `swap:"-"`
+		Mailer  ToolError        `swap:"degrade"`
+	}
+
+	var out bytes.Buffer
+	builder := swap.NewBuilder(configPath, swap.WithLogger(log.New(&out, "", 0)))
+	builder.DebugOptions.Format = swap.DebugFormatJSON
+
+	var box Box
+	require.NoError(t, builder.Build(&box))
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &decoded), line)
+		lines = append(lines, decoded)
+	}
+
+	require.Len(t, lines, 3)
+	require.Equal(t, builder.EnvHandler.Current().Tag(), lines[0]["Environment"])
+	require.Equal(t, "Tool", lines[1]["Path"])
+	require.Equal(t, "configured", lines[1]["State"])
+	require.NotZero(t, lines[1]["Duration"])
+	require.Equal(t, "Mailer", lines[2]["Path"])
+	require.Equal(t, "degraded", lines[2]["State"])
+	require.NotEmpty(t, lines[2]["Err"])
+}
+
+func TestSlog(t *testing.T) {
+	createYAML(ToolConfig{TestString: "0"}, "Tool.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		Tool   ToolConfigurable
+		Mailer ToolError `swap:"degrade"`
+	}
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	builder := swap.NewBuilder(configPath, swap.WithSlog(logger))
+
+	var box Box
+	require.NoError(t, builder.Build(&box))
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &decoded), line)
+		records = append(records, decoded)
+	}
+
+	require.Len(t, records, 3)
+	require.Equal(t, "INFO", records[0]["level"])
+	require.Equal(t, builder.EnvHandler.Current().Tag(), records[0]["environment"])
+	require.Equal(t, "DEBUG", records[1]["level"])
+	require.Equal(t, "Tool", records[1]["path"])
+	require.Equal(t, "configured", records[1]["state"])
+	require.Equal(t, []interface{}{filepath.Join(configPath, "Tool.yml")}, records[1]["files"])
+	require.Equal(t, "WARN", records[2]["level"])
+	require.Equal(t, "Mailer", records[2]["path"])
+	require.NotEmpty(t, records[2]["error"])
+
+	// the handler level filters the fields records
+	out.Reset()
+	logger = slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	box = Box{}
+	require.NoError(t, swap.NewBuilder(configPath, swap.WithSlog(logger)).Build(&box))
+	require.Equal(t, 2, strings.Count(out.String(), "\n"))
+}
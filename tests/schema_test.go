@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	type Node struct {
+		Name     string
+		Children []*Node
+	}
+
+	type Config struct {
+		Host     string        `yaml:"host" swapcp:"required"`
+		Port     int           `swapcp:"default=5432"`
+		Password swap.Secret   `swapcp:"env=DB_PASSWORD,required,desc=the database password"`
+		CA       []byte        `swapcp:"fromfile=certs/ca.pem,required"`
+		Timeout  time.Duration `swapcp:"default=5s"`
+		Ratio    float64
+		Debug    bool
+		Labels   map[string]string
+		Retry    swap.RetryPolicy `yaml:"retry"`
+		Tree     Node
+		Updated  time.Time
+		Release  time.Time `swapcp:"layout=2006-01-02"`
+		Buffer   int64     `swapcp:"bytes,default=10MB"`
+		Endpoint *url.URL
+		Ignored  string `yaml:"-"`
+		private  string
+	}
+
+	data, err := swap.JSONSchema(&Config{})
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	require.Equal(t, "Config", schema["title"])
+	require.Equal(t, false, schema["additionalProperties"])
+	require.Equal(t, []interface{}{"host"}, schema["required"])
+
+	properties := schema["properties"].(map[string]interface{})
+	require.Len(t, properties, 14)
+	require.Equal(t, map[string]interface{}{"type": "integer", "default": float64(5432)}, properties["port"])
+	require.Equal(t, "string", properties["password"].(map[string]interface{})["type"])
+	require.Equal(t, "Overridden by the certs/ca.pem file content.", properties["ca"].(map[string]interface{})["description"])
+	require.Equal(t, "the database password. Overridden by the DB_PASSWORD environment variable.",
+		properties["password"].(map[string]interface{})["description"])
+	require.Equal(t, "5s", properties["timeout"].(map[string]interface{})["default"])
+	require.Equal(t, "number", properties["ratio"].(map[string]interface{})["type"])
+	require.Equal(t, "boolean", properties["debug"].(map[string]interface{})["type"])
+	require.Equal(t, map[string]interface{}{"type": "string", "format": "date-time"}, properties["updated"])
+	require.Equal(t, map[string]interface{}{"type": "string"}, properties["release"])
+	require.Equal(t, map[string]interface{}{"type": []interface{}{"integer", "string"}, "default": "10MB"}, properties["buffer"])
+	require.Equal(t, map[string]interface{}{"type": "string", "format": "uri"}, properties["endpoint"])
+
+	retry := properties["retry"].(map[string]interface{})["properties"].(map[string]interface{})
+	require.Equal(t, float64(3), retry["maxAttempts"].(map[string]interface{})["default"])
+
+	_, err = swap.JSONSchema(nil)
+	require.Error(t, err)
+}
+
+func TestVSCodeSettings(t *testing.T) {
+	require.Equal(t, "# yaml-language-server: $schema=schemas/pg.json", swap.YAMLSchemaModeline("schemas/pg.json"))
+
+	data, err := swap.VSCodeSettings(map[string][]string{
+		"schemas/pg.json": {"config/pg*.yaml", "config/pg*.json"},
+	})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"yaml.schemas": {"schemas/pg.json": ["config/pg*.yaml"]},
+		"json.schemas": [{"fileMatch": ["config/pg*.json"], "url": "schemas/pg.json"}]
+	}`, string(data))
+}
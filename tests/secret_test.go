@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type secretConfig struct {
+	User     string
+	Password swap.Secret
+	Token    swap.Secret `swapcp:"env=SWAP_TEST_TOKEN"`
+	Key      swap.Secret `swapcp:"default=default-key"`
+	Required swap.Secret `swapcp:"required"`
+}
+
+func TestSecret(t *testing.T) {
+	writeFiles("secret.yaml", []byte("user: admin\npassword: yaml-pass\nrequired: set\n"), t)
+	writeFiles("secret.json", []byte(`{"Password": "json-pass", "Required": "set"}`), t)
+	writeFiles("secret.toml", []byte("Password = \"toml-pass\"\nRequired = \"set\"\n"), t)
+	writeFiles("missing.yaml", []byte("user: admin\n"), t)
+	defer removeConfigFiles(t)
+
+	require.NoError(t, os.Setenv("SWAP_TEST_TOKEN", "env-token"))
+	defer os.Unsetenv("SWAP_TEST_TOKEN")
+
+	for ext, password := range map[string]string{"yaml": "yaml-pass", "json": "json-pass", "toml": "toml-pass"} {
+		var config secretConfig
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "secret."+ext)))
+		require.Equal(t, password, config.Password.Reveal())
+		require.Equal(t, "env-token", config.Token.Reveal())
+		require.Equal(t, "default-key", config.Key.Reveal())
+
+		printed := fmt.Sprintf("%v %+v %#v %s", config, config, config, config.Password)
+		jsonData, err := json.Marshal(config)
+		require.NoError(t, err)
+		yamlData, err := yaml.Marshal(config)
+		require.NoError(t, err)
+		for _, output := range []string{printed, string(jsonData), string(yamlData)} {
+			require.NotContains(t, output, password)
+			require.NotContains(t, output, "env-token")
+			require.Contains(t, output, "*****")
+		}
+	}
+
+	var config secretConfig
+	err := swap.Parse(&config, filepath.Join(configPath, "missing.yaml"))
+	require.EqualError(t, err, "Required: required")
+
+	secret := swap.NewSecret("s3cr3t")
+	require.Equal(t, "s3cr3t", secret.Reveal())
+	require.True(t, swap.NewSecret("").IsZero())
+	require.False(t, strings.Contains(fmt.Sprint(secret), "s3cr3t"))
+}
+
+type secretOfConfig struct {
+	Password swap.SecretOf[string]
+	Port     swap.SecretOf[int]
+	Keys     swap.SecretOf[map[string]string]
+	Cert     swap.SecretOf[[]byte]        `swapcp:"env=SWAP_TEST_CERT,base64"`
+	TTL      swap.SecretOf[time.Duration] `swapcp:"env=SWAP_TEST_TTL"`
+	Token    swap.SecretOf[string]        `swapcp:"env=SWAP_TEST_TOKEN,default=p4ss: #word"`
+	Required swap.SecretOf[string]        `swapcp:"required"`
+}
+
+func TestSecretOf(t *testing.T) {
+	writeFiles("secret_of.yaml", []byte("password: yaml-pass\nport: 5432\nkeys: {a: yaml-key}\nrequired: set\n"), t)
+	writeFiles("secret_of.json", []byte(`{"Password": "json-pass", "Port": 5432, "Keys": {"a": "json-key"}, "Required": "set"}`), t)
+	writeFiles("secret_of.toml", []byte("Password = \"toml-pass\"\nPort = \"5432\"\nRequired = \"set\"\n"), t)
+	writeFiles("missing.yaml", []byte("password: yaml-pass\n"), t)
+	defer removeConfigFiles(t)
+
+	require.NoError(t, os.Setenv("SWAP_TEST_CERT", "LS0tLS1CRUdJTi0tLS0t"))
+	defer os.Unsetenv("SWAP_TEST_CERT")
+	require.NoError(t, os.Setenv("SWAP_TEST_TTL", "90s"))
+	defer os.Unsetenv("SWAP_TEST_TTL")
+
+	for ext, password := range map[string]string{"yaml": "yaml-pass", "json": "json-pass", "toml": "toml-pass"} {
+		var config secretOfConfig
+		require.NoError(t, swap.Parse(&config, filepath.Join(configPath, "secret_of."+ext)), ext)
+		require.Equal(t, password, config.Password.Value())
+		require.Equal(t, 5432, config.Port.Value())
+		require.Equal(t, []byte("-----BEGIN-----"), config.Cert.Value())
+		require.Equal(t, 90*time.Second, config.TTL.Value())
+		require.Equal(t, "p4ss: #word", config.Token.Value())
+
+		printed := fmt.Sprintf("%v %+v %#v %s", config, config, config, config.Password)
+		jsonData, err := json.Marshal(config)
+		require.NoError(t, err)
+		yamlData, err := yaml.Marshal(config)
+		require.NoError(t, err)
+		for _, output := range []string{printed, string(jsonData), string(yamlData)} {
+			require.NotContains(t, output, password)
+			require.NotContains(t, output, "5432")
+			require.NotContains(t, output, "BEGIN")
+			require.Contains(t, output, "*****")
+		}
+	}
+
+	var config secretOfConfig
+	err := swap.Parse(&config, filepath.Join(configPath, "missing.yaml"))
+	require.EqualError(t, err, "Required: required")
+
+	secret := swap.NewSecretOf([]string{"a", "b"})
+	require.Equal(t, []string{"a", "b"}, secret.Value())
+	require.False(t, secret.IsZero())
+	require.True(t, swap.SecretOf[int]{}.IsZero())
+}
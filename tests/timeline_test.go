@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeline(t *testing.T) {
+	createYAML(ToolConfig{TestString: "API"}, "API.yml", t)
+	createYAML(ToolConfig{TestString: "DB"}, "DB.yml", t)
+	createYAML(ToolConfig{TestString: "Cache"}, "Cache.yml", t)
+	defer removeConfigFiles(t)
+
+	type Box struct {
+		API    ToolOrdered `swap:"deps=DB|Nested"`
+		DB     ToolOrdered
+		Nested struct {
+			Cache ToolOrdered
+		}
+		Skipped ToolOrdered `swap:"-"`
+	}
+
+	var test Box
+	builder := swap.NewBuilder(configPath).Concurrency(2)
+	require.NoError(t, builder.Build(&test))
+
+	spans := builder.Timeline()
+	require.Len(t, spans, 4)
+
+	paths := make(map[string]swap.TimelineSpan)
+	for _, span := range spans {
+		paths[span.Path] = span
+	}
+	require.Contains(t, paths, "Nested.Cache")
+	require.NotContains(t, paths, "Skipped")
+	require.False(t, paths["API"].Start.Before(paths["DB"].End))
+
+	var buf bytes.Buffer
+	require.NoError(t, builder.WriteTimeline(&buf))
+
+	var trace struct {
+		TraceEvents []struct {
+			Name string `json:"name"`
+			Ph   string `json:"ph"`
+			Tid  int    `json:"tid"`
+		} `json:"traceEvents"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &trace))
+	require.Len(t, trace.TraceEvents, 4)
+
+	tids := make(map[int]bool)
+	for _, event := range trace.TraceEvents {
+		require.Equal(t, "X", event.Ph)
+		tids[event.Tid] = true
+	}
+	require.True(t, len(tids) > 1, "concurrent fields should be on different threads")
+}
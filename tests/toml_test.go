@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOMLDecoders(t *testing.T) {
+	type Config struct {
+		Host    string
+		Values  []interface{}
+		Release time.Time
+		Opening time.Time
+		Updated time.Time
+		Strict  struct {
+			Timeout time.Duration
+		}
+	}
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.toml": []byte(`host = "localhost"
+values = [1, "two", 3.0]
+release = 2024-01-02
+opening = 2024-01-02T09:30:00
+updated = "2021-02-03T04:05:06Z"
+
+[strict]
+timeout = "1m"
+`),
+		"config/legacy.toml": []byte("host = \"localhost\"\nupdated = \"2021-02-03T04:05:06Z\"\n"),
+	})
+
+	// TOML 1.0: heterogeneous arrays and local dates
+	var config Config
+	require.NoError(t, swap.ParseWithFS(fsys, &config, "config/app"))
+	require.Equal(t, "localhost", config.Host)
+	require.Equal(t, []interface{}{int64(1), "two", 3.0}, config.Values)
+	require.Equal(t, "2024-01-02", config.Release.Format("2006-01-02"))
+	require.Equal(t, "2024-01-02 09:30", config.Opening.Format("2006-01-02 15:04"))
+	require.True(t, time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC).Equal(config.Updated))
+	require.Equal(t, time.Minute, config.Strict.Timeout)
+
+	// the legacy decoder rejects them
+	err := swap.ParseWithOptions(&Config{}, swap.ParseOptions{FileSystem: fsys, LegacyTOML: true}, "config/app")
+	require.Error(t, err)
+
+	config = Config{}
+	require.NoError(t, swap.ParseWithOptions(&config, swap.ParseOptions{FileSystem: fsys, LegacyTOML: true}, "config/legacy"))
+	require.True(t, time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC).Equal(config.Updated))
+
+	config = Config{}
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(fsys), swap.WithLegacyTOML(true))
+	require.Error(t, builder.Parse(&config, "config/app"))
+
+	// strict mode
+	fsys = swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.toml": []byte("host = \"localhost\"\nhots = \"x\"\n[strict]\ntimeot = \"1s\"\n"),
+	})
+	for _, legacy := range []bool{false, true} {
+		err = swap.ParseWithOptions(&Config{}, swap.ParseOptions{FileSystem: fsys, Strict: true, LegacyTOML: legacy}, "config/app")
+		require.EqualError(t, err, "config/app.toml: unknown keys: hots, strict.timeot")
+	}
+}
+
+func TestTOMLBytesMaps(t *testing.T) {
+	type Server struct {
+		Name string
+	}
+
+	type Config struct {
+		Name   string
+		Labels map[string]string
+		Groups map[string][]Server
+	}
+
+	// the maps not set by the data are kept, as by the YAML decoder
+	config := Config{Labels: map[string]string{"keep": "1"}}
+	require.NoError(t, swap.ParseBytes([]byte(`name = "x"`), swap.FormatTOML, &config))
+	require.Equal(t, "x", config.Name)
+	require.Equal(t, map[string]string{"keep": "1"}, config.Labels)
+
+	// the data maps are merged, array tables and templates included
+	config = Config{
+		Labels: map[string]string{"keep": "1"},
+		Groups: map[string][]Server{"a": {{Name: "old"}}},
+	}
+	data := "name = '{{ \"x\" }}'\n[labels]\nadd = \"2\"\n[[groups.a]]\nname = \"new\"\n"
+	require.NoError(t, swap.ParseBytes([]byte(data), swap.FormatTOML, &config))
+	require.Equal(t, "x", config.Name)
+	require.Equal(t, map[string]string{"keep": "1", "add": "2"}, config.Labels)
+	require.Equal(t, map[string][]Server{"a": {{Name: "new"}}}, config.Groups)
+
+	config = Config{Labels: map[string]string{"keep": "1"}}
+	require.NoError(t, swap.UnmarshalBytes([]byte("name = \"x\"\n[labels]\nadd = \"2\"\n"), &config))
+	require.Equal(t, map[string]string{"keep": "1", "add": "2"}, config.Labels)
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFiles(t *testing.T) {
+	type Config struct {
+		Host string `swapcp:"required"`
+		DB   struct {
+			User     string
+			Password string `swapcp:"required"`
+		}
+		Replicas []struct {
+			Host string
+		}
+		Labels map[string]string
+		Token  string `swapcp:"required,env=VALIDATE_TOKEN"`
+	}
+
+	schema, err := swap.JSONSchema(&Config{})
+	require.NoError(t, err)
+
+	fsys := swap.NewFileSystemMemory(map[string][]byte{
+		"config/app.yaml":            []byte("host: localhost\ndb: {user: root, pasword: x}\nreplicas: [{host: a}, {hots: b}]\nlabels: {any: key}\n"),
+		"config/app.production.yaml": []byte("DB: {Password: s3cr3t}\nport: 80\n"),
+		"config/pg.yaml":             []byte("host: localhost\n"),
+	})
+
+	env := swap.NewEnvironment("production", `(production)|(master)`)
+	violations, err := swap.ValidateFiles(fsys, schema, env, "config/app", "config/pg", "config/missing")
+	require.NoError(t, err)
+
+	var found []string
+	for _, violation := range violations {
+		found = append(found, violation.String())
+	}
+	require.Equal(t, []string{
+		"config/app.yaml: db.pasword: unknown key",
+		"config/app.yaml: replicas.1.hots: unknown key",
+		"config/app.production.yaml: port: unknown key",
+		"config/pg.yaml: db.password: required",
+		"config/missing: missing config file",
+	}, found)
+
+	_, err = swap.ValidateFiles(fsys, []byte("{"), nil, "config/app")
+	require.Error(t, err)
+}
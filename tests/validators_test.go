@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterValidator(t *testing.T) {
+	createYAML(ToolConfig{TestString: "valid"}, "Tool.yaml", t)
+	createYAML(ToolConfig{TestString: "invalid"}, "Other.yaml", t)
+	defer removeConfigFiles(t)
+
+	validated := 0
+	validator := func(v interface{}) error {
+		validated++
+		if v.(ToolConfig).TestString != "valid" {
+			return errors.New("invalid TestString")
+		}
+		return nil
+	}
+
+	type Box struct {
+		Tool     ToolConfigurable
+		Services struct {
+			Tools []*ToolConfigurable
+		}
+	}
+
+	builder := swap.NewBuilder(configPath).RegisterValidator(reflect.TypeOf(ToolConfig{}), validator)
+
+	var test Box
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, 1, validated)
+
+	type BoxInvalid struct {
+		Nested struct {
+			Other ToolConfigurable
+		}
+	}
+
+	err := builder.Build(&BoxInvalid{})
+	require.EqualError(t, err, "Nested.Other.Config: invalid TestString")
+	var fe *swap.FieldError
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "validator", fe.Tag)
+
+	// degraded fields are not validated
+	type BoxDegraded struct {
+		Other ToolError `swap:"degrade"`
+	}
+
+	require.NoError(t, swap.NewBuilder(configPath).
+		RegisterValidator(reflect.TypeOf(ToolError{}), func(interface{}) error { return errors.New("zero") }).
+		Build(&BoxDegraded{}))
+
+	var config ToolConfig
+	require.EqualError(t, builder.Parse(&config, configPath+"/Other.yaml"), "invalid TestString")
+}
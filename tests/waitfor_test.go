@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// boxWaitingFor returns a toolBox whose Tool1 field has the given tag.
+func boxWaitingFor(tag string) interface{} {
+	return reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "Tool1",
+		Type: reflect.TypeOf(ToolConfigurable{}),
+		Tag:  reflect.StructTag(`swap:"` + tag + `"`),
+	}})).Interface()
+}
+
+func TestBoxWaitFor(t *testing.T) {
+	createYAML(ToolConfig{TestString: "1"}, "Tool1.yml", t)
+	defer removeConfigFiles(t)
+
+	// reserve a free port, then listen on it later
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		if listener, err := net.Listen("tcp", addr); err == nil {
+			defer listener.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+
+	builder := swap.NewBuilder(configPath, swap.WithDebug(false))
+
+	start := time.Now()
+	box := boxWaitingFor("waitfor=tcp://" + addr + "|" + api.URL + "/health,timeout=5s")
+	require.NoError(t, builder.Build(box))
+	require.True(t, time.Since(start) >= 500*time.Millisecond)
+	require.Equal(t, "1", reflect.ValueOf(box).Elem().Field(0).Interface().(ToolConfigurable).Config.TestString)
+
+	api.Close()
+	err = builder.Build(boxWaitingFor("waitfor=" + api.URL + ",timeout=300ms"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timeout waiting for '"+api.URL+"'")
+
+	err = builder.Build(boxWaitingFor("waitfor=udp://" + addr))
+	require.EqualError(t, err, "Tool1: unsupported scheme for 'udp://"+addr+"', must be one of: tcp, http, https")
+
+	// malformed timeout
+	err = builder.Build(boxWaitingFor("waitfor=tcp://" + addr + ",timeout=30"))
+	require.EqualError(t, err, `Tool1: invalid timeout '30': time: missing unit in duration "30"`)
+	var fe *swap.FieldError
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "timeout", fe.Tag)
+}
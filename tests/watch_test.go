@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/stretchr/testify/require"
+)
+
+// etagFS is a swap.ConditionalFileSystem reading the files from an HTTP server.
+type etagFS struct {
+	url string
+}
+
+func (e etagFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not supported")}
+}
+
+func (e etagFS) ReadFile(name string) ([]byte, error) {
+	data, _, err := e.ReadFileIfModified(name, "")
+	return data, err
+}
+
+func (e etagFS) ReadFileIfModified(name, version string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, e.url+"/"+name, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(version) > 0 {
+		req.Header.Set("If-None-Match", version)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, version, swap.ErrNotModified
+	case http.StatusNotFound:
+		return nil, "", fs.ErrNotExist
+	case http.StatusOK:
+		data, err := ioutil.ReadAll(resp.Body)
+		return data, resp.Header.Get("ETag"), err
+	default:
+		return nil, "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
+
+// mutableFS is a swap.FileSystem whose files can be changed.
+type mutableFS struct {
+	mutex sync.Mutex
+	files map[string][]byte
+}
+
+func (m *mutableFS) set(name string, data []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if data == nil {
+		delete(m.files, name)
+		return
+	}
+	m.files[name] = data
+}
+
+func (m *mutableFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return swap.NewFileSystemMemory(m.files).ReadDir(name)
+}
+
+func (m *mutableFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return swap.NewFileSystemMemory(m.files).ReadFile(name)
+}
+
+func TestPollingWatcherETag(t *testing.T) {
+	var etag, downloads int32 = 1, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := fmt.Sprintf(`"v%d"`, atomic.LoadInt32(&etag))
+		if r.Header.Get("If-None-Match") == current {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", current)
+		_, _ = w.Write([]byte("port: 80"))
+	}))
+	defer server.Close()
+
+	changes := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := swap.NewPollingWatcher(etagFS{url: server.URL}, 10*time.Millisecond, "config/app.yaml")
+	done := make(chan error)
+	go func() { done <- watcher.Watch(ctx, func() { changes <- struct{}{} }) }()
+
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, changes, 0)
+	// unchanged files are not downloaded again
+	require.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	atomic.StoreInt32(&etag, 2)
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change not detected")
+	}
+
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+}
+
+func TestPollingWatcherContent(t *testing.T) {
+	fsys := &mutableFS{files: map[string][]byte{
+		"config/app.yaml": []byte("port: 80"),
+	}}
+
+	changes := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := swap.NewPollingWatcher(fsys, 10*time.Millisecond, "config")
+	go func() { _ = watcher.Watch(ctx, func() { changes <- struct{}{} }) }()
+
+	expectChange := func(change func()) {
+		time.Sleep(30 * time.Millisecond)
+		require.Len(t, changes, 0)
+		change()
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("change not detected")
+		}
+	}
+
+	// modified, added and removed files
+	expectChange(func() { fsys.set("config/app.yaml", []byte("port: 8080")) })
+	expectChange(func() { fsys.set("config/app.production.yaml", []byte("port: 443")) })
+	expectChange(func() { fsys.set("config/app.yaml", nil) })
+}
@@ -0,0 +1,121 @@
+package swap
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Build timeline ------------------------------------------------------------------------------------------------------
+
+// TimelineSpan is the time spent building a field,
+// sub-fields time included.
+type TimelineSpan struct {
+	// Path is the field path from the root object (eg.: `MediaProcessing.Pictures`).
+	Path  string
+	Type  string
+	Start time.Time
+	End   time.Time
+	Err   error
+}
+
+// Duration returns the span duration.
+func (ts TimelineSpan) Duration() time.Duration {
+	return ts.End.Sub(ts.Start)
+}
+
+// addSpan record a timeline span.
+func (s *Builder) addSpan(span TimelineSpan) {
+	s.timelineMutex.Lock()
+	defer s.timelineMutex.Unlock()
+
+	s.timeline = append(s.timeline, span)
+}
+
+// Timeline returns the spans recorded during the last Build,
+// sorted by start time.
+func (s *Builder) Timeline() []TimelineSpan {
+	s.timelineMutex.Lock()
+	defer s.timelineMutex.Unlock()
+
+	spans := make([]TimelineSpan, len(s.timeline))
+	copy(spans, s.timeline)
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].Start.Equal(spans[j].Start) {
+			return spans[i].End.After(spans[j].End)
+		}
+		return spans[i].Start.Before(spans[j].Start)
+	})
+	return spans
+}
+
+// chromeTraceEvent is a complete event ("ph": "X") of the
+// Chrome Trace Event Format, timestamps are in microseconds.
+type chromeTraceEvent struct {
+	Name string            `json:"name"`
+	Cat  string            `json:"cat"`
+	Ph   string            `json:"ph"`
+	Ts   int64             `json:"ts"`
+	Dur  int64             `json:"dur"`
+	Pid  int               `json:"pid"`
+	Tid  int               `json:"tid"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// WriteTimeline writes the last Build timeline in the Chrome Trace Event Format,
+// it can be opened with chrome://tracing, Perfetto or speedscope.
+// Concurrently built fields are spread on different threads (tid).
+func (s *Builder) WriteTimeline(w io.Writer) error {
+	spans := s.Timeline()
+
+	events := make([]chromeTraceEvent, 0, len(spans))
+	var origin time.Time
+	if len(spans) > 0 {
+		origin = spans[0].Start
+	}
+
+	// lanes hold the stack of open spans for every thread,
+	// a span goes in the first lane where the last open span is its parent field.
+	var lanes [][]TimelineSpan
+	for _, span := range spans {
+		tid := -1
+		for i := range lanes {
+			stack := lanes[i]
+			for len(stack) > 0 && !stack[len(stack)-1].End.After(span.Start) {
+				stack = stack[:len(stack)-1]
+			}
+			lanes[i] = stack
+			if len(stack) == 0 || strings.HasPrefix(span.Path, stack[len(stack)-1].Path+".") {
+				tid = i
+				break
+			}
+		}
+		if tid < 0 {
+			lanes = append(lanes, nil)
+			tid = len(lanes) - 1
+		}
+		lanes[tid] = append(lanes[tid], span)
+
+		event := chromeTraceEvent{
+			Name: span.Path,
+			Cat:  "swap",
+			Ph:   "X",
+			Ts:   span.Start.Sub(origin).Microseconds(),
+			Dur:  span.Duration().Microseconds(),
+			Pid:  1,
+			Tid:  tid + 1,
+			Args: map[string]string{"type": span.Type},
+		}
+		if span.Err != nil {
+			event.Args["error"] = span.Err.Error()
+		}
+		events = append(events, event)
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string             `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
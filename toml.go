@@ -0,0 +1,79 @@
+package swap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	legacytoml "github.com/BurntSushi/toml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// TOML decoding -------------------------------------------------------------------------------------------------------
+
+// unmarshalTOMLData decodes the TOML data into v with github.com/pelletier/go-toml/v2 (TOML 1.0),
+// or with github.com/BurntSushi/toml, the decoder of the former versions, if legacy.
+// strict make the unknown keys an error.
+func unmarshalTOMLData(data []byte, v interface{}, strict, legacy bool) error {
+	if legacy {
+		md, err := legacytoml.Decode(string(data), v)
+		if err != nil || !strict {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+		}
+		return nil
+	}
+
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+
+	var missing *toml.StrictMissingError
+	if errors.As(err, &missing) {
+		keys := make([]string, len(missing.Errors))
+		for i, e := range missing.Errors {
+			keys[i] = strings.Join(e.Key(), ".")
+		}
+		return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+	return err
+}
+
+// decodeTOMLTree decodes the TOML data tree,
+// the files rejected by the TOML 1.0 decoder are decoded by the legacy one.
+func decodeTOMLTree(data []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	err := toml.Unmarshal(data, &tree)
+	if err != nil {
+		tree = nil
+		if _, legacyErr := legacytoml.Decode(string(data), &tree); legacyErr == nil {
+			return tree, nil
+		}
+	}
+	return tree, err
+}
+
+// resetMaps set the map and the struct map fields to nil, recursively.
+func resetMaps(v reflect.Value) {
+	v = reflect.Indirect(v)
+	switch {
+	case v.Kind() == reflect.Map && v.CanSet():
+		v.Set(reflect.Zero(v.Type()))
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if fv := v.Field(i); fv.CanSet() {
+				resetMaps(fv)
+			}
+		}
+	}
+}
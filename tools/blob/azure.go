@@ -0,0 +1,197 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const azureVersion = "2020-10-02"
+
+// azure is a Bucket backed by the Azure Blob Storage REST API,
+// requests are signed with the account Shared Key.
+type azure struct {
+	endpoint  *url.URL
+	container string
+	account   string
+	key       []byte
+	client    *http.Client
+}
+
+func openAzure(config Config) (Bucket, error) {
+	if len(config.Bucket) == 0 {
+		return nil, errors.New("missing `bucket` (the container name) for the azure blob store")
+	}
+	if len(config.AccessKey) == 0 || len(config.SecretKey) == 0 {
+		return nil, errors.New("missing `accessKey` (the account name) or `secretKey` for the azure blob store")
+	}
+	key, err := base64.StdEncoding.DecodeString(config.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure account key: %s", err.Error())
+	}
+	if len(config.Endpoint) == 0 {
+		config.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", config.AccessKey)
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &azure{
+		endpoint:  endpoint,
+		container: config.Bucket,
+		account:   config.AccessKey,
+		key:       key,
+		client:    &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (a *azure) Get(ctx context.Context, key string) ([]byte, error) {
+	return a.do(ctx, http.MethodGet, key, nil, nil)
+}
+
+func (a *azure) Put(ctx context.Context, key string, data []byte) error {
+	_, err := a.do(ctx, http.MethodPut, key, nil, data)
+	return err
+}
+
+func (a *azure) Delete(ctx context.Context, key string) error {
+	_, err := a.do(ctx, http.MethodDelete, key, nil, nil)
+	return err
+}
+
+func (a *azure) List(ctx context.Context, prefix string) (keys []string, err error) {
+	query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+	for {
+		// a new result for every page, xml.Unmarshal appends to slices
+		var result struct {
+			Blobs struct {
+				Blob []struct {
+					Name string
+				}
+			}
+			NextMarker string
+		}
+
+		var body []byte
+		if body, err = a.do(ctx, http.MethodGet, "", query, nil); err != nil {
+			return nil, err
+		}
+		if err = xml.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		for _, b := range result.Blobs.Blob {
+			keys = append(keys, b.Name)
+		}
+		if len(result.NextMarker) == 0 {
+			return keys, nil
+		}
+		query.Set("marker", result.NextMarker)
+	}
+}
+
+func (a *azure) do(ctx context.Context, method, key string, query url.Values, data []byte) ([]byte, error) {
+	u := *a.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + a.container
+	if len(key) > 0 {
+		u.Path += "/" + key
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureVersion)
+	if method == http.MethodPut {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+	}
+	a.sign(req, len(data))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		return nil, errors.New(resp.Status + ": " + string(body))
+	}
+	return body, nil
+}
+
+// sign add the Shared Key Authorization header.
+func (a *azure) sign(req *http.Request, contentLength int) {
+	length := ""
+	if contentLength > 0 {
+		length = strconv.Itoa(contentLength)
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name)
+		}
+	}
+	sort.Strings(msHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range msHeaders {
+		canonicalHeaders.WriteString(name + ":" + req.Header.Get(name) + "\n")
+	}
+
+	canonicalResource := "/" + a.account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		canonicalResource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		"", // Content-Encoding
+		"", // Content-Language
+		length,
+		"", // Content-MD5
+		req.Header.Get("Content-Type"),
+		"", // Date
+		"", // If-Modified-Since
+		"", // If-Match
+		"", // If-None-Match
+		"", // If-Unmodified-Since
+		"", // Range
+		canonicalHeaders.String() + canonicalResource,
+	}, "\n")
+
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Authorization", "SharedKey "+a.account+":"+signature)
+}
@@ -0,0 +1,155 @@
+// Package blob is a swap tool exposing a uniform blob-store interface.
+//
+// The backend is chosen by the `kind` key in the config files,
+// so that, for instance, a local directory can be used in development
+// and an S3 bucket in production:
+//
+//	# Store.yaml
+//	kind: local
+//	path: ./data
+//
+//	# Store.production.yaml
+//	kind: s3
+//	bucket: my-bucket
+//	region: eu-west-1
+//
+// The `local`, `s3`, `gcs` and `azure` kinds are built-in,
+// any other implementation can be registered with Register.
+// The config is parsed by the builder once the Store is registered:
+//
+//	blob.RegisterStore(builder)
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oblq/swap"
+)
+
+// ErrNotFound is returned when the requested key does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// Bucket is the uniform blob-store interface.
+type Bucket interface {
+	// Get returns the content of the given key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put creates or replaces the given key.
+	Put(ctx context.Context, key string, data []byte) error
+	// Delete removes the given key, ErrNotFound is returned if it does not exist.
+	Delete(ctx context.Context, key string) error
+	// List returns all the keys with the given prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Config is the blob store configuration,
+// only the keys relevant to the chosen kind are used.
+type Config struct {
+	// Kind is the backend kind: local, s3, gcs, azure or a registered one.
+	Kind string `swapcp:"env=BLOB_KIND,required" yaml:"kind" json:"kind" toml:"kind"`
+
+	// Path is the root directory for the `local` kind.
+	Path string `yaml:"path" json:"path" toml:"path"`
+
+	// Bucket is the bucket name (the container name for `azure`).
+	Bucket string `yaml:"bucket" json:"bucket" toml:"bucket"`
+
+	// Endpoint optionally override the default service endpoint
+	// (eg.: for S3 compatible services), scheme included.
+	Endpoint string `yaml:"endpoint" json:"endpoint" toml:"endpoint"`
+
+	// Region is the bucket region.
+	Region string `yaml:"region" json:"region" toml:"region"`
+
+	// AccessKey is the access key id (the account name for `azure`).
+	AccessKey string `swapcp:"env=BLOB_ACCESS_KEY" yaml:"accessKey" json:"accessKey" toml:"accessKey"`
+
+	// SecretKey is the secret access key (the base64 account key for `azure`).
+	SecretKey string `swapcp:"env=BLOB_SECRET_KEY" yaml:"secretKey" json:"secretKey" toml:"secretKey"`
+}
+
+// Opener return a Bucket for the given config.
+type Opener func(config Config) (Bucket, error)
+
+var (
+	openers      = make(map[string]Opener)
+	openersMutex sync.RWMutex
+)
+
+// Register register an Opener for the given kind,
+// an already registered kind will be replaced.
+func Register(kind string, opener Opener) {
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+
+	openers[strings.ToLower(kind)] = opener
+}
+
+// Kinds returns the registered kinds.
+func Kinds() []string {
+	openersMutex.RLock()
+	defer openersMutex.RUnlock()
+
+	kinds := make([]string, 0, len(openers))
+	for kind := range openers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Open returns the Bucket for config.Kind.
+func Open(config Config) (Bucket, error) {
+	openersMutex.RLock()
+	opener, ok := openers[strings.ToLower(config.Kind)]
+	openersMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown blob store kind: '%s', must be one of: %s",
+			config.Kind, strings.Join(Kinds(), ", "))
+	}
+	return opener(config)
+}
+
+func init() {
+	Register("local", openLocal)
+	Register("s3", openS3)
+	Register("gcs", openGCS)
+	Register("azure", openAzure)
+}
+
+// Store is the blob store tool, it implements the Bucket
+// interface through the configured backend.
+type Store struct {
+	Config Config
+
+	Bucket
+}
+
+// RegisterStore registers the Store factory on the builder,
+// so that the config is parsed by the builder.
+// Otherwise, Configure uses the package level swap.Parse.
+func RegisterStore(builder *swap.Builder) {
+	builder.RegisterType(reflect.TypeOf(Store{}), func(configFiles ...string) (interface{}, error) {
+		s := &Store{}
+		return s, s.configure(builder.Parse, configFiles)
+	})
+}
+
+// Configure is the swap 'Configurable' interface implementation.
+func (s *Store) Configure(configFiles ...string) error {
+	return s.configure(swap.Parse, configFiles)
+}
+
+func (s *Store) configure(parse func(interface{}, ...string) error, configFiles []string) (err error) {
+	if err = parse(&s.Config, configFiles...); err != nil {
+		return err
+	}
+	s.Bucket, err = Open(s.Config)
+	return err
+}
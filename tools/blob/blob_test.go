@@ -0,0 +1,211 @@
+package blob_test
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/oblq/swap"
+	"github.com/oblq/swap/tools/blob"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryBucket map[string][]byte
+
+func (m memoryBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	if data, ok := m[key]; ok {
+		return data, nil
+	}
+	return nil, blob.ErrNotFound
+}
+
+func (m memoryBucket) Put(ctx context.Context, key string, data []byte) error {
+	m[key] = data
+	return nil
+}
+
+func (m memoryBucket) Delete(ctx context.Context, key string) error {
+	if _, ok := m[key]; !ok {
+		return blob.ErrNotFound
+	}
+	delete(m, key)
+	return nil
+}
+
+func (m memoryBucket) List(ctx context.Context, prefix string) (keys []string, err error) {
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+func TestBlobStore(t *testing.T) {
+	configPath := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configPath, "Store.yaml"),
+		[]byte("kind: local\npath: "+filepath.Join(configPath, "data")+"\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configPath, "Store.testing.yaml"),
+		[]byte("kind: memory\n"), 0644))
+
+	ctx := context.Background()
+
+	var test struct {
+		Store blob.Store
+	}
+	require.NoError(t, swap.NewBuilder(configPath).Build(&test))
+
+	require.NoError(t, test.Store.Put(ctx, "dir/file.txt", []byte("swap")))
+	data, err := test.Store.Get(ctx, "dir/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "swap", string(data))
+
+	keys, err := test.Store.List(ctx, "dir/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir/file.txt"}, keys)
+
+	require.NoError(t, test.Store.Delete(ctx, "dir/file.txt"))
+	_, err = test.Store.Get(ctx, "dir/file.txt")
+	require.Equal(t, blob.ErrNotFound, err)
+	require.Equal(t, blob.ErrNotFound, test.Store.Delete(ctx, "dir/file.txt"))
+
+	// the backend is chosen by the environment specific file
+	builder := swap.NewBuilder(configPath)
+	builder.EnvHandler.SetCurrent(swap.DefaultEnvs.Testing.Tag())
+
+	var testEnv struct {
+		Store blob.Store
+	}
+	require.Error(t, builder.Build(&testEnv))
+
+	blob.Register("memory", func(blob.Config) (blob.Bucket, error) {
+		return memoryBucket{}, nil
+	})
+
+	testEnv.Store = blob.Store{}
+	require.NoError(t, builder.Build(&testEnv))
+	require.IsType(t, memoryBucket{}, testEnv.Store.Bucket)
+
+	// the registered store is parsed by the builder, from its FileSystem
+	fsys := fstest.MapFS{"Store.yaml": {Data: []byte("kind: memory\n")}}
+	builder = swap.NewBuilder("./config", swap.WithFileSystem(swap.NewFileSystemFS(fsys, "./config")))
+	var registered struct {
+		Store blob.Store
+	}
+	require.Error(t, builder.Build(&registered))
+	blob.RegisterStore(builder)
+	registered.Store = blob.Store{}
+	require.NoError(t, builder.Build(&registered))
+	require.Equal(t, "memory", registered.Store.Config.Kind)
+	require.IsType(t, memoryBucket{}, registered.Store.Bucket)
+}
+
+func TestBlobS3(t *testing.T) {
+	objects := make(map[string][]byte)
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/"))
+		paths = append(paths, r.URL.EscapedPath())
+
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+		case http.MethodHead:
+			if _, ok := objects[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodDelete:
+			// S3 deletes the missing keys too
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			if r.URL.Query().Get("list-type") == "2" {
+				// two pages
+				if r.URL.Query().Get("continuation-token") == "" {
+					_, _ = w.Write([]byte(`<ListBucketResult><Contents><Key>a.txt</Key></Contents><Contents><Key>b.txt</Key></Contents>` +
+						`<IsTruncated>true</IsTruncated><NextContinuationToken>next</NextContinuationToken></ListBucketResult>`))
+					return
+				}
+				_, _ = w.Write([]byte(`<ListBucketResult><Contents><Key>file.txt</Key></Contents></ListBucketResult>`))
+				return
+			}
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	bucket, err := blob.Open(blob.Config{Kind: "s3", Bucket: "bucket", Endpoint: server.URL, AccessKey: "key", SecretKey: "secret"})
+	require.NoError(t, err)
+
+	require.NoError(t, bucket.Put(ctx, "file.txt", []byte("swap")))
+	data, err := bucket.Get(ctx, "file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "swap", string(data))
+
+	_, err = bucket.Get(ctx, "missing.txt")
+	require.Equal(t, blob.ErrNotFound, err)
+
+	keys, err := bucket.List(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "b.txt", "file.txt"}, keys)
+
+	// keys are sent and signed with the SigV4 canonical URI encoding
+	require.NoError(t, bucket.Put(ctx, "dir/a=b@c:(d)*e!.txt", []byte("swap")))
+	require.Equal(t, "/bucket/dir/a%3Db%40c%3A%28d%29%2Ae%21.txt", paths[len(paths)-1])
+	data, err = bucket.Get(ctx, "dir/a=b@c:(d)*e!.txt")
+	require.NoError(t, err)
+	require.Equal(t, "swap", string(data))
+
+	// deleting a missing key is ErrNotFound, as for the local backend
+	require.Equal(t, blob.ErrNotFound, bucket.Delete(ctx, "missing.txt"))
+	require.NoError(t, bucket.Delete(ctx, "file.txt"))
+	_, err = bucket.Get(ctx, "file.txt")
+	require.Equal(t, blob.ErrNotFound, err)
+
+	// the keys are joined to the endpoint path
+	bucket, err = blob.Open(blob.Config{Kind: "s3", Bucket: "bucket", Endpoint: server.URL + "/storage/", AccessKey: "key", SecretKey: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put(ctx, "file.txt", []byte("swap")))
+	require.Equal(t, "/storage/bucket/file.txt", paths[len(paths)-1])
+
+	_, err = blob.Open(blob.Config{Kind: "s3"})
+	require.Error(t, err)
+	_, err = blob.Open(blob.Config{Kind: "azure", Bucket: "c", AccessKey: "account", SecretKey: "not base64!"})
+	require.Error(t, err)
+}
+
+func TestBlobAzureList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey account:"))
+		require.Equal(t, "/account/container", r.URL.Path)
+
+		// two pages
+		if r.URL.Query().Get("marker") == "" {
+			_, _ = w.Write([]byte(`<EnumerationResults><Blobs><Blob><Name>a.txt</Name></Blob><Blob><Name>b.txt</Name></Blob></Blobs>` +
+				`<NextMarker>next</NextMarker></EnumerationResults>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<EnumerationResults><Blobs><Blob><Name>c.txt</Name></Blob></Blobs><NextMarker/></EnumerationResults>`))
+	}))
+	defer server.Close()
+
+	container, err := blob.Open(blob.Config{Kind: "azure", Bucket: "container", Endpoint: server.URL + "/account",
+		AccessKey: "account", SecretKey: base64.StdEncoding.EncodeToString([]byte("secret"))})
+	require.NoError(t, err)
+
+	keys, err := container.List(context.Background(), "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, keys)
+}
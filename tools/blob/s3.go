@@ -0,0 +1,234 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3 is a Bucket backed by the S3 REST API,
+// requests are signed with AWS Signature Version 4.
+// It also works with S3 compatible services (eg.: MinIO and
+// the GCS interoperability API).
+type s3 struct {
+	endpoint *url.URL
+	bucket   string
+	region   string
+	access   string
+	secret   string
+	client   *http.Client
+}
+
+func openS3(config Config) (Bucket, error) {
+	if len(config.Region) == 0 {
+		config.Region = "us-east-1"
+	}
+	if len(config.Endpoint) == 0 {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	return newS3(config)
+}
+
+// openGCS use the GCS XML API in interoperability mode,
+// AccessKey and SecretKey must be an HMAC key.
+func openGCS(config Config) (Bucket, error) {
+	if len(config.Region) == 0 {
+		config.Region = "auto"
+	}
+	if len(config.Endpoint) == 0 {
+		config.Endpoint = "https://storage.googleapis.com"
+	}
+	return newS3(config)
+}
+
+func newS3(config Config) (Bucket, error) {
+	if len(config.Bucket) == 0 {
+		return nil, fmt.Errorf("missing `bucket` for the %s blob store", config.Kind)
+	}
+	if len(config.AccessKey) == 0 || len(config.SecretKey) == 0 {
+		return nil, fmt.Errorf("missing `accessKey` or `secretKey` for the %s blob store", config.Kind)
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &s3{
+		endpoint: endpoint,
+		bucket:   config.Bucket,
+		region:   config.Region,
+		access:   config.AccessKey,
+		secret:   config.SecretKey,
+		client:   &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (s *s3) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, key, nil, nil)
+}
+
+func (s *s3) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.do(ctx, http.MethodPut, key, nil, data)
+	return err
+}
+
+// Delete returns ErrNotFound for the missing keys, as the other backends,
+// S3 deletes them successfully.
+func (s *s3) Delete(ctx context.Context, key string) error {
+	if _, err := s.do(ctx, http.MethodHead, key, nil, nil); err != nil {
+		return err
+	}
+	_, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	return err
+}
+
+func (s *s3) List(ctx context.Context, prefix string) (keys []string, err error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		// a new result for every page, xml.Unmarshal appends to slices
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+
+		var body []byte
+		if body, err = s.do(ctx, http.MethodGet, "", query, nil); err != nil {
+			return nil, err
+		}
+		if err = xml.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// do sends a path-style request for the given key,
+// relative to the endpoint path (eg.: for S3 compatible services behind a proxy).
+func (s *s3) do(ctx context.Context, method, key string, query url.Values, data []byte) ([]byte, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	u.RawPath = canonicalURI(u.Path)
+	u.RawQuery = strings.Replace(query.Encode(), "+", "%20", -1)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, data, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		return nil, errors.New(resp.Status + ": " + string(body))
+	}
+	return body, nil
+}
+
+// sign add the AWS Signature Version 4 Authorization header.
+func (s *s3) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		strings.Replace(req.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secret), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.access, scope, signedHeaders, signature))
+}
+
+// canonicalURI returns the SigV4 canonical URI of path,
+// every segment is encoded but the AWS unreserved characters (A-Z, a-z, 0-9, `-`, `_`, `.`, `~`).
+func canonicalURI(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		var encoded strings.Builder
+		for _, b := range []byte(segment) {
+			if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') ||
+				b == '-' || b == '_' || b == '.' || b == '~' {
+				encoded.WriteByte(b)
+			} else {
+				fmt.Fprintf(&encoded, "%%%02X", b)
+			}
+		}
+		segments[i] = encoded.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
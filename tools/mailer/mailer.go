@@ -0,0 +1,381 @@
+// Package mailer is a swap tool which sends emails through SMTP.
+//
+// Outside of the production environment the mailer always runs
+// in dry-run mode: messages are composed and printed but never sent,
+// regardless of the configuration files content.
+// The environment is the builder's one once the mailer is registered:
+//
+//	mailer.Register(builder)
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oblq/swap"
+)
+
+// Supported TLS modes.
+const (
+	// TLSNone send emails in plain text.
+	TLSNone = "none"
+	// TLSStartTLS upgrade the connection with the STARTTLS command.
+	TLSStartTLS = "starttls"
+	// TLSImplicit use an implicit TLS connection (eg.: port 465).
+	TLSImplicit = "tls"
+)
+
+// Config is the mailer configuration.
+type Config struct {
+	Host string `swapcp:"env=SMTP_HOST,required" yaml:"host" json:"host" toml:"host"`
+	Port int    `swapcp:"env=SMTP_PORT,default=587" yaml:"port" json:"port" toml:"port"`
+
+	Username string `swapcp:"env=SMTP_USERNAME" yaml:"username" json:"username" toml:"username"`
+	Password string `swapcp:"env=SMTP_PASSWORD" yaml:"password" json:"password" toml:"password"`
+
+	// TLS is the TLS mode: `none`, `starttls` or `tls`.
+	TLS                string `swapcp:"default=starttls" yaml:"tls" json:"tls" toml:"tls"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" json:"insecureSkipVerify" toml:"insecureSkipVerify"`
+
+	// From is the default sender address.
+	From string `swapcp:"required" yaml:"from" json:"from" toml:"from"`
+
+	// TemplatesDir contains the message templates (html/template),
+	// files with the `.html` extension are sent as HTML.
+	// It is read from the builder FileSystem once the Mailer is registered.
+	TemplatesDir string `yaml:"templatesDir" json:"templatesDir" toml:"templatesDir"`
+
+	// DryRun prevent the messages from being sent,
+	// it is always true outside of the production environment.
+	DryRun bool `yaml:"dryRun" json:"dryRun" toml:"dryRun"`
+}
+
+// Message is an email message.
+type Message struct {
+	// From override the configured sender address.
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+
+	// Body is the message body, ignored if Template is provided.
+	Body string
+	// HTML send the Body as text/html.
+	HTML bool
+
+	// Template is the name of the template file in TemplatesDir
+	// executed with Data to produce the message body.
+	Template string
+	Data     interface{}
+}
+
+// Mailer is the SMTP email sender.
+type Mailer struct {
+	Config Config
+
+	templates *template.Template
+	dryRun    bool
+	output    io.Writer
+
+	mutex sync.Mutex
+}
+
+// Register registers the Mailer factory on the builder,
+// so that the active environment is the builder's one
+// and the config is parsed by the builder.
+// Otherwise, Configure uses the default environments.
+func Register(builder *swap.Builder) {
+	builder.RegisterType(reflect.TypeOf(Mailer{}), func(configFiles ...string) (interface{}, error) {
+		m := &Mailer{}
+		return m, m.configure(builder.EnvHandler, builder.FileSystem(), builder.Parse, configFiles)
+	})
+}
+
+// Configure is the swap 'Configurable' interface implementation.
+func (m *Mailer) Configure(configFiles ...string) error {
+	return m.configure(swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice()), swap.NewFileSystemLocal(), swap.Parse, configFiles)
+}
+
+func (m *Mailer) configure(eh *swap.EnvironmentHandler, fsys swap.FileSystem, parse func(interface{}, ...string) error, configFiles []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := parse(&m.Config, configFiles...); err != nil {
+		return err
+	}
+
+	switch m.Config.TLS {
+	case TLSNone, TLSStartTLS, TLSImplicit:
+	default:
+		return fmt.Errorf("unknown tls mode: '%s', must be one of: %s, %s, %s",
+			m.Config.TLS, TLSNone, TLSStartTLS, TLSImplicit)
+	}
+
+	m.templates = nil
+	if len(m.Config.TemplatesDir) > 0 {
+		tpl, err := parseTemplates(fsys, m.Config.TemplatesDir)
+		if err != nil {
+			return err
+		}
+		m.templates = tpl
+	}
+
+	m.dryRun = m.Config.DryRun || eh.Current().Tag() != swap.DefaultEnvs.Production.Tag()
+	return nil
+}
+
+// parseTemplates parse the dir files read from fsys, named after their file name.
+func parseTemplates(fsys swap.FileSystem, dir string) (*template.Template, error) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tpl *template.Template
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := fsys.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// as template.ParseFiles, the first file is the root template
+		var t *template.Template
+		if tpl == nil {
+			tpl = template.New(entry.Name())
+			t = tpl
+		} else {
+			t = tpl.New(entry.Name())
+		}
+		if _, err = t.Parse(string(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	if tpl == nil {
+		return nil, fmt.Errorf("no templates found in '%s'", dir)
+	}
+	return tpl, nil
+}
+
+// DryRun returns true if messages are not actually sent.
+func (m *Mailer) DryRun() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.dryRun
+}
+
+// SetDryRunOutput set the writer where messages are printed
+// in dry-run mode, os.Stdout by default.
+func (m *Mailer) SetDryRunOutput(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.output = w
+}
+
+// Send composes and sends the message.
+func (m *Mailer) Send(msg Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(msg.From) == 0 {
+		msg.From = m.Config.From
+	}
+
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return errors.New("no recipients provided")
+	}
+
+	h, err := parseHeaders(msg)
+	if err != nil {
+		return err
+	}
+
+	data, err := m.compose(msg, h)
+	if err != nil {
+		return err
+	}
+
+	var recipients []string
+	for _, addr := range append(append(append([]*mail.Address{}, h.to...), h.cc...), h.bcc...) {
+		recipients = append(recipients, addr.Address)
+	}
+
+	if m.dryRun {
+		out := m.output
+		if out == nil {
+			out = os.Stdout
+		}
+		_, err = fmt.Fprintf(out, "Mailer (dry-run), to: %s\n%s\n", strings.Join(recipients, ", "), data)
+		return err
+	}
+
+	return m.send(h.from.Address, recipients, data)
+}
+
+// headers are the message addresses, parsed with net/mail.
+type headers struct {
+	from        *mail.Address
+	to, cc, bcc []*mail.Address
+}
+
+// parseHeaders parse the message addresses,
+// the line breaks are rejected so that no header can be injected.
+func parseHeaders(msg Message) (h headers, err error) {
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return h, errors.New("invalid subject: line breaks are not allowed")
+	}
+	if h.from, err = parseAddress("From", msg.From); err != nil {
+		return
+	}
+	if h.to, err = parseAddresses("To", msg.To); err != nil {
+		return
+	}
+	if h.cc, err = parseAddresses("Cc", msg.Cc); err != nil {
+		return
+	}
+	h.bcc, err = parseAddresses("Bcc", msg.Bcc)
+	return
+}
+
+func parseAddresses(header string, addresses []string) ([]*mail.Address, error) {
+	parsed := make([]*mail.Address, 0, len(addresses))
+	for _, address := range addresses {
+		addr, err := parseAddress(header, address)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, addr)
+	}
+	return parsed, nil
+}
+
+func parseAddress(header, address string) (*mail.Address, error) {
+	if strings.ContainsAny(address, "\r\n") {
+		return nil, fmt.Errorf("invalid %s address %q: line breaks are not allowed", header, address)
+	}
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s address '%s': %s", header, address, err.Error())
+	}
+	return addr, nil
+}
+
+// joinAddresses returns the header value of the addresses.
+func joinAddresses(addresses []*mail.Address) string {
+	values := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		values = append(values, addr.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+// compose returns the raw message, headers included.
+func (m *Mailer) compose(msg Message, h headers) ([]byte, error) {
+	body := msg.Body
+	html := msg.HTML
+
+	if len(msg.Template) > 0 {
+		if m.templates == nil {
+			return nil, fmt.Errorf("template '%s' not found: no templates dir configured", msg.Template)
+		}
+		var buf bytes.Buffer
+		if err := m.templates.ExecuteTemplate(&buf, msg.Template, msg.Data); err != nil {
+			return nil, err
+		}
+		body = buf.String()
+		html = strings.EqualFold(filepath.Ext(msg.Template), ".html")
+	}
+
+	contentType := "text/plain"
+	if html {
+		contentType = "text/html"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", h.from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", joinAddresses(h.to))
+	if len(h.cc) > 0 {
+		fmt.Fprintf(&buf, "Cc: %s\r\n", joinAddresses(h.cc))
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n\r\n", contentType)
+	buf.WriteString(body)
+
+	return buf.Bytes(), nil
+}
+
+func (m *Mailer) send(from string, recipients []string, data []byte) (err error) {
+	addr := net.JoinHostPort(m.Config.Host, strconv.Itoa(m.Config.Port))
+	tlsConfig := &tls.Config{ServerName: m.Config.Host, InsecureSkipVerify: m.Config.InsecureSkipVerify}
+
+	var client *smtp.Client
+	if m.Config.TLS == TLSImplicit {
+		var conn *tls.Conn
+		if conn, err = tls.Dial("tcp", addr, tlsConfig); err != nil {
+			return err
+		}
+		if client, err = smtp.NewClient(conn, m.Config.Host); err != nil {
+			return err
+		}
+	} else if client, err = smtp.Dial(addr); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.Config.TLS == TLSStartTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if len(m.Config.Username) > 0 {
+		auth := smtp.PlainAuth("", m.Config.Username, m.Config.Password, m.Config.Host)
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		if err = client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,89 @@
+package mailer_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/oblq/swap"
+	"github.com/oblq/swap/tools/mailer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailerDryRun(t *testing.T) {
+	configPath := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configPath, "Mailer.yaml"),
+		[]byte("host: localhost\nfrom: swap@example.com\ntemplatesDir: "+configPath+"\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configPath, "welcome.html"), []byte("<p>Hello {{.}}!</p>"), 0644))
+
+	eh := swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice())
+	eh.SetCurrent(swap.DefaultEnvs.Staging.Tag())
+	builder := swap.NewBuilder(configPath, swap.WithEnvHandler(eh))
+	mailer.Register(builder)
+
+	var test struct {
+		Mailer mailer.Mailer
+	}
+	require.NoError(t, builder.Build(&test))
+	require.Equal(t, 587, test.Mailer.Config.Port)
+	require.Equal(t, mailer.TLSStartTLS, test.Mailer.Config.TLS)
+	require.True(t, test.Mailer.DryRun())
+
+	var out bytes.Buffer
+	test.Mailer.SetDryRunOutput(&out)
+	err := test.Mailer.Send(mailer.Message{To: []string{"to@example.com"}, Subject: "Hi", Template: "welcome.html", Data: "swap"})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "Content-Type: text/html")
+	require.Contains(t, out.String(), "<p>Hello swap!</p>")
+
+	require.Error(t, test.Mailer.Send(mailer.Message{Subject: "no recipients"}))
+
+	// addresses are parsed and the subject encoded
+	out.Reset()
+	require.NoError(t, test.Mailer.Send(mailer.Message{From: "Swap <swap@example.com>", To: []string{"to@example.com"}, Subject: "Ciao è", Body: "hi"}))
+	require.Contains(t, out.String(), "From: \"Swap\" <swap@example.com>\r\n")
+	require.Contains(t, out.String(), "Subject: =?utf-8?q?Ciao_=C3=A8?=\r\n")
+	require.Error(t, test.Mailer.Send(mailer.Message{To: []string{"not an address"}, Body: "hi"}))
+
+	// no header can be injected
+	require.Error(t, test.Mailer.Send(mailer.Message{To: []string{"to@example.com"}, Subject: "Hi\r\nBcc: evil@example.com", Body: "hi"}))
+	require.Error(t, test.Mailer.Send(mailer.Message{To: []string{"to@example.com\r\nBcc: evil@example.com"}, Body: "hi"}))
+	require.Error(t, test.Mailer.Send(mailer.Message{From: "swap@example.com\nBcc: evil@example.com", To: []string{"to@example.com"}, Body: "hi"}))
+	require.Error(t, test.Mailer.Send(mailer.Message{To: []string{"to@example.com"}, Cc: []string{"cc@example.com\r\nX: y"}, Body: "hi"}))
+
+	// dry-run is disabled only in production, the builder's one
+	eh.SetCurrent(swap.DefaultEnvs.Production.Tag())
+	var prod struct {
+		Mailer *mailer.Mailer
+	}
+	require.NoError(t, builder.Build(&prod))
+	require.False(t, prod.Mailer.DryRun())
+
+	// the templates are read from the builder FileSystem
+	fsys := fstest.MapFS{
+		"Mailer.yaml":            {Data: []byte("host: localhost\nfrom: swap@example.com\ntemplatesDir: config/templates\ndryRun: true\n")},
+		"templates/welcome.html": {Data: []byte("<p>Welcome {{.}}!</p>")},
+	}
+	fsBuilder := swap.NewBuilder("./config", swap.WithFileSystem(swap.NewFileSystemFS(fsys, "./config")), swap.WithEnvHandler(eh))
+	mailer.Register(fsBuilder)
+	var fsTest struct {
+		Mailer mailer.Mailer
+	}
+	require.NoError(t, fsBuilder.Build(&fsTest))
+	out.Reset()
+	fsTest.Mailer.SetDryRunOutput(&out)
+	require.NoError(t, fsTest.Mailer.Send(mailer.Message{To: []string{"to@example.com"}, Template: "welcome.html", Data: "swap"}))
+	require.Contains(t, out.String(), "<p>Welcome swap!</p>")
+
+	// the default environments without the builder
+	m := mailer.Mailer{}
+	require.NoError(t, m.Configure(filepath.Join(configPath, "Mailer.yaml")))
+	require.Equal(t, swap.NewEnvironmentHandler(swap.DefaultEnvs.Slice()).Current().Tag() != swap.DefaultEnvs.Production.Tag(), m.DryRun())
+
+	// wrong tls mode
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configPath, "Mailer.yaml"),
+		[]byte("host: localhost\nfrom: swap@example.com\ntls: wrong\n"), 0644))
+	require.Error(t, m.Configure(filepath.Join(configPath, "Mailer.yaml")))
+}
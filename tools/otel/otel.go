@@ -0,0 +1,263 @@
+// Package otel is a swap tool which sets up the OpenTelemetry
+// tracer and meter providers from environment specific config files,
+// so that the whole observability setup is just one toolbox field:
+//
+//	var ToolBox struct {
+//		// will be configured with `OTel.yaml` and `OTel.<environment>.yaml`
+//		OTel otel.Tool
+//	}
+//
+// The config is parsed by the builder once the tool is registered:
+//
+//	otel.Register(builder)
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/oblq/swap"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Supported OTLP exporter protocols.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// Default OTLP collector endpoints, by protocol.
+const (
+	DefaultEndpointGRPC = "localhost:4317"
+	DefaultEndpointHTTP = "localhost:4318"
+)
+
+// Config is the OpenTelemetry tool configuration.
+type Config struct {
+	// ServiceName is exported as the `service.name` resource attribute.
+	ServiceName string `swapcp:"env=OTEL_SERVICE_NAME,required" yaml:"serviceName" json:"serviceName" toml:"serviceName"`
+
+	// ServiceVersion is exported as the `service.version` resource attribute.
+	ServiceVersion string `yaml:"serviceVersion" json:"serviceVersion" toml:"serviceVersion"`
+
+	// Endpoint is the OTLP collector address (host:port),
+	// `localhost:4317` for grpc and `localhost:4318` for http/protobuf by default.
+	Endpoint string `swapcp:"env=OTEL_EXPORTER_OTLP_ENDPOINT" yaml:"endpoint" json:"endpoint" toml:"endpoint"`
+
+	// Protocol is the OTLP protocol, `grpc` or `http/protobuf`.
+	Protocol string `swapcp:"env=OTEL_EXPORTER_OTLP_PROTOCOL,default=grpc" yaml:"protocol" json:"protocol" toml:"protocol"`
+
+	// Insecure disable the client transport security.
+	Insecure bool `yaml:"insecure" json:"insecure" toml:"insecure"`
+
+	// Headers are sent along with every export request.
+	Headers map[string]string `yaml:"headers" json:"headers" toml:"headers"`
+
+	// SamplingRatio is the ratio of sampled root spans, from 0 to 1,
+	// all spans are sampled when nil.
+	SamplingRatio *float64 `yaml:"samplingRatio" json:"samplingRatio" toml:"samplingRatio"`
+
+	// ResourceAttributes are added to every exported span and metric.
+	ResourceAttributes map[string]string `yaml:"resourceAttributes" json:"resourceAttributes" toml:"resourceAttributes"`
+
+	// DisableTraces does not set up the tracer provider.
+	DisableTraces bool `yaml:"disableTraces" json:"disableTraces" toml:"disableTraces"`
+
+	// DisableMetrics does not set up the meter provider.
+	DisableMetrics bool `yaml:"disableMetrics" json:"disableMetrics" toml:"disableMetrics"`
+}
+
+// Tool holds the OpenTelemetry providers,
+// they are also registered as the otel global ones.
+// The config and the providers are skipped by the builder,
+// they are filled by Configure and Start.
+type Tool struct {
+	Config Config `swap:"-"`
+
+	TracerProvider *sdktrace.TracerProvider `swap:"-"`
+	MeterProvider  *sdkmetric.MeterProvider `swap:"-"`
+}
+
+// Register registers the Tool factory on the builder,
+// so that the config is parsed by the builder.
+// Otherwise, Configure uses the package level swap.Parse.
+func Register(builder *swap.Builder) {
+	builder.RegisterType(reflect.TypeOf(Tool{}), func(configFiles ...string) (interface{}, error) {
+		t := &Tool{}
+		return t, t.configure(builder.Parse, configFiles)
+	})
+}
+
+// Configure is the swap 'Configurable' interface implementation,
+// it parses the config files and starts the providers.
+func (t *Tool) Configure(configFiles ...string) error {
+	return t.configure(swap.Parse, configFiles)
+}
+
+func (t *Tool) configure(parse func(interface{}, ...string) error, configFiles []string) error {
+	if err := parse(&t.Config, configFiles...); err != nil {
+		return err
+	}
+	return t.Start(context.Background())
+}
+
+// Shutdown is the swap 'Shutdowner' interface implementation.
+func (t *Tool) Shutdown(ctx context.Context) error {
+	return t.Stop(ctx)
+}
+
+// Start creates the exporters and the providers
+// and registers them globally.
+func (t *Tool) Start(ctx context.Context) error {
+	protocol := strings.ToLower(t.Config.Protocol)
+	if protocol == "http" {
+		protocol = ProtocolHTTP
+	}
+	if protocol != ProtocolGRPC && protocol != ProtocolHTTP {
+		return fmt.Errorf("unknown otlp protocol: '%s', must be one of: %s, %s",
+			t.Config.Protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+
+	if len(t.Config.Endpoint) == 0 {
+		t.Config.Endpoint = DefaultEndpointGRPC
+		if protocol == ProtocolHTTP {
+			t.Config.Endpoint = DefaultEndpointHTTP
+		}
+	}
+
+	ratio := 1.0
+	if t.Config.SamplingRatio != nil {
+		ratio = *t.Config.SamplingRatio
+	}
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("invalid sampling ratio: %v, must be between 0 and 1", ratio)
+	}
+
+	res, err := t.resource(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !t.Config.DisableTraces {
+		var exporter sdktrace.SpanExporter
+		if exporter, err = t.traceExporter(ctx, protocol); err != nil {
+			return err
+		}
+		t.TracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		)
+		otel.SetTracerProvider(t.TracerProvider)
+	}
+
+	if !t.Config.DisableMetrics {
+		var exporter sdkmetric.Exporter
+		if exporter, err = t.metricExporter(ctx, protocol); err != nil {
+			// do not leak the already started tracer provider
+			_ = t.Stop(ctx)
+			return err
+		}
+		t.MeterProvider = sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+			sdkmetric.WithResource(res),
+		)
+		otel.SetMeterProvider(t.MeterProvider)
+	}
+
+	return nil
+}
+
+// Stop flushes and shuts down the providers.
+func (t *Tool) Stop(ctx context.Context) error {
+	var errs []string
+
+	if t.TracerProvider != nil {
+		if err := t.TracerProvider.Shutdown(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+		t.TracerProvider = nil
+	}
+
+	if t.MeterProvider != nil {
+		if err := t.MeterProvider.Shutdown(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+		t.MeterProvider = nil
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (t *Tool) resource(ctx context.Context) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{attribute.String("service.name", t.Config.ServiceName)}
+	if len(t.Config.ServiceVersion) > 0 {
+		attrs = append(attrs, attribute.String("service.version", t.Config.ServiceVersion))
+	}
+	for k, v := range t.Config.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	return resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attrs...),
+	)
+}
+
+func (t *Tool) traceExporter(ctx context.Context, protocol string) (sdktrace.SpanExporter, error) {
+	if protocol == ProtocolHTTP {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(t.Config.Endpoint),
+			otlptracehttp.WithHeaders(t.Config.Headers),
+		}
+		if t.Config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(t.Config.Endpoint),
+		otlptracegrpc.WithHeaders(t.Config.Headers),
+	}
+	if t.Config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func (t *Tool) metricExporter(ctx context.Context, protocol string) (sdkmetric.Exporter, error) {
+	if protocol == ProtocolHTTP {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(t.Config.Endpoint),
+			otlpmetrichttp.WithHeaders(t.Config.Headers),
+		}
+		if t.Config.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(t.Config.Endpoint),
+		otlpmetricgrpc.WithHeaders(t.Config.Headers),
+	}
+	if t.Config.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
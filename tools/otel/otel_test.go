@@ -0,0 +1,84 @@
+package otel_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/oblq/swap"
+	"github.com/oblq/swap/tools/otel"
+	"github.com/stretchr/testify/require"
+	globalotel "go.opentelemetry.io/otel"
+)
+
+func TestOTel(t *testing.T) {
+	configPath := t.TempDir()
+	configFile := filepath.Join(configPath, "OTel.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`serviceName: swap
+endpoint: localhost:4318
+protocol: http
+insecure: true
+resourceAttributes:
+  deployment.environment: testing
+disableMetrics: true
+`), 0644))
+
+	var test struct {
+		OTel otel.Tool
+	}
+	require.NoError(t, swap.NewBuilder(configPath).Build(&test))
+	require.Equal(t, "http", test.OTel.Config.Protocol)
+	require.Nil(t, test.OTel.Config.SamplingRatio)
+	require.NotNil(t, test.OTel.TracerProvider)
+	require.Nil(t, test.OTel.MeterProvider)
+	require.Equal(t, test.OTel.TracerProvider, globalotel.GetTracerProvider())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, test.OTel.Shutdown(ctx))
+	require.Nil(t, test.OTel.TracerProvider)
+
+	// an explicit zero sampling ratio is kept
+	require.NoError(t, ioutil.WriteFile(configFile,
+		[]byte("serviceName: swap\nprotocol: http\nsamplingRatio: 0\ndisableMetrics: true\n"), 0644))
+	tool := otel.Tool{}
+	require.NoError(t, tool.Configure(configFile))
+	require.Equal(t, otel.DefaultEndpointHTTP, tool.Config.Endpoint)
+	require.NotNil(t, tool.Config.SamplingRatio)
+	require.Equal(t, float64(0), *tool.Config.SamplingRatio)
+	require.NoError(t, tool.Shutdown(ctx))
+
+	// the registered tool is parsed by the builder, from its FileSystem
+	fsys := fstest.MapFS{"OTel.yaml": {
+		Data: []byte("serviceName: swap\nprotocol: http\ndisableMetrics: true\n")}}
+	builder := swap.NewBuilder("./config", swap.WithFileSystem(swap.NewFileSystemFS(fsys, "./config")))
+	var registered struct {
+		OTel otel.Tool
+	}
+	require.Error(t, builder.Build(&registered))
+	otel.Register(builder)
+	registered.OTel = otel.Tool{}
+	require.NoError(t, builder.Build(&registered))
+	require.Equal(t, "swap", registered.OTel.Config.ServiceName)
+	require.Equal(t, otel.DefaultEndpointHTTP, registered.OTel.Config.Endpoint)
+	require.NotNil(t, registered.OTel.TracerProvider)
+	require.NoError(t, registered.OTel.Shutdown(ctx))
+
+	// the default endpoint depends on the protocol
+	tool = otel.Tool{Config: otel.Config{ServiceName: "swap", Protocol: otel.ProtocolGRPC, DisableTraces: true, DisableMetrics: true}}
+	require.NoError(t, tool.Start(ctx))
+	require.Equal(t, otel.DefaultEndpointGRPC, tool.Config.Endpoint)
+
+	// wrong protocol
+	require.NoError(t, ioutil.WriteFile(configFile,
+		[]byte("serviceName: swap\nprotocol: udp\n"), 0644))
+	require.Error(t, (&otel.Tool{}).Configure(configFile))
+
+	// wrong sampling ratio
+	require.NoError(t, ioutil.WriteFile(configFile,
+		[]byte("serviceName: swap\nsamplingRatio: 2\n"), 0644))
+	require.Error(t, (&otel.Tool{}).Configure(configFile))
+}
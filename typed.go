@@ -0,0 +1,482 @@
+package swap
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Typed strings -------------------------------------------------------------------------------------------------------
+
+var (
+	ipType     = reflect.TypeOf(net.IP{})
+	urlType    = reflect.TypeOf(url.URL{})
+	regexpType = reflect.TypeOf(regexp.Regexp{})
+)
+
+// typedValue is a value parsed from a file string, set once the file is unmarshalled.
+type typedValue struct {
+	keys  []string
+	value reflect.Value
+}
+
+// deferredNode replaces the typed values nodes set once the file is unmarshalled.
+type deferredNode struct{}
+
+// parseTypedStrings returns the file data with the typed strings converted:
+// the duration strings (eg.: `"1h30m"`) to nanoseconds for the time.Duration fields
+// in JSON and TOML files (YAML supports them natively), the time strings parsed with the
+// `layout` flag to RFC3339 for the time.Time fields and the byte sizes (eg.: `"10MB"`)
+// to bytes for the integer fields with the `bytes` flag.
+// The url.URL, regexp.Regexp and registered decoders strings are parsed
+// and returned as values to set, since no format can decode them.
+// Data is untouched if nothing changed, ext is the extension of the data format.
+func (p *parser) parseTypedStrings(file, ext string, data []byte, config interface{}) ([]byte, []typedValue, error) {
+	w := typedWalker{
+		tagKey:    p.tagKey,
+		durations: regexpJSON.MatchString(ext) || regexpTOML.MatchString(ext),
+		times:     regexpTOML.MatchString(ext),
+	}
+	if !w.needed(reflect.TypeOf(config), nil, map[reflect.Type]bool{}) {
+		return data, nil, nil
+	}
+
+	tree, err := decodeTree(ext, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tree, err = w.walk(nil, tree, reflect.TypeOf(config), nil); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	if !w.changed {
+		return data, nil, nil
+	}
+	data, err = encodeTree(ext, tree)
+	return data, w.values, err
+}
+
+// setTypedValues set the values parsed by parseTypedStrings.
+func setTypedValues(config interface{}, values []typedValue) error {
+	for _, tv := range values {
+		if err := setKeyPath(reflect.ValueOf(config), tv.keys, tv.value); err != nil {
+			return fmt.Errorf("%s: %s", strings.Join(tv.keys, "."), err.Error())
+		}
+	}
+	return nil
+}
+
+// setKeyPath set the value at the document keys path of v.
+func setKeyPath(v reflect.Value, keys []string, value reflect.Value) error {
+	if len(keys) == 0 {
+		return setIndirect(v, value)
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return fmt.Errorf("nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		sf, found := matchField(v.Type(), keys[0])
+		if !found {
+			return fmt.Errorf("unknown key '%s'", keys[0])
+		}
+		return setKeyPath(v.FieldByIndex(sf.Index), keys[1:], value)
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(keys[0])
+		if err != nil || i >= v.Len() {
+			return fmt.Errorf("invalid index '%s'", keys[0])
+		}
+		return setKeyPath(v.Index(i), keys[1:], value)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(keys[0]).Convert(v.Type().Key())
+		// map elements are not addressable
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if current := v.MapIndex(key); current.IsValid() {
+			elem.Set(current)
+		}
+		if err := setKeyPath(elem, keys[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	default:
+		return fmt.Errorf("can't set key '%s' of %s", keys[0], v.Type())
+	}
+}
+
+// setIndirect set fv to value, allocating the fv pointers
+// and dereferencing the value pointer if needed.
+func setIndirect(fv, value reflect.Value) error {
+	for fv.Kind() == reflect.Ptr && fv.Type() != value.Type() {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	if value.Kind() == reflect.Ptr && fv.Type() == value.Type().Elem() {
+		value = value.Elem()
+	}
+	switch {
+	case value.Type().AssignableTo(fv.Type()):
+		fv.Set(value)
+	case value.Type().ConvertibleTo(fv.Type()):
+		fv.Set(value.Convert(fv.Type()))
+	default:
+		return fmt.Errorf("can't set %s to %s", value.Type(), fv.Type())
+	}
+	return nil
+}
+
+// typedWalker walks a document tree along with the config type.
+type typedWalker struct {
+	tagKey    string
+	durations bool
+	changed   bool
+	values    []typedValue
+
+	// times converts every time string to a time.Time value,
+	// the TOML decoder decodes only the TOML datetimes to time.Time.
+	times bool
+}
+
+// walk converts the node strings, flags are the tag flags of the enclosing field.
+func (w *typedWalker) walk(keys []string, node interface{}, t reflect.Type, flags []string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var err error
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			switch t.Kind() {
+			case reflect.Struct:
+				if sf, found := matchField(t, k); found {
+					value[k], err = w.walk(appendKey(keys, k), v, sf.Type, splitFlags(sf.Tag.Get(w.tagKey)))
+				}
+			case reflect.Map:
+				value[k], err = w.walk(appendKey(keys, k), v, t.Elem(), flags)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, deferred := value[k].(deferredNode); deferred {
+				delete(value, k)
+			}
+		}
+
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, v := range value {
+				if value[i], err = w.walk(appendKey(keys, strconv.Itoa(i)), v, t.Elem(), flags); err != nil {
+					return nil, err
+				}
+				if _, deferred := value[i].(deferredNode); deferred {
+					// the empty object leaves the element untouched
+					value[i] = map[string]interface{}{}
+				}
+			}
+		}
+
+	case string:
+		if !w.convertible(t, flags) {
+			return node, nil
+		}
+		typed, _, err := parseTypedString(t, value, flags)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", strings.Join(keys, "."), err.Error())
+		}
+		w.changed = true
+
+		if _, registered := decoderFor(t); registered || t == urlType || t == regexpType {
+			if t == regexpType && reflect.PtrTo(t).Implements(textUnmarshalerType) {
+				// decoded natively since go1.21, validated only
+				return node, nil
+			}
+			w.values = append(w.values, typedValue{keys: keys, value: reflect.ValueOf(typed)})
+			return deferredNode{}, nil
+		}
+		if t == timeType && !w.times {
+			return typed.(time.Time).Format(time.RFC3339Nano), nil
+		}
+		return typed, nil
+	}
+
+	return node, nil
+}
+
+// convertible returns true if the strings of the t fields needs a conversion.
+func (w *typedWalker) convertible(t reflect.Type, flags []string) bool {
+	if _, registered := decoderFor(t); registered {
+		return true
+	}
+
+	switch {
+	case t == durationType:
+		return w.durations
+	case t == timeType:
+		return w.times || len(flagValue(flags, sffConfigLayout)) > 0
+	case t == urlType, t == regexpType:
+		return true
+	default:
+		return isIntegerKind(t.Kind()) && hasFlag(flags, sffConfigBytes)
+	}
+}
+
+// needed returns true if t contains a field to convert.
+func (w *typedWalker) needed(t reflect.Type, flags []string, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if w.convertible(t, flags) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if w.needed(sf.Type, splitFlags(sf.Tag.Get(w.tagKey)), seen) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return w.needed(t.Elem(), flags, seen)
+	}
+	return false
+}
+
+// appendKey returns a copy of keys with key appended.
+func appendKey(keys []string, key string) []string {
+	return append(keys[:len(keys):len(keys)], key)
+}
+
+// parseTypedString returns the typed value of s for the t field:
+// the registered decoders ones, byte sizes for the integers with the `bytes` flag,
+// durations, times with the `layout` flag (RFC3339 by default), IPs, URLs and regexps.
+// ok is false for the other types.
+func parseTypedString(t reflect.Type, s string, flags []string) (typed interface{}, ok bool, err error) {
+	if decoder, registered := decoderFor(t); registered {
+		typed, err = decoder(s)
+		return typed, true, err
+	}
+
+	s = strings.TrimSpace(s)
+
+	switch {
+	case isIntegerKind(t.Kind()) && hasFlag(flags, sffConfigBytes):
+		typed, err = parseByteSize(s)
+	case t == durationType:
+		if ns, nsErr := strconv.ParseInt(s, 10, 64); nsErr == nil {
+			// plain numbers are nanoseconds
+			typed = time.Duration(ns)
+		} else if typed, err = time.ParseDuration(s); err != nil {
+			err = fmt.Errorf("invalid duration '%s'", s)
+		}
+	case t == timeType:
+		layout := flagValue(flags, sffConfigLayout)
+		if len(layout) == 0 {
+			layout = time.RFC3339
+		}
+		if typed, err = time.Parse(layout, s); err != nil {
+			err = fmt.Errorf("invalid time '%s', must be like '%s'", s, layout)
+		}
+	case t == ipType:
+		if typed = net.ParseIP(s); typed.(net.IP) == nil {
+			err = fmt.Errorf("invalid IP address '%s'", s)
+		}
+	case t == urlType:
+		typed, err = url.Parse(s)
+	case t == regexpType:
+		typed, err = regexp.Compile(s)
+	default:
+		return nil, false, nil
+	}
+	return typed, true, err
+}
+
+// unmarshalTagValue decodes the env var or default value into fv,
+// parsing the typed strings (see parseTypedString), then using the encoding.TextUnmarshaler
+// or the json.Unmarshaler implementation if any, yaml otherwise.
+func unmarshalTagValue(value string, fv reflect.Value, flags []string) error {
+	t := indirectType(fv.Type())
+
+	if hasFlag(flags, sffConfigBase64) {
+		decoded, err := decodeBase64(value)
+		if err != nil {
+			return err
+		}
+		switch {
+		case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+			return setIndirect(fv, reflect.ValueOf(decoded))
+		case t.Kind() == reflect.String && !reflect.PtrTo(t).Implements(textUnmarshalerType):
+			return setIndirect(fv, reflect.ValueOf(string(decoded)))
+		}
+		value = string(decoded)
+	}
+
+	if hasFlag(flags, sffConfigJSON) {
+		return unmarshalStrictJSON(value, fv)
+	}
+
+	typed, ok, err := parseTypedString(t, value, flags)
+	switch {
+	case err != nil:
+		return err
+	case ok:
+		return setIndirect(fv, reflect.ValueOf(typed))
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return allocIndirect(fv).Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	case reflect.PtrTo(t).Implements(jsonUnmarshalerType):
+		// raw JSON values (eg.: `{"id": 1}`), or plain strings
+		data := []byte(value)
+		if !json.Valid(data) {
+			data, _ = json.Marshal(value)
+		}
+		return allocIndirect(fv).Addr().Interface().(json.Unmarshaler).UnmarshalJSON(data)
+	default:
+		return yaml.Unmarshal([]byte(value), fv.Addr().Interface())
+	}
+}
+
+// allocIndirect returns the value pointed by fv, allocating the nil pointers.
+func allocIndirect(fv reflect.Value) reflect.Value {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	return fv
+}
+
+// unmarshalStrictJSON decodes the JSON value into fv,
+// unknown fields and trailing data are rejected.
+func unmarshalStrictJSON(value string, fv reflect.Value) error {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(fv.Addr().Interface()); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid JSON value, unexpected data after the top-level value")
+	}
+	return nil
+}
+
+// base64Encodings are the accepted encodings, padded or not.
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+}
+
+// decodeBase64 decodes the standard or URL base64 value,
+// line breaks are ignored (eg.: `base64 -w 76` encoded certificates).
+func decodeBase64(value string) ([]byte, error) {
+	value = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, value)
+
+	for _, encoding := range base64Encodings {
+		if decoded, err := encoding.DecodeString(value); err == nil {
+			return decoded, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid base64 value")
+}
+
+// byteUnits are the byte size multipliers, decimal (SI) and binary (IEC).
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12, "pb": 1e15,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40, "pib": 1 << 50,
+}
+
+// parseByteSize returns the bytes of the human-readable size,
+// eg.: `10MB` (10 * 1000^2) or `512KiB` (512 * 1024).
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+
+	number, err := strconv.ParseFloat(s[:i], 64)
+	multiplier, found := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if err != nil || !found {
+		return 0, fmt.Errorf("invalid byte size '%s', must be like '10MB' or '512KiB'", s)
+	}
+	return int64(number * multiplier), nil
+}
+
+// isIntegerKind returns true for the signed and unsigned integer kinds.
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// Decoders ------------------------------------------------------------------------------------------------------------
+
+// DecoderFunc decodes a config string into a value of the registered type.
+type DecoderFunc func(value string) (interface{}, error)
+
+var decoders = struct {
+	sync.RWMutex
+	m map[reflect.Type]DecoderFunc
+}{m: make(map[reflect.Type]DecoderFunc)}
+
+// RegisterDecoder register the decoder of the t strings (eg.: decimal.Decimal, uuid.UUID),
+// used for the `env` and `default` values and the config files strings,
+// before any other decoding. Pointers to t are decoded too.
+func RegisterDecoder(t reflect.Type, decoder DecoderFunc) {
+	decoders.Lock()
+	defer decoders.Unlock()
+
+	if decoder == nil {
+		delete(decoders.m, indirectType(t))
+		return
+	}
+	decoders.m[indirectType(t)] = decoder
+}
+
+// decoderFor returns the decoder registered for t, if any.
+func decoderFor(t reflect.Type) (DecoderFunc, bool) {
+	decoders.RLock()
+	defer decoders.RUnlock()
+
+	decoder, found := decoders.m[t]
+	return decoder, found
+}
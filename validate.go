@@ -0,0 +1,193 @@
+package swap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config files validation ---------------------------------------------------------------------------------------------
+
+// FileViolation is a config files issue found by ValidateFiles.
+type FileViolation struct {
+	// File is the config file, or the searched name for the missing files.
+	File string
+
+	// Key is the dot separated key path (eg.: `db.host`), empty for file issues.
+	Key string
+
+	Message string
+}
+
+func (fv FileViolation) String() string {
+	if len(fv.Key) == 0 {
+		return fmt.Sprintf("%s: %s", fv.File, fv.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", fv.File, fv.Key, fv.Message)
+}
+
+// ValidateFiles checks the config files of every name (with their environment specific ones,
+// if env is not nil) against the JSON schema of the config struct (see JSONSchema), eg.: before deploy.
+// Missing files, unknown keys and required keys not set by the files are reported,
+// the required fields with an `env` flag are not, since the env var can provide them.
+func ValidateFiles(fsys FileSystem, schema []byte, env *Environment, names ...string) ([]FileViolation, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err.Error())
+	}
+
+	p := newParser()
+	if fsys != nil {
+		p.fs = fsys
+	}
+
+	var violations []FileViolation
+	for _, name := range names {
+		files, err := p.appendEnvFiles(env, []string{name})
+		if errors.Is(err, errNoConfigFile) {
+			violations = append(violations, FileViolation{File: name, Message: "missing config file"})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		merged := make(map[string]interface{})
+		for _, file := range files {
+			tree, err := p.readTree(file)
+			if err != nil {
+				violations = append(violations, FileViolation{File: file, Message: err.Error()})
+				continue
+			}
+			for _, key := range unknownKeys("", tree, root) {
+				violations = append(violations, FileViolation{File: file, Key: key, Message: "unknown key"})
+			}
+			if m, isMap := lowerKeys(tree).(map[string]interface{}); isMap {
+				mergeTrees(merged, m)
+			}
+		}
+
+		file := files[len(files)-1]
+		for _, key := range missingKeys("", merged, root) {
+			violations = append(violations, FileViolation{File: file, Key: key, Message: "required"})
+		}
+	}
+	return violations, nil
+}
+
+// readTree returns the tree of the file, with the references and env vars resolved.
+func (p *parser) readTree(file string) (interface{}, error) {
+	data, err := p.fs.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = p.resolveRefs(file, data); err != nil {
+		return nil, err
+	}
+	if data, err = p.expandEnv(file, data, nil); err != nil {
+		return nil, err
+	}
+	return decodeTree(file, data)
+}
+
+// unknownKeys returns the node keys not described by the schema,
+// keys are matched case-insensitively as by the parser.
+func unknownKeys(path string, node interface{}, schema map[string]interface{}) (keys []string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, key := range sortedKeys(n) {
+			keyPath := joinFieldPath(path, key)
+			if property, found := lookupProperty(properties, key); found {
+				keys = append(keys, unknownKeys(keyPath, n[key], property)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case map[string]interface{}:
+				keys = append(keys, unknownKeys(keyPath, n[key], additional)...)
+			case bool:
+				if !additional {
+					keys = append(keys, keyPath)
+				}
+			}
+		}
+	case []interface{}:
+		if items, isSchema := schema["items"].(map[string]interface{}); isSchema {
+			for i, item := range n {
+				keys = append(keys, unknownKeys(joinFieldPath(path, strconv.Itoa(i)), item, items)...)
+			}
+		}
+	}
+	return keys
+}
+
+// missingKeys returns the required keys missing in the node, recursively,
+// the node keys must be lowercase.
+func missingKeys(path string, node interface{}, schema map[string]interface{}) (keys []string) {
+	present, _ := node.(map[string]interface{})
+
+	required, _ := schema["required"].([]interface{})
+	for _, key := range required {
+		if value, found := present[strings.ToLower(fmt.Sprint(key))]; !found || value == nil {
+			keys = append(keys, joinFieldPath(path, fmt.Sprint(key)))
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	for _, key := range sortedKeys(properties) {
+		property, _ := properties[key].(map[string]interface{})
+		if property["type"] == "object" {
+			keys = append(keys, missingKeys(joinFieldPath(path, key), present[strings.ToLower(key)], property)...)
+		}
+	}
+	return keys
+}
+
+// lowerKeys returns the tree with lowercase map keys, recursively.
+func lowerKeys(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		lowered := make(map[string]interface{}, len(n))
+		for key, value := range n {
+			value = lowerKeys(value)
+			if existing, isMap := lowered[strings.ToLower(key)].(map[string]interface{}); isMap {
+				if m, isMap := value.(map[string]interface{}); isMap {
+					mergeTrees(existing, m)
+					continue
+				}
+			}
+			lowered[strings.ToLower(key)] = value
+		}
+		return lowered
+	case []interface{}:
+		lowered := make([]interface{}, len(n))
+		for i, item := range n {
+			lowered[i] = lowerKeys(item)
+		}
+		return lowered
+	}
+	return node
+}
+
+// lookupProperty returns the schema property of the key, matched case-insensitively.
+func lookupProperty(properties map[string]interface{}, key string) (map[string]interface{}, bool) {
+	for name, property := range properties {
+		if strings.EqualFold(name, key) {
+			schema, _ := property.(map[string]interface{})
+			return schema, true
+		}
+	}
+	return nil, false
+}
+
+// sortedKeys returns the map keys in lexical order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
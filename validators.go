@@ -0,0 +1,88 @@
+package swap
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Registered validators -----------------------------------------------------------------------------------------------
+
+// ValidatorFunc is the registered validator func type,
+// v is a value of the registered type.
+type ValidatorFunc func(v interface{}) error
+
+// RegisterValidator register a validator func for a specific type and
+// return the builder itself.
+// Every value of that type in the toolbox (tools, their configs and nested fields)
+// is validated at the end of Build, so domain-specific invariants
+// (port ranges, mutually exclusive fields...) are enforced centrally.
+// Configs parsed with the builder Parse method are validated too.
+func (s *Builder) RegisterValidator(t reflect.Type, validator ValidatorFunc) *Builder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.typeValidators == nil {
+		s.typeValidators = make(map[reflect.Type]ValidatorFunc)
+	}
+	s.typeValidators[t] = validator
+	return s
+}
+
+// validate run the registered validators on v and its exported fields,
+// recursively, skipping the degraded fields.
+func (s *Builder) validate(path string, v reflect.Value) error {
+	if len(s.typeValidators) == 0 {
+		return nil
+	}
+	return s.validateValue(path, v, map[uintptr]bool{})
+}
+
+// validateValue validate v, visited holds the pointers
+// already validated to stop cyclic references.
+func (s *Builder) validateValue(path string, v reflect.Value, visited map[uintptr]bool) error {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+
+	if validator, found := s.typeValidators[v.Type()]; found {
+		if err := validator(v.Interface()); err != nil {
+			return fieldError(path, "validator", err)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return nil
+		}
+		visited[v.Pointer()] = true
+		return s.validateValue(path, v.Elem(), visited)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fieldPath := joinFieldPath(path, v.Type().Field(i).Name)
+			if !s.Available(fieldPath) {
+				continue
+			}
+			if err := s.validateValue(fieldPath, v.Field(i), visited); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := s.validateValue(fmt.Sprintf("%s[%d]", path, i), v.Index(i), visited); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if err := s.validateValue(fmt.Sprintf("%s[%v]", path, key.Interface()), v.MapIndex(key), visited); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
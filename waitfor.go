@@ -0,0 +1,98 @@
+package swap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Wait for external services ------------------------------------------------------------------------------------------
+
+const (
+	// defaultWaitForTimeout is the `waitfor` tag default timeout.
+	defaultWaitForTimeout = 30 * time.Second
+
+	// waitForInterval is the time between two attempts.
+	waitForInterval = 250 * time.Millisecond
+)
+
+// waitFor blocks until all the endpoints are reachable,
+// the timeout (30s if zero) expires or ctx is done.
+// Supported endpoints are `tcp://host:port` and `http(s)://host/path`,
+// http endpoints are reachable if they respond without a 5xx status code.
+func waitFor(ctx context.Context, endpoints []string, timeout time.Duration) error {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultWaitForTimeout
+	}
+
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		switch u.Scheme {
+		case "tcp", "http", "https":
+		default:
+			return fmt.Errorf("unsupported scheme for '%s', must be one of: tcp, http, https", endpoint)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	probeCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	for _, endpoint := range endpoints {
+		for {
+			err := probe(probeCtx, endpoint)
+			if err == nil {
+				break
+			}
+			if time.Until(deadline) < waitForInterval {
+				return fmt.Errorf("timeout waiting for '%s': %s", endpoint, err.Error())
+			}
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("waiting for '%s': %w", endpoint, ctx.Err())
+			case <-time.After(waitForInterval):
+			}
+		}
+	}
+	return nil
+}
+
+// probe check once if the endpoint is reachable,
+// it gives up as soon as ctx is done.
+func probe(ctx context.Context, endpoint string) error {
+	u, _ := url.Parse(endpoint)
+
+	switch u.Scheme {
+	case "tcp":
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", u.Host)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	default:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return errors.New(resp.Status)
+		}
+	}
+	return nil
+}
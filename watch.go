@@ -0,0 +1,162 @@
+package swap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Polling watcher -----------------------------------------------------------------------------------------------------
+
+// ErrNotModified is returned by the ConditionalFileSystem
+// when the file still has the given version.
+var ErrNotModified = errors.New("not modified")
+
+// ConditionalFileSystem is a FileSystem which can read files conditionally,
+// eg.: HTTP backed ones, sending the version as `If-None-Match` (ETag)
+// or `If-Modified-Since` (Last-Modified), so that unchanged files are not downloaded.
+type ConditionalFileSystem interface {
+	FileSystem
+
+	// ReadFileIfModified returns the content and the version of the named file,
+	// or ErrNotModified if its version is still version (empty to read it anyway).
+	ReadFileIfModified(name, version string) (data []byte, nextVersion string, err error)
+}
+
+// PollingWatcher polls the files of a FileSystem, detecting changes
+// where no native notification exists (eg.: remote FileSystems).
+// Files versions are (in order of preference): the ConditionalFileSystem versions,
+// the StatFileSystem size and modification time, or the content hash.
+type PollingWatcher struct {
+	FileSystem FileSystem
+
+	// Paths are the watched files or directories (their files, non-recursively).
+	Paths []string
+
+	// Interval between polls, 30 seconds by default.
+	Interval time.Duration
+}
+
+// NewPollingWatcher returns the PollingWatcher of the paths in fsys.
+func NewPollingWatcher(fsys FileSystem, interval time.Duration, paths ...string) *PollingWatcher {
+	return &PollingWatcher{FileSystem: fsys, Paths: paths, Interval: interval}
+}
+
+// Watch block until ctx is done, calling onChange every time
+// a watched file is added, removed or modified (eg.: to Build or Reload the tools).
+// Files that can't be read are retried at the next poll.
+func (pw *PollingWatcher) Watch(ctx context.Context, onChange func()) error {
+	interval := pw.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	versions := pw.poll(nil)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		next := pw.poll(versions)
+		if versionsChanged(versions, next) {
+			onChange()
+		}
+		versions = next
+	}
+}
+
+// poll returns the versions of the watched files, by path,
+// the previous versions are sent to the ConditionalFileSystem.
+func (pw *PollingWatcher) poll(previous map[string]string) map[string]string {
+	versions := make(map[string]string)
+	for _, file := range pw.files() {
+		version, err := pw.version(file, previous[file])
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			// unknown, keep the previous one
+			if version, found := previous[file]; found {
+				versions[file] = version
+			}
+			continue
+		}
+		versions[file] = version
+	}
+	return versions
+}
+
+// files returns the watched files, with the directories ones.
+func (pw *PollingWatcher) files() (files []string) {
+	for _, path := range pw.Paths {
+		entries, err := pw.FileSystem.ReadDir(path)
+		if err != nil {
+			files = append(files, path)
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// version returns the current version of the file.
+func (pw *PollingWatcher) version(file, previous string) (string, error) {
+	switch fsys := pw.FileSystem.(type) {
+	case ConditionalFileSystem:
+		data, version, err := fsys.ReadFileIfModified(file, previous)
+		if errors.Is(err, ErrNotModified) {
+			return previous, nil
+		}
+		if err == nil && len(version) == 0 {
+			// no version available, eg.: no ETag
+			version = contentVersion(data)
+		}
+		return version, err
+	case StatFileSystem:
+		info, err := fsys.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()), nil
+	default:
+		data, err := fsys.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return contentVersion(data), nil
+	}
+}
+
+// contentVersion returns the version of the file content, its hash.
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// versionsChanged returns true if the versions differ.
+func versionsChanged(versions, next map[string]string) bool {
+	if len(versions) != len(next) {
+		return true
+	}
+	for file, version := range versions {
+		if nextVersion, found := next[file]; !found || nextVersion != version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package swap
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Weak typing ---------------------------------------------------------------------------------------------------------
+
+// WeakTyping is the opt-in normalization of common value spellings,
+// useful for configs authored by non-developers.
+// Quoted and unquoted "yes"/"no"/"on"/"off"/"y"/"n"/"true"/"false" values
+// are converted for bool fields, numbers in strings for numeric fields.
+type WeakTyping struct {
+	// DecimalComma parse decimal commas (eg.: "1,5") for float fields.
+	DecimalComma bool
+
+	// Report, if not nil, receive every applied normalization.
+	Report func(n Normalization)
+}
+
+// Normalization is a value normalized by WeakTyping.
+type Normalization struct {
+	File string
+
+	// Key is the dot separated key path (eg.: `db.port`).
+	Key  string
+	From interface{}
+	To   interface{}
+}
+
+var weakBools = map[string]bool{
+	"yes": true, "y": true, "on": true, "true": true, "1": true,
+	"no": false, "n": false, "off": false, "false": false, "0": false,
+}
+
+// normalize returns the file data with the values normalized
+// to the config types, data is untouched if nothing changed.
+func (p *parser) normalize(file string, data []byte, config interface{}) ([]byte, error) {
+	tree, err := decodeTree(file, data)
+	if err != nil {
+		return nil, err
+	}
+
+	n := normalizer{wt: p.weakTyping, file: file}
+	tree = n.walk("", tree, reflect.TypeOf(config))
+	if !n.changed {
+		return data, nil
+	}
+	return encodeTree(file, tree)
+}
+
+// normalizer walks a document tree along with the config type.
+type normalizer struct {
+	wt      *WeakTyping
+	file    string
+	changed bool
+}
+
+func (n *normalizer) walk(key string, node interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			switch t.Kind() {
+			case reflect.Struct:
+				if sf, found := matchField(t, k); found {
+					value[k] = n.walk(joinFieldPath(key, k), v, sf.Type)
+				}
+			case reflect.Map:
+				value[k] = n.walk(joinFieldPath(key, k), v, t.Elem())
+			}
+		}
+		return value
+
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, v := range value {
+				value[i] = n.walk(joinFieldPath(key, strconv.Itoa(i)), v, t.Elem())
+			}
+		}
+		return value
+	}
+
+	if normalized, ok := n.convert(node, t); ok {
+		n.changed = true
+		if n.wt.Report != nil {
+			n.wt.Report(Normalization{File: n.file, Key: key, From: node, To: normalized})
+		}
+		return normalized
+	}
+	return node
+}
+
+// convert returns the value converted to the t kind, if needed.
+func (n *normalizer) convert(node interface{}, t reflect.Type) (interface{}, bool) {
+	s := strings.ToLower(strings.TrimSpace(toString(node)))
+	if len(s) == 0 {
+		return nil, false
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if _, isBool := node.(bool); isBool {
+			return nil, false
+		}
+		b, found := weakBools[s]
+		return b, found
+
+	case reflect.Float32, reflect.Float64:
+		if _, isString := node.(string); !isString {
+			return nil, false
+		}
+		if n.wt.DecimalComma && strings.Count(s, ",") == 1 && !strings.Contains(s, ".") {
+			s = strings.Replace(s, ",", ".", 1)
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, isString := node.(string); !isString || t.PkgPath() == "time" {
+			return nil, false
+		}
+		i, err := strconv.ParseInt(s, 10, 64)
+		return i, err == nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if _, isString := node.(string); !isString {
+			return nil, false
+		}
+		u, err := strconv.ParseUint(s, 10, 64)
+		return u, err == nil
+	}
+
+	return nil, false
+}
+
+// matchField returns the struct field for the document key,
+// matching the yaml, json and toml tags or the field name case-insensitively.
+func matchField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		for _, tagKey := range []string{"yaml", "json", "toml"} {
+			if name := strings.Split(sf.Tag.Get(tagKey), ",")[0]; name == key {
+				return sf, true
+			}
+		}
+		if strings.EqualFold(sf.Name, key) {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// toString returns the scalar string representation.
+func toString(node interface{}) string {
+	switch value := node.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case int:
+		return strconv.Itoa(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}